@@ -0,0 +1,420 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/hooks"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/must"
+)
+
+// agentRequestTimeout bounds every request an agent sends to the
+// coordinator
+const agentRequestTimeout = time.Minute
+
+var (
+	// errCoordinator is returned when the coordinator refuses a request
+	errCoordinator = errors.New("coordinator refused the request")
+	// errUnreportedBackup is returned for a deletion the coordinator asked
+	// for of a backup the agent did not report
+	errUnreportedBackup = errors.New("backup was not reported to the coordinator")
+	// errPinnedBackup is returned for a deletion the coordinator asked for
+	// of a backup pinned on this host by pins or a hold marker
+	errPinnedBackup = errors.New("backup is pinned")
+	// errAgentBackend is returned by agent for backends that apply retention
+	// themselves
+	errAgentBackend = errors.New("backend cannot be coordinated")
+	// errCoordinatorURL is returned for a --coordinator URL the agent will
+	// not send its token to
+	errCoordinatorURL = errors.New("unsupported coordinator URL")
+)
+
+var (
+	agentCoordinator         string
+	agentInsecureCoordinator bool
+	agentName                string
+	agentInterval            time.Duration
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Prune the backups of this host as planned by a central coordinator",
+	Long: `Report the backups in the configured directories to a coordinator, an
+instance of the serve command, and delete those it plans to delete, so the
+retention policy of a whole fleet is managed in one place.
+
+The coordinator applies its own retention settings: the named policy with
+the agent's name if its config has one, otherwise its active policy. The
+agent's config only says where its backups are and how to list them, and
+its api.token must match the coordinator's. The agent deletes only backups
+it reported, running the pre_delete hook and archiving as configured, and
+reports the outcome back. The run is a dry run if either config sets
+dry_run. The agent sends its api.token with every request, so the
+coordinator must be reached over https unless --insecure-coordinator is
+given.
+
+With --interval the agent repeats this at that interval until SIGINT or
+SIGTERM, logging failed rounds; otherwise it runs once and exits with the
+status prune would.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		coordinator, err := coordinatorURL(agentCoordinator, agentInsecureCoordinator)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if cfg.API.Token == "" {
+			return errNoToken
+		}
+
+		if cfg.Backend == config.BackendRestic || cfg.Backend == config.BackendBorg {
+			return fmt.Errorf("%w: %s", errAgentBackend, cfg.Backend)
+		}
+
+		name := agentName
+		if name == "" {
+			name, err = os.Hostname()
+			if err != nil {
+				return fmt.Errorf("failed to determine agent name: %w", err)
+			}
+		}
+
+		log, err := logging.New(cfg.LogLevel, loggerOptions(cfg)...)
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer log.SyncQuietly()
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		a := &agent{
+			log:         log,
+			cfg:         cfg,
+			name:        name,
+			coordinator: coordinator,
+			client:      &http.Client{Timeout: agentRequestTimeout},
+		}
+
+		if agentInterval <= 0 {
+			rep := newReporter(cmd.OutOrStdout(), false, false)
+			return a.run(ctx, rep)
+		}
+
+		for {
+			rep := newReporter(cmd.OutOrStdout(), false, false)
+			if err := a.run(ctx, rep); err != nil {
+				log.Error("agent round failed", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(agentInterval):
+			}
+		}
+	},
+}
+
+// coordinatorURL checks a --coordinator URL and returns it without a
+// trailing slash. The agent sends api.token with every request, which
+// anyone on the network path could read and replay over plain http, so
+// http is only accepted when insecure is set.
+func coordinatorURL(rawURL string, insecure bool) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid coordinator URL: %w", err)
+	}
+
+	switch {
+	case u.Host == "":
+		return "", fmt.Errorf("%w: %q has no host", errCoordinatorURL, rawURL)
+	case u.Scheme == "http" && !insecure:
+		return "", fmt.Errorf("%w: http would send api.token in the clear "+
+			"(use an https:// URL or --insecure-coordinator)", errCoordinatorURL)
+	case u.Scheme != "https" && u.Scheme != "http":
+		return "", fmt.Errorf("%w: %s (use an https:// URL)", errCoordinatorURL, u.Scheme)
+	}
+
+	return strings.TrimSuffix(rawURL, "/"), nil
+}
+
+// agent reports the backups of this host to a coordinator and deletes the
+// ones it plans to delete
+type agent struct {
+	log         *logging.Logger
+	cfg         *config.Config
+	name        string
+	coordinator string
+	client      *http.Client
+}
+
+// agentEntry is a backup an agent reported, with the backend it was listed
+// by
+type agentEntry struct {
+	directory string
+	store     backend.Backend
+	file      file.Info
+}
+
+// run makes a single round: it lists the backups, asks the coordinator for
+// a plan, carries it out and reports the outcome to the coordinator. Once
+// ctx is done no further backup is deleted.
+func (a *agent) run(ctx context.Context, rep *reporter) error {
+	started := time.Now()
+
+	inventory, entries, err := a.inventory(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeEntries(entries)
+
+	var plan agentPlan
+
+	err = a.post(ctx, "inventory", inventory, &plan)
+	if err != nil {
+		return err
+	}
+
+	cfg := *a.cfg
+	cfg.DryRun = cfg.DryRun || plan.DryRun
+
+	summary := notify.Summary{
+		RunID:     rep.runID,
+		Directory: strings.Join(cfg.Directories, ", "),
+		DryRun:    cfg.DryRun,
+		Matched:   len(entries),
+	}
+
+	log := a.log.With(zap.String("run_id", rep.runID))
+	hookRunner := hooks.NewRunner(hooks.WithLogger(log))
+
+	var deleteErrs []error
+
+	for _, d := range plan.Delete {
+		if ctx.Err() != nil {
+			break
+		}
+
+		entry, ok := entries[d.Path]
+
+		var refused error
+
+		switch {
+		case !ok:
+			refused = fmt.Errorf("%w: %s", errUnreportedBackup, d.Path)
+		case entry.file.Pinned:
+			// Pins are local, the coordinator must not override them
+			refused = fmt.Errorf("%w: %s", errPinnedBackup, d.Path)
+		}
+
+		if refused != nil {
+			deleteErrs = append(deleteErrs, refused)
+			recordFailure(&summary, refused)
+			rep.fail(d.Path, refused)
+
+			continue
+		}
+
+		decision := retention.Decision{
+			File:   entry.file,
+			Delete: true,
+			Reason: d.Reason,
+			Tier:   d.Tier,
+		}
+
+		err := deleteFile(ctx, log, &cfg, nil, nil, entry.store, entry.directory, hookRunner, rep,
+			decision)
+		if err != nil {
+			deleteErrs = append(deleteErrs, err)
+			recordFailure(&summary, err)
+
+			continue
+		}
+
+		summary.Deleted++
+		summary.ReclaimedBytes += entry.file.Size
+
+		if _, ok := archiveDestination(&cfg, decision); ok {
+			summary.Archived++
+		}
+	}
+
+	rep.footer(summary)
+
+	err = deletionError(summary.Deleted, deleteErrs)
+
+	reportErr := a.post(ctx, "report",
+		newRunSummary(summary, rep.files, started, err), &map[string]string{})
+
+	return errors.Join(err, reportErr)
+}
+
+// inventory lists the backups in every configured directory
+func (a *agent) inventory(ctx context.Context) (agentInventory, map[string]agentEntry, error) {
+	directories, err := expandDirectories(a.cfg)
+	if err != nil {
+		return agentInventory{}, nil, err
+	}
+
+	inventory := agentInventory{Directories: make([]agentDirectory, 0, len(directories))}
+	entries := map[string]agentEntry{}
+
+	fail := func(err error) (agentInventory, map[string]agentEntry, error) {
+		closeEntries(entries)
+		return agentInventory{}, nil, err
+	}
+
+	for _, directory := range directories {
+		store, err := backend.New(a.cfg, directory, a.log)
+		if err != nil {
+			return fail(fmt.Errorf("failed to initialize backend: %w", err))
+		}
+
+		files, err := store.ListFiles(ctx)
+		if err != nil || len(files) == 0 {
+			// Only backends with backups to delete are kept open
+			_ = backend.Close(store)
+		}
+
+		if err != nil {
+			return fail(fmt.Errorf("failed to list backups in %s: %w", directory, err))
+		}
+
+		dir := agentDirectory{Directory: directory, Backups: make([]agentBackup, len(files))}
+
+		for i, f := range files {
+			dir.Backups[i] = newAgentBackup(f)
+			entries[f.Path] = agentEntry{directory: directory, store: store, file: f}
+		}
+
+		inventory.Directories = append(inventory.Directories, dir)
+	}
+
+	return inventory, entries, nil
+}
+
+// closeEntries closes the backends the entries were listed by
+func closeEntries(entries map[string]agentEntry) {
+	closed := map[backend.Backend]bool{}
+
+	for _, entry := range entries {
+		if !closed[entry.store] {
+			closed[entry.store] = true
+			_ = backend.Close(entry.store)
+		}
+	}
+}
+
+// post sends in as JSON to the endpoint of this agent on the coordinator
+// and decodes the response into out
+func (a *agent) post(ctx context.Context, endpoint string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", endpoint, err)
+	}
+
+	target := a.coordinator + "/v1/agents/" + url.PathEscape(a.name) + "/" + endpoint
+
+	req, err := http.NewRequestWithContext(
+		context.WithoutCancel(ctx), http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", endpoint, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.cfg.API.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", endpoint, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+
+		return fmt.Errorf("%w: %s: %s %s", errCoordinator, endpoint, resp.Status, apiErr.Error)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to read %s response: %w", endpoint, err)
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+
+	agentCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	agentCmd.Flags().
+		StringVar(&agentCoordinator, "coordinator", "",
+			"URL of the coordinator, such as https://backups.example.com:8080")
+	agentCmd.Flags().
+		BoolVar(&agentInsecureCoordinator, "insecure-coordinator", false,
+			"Allow an http:// coordinator, sending api.token unencrypted")
+	agentCmd.Flags().
+		StringVar(&agentName, "name", "", "Name to report as (default is the host name)")
+	agentCmd.Flags().
+		DurationVar(&agentInterval, "interval", 0,
+			"Repeat every interval instead of running once")
+
+	must.Must(agentCmd.MarkFlagRequired("coordinator"))
+}
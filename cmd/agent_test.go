@@ -0,0 +1,267 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// agentBackups creates three daily backups in a new directory and returns
+// it with the backup names, newest first
+func agentBackups(t *testing.T) (string, []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	names := []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-13-12-00.tar.gz",
+	}
+
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	return dir, names
+}
+
+// newTestAgent returns an agent named name pruning dir for the coordinator
+// at url
+func newTestAgent(name, url, dir string) *agent {
+	return &agent{
+		log: logging.NewDefault(),
+		cfg: &config.Config{
+			FilePattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz",
+			Directories: []string{dir},
+			API:         config.APIConfig{Token: "test-token"},
+		},
+		name:        name,
+		coordinator: url,
+		client:      http.DefaultClient,
+	}
+}
+
+func TestAgent(t *testing.T) {
+	coordinator := &config.Config{
+		Retention:      config.RetentionPolicy{Daily: 2},
+		FilePattern:    "*",
+		Directories:    []string{t.TempDir()},
+		RequireMinimum: config.DefaultRequireMinimum,
+		Policies: map[string]config.NamedPolicy{
+			"db1": {Retention: config.RetentionPolicy{Daily: 1}},
+		},
+		API: config.APIConfig{Token: "test-token"},
+	}
+	require.NoError(t, coordinator.Validate())
+
+	srv := httptest.NewServer(newAPIServer(coordinator, logging.NewDefault()).handler())
+	t.Cleanup(srv.Close)
+
+	webDir, names := agentBackups(t)
+	dbDir, _ := agentBackups(t)
+
+	var out bytes.Buffer
+
+	// web uses the active policy, db1 the named policy of the same name
+	require.NoError(t, newTestAgent("web", srv.URL, webDir).run(t.Context(),
+		newReporter(&out, false, false)))
+	require.NoError(t, newTestAgent("db1", srv.URL, dbDir).run(t.Context(),
+		newReporter(&out, false, false)))
+
+	require.FileExists(t, filepath.Join(webDir, names[1]))
+	require.NoFileExists(t, filepath.Join(webDir, names[2]))
+	require.FileExists(t, filepath.Join(dbDir, names[0]))
+	require.NoFileExists(t, filepath.Join(dbDir, names[1]))
+	require.NoFileExists(t, filepath.Join(dbDir, names[2]))
+	require.Contains(t, out.String(), names[2])
+
+	var agents []agentStatus
+	require.Equal(t, http.StatusOK,
+		apiRequest(t, srv.URL, http.MethodGet, "/v1/agents", "", &agents))
+	require.Len(t, agents, 2)
+
+	require.Equal(t, "db1", agents[0].Name)
+	require.Equal(t, 3, agents[0].Backups)
+	require.Equal(t, 2, agents[0].Planned)
+	require.NotNil(t, agents[0].LastReport)
+	require.Equal(t, 2, agents[0].LastReport.Deleted)
+
+	require.Equal(t, "web", agents[1].Name)
+	require.Equal(t, 1, agents[1].LastReport.Deleted)
+}
+
+func TestAgentDryRun(t *testing.T) {
+	coordinator := &config.Config{
+		Retention:      config.RetentionPolicy{Daily: 1},
+		FilePattern:    "*",
+		RequireMinimum: config.DefaultRequireMinimum,
+		DryRun:         true,
+		API:            config.APIConfig{Token: "test-token"},
+	}
+
+	srv := httptest.NewServer(newAPIServer(coordinator, logging.NewDefault()).handler())
+	t.Cleanup(srv.Close)
+
+	dir, names := agentBackups(t)
+
+	var out bytes.Buffer
+	require.NoError(t, newTestAgent("web", srv.URL, dir).run(t.Context(),
+		newReporter(&out, false, false)))
+
+	for _, name := range names {
+		require.FileExists(t, filepath.Join(dir, name))
+	}
+
+	require.Contains(t, out.String(), "would delete")
+}
+
+func TestAgentRefusesUnreportedBackups(t *testing.T) {
+	dir, names := agentBackups(t)
+	outside := filepath.Join(t.TempDir(), "secret.tar.gz")
+	require.NoError(t, os.WriteFile(outside, nil, 0o600))
+
+	var report runSummary
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Base(r.URL.Path) == "report" {
+			_ = json.NewDecoder(r.Body).Decode(&report)
+			_, _ = w.Write([]byte("{}"))
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(agentPlan{Delete: []agentDeletion{
+			{Path: outside, Reason: "expired"},
+			{Path: filepath.Join(dir, names[2]), Reason: "expired"},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+
+	err := newTestAgent("web", srv.URL, dir).run(t.Context(),
+		newReporter(&bytes.Buffer{}, true, false))
+	require.ErrorIs(t, err, errUnreportedBackup)
+	require.ErrorIs(t, err, errPartialFailure)
+
+	require.FileExists(t, outside)
+	require.NoFileExists(t, filepath.Join(dir, names[2]))
+	require.Equal(t, 1, report.Deleted)
+	require.Equal(t, exitCodePartialFailure, report.ExitCode)
+}
+
+func TestAgentRefusesPinnedBackups(t *testing.T) {
+	dir, names := agentBackups(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, names[1]+file.HoldSuffix), nil, 0o600))
+
+	var report runSummary
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Base(r.URL.Path) == "report" {
+			_ = json.NewDecoder(r.Body).Decode(&report)
+			_, _ = w.Write([]byte("{}"))
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(agentPlan{Delete: []agentDeletion{
+			{Path: filepath.Join(dir, names[1]), Reason: "expired"},
+			{Path: filepath.Join(dir, names[2]), Reason: "expired"},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+
+	err := newTestAgent("web", srv.URL, dir).run(t.Context(),
+		newReporter(&bytes.Buffer{}, true, false))
+	require.ErrorIs(t, err, errPinnedBackup)
+	require.ErrorIs(t, err, errPartialFailure)
+
+	require.FileExists(t, filepath.Join(dir, names[1]))
+	require.NoFileExists(t, filepath.Join(dir, names[2]))
+	require.Equal(t, 1, report.Deleted)
+	require.Equal(t, exitCodePartialFailure, report.ExitCode)
+}
+
+func TestAgentCoordinatorRefuses(t *testing.T) {
+	dir, _ := agentBackups(t)
+
+	srv := httptest.NewServer(newAPIServer(&config.Config{
+		API: config.APIConfig{Token: "other-token"},
+	}, logging.NewDefault()).handler())
+	t.Cleanup(srv.Close)
+
+	err := newTestAgent("web", srv.URL, dir).run(t.Context(),
+		newReporter(&bytes.Buffer{}, true, false))
+	require.ErrorIs(t, err, errCoordinator)
+	require.ErrorContains(t, err, "401")
+}
+
+func TestCoordinatorURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		insecure bool
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "https",
+			url:  "https://backups.example.com:8080/",
+			want: "https://backups.example.com:8080",
+		},
+		{name: "http refused", url: "http://backups.example.com:8080", wantErr: true},
+		{
+			name:     "http allowed when insecure",
+			url:      "http://127.0.0.1:8080",
+			insecure: true,
+			want:     "http://127.0.0.1:8080",
+		},
+		{name: "other scheme", url: "ftp://backups.example.com", insecure: true, wantErr: true},
+		{name: "no host", url: "backups.example.com:8080", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coordinatorURL(tt.url, tt.insecure)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errCoordinatorURL)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+)
+
+// Exit codes of the audit command
+const (
+	// exitCodeOverRetained means backups are kept that the policy would
+	// delete
+	exitCodeOverRetained = 1
+	// exitCodeUnderRetained means periods the policy covers have no backup
+	exitCodeUnderRetained = 2
+	// exitCodeAuditFailed means the audit could not be completed
+	exitCodeAuditFailed = 3
+)
+
+// Outcomes of the audit command other than conforming
+var (
+	errOverRetained  = errors.New("backups are over-retained")
+	errUnderRetained = errors.New("backups are under-retained")
+)
+
+// auditResult is what the audit found in a single directory
+type auditResult struct {
+	directory string
+	excess    []retention.Decision
+	gaps      []retention.Gap
+}
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check that the backups conform to the retention policy",
+	Long: `Apply the retention policy to the current backups without deleting
+anything, and check that every directory conforms to it: no backup would be
+deleted by a prune, and no period within the reach of a tier lacks a
+backup, as reported by gaps.
+
+Exits with status 0 when every directory conforms, 1 when backups are
+over-retained, 2 when backups are under-retained, taking precedence over
+over-retained, and 3 when the audit could not be completed, for use in
+compliance checks run by monitoring.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		results, err := audit(ctx)
+		if err != nil {
+			return &exitError{code: exitCodeAuditFailed, err: err}
+		}
+
+		return writeAudit(cmd.OutOrStdout(), results)
+	},
+}
+
+// audit applies the retention policy to every configured directory
+func audit(ctx context.Context) ([]auditResult, error) {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	directories, err := expandDirectories(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]auditResult, 0, len(directories))
+
+	for _, directory := range directories {
+		policy, result, err := applyPolicy(ctx, cfg, directory)
+		if err != nil {
+			return nil, err
+		}
+
+		audited := auditResult{directory: directory, gaps: policy.Gaps(result)}
+
+		for _, d := range result.Decisions {
+			if d.Delete {
+				audited.excess = append(audited.excess, d)
+			}
+		}
+
+		results = append(results, audited)
+	}
+
+	return results, nil
+}
+
+// writeAudit prints the backups every directory keeps beyond the policy and
+// its gaps, and returns the error selecting the exit code of the audit
+func writeAudit(w io.Writer, results []auditResult) error {
+	excess, gaps := 0, 0
+
+	for i, result := range results {
+		if i > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+
+		_, _ = fmt.Fprintf(w, "Directory: %s\n", result.directory)
+
+		for _, d := range result.excess {
+			_, _ = fmt.Fprintf(w, "  %-8s %s (%s)\n", "excess",
+				relPath(result.directory, d.File.Path), d.Reason)
+		}
+
+		for _, gap := range result.gaps {
+			line := fmt.Sprintf("  %-8s %s %s",
+				"missing", gap.Tier, periodLabel(gap.Tier, gap.Start))
+			if gap.Tag != "" {
+				line += " (tag " + gap.Tag + ")"
+			}
+
+			_, _ = fmt.Fprintln(w, line)
+		}
+
+		status := "conforming"
+
+		switch {
+		case len(result.gaps) > 0:
+			status = "under-retained"
+		case len(result.excess) > 0:
+			status = "over-retained"
+		}
+
+		_, _ = fmt.Fprintf(w, "Status:    %s\n", status)
+
+		excess += len(result.excess)
+		gaps += len(result.gaps)
+	}
+
+	switch {
+	case gaps > 0:
+		return &exitError{
+			code: exitCodeUnderRetained,
+			err:  fmt.Errorf("%w: %d periods without a backup", errUnderRetained, gaps),
+		}
+	case excess > 0:
+		return &exitError{
+			code: exitCodeOverRetained,
+			err:  fmt.Errorf("%w: %d backups would be deleted", errOverRetained, excess),
+		}
+	default:
+		return nil
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+}
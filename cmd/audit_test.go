@@ -0,0 +1,107 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditCommand(t *testing.T) {
+	for name, tc := range map[string]struct {
+		daily   int
+		backups []string
+		code    int
+		want    string
+	}{
+		"conforming": {
+			daily:   3,
+			backups: []string{"2024-03-13", "2024-03-14", "2024-03-15"},
+			want:    "Status:    conforming\n",
+		},
+		"over-retained": {
+			daily:   2,
+			backups: []string{"2024-03-13", "2024-03-14", "2024-03-15"},
+			code:    exitCodeOverRetained,
+			want: "  excess   backup-2024-03-13.tar.gz (expired)\n" +
+				"Status:    over-retained\n",
+		},
+		"under-retained": {
+			daily:   2,
+			backups: []string{"2024-03-12", "2024-03-13", "2024-03-15"},
+			code:    exitCodeUnderRetained,
+			want: "  excess   backup-2024-03-12.tar.gz (expired)\n" +
+				"  missing  daily 2024-03-14\n" +
+				"Status:    under-retained\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for _, date := range tc.backups {
+				path := filepath.Join(dir, "backup-"+date+".tar.gz")
+				require.NoError(t, os.WriteFile(path, []byte(date), 0o600))
+			}
+
+			configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+			require.NoError(t, os.WriteFile(configFile, []byte(`retention:
+  daily: `+strconv.Itoa(tc.daily)+`
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: "`+filepath.ToSlash(dir)+`"
+`), 0o600))
+
+			viper.Reset()
+
+			var out bytes.Buffer
+
+			cmd := auditCmd
+			setContext(t, cmd, t.Context())
+			cmd.SetOut(&out)
+			require.NoError(t, cmd.Flags().Set("config", configFile))
+
+			err := cmd.RunE(cmd, nil)
+			require.Equal(t, tc.code, exitCode(err))
+			require.Equal(t, "Directory: "+dir+"\n"+tc.want, out.String())
+
+			for _, date := range tc.backups {
+				require.FileExists(t, filepath.Join(dir, "backup-"+date+".tar.gz"))
+			}
+		})
+	}
+
+	t.Run("failed", func(t *testing.T) {
+		viper.Reset()
+
+		cmd := auditCmd
+		require.NoError(t, cmd.Flags().Set("config", filepath.Join(t.TempDir(), "absent.yaml")))
+		require.Equal(t, exitCodeAuditFailed, exitCode(cmd.RunE(cmd, nil)))
+	})
+}
@@ -0,0 +1,219 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// Errors returned by check-freshness
+var (
+	errNoMaxAge     = errors.New("no maximum age, set freshness.max_age or pass --max-age")
+	errStaleBackups = errors.New("backups are stale")
+)
+
+// freshness is the newest backup found in a directory
+type freshness struct {
+	directory string
+	newest    file.Info
+	found     bool
+}
+
+// checkFreshnessCmd represents the check-freshness command
+var checkFreshnessCmd = &cobra.Command{
+	Use:   "check-freshness",
+	Short: "Fail when the newest backup is older than a maximum age",
+	Long: `List the backups in every configured directory and check that the newest
+one is younger than freshness.max_age, or --max-age. A directory without any
+matching backup is stale too.
+
+Exits with a non-zero status if any directory is stale. With
+freshness.notify, or --notify, an alert listing the stale directories is
+also sent to the configured notification targets, so the same tool that
+prunes backups can alert when they stop arriving.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		maxAge, notifyStale, err := freshnessSettings(cmd, cfg)
+		if err != nil {
+			return err
+		}
+
+		results, err := newestBackups(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		stale := writeFreshnessReport(cmd.OutOrStdout(), results, maxAge, clock())
+		if len(stale) == 0 {
+			return nil
+		}
+
+		if notifyStale {
+			sendAlert(ctx, cmd.ErrOrStderr(), cfg, notify.Alert{
+				Title:    "Backups are stale",
+				Problems: stale,
+			})
+		}
+
+		return fmt.Errorf("%w: %d of %d directories", errStaleBackups, len(stale), len(results))
+	},
+}
+
+// freshnessSettings returns the maximum age and whether to notify, with the
+// command line flags taking precedence over the config
+func freshnessSettings(cmd *cobra.Command, cfg *config.Config) (time.Duration, bool, error) {
+	maxAge, notifyStale := cfg.Freshness.MaxAge, cfg.Freshness.Notify
+
+	if cmd.Flags().Changed("max-age") {
+		maxAge, _ = cmd.Flags().GetDuration("max-age")
+	}
+
+	if cmd.Flags().Changed("notify") {
+		notifyStale, _ = cmd.Flags().GetBool("notify")
+	}
+
+	if maxAge <= 0 {
+		return 0, false, errNoMaxAge
+	}
+
+	return maxAge, notifyStale, nil
+}
+
+// newestBackups finds the newest backup in every configured directory
+func newestBackups(ctx context.Context, cfg *config.Config) ([]freshness, error) {
+	directories, err := file.ExpandDirectories(cfg.Directories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand directories: %w", err)
+	}
+
+	log := &logging.Logger{Logger: zap.NewNop()}
+
+	results := make([]freshness, 0, len(directories))
+
+	for _, directory := range directories {
+		store, err := backend.New(cfg, directory, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize backend: %w", err)
+		}
+
+		files, err := store.ListFiles(ctx)
+		err = errors.Join(err, backend.Close(store))
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+
+		result := freshness{directory: directory}
+
+		for _, f := range files {
+			if !result.found || f.Timestamp.After(result.newest.Timestamp) {
+				result.newest, result.found = f, true
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// writeFreshnessReport prints the age of the newest backup of every
+// directory and returns a description of each stale one
+func writeFreshnessReport(
+	w io.Writer,
+	results []freshness,
+	maxAge time.Duration,
+	now time.Time,
+) []string {
+	var stale []string
+
+	for _, result := range results {
+		if !result.found {
+			_, _ = fmt.Fprintf(w, "%-6s %s: no backups found\n", "STALE", result.directory)
+			stale = append(stale, result.directory+": no backups found")
+
+			continue
+		}
+
+		age := now.Sub(result.newest.Timestamp).Round(time.Second)
+		status := "OK"
+
+		if age > maxAge {
+			status = "STALE"
+			stale = append(stale, fmt.Sprintf("%s: newest backup %s is %s old",
+				result.directory, relPath(result.directory, result.newest.Path), age))
+		}
+
+		_, _ = fmt.Fprintf(w, "%-6s %s: newest backup %s is %s old (max %s)\n", status,
+			result.directory, relPath(result.directory, result.newest.Path), age, maxAge)
+	}
+
+	return stale
+}
+
+// sendAlert delivers an alert to every configured notification target.
+// Delivery failures are only reported on w.
+func sendAlert(ctx context.Context, w io.Writer, cfg *config.Config, alert notify.Alert) {
+	for _, sender := range notify.FromConfig(cfg.Notifications) {
+		if err := sender.Alert(ctx, alert); err != nil {
+			_, _ = fmt.Fprintf(w, "Warning: failed to send %s alert: %v\n", sender.Name(), err)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(checkFreshnessCmd)
+
+	checkFreshnessCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	checkFreshnessCmd.Flags().
+		Duration("max-age", 0, "Maximum age of the newest backup, overriding freshness.max_age")
+	checkFreshnessCmd.Flags().
+		Bool("notify", false, "Send an alert to the notification targets when backups are stale")
+}
@@ -0,0 +1,144 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+func TestCheckFreshnessCommand(t *testing.T) {
+	fresh, old, empty := t.TempDir(), t.TempDir(), t.TempDir()
+
+	setClock(t, time.Date(2024, 3, 16, 12, 0, 0, 0, time.UTC))
+
+	// The old backup was taken 27 hours before the clock
+	recent := "backup-2024-03-16-11-00.tar.gz"
+	require.NoError(t, os.WriteFile(filepath.Join(fresh, recent), []byte("a"), 0o600))
+	require.NoError(t,
+		os.WriteFile(filepath.Join(old, "backup-2024-03-15-09-00.tar.gz"), []byte("b"), 0o600))
+
+	var alerts []map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if json.NewDecoder(r.Body).Decode(&body) == nil {
+			alerts = append(alerts, body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	configContent := `file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory:
+  - "` + filepath.ToSlash(fresh) + `"
+  - "` + filepath.ToSlash(old) + `"
+  - "` + filepath.ToSlash(empty) + `"
+freshness:
+  max_age: 25h
+  notify: true
+notifications:
+  slack:
+    webhook_url: "` + server.URL + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := checkFreshnessCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+
+	err := cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errStaleBackups)
+	require.ErrorContains(t, err, "2 of 3 directories")
+	require.Contains(t, out.String(), "OK     "+fresh+": newest backup "+recent)
+	require.Contains(t, out.String(), "STALE  "+old+": newest backup")
+	require.Contains(t, out.String(), "STALE  "+empty+": no backups found")
+
+	require.Len(t, alerts, 1)
+	require.Equal(t, "Backups are stale", alerts[0]["text"])
+
+	// The flags take precedence over the config
+	out.Reset()
+	viper.Reset()
+	require.NoError(t, cmd.Flags().Set("max-age", "28h"))
+	require.NoError(t, cmd.Flags().Set("notify", "false"))
+
+	err = cmd.RunE(cmd, nil)
+	require.ErrorContains(t, err, "1 of 3 directories")
+	require.Len(t, alerts, 1)
+}
+
+func TestWriteFreshnessReport(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	results := []freshness{
+		{
+			directory: "/backups/db",
+			newest: file.Info{
+				Path:      "/backups/db/db-2024-03-15.tar.gz",
+				Timestamp: now.Add(-2 * time.Hour),
+			},
+			found: true,
+		},
+		{
+			directory: "/backups/www",
+			newest: file.Info{
+				Path:      "/backups/www/www-2024-03-12.tar.gz",
+				Timestamp: now.Add(-72 * time.Hour),
+			},
+			found: true,
+		},
+	}
+
+	var out bytes.Buffer
+
+	stale := writeFreshnessReport(&out, results, 24*time.Hour, now)
+	require.Equal(t, []string{
+		"/backups/www: newest backup www-2024-03-12.tar.gz is 72h0m0s old",
+	}, stale)
+	require.Equal(t,
+		"OK     /backups/db: newest backup db-2024-03-15.tar.gz is 2h0m0s old (max 24h0m0s)\n"+
+			"STALE  /backups/www: newest backup www-2024-03-12.tar.gz is 72h0m0s old "+
+			"(max 24h0m0s)\n",
+		out.String())
+}
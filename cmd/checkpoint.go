@@ -0,0 +1,127 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+)
+
+// Errors reading or resuming from a checkpoint
+var (
+	errNoCheckpoint       = errors.New("no checkpoint to resume from")
+	errCheckpointMismatch = errors.New("checkpoint does not match this run")
+)
+
+// checkpoint records the directories an interrupted prune had not finished,
+// so that prune --resume can continue with them
+type checkpoint struct {
+	CreatedAt time.Time `json:"created_at"`
+	DryRun    bool      `json:"dry_run"`
+	Remaining []string  `json:"remaining"`
+}
+
+// checkpointPath returns the configured checkpoint path, defaulting to a
+// file in the user's cache directory
+func checkpointPath(cfg *config.Config) (string, error) {
+	if cfg.Checkpoint != "" {
+		return cfg.Checkpoint, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate checkpoint: %w", err)
+	}
+
+	return filepath.Join(dir, "apply-retention-policy", "checkpoint.json"), nil
+}
+
+// writeCheckpoint records the directories still to be pruned
+func writeCheckpoint(path string, dryRun bool, remaining []string) error {
+	data, err := json.MarshalIndent(checkpoint{
+		CreatedAt: time.Now().UTC(),
+		DryRun:    dryRun,
+		Remaining: remaining,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// removeCheckpoint deletes the checkpoint once a resumed run has completed,
+// so a later --resume cannot pick up stale work
+func removeCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// resumeDirectories returns the directories remaining in the checkpoint at
+// path, in their original order. Directories that are no longer configured
+// are dropped, and a checkpoint of a dry run cannot be resumed for real or
+// the other way round.
+func resumeDirectories(path string, dryRun bool, configured []string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", errNoCheckpoint, path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	if cp.DryRun != dryRun {
+		return nil, fmt.Errorf("%w: dry_run was %t", errCheckpointMismatch, cp.DryRun)
+	}
+
+	return slices.DeleteFunc(cp.Remaining, func(directory string) bool {
+		return !slices.Contains(configured, directory)
+	}), nil
+}
@@ -0,0 +1,126 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/hooks"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+func TestPruneInterruptAndResume(t *testing.T) {
+	var directories []string
+
+	for range 2 {
+		dir := t.TempDir()
+		directories = append(directories, dir)
+
+		for _, name := range []string{
+			"backup-2024-03-15-12-00.tar.gz",
+			"backup-2024-03-14-12-00.tar.gz",
+		} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+		}
+	}
+
+	cfg := &config.Config{
+		Retention:      config.RetentionPolicy{Daily: 1},
+		RequireMinimum: 1,
+		FilePattern:    "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz",
+		Directories:    directories,
+		Checkpoint:     filepath.Join(t.TempDir(), "state", "checkpoint.json"),
+	}
+
+	log := logging.NewDefault()
+	hookRunner := hooks.NewRunner()
+	rep := newReporter(&bytes.Buffer{}, false, false)
+
+	remaining := func() []string {
+		t.Helper()
+
+		var names []string
+
+		for _, dir := range directories {
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+		}
+
+		return names
+	}
+
+	stopped, stop := context.WithCancel(t.Context())
+	stop()
+
+	t.Run("interrupted directory", func(t *testing.T) {
+		_, _, err := pruneDirectory(
+			t.Context(), stopped, log, cfg, nil, nil, directories[0], hookRunner, rep,
+		)
+		require.ErrorIs(t, err, errInterrupted)
+		require.Len(t, remaining(), 4)
+	})
+
+	t.Run("interrupted run", func(t *testing.T) {
+		_, err := prune(t.Context(), stopped, log, cfg, hookRunner, rep)
+		require.ErrorIs(t, err, errInterrupted)
+		require.Equal(t, exitCodeInterrupted, exitCode(err))
+		require.Len(t, remaining(), 4)
+
+		resumed, err := resumeDirectories(cfg.Checkpoint, false, directories)
+		require.NoError(t, err)
+		require.Equal(t, directories, resumed)
+
+		_, err = resumeDirectories(cfg.Checkpoint, true, directories)
+		require.ErrorIs(t, err, errCheckpointMismatch)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		pruneResume = true
+
+		t.Cleanup(func() { pruneResume = false })
+
+		_, err := prune(t.Context(), t.Context(), log, cfg, hookRunner, rep)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"backup-2024-03-15-12-00.tar.gz",
+			"backup-2024-03-15-12-00.tar.gz",
+		}, remaining())
+		require.NoFileExists(t, cfg.Checkpoint)
+
+		_, err = prune(t.Context(), t.Context(), log, cfg, hookRunner, rep)
+		require.ErrorIs(t, err, errNoCheckpoint)
+	})
+}
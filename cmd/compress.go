@@ -0,0 +1,111 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// compressible reports whether a backup the policy keeps is old enough to
+// be compressed. Pinned backups are left alone since renaming them would
+// separate them from their hold marker.
+func compressible(cfg *config.Config, decision retention.Decision, now time.Time) bool {
+	return cfg.CompressAfter > 0 && !decision.Delete && !decision.File.Pinned &&
+		decision.File.Timestamp.Before(now.Add(-cfg.CompressAfter))
+}
+
+// compressKept compresses the kept backups in candidates that are not
+// compressed yet, until stop is cancelled. The space saved counts towards
+// summary as reclaimed. The compressed backups are returned by their
+// original path, together with the errors of those that failed.
+func compressKept(
+	ctx context.Context,
+	stop context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	rep *reporter,
+	candidates []retention.Decision,
+	summary *notify.Summary,
+) (map[string]file.Info, []error) {
+	format := cfg.CompressFormat
+	if format == "" {
+		format = config.CompressFormatGzip
+	}
+
+	compressed := map[string]file.Info{}
+
+	var errs []error
+
+	for _, decision := range candidates {
+		if stop.Err() != nil {
+			break
+		}
+
+		f := decision.File
+
+		done, err := file.IsCompressed(f.Path)
+		if err == nil && done {
+			continue
+		}
+
+		target := file.Info{Path: f.Path + file.CompressionSuffix(format)}
+		if err == nil && !cfg.DryRun {
+			target, err = file.CompressFile(ctx, f, format)
+		}
+
+		if err != nil {
+			log.Error("failed to compress file", zap.String("file", f.Path), zap.Error(err))
+			rep.fail(f.Path, err)
+			recordFailure(summary, err)
+			errs = append(errs, err)
+
+			continue
+		}
+
+		log.Info("compressed file",
+			zap.String("file", f.Path),
+			zap.String("compressed", target.Path),
+			zap.Bool("dry_run", cfg.DryRun))
+		rep.compress(f, target.Path, cfg.DryRun)
+
+		summary.Compressed++
+
+		if !cfg.DryRun {
+			summary.ReclaimedBytes += max(f.Size-target.Size, 0)
+			compressed[f.Path] = target
+		}
+	}
+
+	return compressed, errs
+}
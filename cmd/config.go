@@ -0,0 +1,90 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+)
+
+// errConfigInvalid is returned by config validate when problems were found
+var errConfigInvalid = errors.New("configuration is invalid")
+
+// configCmd groups the configuration subcommands
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration and report every problem found",
+	Long: `Load the configuration from the config file and environment and report
+every problem at once: unknown keys (with a suggestion for near misses such
+as file-pattern instead of file_pattern), values of the wrong type and
+invalid settings.
+
+Exits with a non-zero status if any problem was found.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		_, err := config.LoadConfig(cfgFile)
+
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			writeConfigProblems(cmd.OutOrStdout(), validationErr.Problems)
+			return errConfigInvalid
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid")
+
+		return nil
+	},
+}
+
+// writeConfigProblems prints one line per configuration problem
+func writeConfigProblems(w io.Writer, problems []error) {
+	_, _ = fmt.Fprintf(w, "Found %d problem(s):\n", len(problems))
+
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(w, "  - %s\n", problem)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configValidateCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+}
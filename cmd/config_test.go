@@ -0,0 +1,106 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidateCommand(t *testing.T) {
+	writeConfig := func(t *testing.T, content string) string {
+		t.Helper()
+
+		configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+
+		return configFile
+	}
+
+	run := func(t *testing.T, configFile string) (string, error) {
+		t.Helper()
+
+		viper.Reset()
+
+		var buf bytes.Buffer
+
+		cmd := configValidateCmd
+		cmd.SetOut(&buf)
+		require.NoError(t, cmd.Flags().Set("config", configFile))
+
+		err := cmd.RunE(cmd, nil)
+
+		return buf.String(), err
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		out, err := run(t, writeConfig(t, `retention:
+  daily: 7
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: "/backups"
+tag_retention:
+  nightly:
+    daily: 3
+`))
+		require.NoError(t, err)
+		require.Contains(t, out, "Configuration is valid")
+	})
+
+	t.Run("all problems reported", func(t *testing.T) {
+		out, err := run(t, writeConfig(t, `retention:
+  daily: -1
+  hourley: 2
+file-pattern: "backup-{year}-{month}-{day}.tar.gz"
+tag_retention:
+  nightly:
+    dayly: 3
+frobnicate: true
+`))
+		require.ErrorIs(t, err, errConfigInvalid)
+		require.Contains(t, out, "Found 7 problem(s)")
+		require.Contains(t, out, `"file-pattern" (did you mean "file_pattern"?)`)
+		require.Contains(t, out, `"retention.hourley" (did you mean "retention.hourly"?)`)
+		require.Contains(
+			t,
+			out,
+			`"tag_retention.nightly.dayly" (did you mean "tag_retention.nightly.daily"?)`,
+		)
+		require.Contains(t, out, `unknown key "frobnicate"`+"\n")
+		require.Contains(t, out, "daily retention must be non-negative")
+		require.Contains(t, out, "file pattern must be specified")
+		require.Contains(t, out, "directory must be specified")
+	})
+
+	t.Run("unreadable file", func(t *testing.T) {
+		_, err := run(t, filepath.Join(t.TempDir(), "missing.yaml"))
+		require.Error(t, err)
+		require.NotErrorIs(t, err, errConfigInvalid)
+	})
+}
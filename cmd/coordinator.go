@@ -0,0 +1,232 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+)
+
+// agentBackup is a backup as reported by an agent
+type agentBackup struct {
+	Path      string    `json:"path"`
+	Timestamp time.Time `json:"timestamp"`
+	Size      int64     `json:"size,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	DependsOn string    `json:"depends_on,omitempty"`
+}
+
+// agentDirectory lists the backups an agent found in one directory
+type agentDirectory struct {
+	Directory string        `json:"directory"`
+	Backups   []agentBackup `json:"backups"`
+}
+
+// agentInventory is the body an agent posts to
+// /v1/agents/{name}/inventory
+type agentInventory struct {
+	Directories []agentDirectory `json:"directories"`
+}
+
+// agentDeletion is a backup the coordinator tells an agent to delete
+type agentDeletion struct {
+	Path   string           `json:"path"`
+	Reason retention.Reason `json:"reason"`
+	Tier   retention.Reason `json:"tier,omitempty"`
+}
+
+// agentPlan is the coordinator's answer to an inventory. Policy names the
+// named policy the plan was made with, if any.
+type agentPlan struct {
+	DryRun bool            `json:"dry_run"`
+	Policy string          `json:"policy,omitempty"`
+	Delete []agentDeletion `json:"delete"`
+}
+
+// agentStatus is what the coordinator knows about an agent, as listed by
+// /v1/agents
+type agentStatus struct {
+	Name       string      `json:"name"`
+	LastSeen   time.Time   `json:"last_seen"`
+	Backups    int         `json:"backups"`
+	Planned    int         `json:"planned"`
+	LastReport *runSummary `json:"last_report,omitempty"`
+}
+
+// newAgentBackup returns a backup as an agent reports it
+func newAgentBackup(f file.Info) agentBackup {
+	return agentBackup{
+		Path:      f.Path,
+		Timestamp: f.Timestamp,
+		Size:      f.Size,
+		Tag:       f.Tag,
+		Pinned:    f.Pinned,
+		DependsOn: f.DependsOn,
+	}
+}
+
+// info returns the backup as the retention policy sees it
+func (b agentBackup) info() file.Info {
+	return file.Info{
+		Path:      b.Path,
+		Timestamp: b.Timestamp,
+		Size:      b.Size,
+		Tag:       b.Tag,
+		Pinned:    b.Pinned,
+		DependsOn: b.DependsOn,
+	}
+}
+
+// handleInventory applies the retention policy to the backups an agent
+// reported, directory by directory, and answers with the backups it is to
+// delete. An agent is planned with the named policy of the same name when
+// there is one, and with the active policy otherwise, unless a
+// subdirectories rule selects another for a directory.
+func (s *apiServer) handleInventory(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var inventory agentInventory
+	body := http.MaxBytesReader(w, r.Body, maxAgentBytes)
+	if err := json.NewDecoder(body).Decode(&inventory); err != nil {
+		s.fail(w, decodeStatus(err), fmt.Errorf("invalid inventory: %w", err))
+		return
+	}
+
+	cfg := s.config()
+	// The coordinator cannot read the agents' files to compare them
+	cfg.Dedupe = false
+
+	plan := agentPlan{DryRun: cfg.DryRun, Delete: []agentDeletion{}}
+	if cfg.UsePolicy(name) {
+		plan.Policy = name
+	}
+
+	backups := 0
+
+	for _, dir := range inventory.Directories {
+		files := make([]file.Info, len(dir.Backups))
+		for i, b := range dir.Backups {
+			files[i] = b.info()
+		}
+
+		backups += len(files)
+
+		result, err := retention.NewPolicy(s.log, cfg.ForDirectory(dir.Directory)).Apply(files)
+		if err != nil {
+			s.fail(w, http.StatusInternalServerError,
+				fmt.Errorf("failed to apply retention policy to %s: %w", dir.Directory, err))
+
+			return
+		}
+
+		for _, d := range result.Decisions {
+			if d.Delete {
+				plan.Delete = append(plan.Delete,
+					agentDeletion{Path: d.File.Path, Reason: d.Reason, Tier: d.Tier})
+			}
+		}
+	}
+
+	s.updateAgent(name, func(status *agentStatus) {
+		status.Backups = backups
+		status.Planned = len(plan.Delete)
+	})
+
+	s.log.Info("planned agent",
+		zap.String("agent", name),
+		zap.Int("backups", backups),
+		zap.Int("delete", len(plan.Delete)))
+
+	s.reply(w, http.StatusOK, plan)
+}
+
+// handleReport records the summary of the deletions an agent made
+func (s *apiServer) handleReport(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var report runSummary
+	body := http.MaxBytesReader(w, r.Body, maxAgentBytes)
+	if err := json.NewDecoder(body).Decode(&report); err != nil {
+		s.fail(w, decodeStatus(err), fmt.Errorf("invalid report: %w", err))
+		return
+	}
+
+	s.updateAgent(name, func(status *agentStatus) {
+		status.LastReport = &report
+	})
+
+	s.log.Info("agent reported",
+		zap.String("agent", name),
+		zap.Int("deleted", report.Deleted),
+		zap.Int64("reclaimed_bytes", report.ReclaimedBytes),
+		zap.Strings("errors", report.Errors))
+
+	s.reply(w, http.StatusOK, map[string]string{})
+}
+
+// handleAgents lists every agent that reported since the server started,
+// by name
+func (s *apiServer) handleAgents(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	agents := slices.SortedFunc(maps.Values(s.agents), func(a, b agentStatus) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	s.mu.Unlock()
+
+	if agents == nil {
+		agents = []agentStatus{}
+	}
+
+	s.reply(w, http.StatusOK, agents)
+}
+
+// updateAgent records that the agent name was seen now and applies update
+// to its status
+func (s *apiServer) updateAgent(name string, update func(*agentStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.agents == nil {
+		s.agents = map[string]agentStatus{}
+	}
+
+	status := s.agents[name]
+	status.Name = name
+	status.LastSeen = time.Now().UTC()
+	update(&status)
+
+	s.agents[name] = status
+}
@@ -0,0 +1,191 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+)
+
+// errInvalidMonths is returned by coverage for a --months below one
+var errInvalidMonths = errors.New("--months must be at least 1")
+
+// coverageCmd represents the coverage command
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Show a calendar of the days the retention policy keeps a backup for",
+	Long: `Apply the retention policy to the current backups without deleting
+anything and print a calendar of the past months, one line per month and one
+column per day. A # marks a day with at least one kept backup, a . a day
+without any, so the recovery granularity the policy leaves is visible at a
+glance: every day of the last week, then one per week, then one per month.
+
+Days are counted like the daily tier, so day_boundary_offset applies.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		months, _ := cmd.Flags().GetInt("months")
+		if months < 1 {
+			return errInvalidMonths
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		directories, err := expandDirectories(cfg)
+		if err != nil {
+			return err
+		}
+
+		for i, directory := range directories {
+			kept, err := keptTimestamps(ctx, cfg, directory)
+			if err != nil {
+				return err
+			}
+
+			if i > 0 {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout())
+			}
+
+			writeCoverage(cmd.OutOrStdout(), directory, kept, clock(), months)
+		}
+
+		return nil
+	},
+}
+
+// keptTimestamps returns the timestamps of the backups the retention policy
+// keeps in a directory, shifted by day_boundary_offset
+func keptTimestamps(
+	ctx context.Context,
+	cfg *config.Config,
+	directory string,
+) ([]time.Time, error) {
+	_, result, err := applyPolicy(ctx, cfg, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []time.Time
+
+	for _, d := range result.Decisions {
+		if !d.Delete {
+			kept = append(kept, d.File.Timestamp.Add(cfg.DayBoundaryOffset))
+		}
+	}
+
+	return kept, nil
+}
+
+// writeCoverage prints a calendar of the months up to the one of now, with
+// the days holding a kept backup marked, and how many such days each month
+// has
+func writeCoverage(w io.Writer, directory string, kept []time.Time, now time.Time, months int) {
+	covered := map[string]bool{}
+	for _, ts := range kept {
+		covered[ts.Format(time.DateOnly)] = true
+	}
+
+	_, _ = fmt.Fprintf(w, "Directory: %s\n", directory)
+	_, _ = fmt.Fprintf(w, "%-9s%s\n", "", coverageHeader())
+
+	first := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, now.Location())
+
+	for m := range months {
+		month := first.AddDate(0, m, 0)
+		row, days := coverageRow(month, now, covered)
+
+		_, _ = fmt.Fprintf(w, "%s  %s %3d\n", month.Format("2006-01"), row, days)
+	}
+
+	if len(kept) == 0 {
+		_, _ = fmt.Fprintln(w, "Restore points: 0")
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Restore points: %d (oldest %s, newest %s)\n", len(kept),
+		slices.MinFunc(kept, time.Time.Compare).Format(time.DateOnly),
+		slices.MaxFunc(kept, time.Time.Compare).Format(time.DateOnly))
+}
+
+// coverageHeader numbers the day columns of the calendar
+func coverageHeader() string {
+	header := []byte(strings.Repeat(" ", 31))
+	header[0] = '1'
+
+	for _, day := range []int{10, 20, 30} {
+		copy(header[day-1:], fmt.Sprint(day))
+	}
+
+	return string(header)
+}
+
+// coverageRow renders the days of month, # for a covered day, . for one
+// without a backup and a space for days after now or the month's end. It
+// also returns how many days of the month are covered.
+func coverageRow(month, now time.Time, covered map[string]bool) (string, int) {
+	row := []byte(strings.Repeat(" ", 31))
+	days := 0
+
+	for day := 1; day <= 31; day++ {
+		date := month.AddDate(0, 0, day-1)
+		if date.Month() != month.Month() || date.After(now) {
+			break
+		}
+
+		row[day-1] = '.'
+
+		if covered[date.Format(time.DateOnly)] {
+			row[day-1] = '#'
+			days++
+		}
+	}
+
+	return string(row), days
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+
+	coverageCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	coverageCmd.Flags().Int("months", 12, "Number of months to show, ending with the current one")
+}
@@ -0,0 +1,98 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverageCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	now := setClock(t, time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)).Now()
+	for _, ts := range []time.Time{now, now.AddDate(0, 0, -1), now.AddDate(-5, 0, 0)} {
+		name := "backup-" + ts.Format(time.DateOnly) + ".tar.gz"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 1
+  yearly: 10
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := coverageCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("months", "3"))
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	// The header, the directory, three months and the summary
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 6)
+	require.Equal(t, "2024-03", lines[4][:7])
+	require.Contains(t, lines[5], "Restore points: 3")
+
+	require.NoError(t, cmd.Flags().Set("months", "0"))
+	require.ErrorIs(t, cmd.RunE(cmd, nil), errInvalidMonths)
+}
+
+func TestWriteCoverage(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	kept := []time.Time{
+		time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 15, 1, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC),
+	}
+
+	var out bytes.Buffer
+
+	writeCoverage(&out, "/backups", kept, now, 3)
+	require.Equal(t, `Directory: /backups
+         1        10        20        30
+2024-01  ..............................#   1
+2024-02  .............................     0
+2024-03  #............##                   3
+Restore points: 5 (oldest 2024-01-31, newest 2024-03-15)
+`, out.String())
+}
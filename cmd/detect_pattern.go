@@ -0,0 +1,100 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// maxListedCandidates limits how many alternative patterns are printed
+const maxListedCandidates = 5
+
+// detectPatternCmd represents the detect-pattern command
+var detectPatternCmd = &cobra.Command{
+	Use:   "detect-pattern <directory>",
+	Short: "Propose a file_pattern for the backups in a directory",
+	Long: `Analyze the names of the files in a directory, detect the date and time
+components in them and propose a file_pattern that matches them. The most
+common pattern is printed first, followed by any alternatives.
+
+No configuration is needed, so this can be run before writing one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := os.ReadDir(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %w", err)
+		}
+
+		names := make([]string, 0, len(entries))
+
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+
+		candidates, err := file.DetectPattern(names)
+		if err != nil {
+			return err
+		}
+
+		writePatternCandidates(cmd.OutOrStdout(), candidates, len(names))
+
+		return nil
+	},
+}
+
+// writePatternCandidates prints the proposed pattern and its alternatives
+func writePatternCandidates(w io.Writer, candidates []file.PatternCandidate, total int) {
+	best := candidates[0]
+
+	_, _ = fmt.Fprintf(w, "file_pattern: %q\n", best.Pattern)
+	_, _ = fmt.Fprintf(w, "# matches %d of %d files\n", best.Matches, total)
+
+	if len(candidates) == 1 {
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "# alternatives:")
+
+	for i, candidate := range candidates[1:] {
+		if i == maxListedCandidates {
+			_, _ = fmt.Fprintf(w, "#   ... and %d more\n", len(candidates)-1-maxListedCandidates)
+			break
+		}
+
+		_, _ = fmt.Fprintf(w, "#   %q (matches %d)\n", candidate.Pattern, candidate.Matches)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(detectPatternCmd)
+}
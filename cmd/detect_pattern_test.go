@@ -0,0 +1,77 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+func TestDetectPatternCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-15-11-00.tar.gz",
+		"backup-2024-03-14-00-00.tar.gz",
+		"db-20240315.sql",
+		"README.txt",
+	} {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "2024-03-16"), 0o750))
+
+	t.Run("proposes most common pattern", func(t *testing.T) {
+		var out bytes.Buffer
+
+		cmd := detectPatternCmd
+		cmd.SetOut(&out)
+		require.NoError(t, cmd.RunE(cmd, []string{tmpDir}))
+
+		require.Equal(t, `file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+# matches 3 of 5 files
+# alternatives:
+#   "db-{year}{month}{day}.sql" (matches 1)
+`, out.String())
+	})
+
+	t.Run("no timestamps", func(t *testing.T) {
+		emptyDir := t.TempDir()
+		err := os.WriteFile(filepath.Join(emptyDir, "README.txt"), nil, 0o600)
+		require.NoError(t, err)
+
+		cmd := detectPatternCmd
+		err = cmd.RunE(cmd, []string{emptyDir})
+		require.ErrorIs(t, err, file.ErrNoPatternDetected)
+	})
+}
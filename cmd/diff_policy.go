@@ -0,0 +1,236 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// errNothingToCompare is returned by diff-policy when given a single config
+// and no retention flags to change it with
+var errNothingToCompare = errors.New(
+	"nothing to compare: give a second config or retention flags",
+)
+
+// diffPolicyCmd represents the diff-policy command
+var diffPolicyCmd = &cobra.Command{
+	Use:   "diff-policy <old-config> [new-config]",
+	Short: "Show which backups a policy change would keep or delete differently",
+	Long: `Apply two retention policies to the backups that exist now and print every
+backup whose disposition differs between them, so a policy change can be
+reviewed before it is rolled out. Nothing is deleted.
+
+The backups are listed with the directories and pattern of the old config.
+The new policy is read from the new config, or is the old one with the
+retention flags applied, e.g.
+
+  apply-retention-policy diff-policy retention-policy.yaml --daily 14`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		if len(args) == 1 && !retentionFlagsChanged(cmd) {
+			return errNothingToCompare
+		}
+
+		oldCfg, err := loadPolicyConfig(args[0])
+		if err != nil {
+			return err
+		}
+
+		newCfg, err := loadPolicyConfig(args[len(args)-1])
+		if err != nil {
+			return err
+		}
+
+		if err := applyRetentionFlags(cmd, newCfg); err != nil {
+			return err
+		}
+
+		return diffPolicies(ctx, cmd.OutOrStdout(), oldCfg, newCfg)
+	},
+}
+
+// loadPolicyConfig loads one of the configs compared by diff-policy. viper
+// is reset first so nothing carries over from the other config.
+func loadPolicyConfig(path string) (*config.Config, error) {
+	viper.Reset()
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// retentionFlagsChanged reports whether any retention flag was given
+func retentionFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range []string{"hourly", "daily", "weekly", "monthly", "yearly", "keep-count"} {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyRetentionFlags overrides the retention of cfg with the retention
+// flags that were given, and validates the result
+func applyRetentionFlags(cmd *cobra.Command, cfg *config.Config) error {
+	for name, value := range map[string]*int{
+		"hourly":     &cfg.Retention.Hourly,
+		"daily":      &cfg.Retention.Daily,
+		"weekly":     &cfg.Retention.Weekly,
+		"monthly":    &cfg.Retention.Monthly,
+		"yearly":     &cfg.Retention.Yearly,
+		"keep-count": &cfg.KeepCount,
+	} {
+		if cmd.Flags().Changed(name) {
+			*value, _ = cmd.Flags().GetInt(name)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid new policy: %w", err)
+	}
+
+	return nil
+}
+
+// diffPolicies lists the backups of every directory of oldCfg and prints
+// those that newCfg decides differently, followed by the totals
+func diffPolicies(ctx context.Context, w io.Writer, oldCfg, newCfg *config.Config) error {
+	directories, err := expandDirectories(oldCfg)
+	if err != nil {
+		return err
+	}
+
+	log := &logging.Logger{Logger: zap.NewNop()}
+
+	var total, deleted, kept int
+
+	for _, directory := range directories {
+		store, err := backend.New(oldCfg, directory, log)
+		if err != nil {
+			return fmt.Errorf("failed to initialize backend: %w", err)
+		}
+
+		files, err := store.ListFiles(ctx)
+		err = errors.Join(err, backend.Close(store))
+
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		before, err := retention.NewPolicy(log, oldCfg.ForDirectory(directory)).Apply(files)
+		if err != nil {
+			return fmt.Errorf("failed to apply old policy: %w", err)
+		}
+
+		after, err := retention.NewPolicy(log, newCfg.ForDirectory(directory)).Apply(files)
+		if err != nil {
+			return fmt.Errorf("failed to apply new policy: %w", err)
+		}
+
+		d, k := writePolicyDiff(w, before, after)
+		total, deleted, kept = total+len(files), deleted+d, kept+k
+	}
+
+	_, _ = fmt.Fprintf(w, "Summary: %d of %d backups change, %d newly deleted, %d newly kept\n",
+		deleted+kept, total, deleted, kept)
+
+	return nil
+}
+
+// writePolicyDiff prints every file whose disposition differs between two
+// results of the same files, and returns how many files the new result
+// deletes and keeps that the old one did not
+func writePolicyDiff(w io.Writer, before, after *retention.Result) (int, int) {
+	old := make(map[string]retention.Decision, len(before.Decisions))
+	for _, d := range before.Decisions {
+		old[d.File.Path] = d
+	}
+
+	var deleted, kept int
+
+	for _, d := range after.Decisions {
+		prev, ok := old[d.File.Path]
+		if !ok || prev.Delete == d.Delete {
+			continue
+		}
+
+		verb := "now kept"
+		if d.Delete {
+			verb = "now deleted"
+			deleted++
+		} else {
+			kept++
+		}
+
+		_, _ = fmt.Fprintf(w, "%-12s %s (%s -> %s)\n",
+			verb, d.File.Path, decisionReason(prev), decisionReason(d))
+	}
+
+	return deleted, kept
+}
+
+// decisionReason describes why a file was kept or deleted, with the tier
+// slot if any
+func decisionReason(d retention.Decision) string {
+	if d.Slot > 0 {
+		return fmt.Sprintf("%s #%d", d.Reason, d.Slot)
+	}
+
+	return string(d.Reason)
+}
+
+func init() {
+	rootCmd.AddCommand(diffPolicyCmd)
+
+	diffPolicyCmd.Flags().Int("hourly", 0, "Number of hourly backups the new policy keeps")
+	diffPolicyCmd.Flags().Int("daily", 0, "Number of daily backups the new policy keeps")
+	diffPolicyCmd.Flags().Int("weekly", 0, "Number of weekly backups the new policy keeps")
+	diffPolicyCmd.Flags().Int("monthly", 0, "Number of monthly backups the new policy keeps")
+	diffPolicyCmd.Flags().Int("yearly", 0, "Number of yearly backups the new policy keeps")
+	diffPolicyCmd.Flags().
+		Int("keep-count", 0, "Newest backups the new policy keeps, by modification time")
+}
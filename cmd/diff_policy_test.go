@@ -0,0 +1,104 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffPolicyCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	for day := 10; day <= 15; day++ {
+		name := filepath.Join(dir, fmt.Sprintf("backup-2024-03-%d.tar.gz", day))
+		require.NoError(t, os.WriteFile(name, nil, 0o600))
+	}
+
+	writeConfig := func(daily int) string {
+		path := filepath.Join(t.TempDir(), "retention-policy.yaml")
+		content := fmt.Sprintf(`file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: %q
+retention:
+  daily: %d
+`, filepath.ToSlash(dir), daily)
+
+		err := os.WriteFile(path, []byte(content), 0o600)
+		require.NoError(t, err)
+
+		return path
+	}
+
+	oldConfig := writeConfig(5)
+
+	run := func(t *testing.T, args ...string) (string, error) {
+		var out bytes.Buffer
+
+		cmd := diffPolicyCmd
+		cmd.SetOut(&out)
+		err := cmd.RunE(cmd, args)
+
+		return out.String(), err
+	}
+
+	t.Run("two configs", func(t *testing.T) {
+		out, err := run(t, oldConfig, writeConfig(3))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf(`now deleted  %s (daily #5 -> expired)
+now deleted  %s (daily #4 -> expired)
+Summary: 2 of 6 backups change, 2 newly deleted, 0 newly kept
+`,
+			filepath.Join(dir, "backup-2024-03-11.tar.gz"),
+			filepath.Join(dir, "backup-2024-03-12.tar.gz"),
+		), out)
+	})
+
+	t.Run("nothing to compare", func(t *testing.T) {
+		_, err := run(t, oldConfig)
+		require.ErrorIs(t, err, errNothingToCompare)
+	})
+
+	t.Run("retention flags", func(t *testing.T) {
+		flag := diffPolicyCmd.Flags().Lookup("daily")
+		require.NoError(t, flag.Value.Set("6"))
+		flag.Changed = true
+
+		t.Cleanup(func() {
+			_ = flag.Value.Set("0")
+			flag.Changed = false
+		})
+
+		out, err := run(t, oldConfig)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf(`now kept     %s (expired -> daily #6)
+Summary: 1 of 6 backups change, 0 newly deleted, 1 newly kept
+`, filepath.Join(dir, "backup-2024-03-10.tar.gz")), out)
+	})
+}
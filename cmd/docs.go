@@ -0,0 +1,270 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// manSection is the manual section the generated pages belong to
+const manSection = "1"
+
+var docsManDir string
+
+// docsCmd groups the documentation subcommands
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate documentation",
+}
+
+// docsManCmd represents the docs man command
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate a manpage for every command",
+	Long: `Write a manpage in section 1 for every command, such as
+apply-retention-policy-prune.1, to the directory given with --dir,
+generated from the commands and flags this binary accepts.
+
+The date in the pages is taken from SOURCE_DATE_EPOCH when it is set, so
+packages built from the same source are reproducible.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		date, err := manDate()
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(docsManDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create man directory: %w", err)
+		}
+
+		return writeManTree(cmd.Root(), docsManDir, date)
+	},
+}
+
+// manDate returns the date printed in the manpages
+func manDate() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Now(), nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// writeManTree writes the manpage of cmd and of every command below it to
+// dir
+func writeManTree(cmd *cobra.Command, dir string, date time.Time) error {
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+
+		if err := writeManTree(c, dir, date); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	writeManPage(&buf, cmd, date)
+
+	path := filepath.Join(dir, manName(cmd)+"."+manSection)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write manpage: %w", err)
+	}
+
+	return nil
+}
+
+// manName returns the name of the manpage of cmd, such as
+// apply-retention-policy-history-show
+func manName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+// writeManPage writes the manpage of cmd in roff
+func writeManPage(w io.Writer, cmd *cobra.Command, date time.Time) {
+	_, _ = fmt.Fprintf(w, ".TH %q %s %q %q %q\n",
+		strings.ToUpper(manName(cmd)), manSection, date.Format(time.DateOnly),
+		cmd.Root().Name(), "User Commands")
+
+	_, _ = fmt.Fprintln(w, ".SH NAME")
+	_, _ = fmt.Fprintf(w, "%s \\- %s\n", roffEscape(manName(cmd)), roffEscape(cmd.Short))
+
+	_, _ = fmt.Fprintln(w, ".SH SYNOPSIS")
+	_, _ = fmt.Fprintf(w, ".B %s\n", roffEscape(cmd.UseLine()))
+
+	_, _ = fmt.Fprintln(w, ".SH DESCRIPTION")
+
+	long := cmd.Long
+	if long == "" {
+		long = cmd.Short
+	}
+
+	writeRoffText(w, long)
+
+	if cmd.HasAvailableLocalFlags() {
+		_, _ = fmt.Fprintln(w, ".SH OPTIONS")
+		writeManFlags(w, cmd.NonInheritedFlags())
+	}
+
+	if cmd.HasAvailableInheritedFlags() {
+		_, _ = fmt.Fprintln(w, ".SH OPTIONS INHERITED FROM PARENT COMMANDS")
+		writeManFlags(w, cmd.InheritedFlags())
+	}
+
+	writeSeeAlso(w, cmd)
+}
+
+// writeSeeAlso refers to the manpages of the parent and the subcommands of
+// cmd
+func writeSeeAlso(w io.Writer, cmd *cobra.Command) {
+	var related []string
+
+	if cmd.HasParent() {
+		related = append(related, manName(cmd.Parent()))
+	}
+
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() && !c.IsAdditionalHelpTopicCommand() {
+			related = append(related, manName(c))
+		}
+	}
+
+	if len(related) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, ".SH SEE ALSO")
+
+	for i, name := range related {
+		sep := ","
+		if i == len(related)-1 {
+			sep = ""
+		}
+
+		_, _ = fmt.Fprintf(w, ".BR %s (%s)%s\n", roffEscape(name), manSection, sep)
+	}
+}
+
+// writeManFlags writes a tagged paragraph for every visible flag
+func writeManFlags(w io.Writer, flags *pflag.FlagSet) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+
+		name, usage := pflag.UnquoteUsage(f)
+
+		tag := `\fB\-\-` + roffEscape(f.Name) + `\fR`
+		if f.Shorthand != "" && f.ShorthandDeprecated == "" {
+			tag = `\fB\-` + roffEscape(f.Shorthand) + `\fR, ` + tag
+		}
+
+		if name != "" {
+			tag += ` \fI` + roffEscape(name) + `\fR`
+		}
+
+		if !isZeroDefault(f.DefValue) {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+
+		_, _ = fmt.Fprintln(w, ".TP")
+		_, _ = fmt.Fprintln(w, tag)
+		_, _ = fmt.Fprintln(w, roffLine(usage))
+	})
+}
+
+// isZeroDefault reports whether a flag default is the zero value of its
+// type, which is not worth mentioning
+func isZeroDefault(value string) bool {
+	switch value {
+	case "", "false", "0", "0s", "[]":
+		return true
+	default:
+		return false
+	}
+}
+
+// writeRoffText writes text as roff paragraphs separated by blank lines.
+// Paragraphs starting with an indented line, such as examples, are kept as
+// they are.
+func writeRoffText(w io.Writer, text string) {
+	for paragraph := range strings.SplitSeq(strings.TrimSpace(text), "\n\n") {
+		_, _ = fmt.Fprintln(w, ".PP")
+
+		preformatted := strings.HasPrefix(paragraph, " ")
+		if preformatted {
+			_, _ = fmt.Fprintln(w, ".nf")
+		}
+
+		for line := range strings.SplitSeq(strings.Trim(paragraph, "\n"), "\n") {
+			_, _ = fmt.Fprintln(w, roffLine(line))
+		}
+
+		if preformatted {
+			_, _ = fmt.Fprintln(w, ".fi")
+		}
+	}
+}
+
+// roffLine escapes a line of text, guarding a leading period or quote
+// that roff would read as a request
+func roffLine(line string) string {
+	line = roffEscape(line)
+	if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+		line = `\&` + line
+	}
+
+	return line
+}
+
+// roffEscape escapes the characters roff interprets within text
+func roffEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+	docsCmd.AddCommand(docsManCmd)
+
+	docsManCmd.Flags().
+		StringVar(&docsManDir, "dir", ".", "Directory to write the manpages to")
+}
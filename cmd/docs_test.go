@@ -0,0 +1,114 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsManCommand(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "man1")
+	docsManDir = dir
+
+	t.Cleanup(func() { docsManDir = "." })
+	t.Setenv("SOURCE_DATE_EPOCH", "1710460800")
+
+	require.NoError(t, docsManCmd.RunE(docsManCmd, nil))
+
+	for _, name := range []string{
+		"apply-retention-policy.1",
+		"apply-retention-policy-prune.1",
+		"apply-retention-policy-history-show.1",
+		"apply-retention-policy-docs-man.1",
+	} {
+		require.FileExists(t, filepath.Join(dir, name))
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "apply-retention-policy-prune.1"))
+	require.NoError(t, err)
+	require.Contains(t, string(page),
+		`.TH "APPLY-RETENTION-POLICY-PRUNE" 1 "2024-03-15" "apply-retention-policy"`)
+	require.Contains(t, string(page), "\n.TP\n\\fB\\-d\\fR, \\fB\\-\\-dry\\-run\\fR\n")
+	require.Contains(t, string(page), "\n.BR apply\\-retention\\-policy (1)\n")
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	require.ErrorContains(t, docsManCmd.RunE(docsManCmd, nil), "invalid SOURCE_DATE_EPOCH")
+}
+
+func TestWriteManPage(t *testing.T) {
+	root := &cobra.Command{Use: "tool"}
+	sub := &cobra.Command{
+		Use:   "run",
+		Short: "Run it",
+		Long: `First paragraph with a \backslash
+.starting with a period.
+
+  indented example
+  more example
+
+Last paragraph.`,
+		Run: func(*cobra.Command, []string) {},
+	}
+	sub.Flags().Int("count", 3, "How many")
+	sub.Flags().Bool("quiet", false, "Say less")
+	root.AddCommand(sub)
+
+	var out bytes.Buffer
+	writeManPage(&out, sub, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+
+	require.Equal(t, `.TH "TOOL-RUN" 1 "2024-03-15" "tool" "User Commands"
+.SH NAME
+tool\-run \- Run it
+.SH SYNOPSIS
+.B tool run [flags]
+.SH DESCRIPTION
+.PP
+First paragraph with a \ebackslash
+\&.starting with a period.
+.PP
+.nf
+  indented example
+  more example
+.fi
+.PP
+Last paragraph.
+.SH OPTIONS
+.TP
+\fB\-\-count\fR \fIint\fR
+How many (default 3)
+.TP
+\fB\-\-quiet\fR
+Say less
+.SH SEE ALSO
+.BR tool (1)
+`, out.String())
+}
@@ -0,0 +1,152 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+)
+
+// Process exit codes returned by the CLI
+const (
+	// exitCodeError is used for any failure that has no more specific code
+	exitCodeError = 1
+	// exitCodePartialFailure means some, but not all, deletions failed
+	exitCodePartialFailure = 2
+	// exitCodeTotalFailure means every attempted deletion failed
+	exitCodeTotalFailure = 3
+	// exitCodeWarnings means the run raised warnings as severe as
+	// fail_on_warnings, but nothing failed
+	exitCodeWarnings = 4
+	// exitCodePendingDeletions means a --check run found backups the
+	// policy would delete
+	exitCodePendingDeletions = 5
+	// exitCodeInterrupted means the run was stopped by SIGINT or SIGTERM
+	// and can be continued with --resume
+	exitCodeInterrupted = 130
+)
+
+// Errors describing the outcome of the delete phase of a prune run
+var (
+	errPartialFailure = errors.New("some files could not be deleted")
+	errTotalFailure   = errors.New("no files could be deleted")
+	errInterrupted    = errors.New("prune interrupted")
+	errWarnings       = errors.New("prune raised warnings")
+	errPending        = errors.New("backups are due for deletion")
+)
+
+// exitError wraps an error with the process exit code it should produce
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the process exit code for an error returned by a command
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	return exitCodeError
+}
+
+// deletionError aggregates per-file deletion errors into a single error
+// carrying the partial/total failure exit code. succeeded is the number of
+// files that were deleted before or despite the failures.
+func deletionError(succeeded int, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	code, kind := exitCodePartialFailure, errPartialFailure
+	if succeeded == 0 {
+		code, kind = exitCodeTotalFailure, errTotalFailure
+	}
+
+	return &exitError{
+		code: code,
+		err: fmt.Errorf(
+			"%w (%d failed, %d deleted): %w",
+			kind,
+			len(errs),
+			succeeded,
+			errors.Join(errs...),
+		),
+	}
+}
+
+// warningsError returns an error carrying the warnings exit code when any
+// of warnings is at least as severe as failOn, and nil when failOn is empty
+func warningsError(failOn string, warnings []notify.Warning) error {
+	if failOn == "" {
+		return nil
+	}
+
+	n := 0
+
+	for _, w := range warnings {
+		if file.Severity(w.Severity).AtLeast(file.Severity(failOn)) {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	return &exitError{
+		code: exitCodeWarnings,
+		err:  fmt.Errorf("%w: %d at %s severity or above", errWarnings, n, failOn),
+	}
+}
+
+// pendingError returns an error carrying the pending deletions exit code
+// when a --check run found pending backups that would be deleted
+func pendingError(check bool, pending int) error {
+	if !check || pending == 0 {
+		return nil
+	}
+
+	return &exitError{
+		code: exitCodePendingDeletions,
+		err:  fmt.Errorf("%w: %d would be deleted", errPending, pending),
+	}
+}
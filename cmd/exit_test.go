@@ -0,0 +1,95 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+)
+
+func TestDeletionError(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+
+	t.Run("no errors", func(t *testing.T) {
+		require.NoError(t, deletionError(3, nil))
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		err := deletionError(2, []error{errA})
+		require.ErrorIs(t, err, errPartialFailure)
+		require.ErrorIs(t, err, errA)
+		require.Equal(t, exitCodePartialFailure, exitCode(err))
+	})
+
+	t.Run("total failure", func(t *testing.T) {
+		err := deletionError(0, []error{errA, errB})
+		require.ErrorIs(t, err, errTotalFailure)
+		require.ErrorIs(t, err, errA)
+		require.ErrorIs(t, err, errB)
+		require.Equal(t, exitCodeTotalFailure, exitCode(err))
+	})
+}
+
+func TestWarningsError(t *testing.T) {
+	warnings := []notify.Warning{
+		{Severity: "warning", Message: "/backups/x.tar.gz: timestamp could not be parsed"},
+	}
+
+	require.NoError(t, warningsError("", warnings))
+	require.NoError(t, warningsError(config.FailOnError, warnings))
+	require.NoError(t, warningsError(config.FailOnWarning, nil))
+
+	err := warningsError(config.FailOnWarning, warnings)
+	require.ErrorIs(t, err, errWarnings)
+	require.Equal(t, exitCodeWarnings, exitCode(err))
+
+	warnings = append(warnings, notify.Warning{Severity: "error", Message: "/backups/locked"})
+	require.ErrorIs(t, warningsError(config.FailOnError, warnings), errWarnings)
+}
+
+func TestPendingError(t *testing.T) {
+	require.NoError(t, pendingError(false, 3))
+	require.NoError(t, pendingError(true, 0))
+
+	err := pendingError(true, 3)
+	require.ErrorIs(t, err, errPending)
+	require.EqualError(t, err, "backups are due for deletion: 3 would be deleted")
+	require.Equal(t, exitCodePendingDeletions, exitCode(err))
+}
+
+func TestExitCode(t *testing.T) {
+	require.Equal(t, 0, exitCode(nil))
+	require.Equal(t, exitCodeError, exitCode(errors.New("plain")))
+
+	wrapped := fmt.Errorf("context: %w", &exitError{code: 42, err: errors.New("x")})
+	require.Equal(t, 42, exitCode(wrapped))
+}
@@ -0,0 +1,191 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// errGapsFound is returned by gaps when a period is missing its backup
+var errGapsFound = errors.New("missing backups found")
+
+// gapsCmd represents the gaps command
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "Report periods the retention policy expected a backup for but has none",
+	Long: `Apply the retention policy to the current backups without deleting
+anything, and report every period within the reach of a tier that holds no
+kept backup: a day among the last "daily" days, a month among the last
+"monthly" months, and so on, counted back from the newest backup of each
+tag. The tiers themselves reach past such periods to older backups, so
+without this report a backup job that silently failed now and then goes
+unnoticed.
+
+Exits with a non-zero status if any gap is found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		directories, err := expandDirectories(cfg)
+		if err != nil {
+			return err
+		}
+
+		found := 0
+
+		for i, directory := range directories {
+			gaps, err := findGaps(ctx, cfg, directory)
+			if err != nil {
+				return err
+			}
+
+			if i > 0 {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout())
+			}
+
+			writeGaps(cmd.OutOrStdout(), directory, gaps)
+
+			found += len(gaps)
+		}
+
+		if found > 0 {
+			return fmt.Errorf("%w: %d gaps", errGapsFound, found)
+		}
+
+		return nil
+	},
+}
+
+// findGaps applies the retention policy to the backups in a directory and
+// returns the gaps in what it keeps
+func findGaps(
+	ctx context.Context,
+	cfg *config.Config,
+	directory string,
+) ([]retention.Gap, error) {
+	policy, result, err := applyPolicy(ctx, cfg, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.Gaps(result), nil
+}
+
+// applyPolicy lists the backups in a directory and applies the retention
+// policy to them without deleting anything
+func applyPolicy(
+	ctx context.Context,
+	cfg *config.Config,
+	directory string,
+) (*retention.Policy, *retention.Result, error) {
+	log := &logging.Logger{Logger: zap.NewNop()}
+	cfg = cfg.ForDirectory(directory)
+
+	store, err := backend.New(cfg, directory, log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize backend: %w", err)
+	}
+	defer func() { _ = backend.Close(store) }()
+
+	files, err := store.ListFiles(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	policy := retention.NewPolicy(log, cfg)
+
+	result, err := policy.Apply(files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to apply retention policy: %w", err)
+	}
+
+	return policy, result, nil
+}
+
+// writeGaps prints the gaps found in a directory, one per line
+func writeGaps(w io.Writer, directory string, gaps []retention.Gap) {
+	_, _ = fmt.Fprintf(w, "Directory: %s\n", directory)
+
+	for _, gap := range gaps {
+		line := fmt.Sprintf("  %-8s %s", gap.Tier, periodLabel(gap.Tier, gap.Start))
+		if gap.Tag != "" {
+			line += " (tag " + gap.Tag + ")"
+		}
+
+		_, _ = fmt.Fprintln(w, line)
+	}
+
+	_, _ = fmt.Fprintf(w, "Gaps:      %d\n", len(gaps))
+}
+
+// periodLabel names the period of a tier starting at start, such as
+// 2024-03 for a month, 2024-W11 for an ISO week or 2024/25 for a fiscal year
+func periodLabel(tier retention.Reason, start time.Time) string {
+	switch tier {
+	case retention.ReasonHourly:
+		return start.Format("2006-01-02 15:00")
+	case retention.ReasonWeekly:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case retention.ReasonMonthly:
+		return start.Format("2006-01")
+	case retention.ReasonYearly:
+		if start.Month() != time.January {
+			return fmt.Sprintf("%d/%02d", start.Year(), (start.Year()+1)%100)
+		}
+
+		return start.Format("2006")
+	default:
+		return start.Format(time.DateOnly)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(gapsCmd)
+
+	gapsCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+}
@@ -0,0 +1,75 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGapsCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-01-15.tar.gz",
+		"backup-2024-03-11.tar.gz",
+		"backup-2024-03-13.tar.gz",
+		"backup-2024-03-14.tar.gz",
+		"backup-2024-03-15.tar.gz",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 5
+  monthly: 3
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := gapsCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+
+	err := cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errGapsFound)
+	require.Equal(t, "Directory: "+dir+`
+  daily    2024-03-12
+  monthly  2024-02
+Gaps:      2
+`, out.String())
+}
@@ -0,0 +1,281 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/catalog"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
+)
+
+// checksumWidth is how many characters of a checksum history prints
+const checksumWidth = 12
+
+var (
+	// errNoCatalog is returned by history when no catalog is configured
+	errNoCatalog = errors.New("no catalog is configured")
+	// errRunNotFound is returned by history show for an unknown run ID
+	errRunNotFound = errors.New("run not found")
+)
+
+var historyLimit int
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past prune runs recorded in the catalog",
+	Long: `List the prune runs recorded in the catalog configured with the catalog key,
+oldest first, with when each run started, how many backups it matched and
+deleted, the space it reclaimed and how many errors it hit.
+
+Use "history show <run-id>" to see exactly which backups a run deleted and
+"history backups" to list every backup the catalog has observed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cat, err := loadCatalog()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		writeRuns(w, newest(cat.Runs(), historyLimit))
+
+		return w.Flush()
+	},
+}
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show the outcome of a prune run and every backup it deleted",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: %s", errRunNotFound, args[0])
+		}
+
+		cat, err := loadCatalog()
+		if err != nil {
+			return err
+		}
+
+		run, ok := cat.Run(id)
+		if !ok {
+			return fmt.Errorf("%w: %d", errRunNotFound, id)
+		}
+
+		events := slices.DeleteFunc(cat.History(), func(e catalog.Event) bool {
+			return e.Run != id
+		})
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		writeRun(w, run, events)
+
+		return w.Flush()
+	},
+}
+
+// historyBackupsCmd represents the history backups command
+var historyBackupsCmd = &cobra.Command{
+	Use:   "backups [pattern...]",
+	Short: "List every backup the catalog has observed",
+	Long: `List every backup the catalog has observed, oldest first, with its size,
+checksum and whether it has since been deleted.
+
+Arguments are glob patterns matched against backup paths; only backups
+matching one of them are shown.`,
+	RunE: func(cmd *cobra.Command, patterns []string) error {
+		cat, err := loadCatalog()
+		if err != nil {
+			return err
+		}
+
+		backups := slices.DeleteFunc(cat.Backups(), func(b catalog.Backup) bool {
+			return !matchesAny(patterns, b.Path)
+		})
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		writeBackups(w, newest(backups, historyLimit))
+
+		return w.Flush()
+	},
+}
+
+// loadCatalog opens the catalog named by the config file
+func loadCatalog() (*catalog.Catalog, error) {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.Catalog == "" {
+		return nil, errNoCatalog
+	}
+
+	cat, err := catalog.Open(cfg.Catalog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog: %w", err)
+	}
+
+	return cat, nil
+}
+
+// matchesAny reports whether path matches one of the glob patterns. Every
+// path matches when there are no patterns.
+func matchesAny(patterns []string, path string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, err := filepath.Match(pattern, path)
+		return err == nil && matched
+	})
+}
+
+// newest returns the last limit entries of a list sorted oldest first, or
+// every entry when limit is not positive
+func newest[T any](entries []T, limit int) []T {
+	if limit <= 0 || limit >= len(entries) {
+		return entries
+	}
+
+	return entries[len(entries)-limit:]
+}
+
+// writeRuns prints one line per recorded run
+func writeRuns(w io.Writer, runs []catalog.Run) {
+	for _, r := range runs {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%d matched\t%d deleted\t%s reclaimed\t%d errors",
+			r.ID, r.Started.Format(time.RFC3339), r.Matched, r.Deleted,
+			units.FormatBytes(r.ReclaimedBytes), len(r.Errors))
+
+		switch {
+		case r.Finished.IsZero():
+			_, _ = fmt.Fprint(w, "\t(interrupted)")
+		case r.DryRun:
+			_, _ = fmt.Fprint(w, "\t(dry run)")
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// writeRun prints the outcome of a single run followed by its decisions
+func writeRun(w io.Writer, r catalog.Run, events []catalog.Event) {
+	_, _ = fmt.Fprintf(w, "Run:\t%d\n", r.ID)
+	_, _ = fmt.Fprintf(w, "Started:\t%s\n", r.Started.Format(time.RFC3339))
+
+	if !r.Finished.IsZero() {
+		_, _ = fmt.Fprintf(w, "Finished:\t%s\n", r.Finished.Format(time.RFC3339))
+	}
+
+	_, _ = fmt.Fprintf(w, "Directory:\t%s\n", r.Directory)
+	_, _ = fmt.Fprintf(w, "Dry run:\t%t\n", r.DryRun)
+	_, _ = fmt.Fprintf(w, "Matched:\t%d\n", r.Matched)
+	_, _ = fmt.Fprintf(w, "Deleted:\t%d (%s reclaimed)\n",
+		r.Deleted, units.FormatBytes(r.ReclaimedBytes))
+
+	for _, msg := range r.Errors {
+		_, _ = fmt.Fprintf(w, "Error:\t%s\n", msg)
+	}
+
+	if len(events) > 0 {
+		_, _ = fmt.Fprintln(w)
+		writeEvents(w, events)
+	}
+}
+
+// writeEvents prints one line per recorded deletion decision
+func writeEvents(w io.Writer, events []catalog.Event) {
+	for _, e := range events {
+		action := "deleted"
+
+		switch {
+		case e.Error != "":
+			action = "failed"
+		case e.DryRun:
+			action = "would delete"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s",
+			e.Time.Format(time.RFC3339), action, e.Reason, e.Path)
+
+		if e.Checksum != "" {
+			_, _ = fmt.Fprintf(w, " sha256:%s", e.Checksum)
+		}
+
+		if e.Error != "" {
+			_, _ = fmt.Fprintf(w, " (%s)", e.Error)
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// writeBackups prints one line per observed backup
+func writeBackups(w io.Writer, backups []catalog.Backup) {
+	for _, b := range backups {
+		status := "present"
+		if !b.DeletedAt.IsZero() {
+			status = "deleted " + b.DeletedAt.Format(time.RFC3339)
+		}
+
+		checksum := "-"
+		if b.Checksum != "" {
+			checksum = b.Checksum[:min(checksumWidth, len(b.Checksum))]
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			b.Timestamp.Format(time.RFC3339), units.FormatBytes(b.Size), checksum,
+			b.Path, status)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyShowCmd, historyBackupsCmd)
+
+	for _, cmd := range []*cobra.Command{historyCmd, historyShowCmd, historyBackupsCmd} {
+		cmd.Flags().
+			StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	}
+
+	historyCmd.Flags().
+		IntVarP(&historyLimit, "limit", "n", 0, "Only show the newest N runs")
+	historyBackupsCmd.Flags().
+		IntVarP(&historyLimit, "limit", "n", 0, "Only show the newest N backups")
+}
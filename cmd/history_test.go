@@ -0,0 +1,156 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-13-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	catalogFile := filepath.Join(t.TempDir(), "catalog.json")
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+catalog: "` + filepath.ToSlash(catalogFile) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	// A dry run followed by a real run
+	for _, dryRun := range []bool{true, false} {
+		viper.Reset()
+		viper.Set("dry_run", dryRun)
+
+		prune := pruneCmd
+		prune.SetOut(&bytes.Buffer{})
+		require.NoError(t, prune.Flags().Set("config", configFile))
+		require.NoError(t, prune.RunE(prune, nil))
+	}
+
+	run := func(t *testing.T, cmd *cobra.Command, limit int, args ...string) []string {
+		t.Helper()
+
+		viper.Reset()
+
+		historyLimit = limit
+
+		t.Cleanup(func() {
+			historyLimit = 0
+		})
+
+		var out bytes.Buffer
+
+		cmd.SetOut(&out)
+		require.NoError(t, cmd.Flags().Set("config", configFile))
+		require.NoError(t, cmd.RunE(cmd, args))
+
+		return strings.Split(strings.TrimSpace(out.String()), "\n")
+	}
+
+	t.Run("runs", func(t *testing.T) {
+		lines := run(t, historyCmd, 0)
+		require.Len(t, lines, 2)
+		require.Regexp(t, `^1 .* 3 matched +2 deleted .* \(dry run\)$`, lines[0])
+		require.Regexp(t, `^2 .* 3 matched +2 deleted .* 0 errors$`, lines[1])
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		lines := run(t, historyCmd, 1)
+		require.Len(t, lines, 1)
+		require.True(t, strings.HasPrefix(lines[0], "2 "))
+	})
+
+	t.Run("show", func(t *testing.T) {
+		out := strings.Join(run(t, historyShowCmd, 0, "2"), "\n")
+		require.Contains(t, out, "Dry run:    false")
+		require.Contains(t, out, "Deleted:    2 (60 B reclaimed)")
+		require.Contains(t, out, "deleted  expired  "+
+			filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"))
+		require.Contains(t, out, "deleted  expired  "+
+			filepath.Join(dir, "backup-2024-03-13-12-00.tar.gz"))
+		require.NotContains(t, out, "would delete")
+	})
+
+	t.Run("show dry run", func(t *testing.T) {
+		out := strings.Join(run(t, historyShowCmd, 0, "1"), "\n")
+		require.Contains(t, out, "would delete")
+	})
+
+	t.Run("unknown run", func(t *testing.T) {
+		viper.Reset()
+
+		cmd := historyShowCmd
+		require.NoError(t, cmd.Flags().Set("config", configFile))
+		require.ErrorIs(t, cmd.RunE(cmd, []string{"3"}), errRunNotFound)
+		require.ErrorIs(t, cmd.RunE(cmd, []string{"latest"}), errRunNotFound)
+	})
+
+	t.Run("backups", func(t *testing.T) {
+		lines := run(t, historyBackupsCmd, 0)
+		require.Len(t, lines, 3)
+		require.Contains(t, lines[0], "backup-2024-03-13-12-00.tar.gz")
+		require.Contains(t, lines[0], "deleted")
+		require.Contains(t, lines[2], "backup-2024-03-15-12-00.tar.gz")
+		require.Contains(t, lines[2], "present")
+	})
+
+	t.Run("backups pattern", func(t *testing.T) {
+		lines := run(t, historyBackupsCmd, 0, filepath.Join(dir, "*-03-13-*"))
+		require.Len(t, lines, 1)
+		require.Contains(t, lines[0], "backup-2024-03-13-12-00.tar.gz")
+	})
+
+	t.Run("no catalog", func(t *testing.T) {
+		viper.Reset()
+
+		other := filepath.Join(t.TempDir(), "retention-policy.yaml")
+		content := strings.ReplaceAll(configContent, "catalog:", "# catalog:")
+		require.NoError(t, os.WriteFile(other, []byte(content), 0o600))
+
+		cmd := historyCmd
+		require.NoError(t, cmd.Flags().Set("config", other))
+		require.ErrorIs(t, cmd.RunE(cmd, nil), errNoCatalog)
+	})
+}
@@ -0,0 +1,164 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/catalog"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/journal"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// errCrashedDeletion is recorded in the catalog for a deletion a crashed
+// run started whose backup still exists
+var errCrashedDeletion = errors.New("deletion interrupted by a crash")
+
+// openJournal opens the journal at path for a prune. The deletions a
+// crashed run left in flight are reported as warnings and recorded in the
+// catalog, if one is kept, first.
+func openJournal(
+	log *logging.Logger,
+	path string,
+	cat *catalog.Catalog,
+	rep *reporter,
+	summary *notify.Summary,
+) (*journal.Journal, error) {
+	pending, err := journal.Pending(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay journal: %w", err)
+	}
+
+	for _, e := range pending {
+		replayDeletion(log, cat, rep, summary, e)
+	}
+
+	jrn, err := journal.Open(path, journal.WithRunID(rep.runID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	return jrn, nil
+}
+
+// replayDeletion reports a deletion a crashed run left in flight, and
+// records it in the catalog, if one is kept, as made when it started. It
+// succeeded if the backup no longer exists.
+func replayDeletion(
+	log *logging.Logger,
+	cat *catalog.Catalog,
+	rep *reporter,
+	summary *notify.Summary,
+	e journal.Entry,
+) {
+	_, err := os.Lstat(e.Path)
+	deleted := errors.Is(err, fs.ErrNotExist)
+
+	log.Warn("found deletion interrupted by a crash",
+		zap.String("file", e.Path),
+		zap.String("crashed_run_id", e.RunID),
+		zap.Time("started", e.Time),
+		zap.Bool("deleted", deleted))
+
+	message := "deletion interrupted by a crash, backup still exists"
+	if deleted {
+		message = "deletion interrupted by a crash, backup is gone"
+	}
+
+	w := file.Warning{Severity: file.SeverityWarning, Path: e.Path, Message: message}
+	warning := notify.Warning{Severity: string(w.Severity), Message: w.String()}
+	summary.Warnings = append(summary.Warnings, warning)
+	rep.warn(warning)
+
+	if cat == nil {
+		return
+	}
+
+	var recorded error
+	if !deleted {
+		recorded = errCrashedDeletion
+	}
+
+	cat.RecordAt(e.Decision(), e.Time, false, recorded)
+}
+
+// journalIntent records in the journal, if one is kept, that the deletion
+// of a backup is about to start
+func journalIntent(jrn *journal.Journal, decision retention.Decision) error {
+	if jrn == nil {
+		return nil
+	}
+
+	return jrn.Intent(decision)
+}
+
+// journalDone records in the journal, if one is kept, that the deletion of a
+// backup finished with err. A failure to do so is only logged, since the
+// deletion has already happened; the next run reports it as in flight.
+func journalDone(
+	log *logging.Logger,
+	jrn *journal.Journal,
+	decision retention.Decision,
+	err error,
+) {
+	if jrn == nil {
+		return
+	}
+
+	if err := jrn.Done(decision, err); err != nil {
+		log.Warn("failed to write journal",
+			zap.String("file", decision.File.Path),
+			zap.Error(err))
+	}
+}
+
+// finishRun saves the catalog of a run and then closes its journal,
+// clearing it once the catalog holds the outcome of every deletion in it.
+// The errors doing so are joined with err.
+func finishRun(
+	cat *catalog.Catalog,
+	jrn *journal.Journal,
+	summary notify.Summary,
+	err error,
+) error {
+	saved := saveCatalog(cat, summary, err)
+	if jrn == nil {
+		return errors.Join(err, saved)
+	}
+
+	if saved != nil {
+		return errors.Join(err, saved, jrn.Close())
+	}
+
+	return errors.Join(err, jrn.Clear())
+}
@@ -0,0 +1,196 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/catalog"
+)
+
+func TestPruneCommandJournal(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	interrupted := filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz")
+	gone := filepath.Join(dir, "backup-2024-03-13-12-00.tar.gz")
+
+	// A crashed run that had finished one deletion and started two more
+	journalFile := filepath.Join(state, "journal.jsonl")
+	err := os.WriteFile(journalFile, []byte(
+		`{"op":"intent","time":"2024-03-16T01:00:00Z","path":"`+filepath.ToSlash(gone)+
+			`","reason":"expired","tier":"expired"}`+"\n"+
+			`{"op":"done","time":"2024-03-16T01:00:01Z","path":"`+filepath.ToSlash(gone)+`"}`+
+			"\n"+
+			`{"op":"intent","time":"2024-03-16T01:00:02Z","path":"`+filepath.ToSlash(gone)+
+			`","reason":"expired","tier":"expired"}`+"\n"+
+			`{"op":"intent","time":"2024-03-16T01:00:03Z","path":"`+
+			filepath.ToSlash(interrupted)+`","reason":"expired","tier":"expired"}`+"\n",
+	), 0o600)
+	require.NoError(t, err)
+
+	catalogFile := filepath.Join(state, "catalog.json")
+
+	// The replayed deletions keep their 2024 times, which the default
+	// retention of the catalog would compact away
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+catalog: "` + filepath.ToSlash(catalogFile) + `"
+catalog_retention: 876000h
+journal: "` + filepath.ToSlash(journalFile) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err = os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "deletion interrupted by a crash, backup is gone")
+	require.Contains(t, out.String(), "deletion interrupted by a crash, backup still exists")
+	require.NoFileExists(t, interrupted)
+
+	// The run finished, so nothing is left in flight
+	data, err := os.ReadFile(journalFile)
+	require.NoError(t, err)
+	require.Empty(t, data)
+
+	cat, err := catalog.Open(catalogFile)
+	require.NoError(t, err)
+
+	history := cat.History()
+	require.Len(t, history, 3)
+
+	require.Equal(t, filepath.ToSlash(gone), history[0].Path)
+	require.Equal(t, time.Date(2024, 3, 16, 1, 0, 2, 0, time.UTC), history[0].Time)
+	require.Empty(t, history[0].Error)
+
+	require.Equal(t, filepath.ToSlash(interrupted), history[1].Path)
+	require.Equal(t, errCrashedDeletion.Error(), history[1].Error)
+
+	// Deleted by this run after all
+	require.Equal(t, interrupted, history[2].Path)
+	require.Empty(t, history[2].Error)
+}
+
+func TestPruneCommandChecksumDeletions(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	sum := sha256.Sum256([]byte("backup-2024-03-14-12-00.tar.gz"))
+	checksum := hex.EncodeToString(sum[:])
+
+	catalogFile := filepath.Join(state, "catalog.json")
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+catalog: "` + filepath.ToSlash(catalogFile) + `"
+journal: "` + filepath.ToSlash(filepath.Join(state, "journal.jsonl")) + `"
+checksum_deletions: true
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("summary-file", summaryFile))
+
+	t.Cleanup(func() {
+		require.NoError(t, cmd.Flags().Set("summary-file", ""))
+	})
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	var summary runSummary
+
+	data, err := os.ReadFile(summaryFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &summary))
+	require.Contains(t, summary.Files, fileRecord{
+		Path:     filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"),
+		Action:   "delete",
+		Reason:   "expired",
+		Checksum: checksum,
+	})
+
+	cat, err := catalog.Open(catalogFile)
+	require.NoError(t, err)
+
+	history := cat.History()
+	require.Len(t, history, 1)
+	require.Equal(t, checksum, history[0].Checksum)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	show := historyShowCmd
+	show.SetOut(&out)
+	require.NoError(t, show.Flags().Set("config", configFile))
+	require.NoError(t, show.RunE(show, []string{"1"}))
+	require.Contains(t, out.String(), "sha256:"+checksum)
+}
@@ -0,0 +1,143 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// errNoBackupFound is returned by latest when no file matches
+var errNoBackupFound = errors.New("no matching backup found")
+
+var (
+	latestTag    string
+	latestCopyTo string
+)
+
+// latestCmd represents the latest command
+var latestCmd = &cobra.Command{
+	Use:   "latest",
+	Short: "Print or copy the newest backup matching the pattern",
+	Long: `Print the path of the newest backup matching the configured file pattern,
+optionally restricted to a single {tag}. With --copy-to the backup is copied
+to the given file or directory and the path of the copy is printed instead.
+
+This lets restore scripts reuse the same pattern parsing as prune instead of
+re-implementing it in shell.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		directories, err := file.ExpandDirectories(cfg.Directories)
+		if err != nil {
+			return fmt.Errorf("failed to expand directories: %w", err)
+		}
+
+		var files []file.Info
+
+		log := &logging.Logger{Logger: zap.NewNop()}
+
+		for _, directory := range directories {
+			store, err := backend.New(cfg, directory, log)
+			if err != nil {
+				return fmt.Errorf("failed to initialize backend: %w", err)
+			}
+
+			dirFiles, err := store.ListFiles(ctx)
+			err = errors.Join(err, backend.Close(store))
+
+			if err != nil {
+				return fmt.Errorf("failed to list files: %w", err)
+			}
+
+			files = append(files, dirFiles...)
+		}
+
+		// Keep the files sorted oldest first across all directories
+		slices.SortStableFunc(files, func(a, b file.Info) int {
+			return a.Timestamp.Compare(b.Timestamp)
+		})
+
+		latest, ok := newestFile(files, latestTag)
+		if !ok {
+			return errNoBackupFound
+		}
+
+		path := latest.Path
+
+		if latestCopyTo != "" {
+			path, err = file.CopyFile(latest.Path, latestCopyTo)
+			if err != nil {
+				return fmt.Errorf("failed to copy backup: %w", err)
+			}
+		}
+
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), path)
+
+		return err
+	},
+}
+
+// newestFile returns the newest file, optionally restricted to a tag
+func newestFile(files []file.Info, tag string) (file.Info, bool) {
+	// Files are sorted oldest first
+	for i := len(files) - 1; i >= 0; i-- {
+		if tag == "" || files[i].Tag == tag {
+			return files[i], true
+		}
+	}
+
+	return file.Info{}, false
+}
+
+func init() {
+	rootCmd.AddCommand(latestCmd)
+
+	latestCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	latestCmd.Flags().
+		StringVar(&latestTag, "tag", "", "Only consider backups with this {tag} value")
+	latestCmd.Flags().
+		StringVar(&latestCopyTo, "copy-to", "", "Copy the backup to this file or directory")
+}
@@ -0,0 +1,104 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{
+		"nightly-2024-03-14.tar.gz",
+		"nightly-2024-03-15.tar.gz",
+		"weekly-2024-03-10.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `file_pattern: "{tag}-{year}-{month}-{day}.tar.gz"
+directory: "` + filepath.ToSlash(tmpDir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	run := func(t *testing.T, tag, copyTo string) (string, error) {
+		viper.Reset()
+
+		latestTag, latestCopyTo = tag, copyTo
+
+		t.Cleanup(func() {
+			latestTag, latestCopyTo = "", ""
+		})
+
+		var out bytes.Buffer
+
+		cmd := latestCmd
+		cmd.SetOut(&out)
+		require.NoError(t, cmd.Flags().Set("config", configFile))
+		err := cmd.RunE(cmd, nil)
+
+		return strings.TrimSpace(out.String()), err
+	}
+
+	t.Run("newest overall", func(t *testing.T) {
+		path, err := run(t, "", "")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(tmpDir, "nightly-2024-03-15.tar.gz"), path)
+	})
+
+	t.Run("filtered by tag", func(t *testing.T) {
+		path, err := run(t, "weekly", "")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(tmpDir, "weekly-2024-03-10.tar.gz"), path)
+	})
+
+	t.Run("unknown tag", func(t *testing.T) {
+		_, err := run(t, "monthly", "")
+		require.ErrorIs(t, err, errNoBackupFound)
+	})
+
+	t.Run("copy to directory", func(t *testing.T) {
+		target := t.TempDir()
+
+		path, err := run(t, "", target)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(target, "nightly-2024-03-15.tar.gz"), path)
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "nightly-2024-03-15.tar.gz", string(content))
+	})
+}
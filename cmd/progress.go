@@ -0,0 +1,225 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
+)
+
+// Intervals at which progress is redrawn on a terminal and logged otherwise
+const (
+	progressRedraw = 200 * time.Millisecond
+	progressLog    = 10 * time.Second
+)
+
+// progress tracks how far a prune run has got. On a terminal a status line
+// is redrawn in place; otherwise a progress line is logged periodically.
+// All methods are safe to call on a nil progress, which tracks nothing.
+type progress struct {
+	mu        sync.Mutex
+	w         io.Writer
+	log       *logging.Logger
+	tty       bool
+	started   time.Time
+	total     int
+	scanned   int
+	deleted   int
+	reclaimed int64
+	drawn     bool
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+// newProgress creates a progress display drawn on w when it is a terminal
+// and logged to log otherwise
+func newProgress(w io.Writer, log *logging.Logger) *progress {
+	return &progress{w: w, log: log, tty: isTTY(w)}
+}
+
+// start begins displaying progress towards total backups, or without an
+// ETA when total is zero, until finish is called
+func (p *progress) start(ctx context.Context, total int) {
+	if p == nil {
+		return
+	}
+
+	p.started, p.total = time.Now(), total
+	p.done = make(chan struct{})
+
+	ctx, p.stop = context.WithCancel(ctx)
+
+	interval := progressLog
+	if p.tty {
+		interval = progressRedraw
+	}
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.update()
+			}
+		}
+	}()
+}
+
+// finish stops updating and removes the status line
+func (p *progress) finish() {
+	if p == nil || p.stop == nil {
+		return
+	}
+
+	p.stop()
+	<-p.done
+	p.clear()
+}
+
+// observe counts a decided backup and, if it was deleted, the bytes it freed
+func (p *progress) observe(deleted bool, size int64) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.scanned++
+
+	if deleted {
+		p.deleted++
+		p.reclaimed += size
+	}
+}
+
+// clear erases the status line so other output can be written in its place;
+// it is redrawn on the next update
+func (p *progress) clear() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn {
+		_, _ = io.WriteString(p.w, "\r\x1b[K")
+		p.drawn = false
+	}
+}
+
+// update redraws the status line or logs the current progress
+func (p *progress) update() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	eta, known := p.eta()
+
+	if !p.tty {
+		fields := []zap.Field{
+			zap.Int("scanned", p.scanned),
+			zap.Int("deleted", p.deleted),
+			zap.Int64("reclaimed_bytes", p.reclaimed),
+		}
+
+		if known {
+			fields = append(fields, zap.Int("total", p.total), zap.Duration("eta", eta))
+		}
+
+		p.log.Info("progress", fields...)
+
+		return
+	}
+
+	scanned := fmt.Sprint(p.scanned)
+	if p.total > 0 {
+		scanned = fmt.Sprintf("%d/%d", p.scanned, p.total)
+	}
+
+	line := fmt.Sprintf("\r\x1b[KScanned %s, deleted %d, reclaimed %s",
+		scanned, p.deleted, units.FormatBytes(p.reclaimed))
+	if known {
+		line += ", ETA " + eta.Round(time.Second).String()
+	}
+
+	_, _ = io.WriteString(p.w, line)
+	p.drawn = true
+}
+
+// eta estimates the time left from the rate backups were decided at so far
+func (p *progress) eta() (time.Duration, bool) {
+	if p.total == 0 || p.scanned == 0 || p.scanned > p.total {
+		return 0, false
+	}
+
+	elapsed := time.Since(p.started)
+
+	return elapsed / time.Duration(p.scanned) * time.Duration(p.total-p.scanned), true
+}
+
+// countBackups counts the backups in every directory, so progress can show
+// an ETA. Directories that cannot be listed are not counted; the prune
+// reports their errors.
+func countBackups(
+	ctx context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	directories []string,
+) int {
+	total := 0
+
+	for _, directory := range directories {
+		store, err := backend.New(cfg, directory, log)
+		if err != nil {
+			continue
+		}
+
+		_ = store.WalkFiles(ctx, func(file.Info) error {
+			total++
+			return nil
+		})
+		_ = backend.Close(store)
+	}
+
+	return total
+}
@@ -0,0 +1,91 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+
+	p := newProgress(&buf, logging.NewDefault())
+	require.False(t, p.tty)
+
+	// Draw as on a terminal
+	p.tty = true
+	p.started, p.total = time.Now().Add(-10*time.Second), 4
+
+	rep := newReporter(&buf, false, false)
+	rep.progress = p
+
+	rep.keep(retention.Decision{File: file.Info{Path: "a.tar.gz"}})
+	rep.remove(retention.Decision{File: file.Info{Path: "b.tar.gz", Size: 2048}}, false)
+
+	eta, ok := p.eta()
+	require.True(t, ok)
+	require.InDelta(t, 10*time.Second, eta, float64(time.Second))
+
+	p.update()
+	require.Contains(t, buf.String(),
+		"\r\x1b[KScanned 2/4, deleted 1, reclaimed 2.0 KiB, ETA 10s")
+
+	// The status line is erased before the next decision is printed
+	buf.Reset()
+	rep.keep(retention.Decision{File: file.Info{Path: "c.tar.gz"}})
+	require.Equal(t, "\r\x1b[Kkeep         c.tar.gz\n", buf.String())
+
+	t.Run("unknown total", func(t *testing.T) {
+		p := newProgress(&buf, logging.NewDefault())
+		p.start(t.Context(), 0)
+		p.observe(false, 0)
+
+		_, ok := p.eta()
+		require.False(t, ok)
+
+		p.finish()
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var p *progress
+
+		p.start(t.Context(), 1)
+		p.observe(true, 1)
+		p.clear()
+		p.finish()
+
+		rep := newReporter(&buf, true, false)
+		rep.footer(notify.Summary{})
+	})
+}
@@ -26,90 +26,1245 @@
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/catalog"
 	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
 	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/hooks"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/journal"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/repository"
 	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/systemd"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
 	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
 	"github.com/TotallyNotRobots/apply-retention-policy/pkg/must"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
 )
 
+var (
+	pruneQuiet       bool
+	pruneVerbose     bool
+	pruneProgress    bool
+	pruneResume      bool
+	pruneSummaryFile string
+	pruneCheck       bool
+)
+
+// errAbortPrune stops streaming a directory after a failed deletion in
+// fail-fast mode
+var errAbortPrune = errors.New("prune aborted")
+
+// errNotMounted is returned when require_mountpoint is set and a directory
+// is not the mountpoint of a filesystem of the expected type
+var errNotMounted = errors.New("directory is not mounted")
+
+// errMissingSentinel is returned when a directory lacks the sentinel_file
+// marking it as a backup directory
+var errMissingSentinel = errors.New("sentinel file not found")
+
+// errOverlappingSubdirectories is returned when the subdirectories rules
+// select a directory inside another selected one
+var errOverlappingSubdirectories = errors.New("overlapping subdirectories")
+
 // pruneCmd represents the prune command
 var pruneCmd = &cobra.Command{
 	Use:   "prune",
 	Short: "Apply retention policy to backup files",
 	Long: `Apply retention policy to backup files based on the configured policy.
 The policy specifies how many hourly, daily, weekly, monthly, and yearly backups to retain.
-Files that don't meet the retention policy will be deleted.`,
+Files that don't meet the retention policy will be deleted.
+
+If any deletion fails the command exits with status 2 when some files were
+deleted and status 3 when none were. With --fail-fast the run stops at the
+first failed deletion. --check makes the run a dry run that exits with
+status 5 when any backup would be deleted, for monitoring a retention
+backlog.
+
+Shell commands configured under hooks.pre_run, hooks.post_run and
+hooks.on_error run before the prune, after a successful prune and after a
+failed prune. They receive the run summary in ARP_* environment variables.
+A failing pre_run hook aborts the prune. hooks.pre_delete runs once per file
+before it is deleted and receives ARP_PATH, ARP_TIMESTAMP, ARP_SIZE and
+ARP_TAG; a non-zero exit keeps that file and counts as a failed deletion.
+
+With --progress the backups are counted first, then the number decided and
+deleted so far, the bytes reclaimed and an ETA are shown on a status line
+when stderr is a terminal, or logged every 10 seconds otherwise.
+
+SIGINT or SIGTERM stops the run after the current deletion and records the
+directories not yet finished in a checkpoint (the checkpoint key, by default
+in the user's cache directory); the command then exits with status 130.
+Run prune again with --resume to continue with those directories only.
+
+With --summary-file a JSON summary of the run, with its counts, errors,
+duration and exit code, is written to a path once it finishes, even when it
+fails, for CronJobs and CI steps to pick up. With summary_signing_key set,
+gpg signs it into a detached signature next to it, ending in .asc.`,
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		// Create context
-		ctx := cmd.Context()
+		started := time.Now()
+
+		rep := newReporter(cmd.OutOrStdout(), pruneQuiet, pruneVerbose)
+		rep.record = pruneSummaryFile != ""
+
+		summary, err := runPrune(cmd, rep)
+		summary.RunID = rep.runID
+
+		if pruneSummaryFile != "" {
+			writeErr := writeSummaryFile(pruneSummaryFile, summary, rep.files, started, err)
+			if writeErr == nil && rep.signingKey != "" {
+				writeErr = signSummaryFile(pruneSummaryFile, rep.signingKey)
+			}
 
-		if ctx == nil {
-			ctx = context.Background()
+			if writeErr != nil {
+				return errors.Join(err, writeErr)
+			}
 		}
 
-		// Load configuration
-		cfg, err := config.LoadConfig(cfgFile)
+		return err
+	},
+}
+
+// runPrune loads the configuration and prunes every configured directory,
+// running hooks and sending notifications around it. Every decision is
+// reported to rep.
+func runPrune(cmd *cobra.Command, rep *reporter) (notify.Summary, error) {
+	// Create context
+	ctx := cmd.Context()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return notify.Summary{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if pruneQuiet {
+		cfg.LogLevel = "error"
+	}
+
+	if pruneCheck {
+		cfg.DryRun = true
+	}
+
+	rep.signingKey = cfg.SummarySigningKey
+
+	// Initialize logger
+	log, err := logging.New(cfg.LogLevel, loggerOptions(cfg)...)
+	if err != nil {
+		return notify.Summary{}, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer log.SyncQuietly()
+
+	log = log.With(zap.String("run_id", rep.runID))
+
+	switch {
+	case pruneProgress:
+		rep.progress = newProgress(cmd.ErrOrStderr(), log)
+	case cfg.LogDecisions == config.LogDecisionsSummary:
+		// Progress replaces the lines per backup in the log, and is never
+		// drawn
+		rep.progress = newProgress(io.Discard, log)
+	}
+
+	var summary notify.Summary
+
+	done := notifySystemd(ctx, log)
+	defer func() { done(summary) }()
+
+	// The first SIGINT or SIGTERM stops the run after the current deletion;
+	// handling is then reset so a second signal terminates at once. Unlike
+	// ctx, stop lets the deletion in progress finish.
+	stop, stopped := signal.NotifyContext(
+		context.WithoutCancel(ctx), os.Interrupt, syscall.SIGTERM,
+	)
+	defer stopped()
+
+	go func() {
+		<-stop.Done()
+		stopped()
+	}()
+
+	summary, err = pruneWithHooks(ctx, stop, log, cfg, rep)
+
+	return summary, err
+}
+
+// pruneWithHooks prunes every configured directory between the pre_run hook
+// and the post_run or on_error hook, and sends the notifications for the
+// run. Once stop is done no further file is deleted.
+func pruneWithHooks(
+	ctx context.Context,
+	stop context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	rep *reporter,
+) (notify.Summary, error) {
+	hookRunner := hooks.NewRunner(hooks.WithLogger(log))
+
+	summary := notify.Summary{
+		RunID:     rep.runID,
+		Directory: strings.Join(cfg.Directories, ", "),
+		DryRun:    cfg.DryRun,
+	}
+
+	err := hookRunner.Run(ctx, hooks.PreRun, cfg.Hooks.PreRun, summaryEnv(summary))
+	if err == nil {
+		summary, err = prune(ctx, stop, log, cfg, hookRunner, rep)
+		summary.RunID = rep.runID
+		rep.footer(summary)
+	}
+
+	if err != nil && len(summary.Errors) == 0 {
+		summary.Errors = append(summary.Errors, err.Error())
+	}
+
+	sendNotifications(ctx, log, cfg, summary)
+	logRunSummary(log, summary, err)
+
+	if err != nil {
+		env := summaryEnv(summary)
+		env["error"] = err.Error()
+
+		hookErr := hookRunner.Run(ctx, hooks.OnError, cfg.Hooks.OnError, env)
+		if hookErr != nil {
+			log.Warn("on_error hook failed", zap.Error(hookErr))
+		}
+
+		return summary, err
+	}
+
+	return summary, hookRunner.Run(ctx, hooks.PostRun, cfg.Hooks.PostRun, summaryEnv(summary))
+}
+
+// logRunSummary logs the outcome of a run with the summary logger, which
+// the Windows Event Log reports even at info level
+func logRunSummary(log *logging.Logger, summary notify.Summary, err error) {
+	fields := []zap.Field{
+		zap.String("directory", summary.Directory),
+		zap.Bool("dry_run", summary.DryRun),
+		zap.Int("matched", summary.Matched),
+		zap.Int("deleted", summary.Deleted),
+		zap.Int64("reclaimed_bytes", summary.ReclaimedBytes),
+		zap.Int("errors", len(summary.Errors)),
+	}
+
+	named := log.Named(logging.EventLogSummary)
+
+	if err != nil {
+		named.Error("prune failed", append(fields, zap.Error(err))...)
+		return
+	}
+
+	named.Info("prune finished", fields...)
+}
+
+// loggerOptions returns the logger options selecting the configured log
+// format and destination
+func loggerOptions(cfg *config.Config) []logging.Option {
+	var opts []logging.Option
+
+	if cfg.LogEventSource != "" {
+		opts = append(opts, logging.WithEventLog(cfg.LogEventSource))
+	}
+
+	if cfg.LogJournal {
+		return append(opts, logging.WithJournal())
+	}
+
+	opts = append(opts, logging.WithFormat(cfg.LogFormat))
+
+	if cfg.LogOutput != "" && cfg.LogOutput != config.LogOutputStderr {
+		// The URL was validated with the config
+		network, address, _ := logging.ParseSyslogURL(cfg.LogOutput)
+
+		opts = append(opts, logging.WithSyslog(network, address))
+	}
+
+	if cfg.LogFile != "" {
+		// The size was validated with the config
+		maxSize, _ := units.ParseBytes(cfg.LogRotation.MaxSize)
+
+		opts = append(opts, logging.WithFile(cfg.LogFile, logging.Rotation{
+			MaxSize:    maxSize,
+			MaxAge:     cfg.LogRotation.MaxAge,
+			MaxBackups: cfg.LogRotation.MaxBackups,
+		}))
+	}
+
+	return opts
+}
+
+// notifySystemd reports readiness to systemd, when running as a Type=notify
+// service, and keeps its watchdog fed while a prune runs. The returned
+// function reports the outcome and stops the watchdog.
+func notifySystemd(ctx context.Context, log *logging.Logger) func(notify.Summary) {
+	notifier := systemd.NewNotifier()
+	if !notifier.Enabled() {
+		return func(notify.Summary) {}
+	}
+
+	warn := func(err error) {
 		if err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+			log.Warn("failed to notify systemd", zap.Error(err))
 		}
+	}
+
+	warn(notifier.Notify(systemd.Ready + "\nSTATUS=Pruning backups"))
+
+	ctx, stop := context.WithCancel(ctx)
+	notifier.StartWatchdog(ctx, warn)
+
+	return func(summary notify.Summary) {
+		stop()
+		warn(notifier.Notify(fmt.Sprintf("%s\nSTATUS=%d matched, %d deleted, %d errors",
+			systemd.Stopping, summary.Matched, summary.Deleted, len(summary.Errors))))
+	}
+}
+
+// prune applies the retention policy to every configured directory in turn,
+// after expanding directory globs, and returns a summary covering all of them.
+// Once stop is done no further file is deleted; the directories not yet
+// finished are recorded in a checkpoint for --resume instead.
+func prune(
+	ctx context.Context,
+	stop context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	hookRunner *hooks.Runner,
+	rep *reporter,
+) (notify.Summary, error) {
+	summary := notify.Summary{
+		Directory: strings.Join(cfg.Directories, ", "),
+		DryRun:    cfg.DryRun,
+	}
+
+	log.Info("config", zap.Any("config", cfg))
+
+	directories, err := expandDirectories(cfg)
+	if err != nil {
+		return summary, err
+	}
+
+	checkpointFile, err := checkpointPath(cfg)
+	if err != nil {
+		return summary, err
+	}
 
-		// Initialize logger
-		log, err := logging.New(cfg.LogLevel)
+	if pruneResume {
+		directories, err = resumeDirectories(checkpointFile, cfg.DryRun, directories)
 		if err != nil {
-			return fmt.Errorf("failed to initialize logger: %w", err)
+			return summary, err
+		}
+
+		log.Info("resuming interrupted prune", zap.Strings("directories", directories))
+	}
+
+	summary.Directory = strings.Join(directories, ", ")
+
+	if rep.progress != nil {
+		// Counting takes another pass over every directory, which only the
+		// ETA shown by --progress is worth
+		total := 0
+		if pruneProgress {
+			total = countBackups(ctx, log, cfg, directories)
 		}
-		defer log.SyncQuietly()
 
-		// Initialize file manager
-		fileManager, err := file.NewManager(
-			cfg.Directory,
-			cfg.FilePattern,
-			file.WithLogger(log),
+		rep.progress.start(ctx, total)
+	}
+
+	// The lines logged for every backup are only wanted at debug level when
+	// log_decisions is summary
+	detail := log
+	if cfg.LogDecisions == config.LogDecisionsSummary {
+		detail = log.Demoted()
+	}
+
+	var cat *catalog.Catalog
+
+	if cfg.Catalog != "" {
+		opts := []catalog.Option{catalog.WithLogger(log)}
+		if cfg.CatalogRetention > 0 {
+			opts = append(opts, catalog.WithRetention(cfg.CatalogRetention))
+		}
+
+		cat, err = catalog.Open(cfg.Catalog, opts...)
+		if err != nil {
+			return summary, fmt.Errorf("failed to open catalog: %w", err)
+		}
+
+		cat.StartRun(cfg.DryRun)
+	}
+
+	// Dry runs delete nothing, so they keep no journal
+	var jrn *journal.Journal
+
+	if cfg.Journal != "" && !cfg.DryRun {
+		jrn, err = openJournal(log, cfg.Journal, cat, rep, &summary)
+		if err != nil {
+			return summary, errors.Join(err, saveCatalog(cat, summary, err))
+		}
+	}
+
+	var deleteErrs []error
+
+	for i, directory := range directories {
+		if stop.Err() != nil {
+			return summary, interrupted(
+				log, cfg, cat, jrn, summary, checkpointFile, directories[i:],
+			)
+		}
+
+		dirSummary, errs, err := pruneDirectory(
+			ctx, stop, detail, cfg, cat, jrn, directory, hookRunner, rep,
 		)
+
+		summary.Matched += dirSummary.Matched
+		summary.Deleted += dirSummary.Deleted
+		summary.ReclaimedBytes += dirSummary.ReclaimedBytes
+		summary.Errors = append(summary.Errors, dirSummary.Errors...)
+		summary.RetriesExhausted += dirSummary.RetriesExhausted
+		summary.Archived += dirSummary.Archived
+		summary.Compressed += dirSummary.Compressed
+		summary.Warnings = append(summary.Warnings, dirSummary.Warnings...)
+		summary.Tiers = append(summary.Tiers, dirSummary.Tiers...)
+		deleteErrs = append(deleteErrs, errs...)
+
+		log.Info("directory summary",
+			zap.String("directory", directory),
+			zap.Int("matched", dirSummary.Matched),
+			zap.Int("deleted", dirSummary.Deleted),
+			zap.Int64("reclaimed_bytes", dirSummary.ReclaimedBytes),
+			zap.Int("errors", len(errs)))
+
+		if errors.Is(err, errInterrupted) {
+			return summary, interrupted(
+				log, cfg, cat, jrn, summary, checkpointFile, directories[i:],
+			)
+		}
+
 		if err != nil {
-			return fmt.Errorf("failed to initialize file manager: %w", err)
+			return summary, finishRun(cat, jrn, summary, err)
+		}
+
+		if cfg.FailFast && len(deleteErrs) > 0 {
+			break
+		}
+	}
+
+	err = deletionError(summary.Deleted, deleteErrs)
+	if err == nil {
+		err = warningsError(cfg.FailOnWarnings, summary.Warnings)
+	}
+
+	if err == nil {
+		err = pendingError(pruneCheck, summary.Deleted)
+	}
+
+	if pruneResume {
+		err = errors.Join(err, removeCheckpoint(checkpointFile))
+	}
+
+	return summary, finishRun(cat, jrn, summary, err)
+}
+
+// expandDirectories returns the directories cfg prunes: those its directory
+// globs match or, with subdirectories rules, the subdirectories of those the
+// rules select. Backups outside the selected subdirectories are left alone,
+// and selecting a directory inside another one is an error since its
+// backups would be pruned twice. The directories the globs match are
+// checked by checkDirectories first.
+func expandDirectories(cfg *config.Config) ([]string, error) {
+	directories, err := file.ExpandDirectories(cfg.Directories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand directories: %w", err)
+	}
+
+	if err := checkDirectories(cfg, directories); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Subdirectories) == 0 {
+		return directories, nil
+	}
+
+	var patterns []string
+	for _, directory := range directories {
+		patterns = append(patterns, cfg.SubdirectoryPatterns(directory)...)
+	}
+
+	subdirectories, err := file.MatchDirectories(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand subdirectories: %w", err)
+	}
+
+	if err := checkOverlap(subdirectories); err != nil {
+		return nil, err
+	}
+
+	return subdirectories, nil
+}
+
+// checkDirectories refuses directories that are not mountpoints with
+// require_mountpoint, or lack the sentinel_file, before anything in them is
+// listed or deleted
+func checkDirectories(cfg *config.Config, directories []string) error {
+	if cfg.RequireMountpoint {
+		if err := checkMountpoints(cfg.MountpointFstype, directories); err != nil {
+			return err
+		}
+	}
+
+	if cfg.SentinelFile != "" {
+		if err := checkSentinels(cfg.SentinelFile, directories); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSentinels returns errMissingSentinel unless every one of directories
+// holds the file name, guarding against a directory setting pointing at the
+// wrong tree
+func checkSentinels(name string, directories []string) error {
+	for _, directory := range directories {
+		if _, err := os.Stat(filepath.Join(directory, name)); err != nil {
+			return fmt.Errorf("%w: %w", errMissingSentinel, err)
 		}
+	}
+
+	return nil
+}
+
+// checkMountpoints returns errNotMounted unless every one of directories is
+// the mountpoint of a filesystem, of type fstype unless it is empty, so an
+// unmounted share is neither reported empty nor pruned in place of the disk
+func checkMountpoints(fstype string, directories []string) error {
+	platform := files.NewPlatform()
 
-		// List files
-		files, err := fileManager.ListFiles(ctx)
+	for _, directory := range directories {
+		mounted, err := platform.MountType(directory)
 		if err != nil {
-			return fmt.Errorf("failed to list files: %w", err)
+			return fmt.Errorf("%w: %w", errNotMounted, err)
 		}
 
-		log.Info("config", zap.Any("config", cfg))
+		if fstype != "" && !strings.EqualFold(mounted, fstype) {
+			return fmt.Errorf("%w: %s is %s, not %s", errNotMounted, directory, mounted, fstype)
+		}
+	}
+
+	return nil
+}
+
+// checkOverlap returns errOverlappingSubdirectories if one of directories is
+// inside another
+func checkOverlap(directories []string) error {
+	for _, parent := range directories {
+		for _, child := range directories {
+			rel, err := filepath.Rel(parent, child)
+			if err == nil && rel != "." && rel != ".." &&
+				!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("%w: %s contains %s", errOverlappingSubdirectories,
+					parent, child)
+			}
+		}
+	}
+
+	return nil
+}
+
+// interrupted records the directories remaining after a stop signal in the
+// checkpoint and returns the error the run exits with
+func interrupted(
+	log *logging.Logger,
+	cfg *config.Config,
+	cat *catalog.Catalog,
+	jrn *journal.Journal,
+	summary notify.Summary,
+	checkpointFile string,
+	remaining []string,
+) error {
+	log.Warn("interrupted, saving checkpoint",
+		zap.String("checkpoint", checkpointFile),
+		zap.Strings("remaining", remaining))
+
+	err := &exitError{
+		code: exitCodeInterrupted,
+		err: fmt.Errorf("%w: %d directories remaining, continue with --resume",
+			errInterrupted, len(remaining)),
+	}
+
+	checkpointErr := writeCheckpoint(checkpointFile, cfg.DryRun, remaining)
+
+	return errors.Join(finishRun(cat, jrn, summary, err), checkpointErr)
+}
+
+// pruneDirectory streams the backups in a single directory through the
+// retention policy and deletes every file the policy rejects as soon as it
+// is decided, so the full listing is never held in memory. Every decided
+// file is recorded in the catalog, and every deletion in the journal, if
+// they are kept. Per-file deletion errors
+// are returned separately from errors that prevented the directory from
+// being processed at all. errInterrupted is returned when stop is done
+// before every rejected file has been deleted.
+func pruneDirectory(
+	ctx context.Context,
+	stop context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	cat *catalog.Catalog,
+	jrn *journal.Journal,
+	directory string,
+	hookRunner *hooks.Runner,
+	rep *reporter,
+) (notify.Summary, []error, error) {
+	summary := notify.Summary{
+		Directory: directory,
+		DryRun:    cfg.DryRun,
+	}
+
+	cfg = cfg.ForDirectory(directory)
+
+	if cfg.Backend == config.BackendRestic || cfg.Backend == config.BackendBorg {
+		return summary, nil, forgetRepository(ctx, log, cfg, directory)
+	}
+
+	// The policy may walk the directory more than once, so every warning is
+	// only reported the first time it is seen
+	seen := make(map[notify.Warning]bool)
+	warn := func(w file.Warning) {
+		warning := notify.Warning{Severity: string(w.Severity), Message: w.String()}
+		if seen[warning] {
+			return
+		}
+
+		seen[warning] = true
+		summary.Warnings = append(summary.Warnings, warning)
+		rep.warn(warning)
+	}
+
+	// Initialize backend
+	store, err := backend.New(cfg, directory, log, backend.WithWarnings(warn))
+	if err != nil {
+		return summary, nil, fmt.Errorf("failed to initialize backend: %w", err)
+	}
+	defer func() { _ = backend.Close(store) }()
+
+	deleteErrs := cleanStaleFiles(ctx, log, cfg, directory, rep, &summary)
+
+	// Initialize retention policy
+	policy := retention.NewPolicy(log, cfg, retention.WithTierStats(func(s retention.TierStats) {
+		summary.Tiers = append(summary.Tiers, notify.Tier{
+			Directory:  directory,
+			Tag:        s.Tag,
+			Name:       s.Name(),
+			Requested:  s.Requested,
+			Filled:     s.Filled,
+			Suggestion: s.Suggestion(),
+		})
+	}))
+	minFree := cfg.MinFreeBytes()
+
+	var kept, compress []retention.Decision
+
+	now := clock()
+
+	// Apply retention policy, deleting files as they are decided
+	err = policy.ApplyStream(ctx, store.WalkFiles, func(decision retention.Decision) error {
+		summary.Matched++
+
+		observeBackup(log, cat, decision.File)
+
+		// Kept backups are compressed once the walk has finished, so the
+		// compressed files are not listed again
+		if compressible(cfg, decision, now) {
+			compress = append(compress, decision)
+		}
+
+		if !decision.Delete {
+			rep.keep(decision)
+
+			// Kept files are only remembered when they may have to be
+			// given up to recover free space
+			if minFree > 0 {
+				kept = append(kept, decision)
+			}
 
-		if len(files) == 0 {
-			log.Info("no backup files found")
 			return nil
 		}
 
-		// Initialize retention policy
-		policy := retention.NewPolicy(log, cfg)
+		if stop.Err() != nil {
+			return errInterrupted
+		}
+
+		err := deleteFile(ctx, log, cfg, cat, jrn, store, directory, hookRunner, rep, decision)
+		if err != nil {
+			deleteErrs = append(deleteErrs, err)
+			recordFailure(&summary, err)
+
+			if cfg.FailFast {
+				log.Warn("aborting after first failed deletion")
+				return errAbortPrune
+			}
+
+			return nil
+		}
+
+		summary.Deleted++
+		summary.ReclaimedBytes += decision.File.Size
+
+		if _, ok := archiveDestination(cfg, decision); ok {
+			summary.Archived++
+		}
+
+		return nil
+	})
+	if errors.Is(err, errAbortPrune) {
+		return summary, deleteErrs, nil
+	}
+
+	if errors.Is(err, errInterrupted) {
+		return summary, deleteErrs, err
+	}
+
+	if err != nil {
+		return summary, deleteErrs, fmt.Errorf("failed to apply retention policy: %w", err)
+	}
+
+	if summary.Matched == 0 {
+		log.Warn("no backup files found", zap.String("directory", directory))
+		warn(file.Warning{
+			Severity: file.SeverityWarning,
+			Path:     directory,
+			Message:  "no backups matched the pattern",
+		})
+	}
+
+	compressed, errs := compressKept(ctx, stop, log, cfg, rep, compress, &summary)
+	deleteErrs = append(deleteErrs, errs...)
+
+	for i, d := range kept {
+		if f, ok := compressed[d.File.Path]; ok {
+			kept[i].File = f
+		}
+	}
+
+	if minFree > 0 && summary.Matched > 0 {
+		errs := recoverFreeSpace(
+			ctx, stop, log, cfg, cat, jrn, store, directory, hookRunner, rep,
+			func() []retention.Decision {
+				return policy.Relax(&retention.Result{Decisions: kept})
+			}, minFree, &summary,
+		)
+		deleteErrs = append(deleteErrs, errs...)
+
+		if stop.Err() != nil {
+			return summary, deleteErrs, errInterrupted
+		}
+	}
+
+	deleteErrs = append(deleteErrs,
+		cleanOrphanedCompanions(ctx, log, cfg, directory, rep, &summary)...)
+
+	return summary, deleteErrs, nil
+}
+
+// cleanStaleFiles deletes the temporary files failed backup jobs left in
+// directory once they are older than stale_files.min_age. Every deletion
+// counts towards summary, and the errors of those that failed are returned.
+func cleanStaleFiles(
+	ctx context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	directory string,
+	rep *reporter,
+	summary *notify.Summary,
+) []error {
+	if cfg.StaleFiles.MinAge <= 0 {
+		return nil
+	}
+
+	cutoff := clock().Add(-cfg.StaleFiles.MinAge)
+	stale, err := file.StaleFiles(
+		ctx, directory, cfg.StaleFiles.Patterns, cutoff, searchesSubdirectories(cfg),
+	)
+
+	return removeExtraFiles(log, cfg, directory, rep, summary, "stale", stale, err)
+}
+
+// cleanOrphanedCompanions deletes the checksum and manifest files in
+// directory whose backup no longer exists. Every deletion counts towards
+// summary, and the errors of those that failed are returned.
+func cleanOrphanedCompanions(
+	ctx context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	directory string,
+	rep *reporter,
+	summary *notify.Summary,
+) []error {
+	if !cfg.CompanionFiles.DeleteOrphans {
+		return nil
+	}
+
+	orphans, err := file.OrphanedCompanions(
+		ctx, directory, cfg.CompanionFiles.Suffixes, searchesSubdirectories(cfg),
+	)
+
+	return removeExtraFiles(log, cfg, directory, rep, summary, "orphaned", orphans, err)
+}
+
+// searchesSubdirectories reports whether the cleanup passes look below the
+// top level of a directory. Only the files backend keeps backups in
+// subdirectories; the other backends keep every backup as a directory.
+func searchesSubdirectories(cfg *config.Config) bool {
+	return cfg.Backend == "" || cfg.Backend == config.BackendFiles
+}
+
+// removeExtraFiles deletes files found in directory outside the retention
+// policy, described as what, or records listErr if they could not be
+// listed. Every deletion counts towards summary, and the errors of those
+// that failed are returned.
+func removeExtraFiles(
+	log *logging.Logger,
+	cfg *config.Config,
+	directory string,
+	rep *reporter,
+	summary *notify.Summary,
+	what string,
+	extra []file.Info,
+	listErr error,
+) []error {
+	if listErr != nil {
+		log.Error("failed to list "+what+" files",
+			zap.String("directory", directory),
+			zap.Error(listErr))
+		summary.Errors = append(summary.Errors, listErr.Error())
+
+		return []error{listErr}
+	}
+
+	var errs []error
+
+	for _, f := range extra {
+		var err error
+		if !cfg.DryRun {
+			err = file.RemoveListed(directory, f)
+		}
 
-		// Apply retention policy
-		toDelete, err := policy.Apply(files)
 		if err != nil {
-			return fmt.Errorf("failed to apply retention policy: %w", err)
+			log.Error("failed to delete "+what+" file", zap.String("file", f.Path), zap.Error(err))
+			rep.fail(f.Path, err)
+			recordFailure(summary, err)
+			errs = append(errs, err)
+
+			continue
+		}
+
+		msg := "deleted " + what + " file"
+		if cfg.DryRun {
+			msg = "dry run: would delete " + what + " file"
+		}
+
+		log.Info(msg,
+			zap.String("file", f.Path),
+			zap.Time("modified", f.Timestamp),
+			zap.Int64("size", f.Size))
+		rep.clean(what, f, cfg.DryRun)
+		removeEmptyParents(log, cfg, directory, f.Path)
+
+		summary.Deleted++
+		summary.ReclaimedBytes += f.Size
+	}
+
+	return errs
+}
+
+// forgetRepository hands the retention policy to restic or borg for a
+// repository, which decide and delete the snapshots themselves. Nothing is
+// counted in the summary because the tools do not report it in a stable
+// format.
+func forgetRepository(
+	ctx context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	location string,
+) error {
+	repo, err := repository.New(cfg.Backend, location, repository.WithLogger(log))
+	if err != nil {
+		return fmt.Errorf("failed to initialize backend: %w", err)
+	}
+
+	policy := repository.Policy{
+		Retention:      cfg.Retention,
+		KeepCount:      cfg.KeepCount,
+		RequireMinimum: cfg.RequireMinimum,
+	}
+
+	return repo.Forget(ctx, policy, cfg.DryRun)
+}
+
+// deleteFile prepares the deletion of a file, deletes it and reports the
+// outcome, recording it in the catalog if one is kept and in the journal
+// once it finishes.
+func deleteFile(
+	ctx context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	cat *catalog.Catalog,
+	jrn *journal.Journal,
+	store backend.Backend,
+	directory string,
+	hookRunner *hooks.Runner,
+	rep *reporter,
+	decision retention.Decision,
+) error {
+	dest, archive := archiveDestination(cfg, decision)
+
+	err := prepareDeletion(ctx, cfg, jrn, directory, hookRunner, rep.runID, &decision)
+
+	switch {
+	case err != nil:
+	case archive && !cfg.DryRun:
+		_, err = file.ArchiveFile(directory, decision.File, dest,
+			archiveOptions(ctx, hookRunner, cfg, directory, rep.runID, decision.File)...)
+		journalDone(log, jrn, decision, err)
+	case !archive:
+		err = store.DeleteFile(ctx, decision.File, cfg.DryRun)
+		journalDone(log, jrn, decision, err)
+	}
+
+	if cat != nil {
+		cat.Record(decision, cfg.DryRun, err)
+	}
+
+	if err != nil {
+		log.Error("failed to delete file",
+			zap.String("file", decision.File.Path),
+			zap.Error(err))
+
+		rep.fail(decision.File.Path, err)
+
+		return err
+	}
+
+	if archive {
+		rep.archive(decision, dest, cfg.DryRun)
+	} else {
+		rep.remove(decision, cfg.DryRun)
+	}
+
+	removeEmptyParents(log, cfg, directory, decision.File.Path)
+
+	return nil
+}
+
+// prepareDeletion runs the pre_delete hook for the file decision gives up,
+// takes its checksum with checksum_deletions and records in the journal, if
+// one is kept, that its deletion is about to start. The file must not be
+// deleted unless it returns nil. Backups stored as directories have no
+// checksum, and nothing is checksummed in a dry run.
+func prepareDeletion(
+	ctx context.Context,
+	cfg *config.Config,
+	jrn *journal.Journal,
+	directory string,
+	hookRunner *hooks.Runner,
+	runID string,
+	decision *retention.Decision,
+) error {
+	if err := runPreDeleteHook(ctx, hookRunner, cfg, directory, runID, decision.File); err != nil {
+		return err
+	}
+
+	if cfg.ChecksumDeletions && !cfg.DryRun {
+		sum, err := file.Checksum(decision.File.Path)
+		if err != nil && !errors.Is(err, file.ErrNotRegularFile) {
+			return fmt.Errorf("failed to checksum backup: %w", err)
 		}
 
-		// Delete files
-		for _, file := range toDelete {
-			if err := fileManager.DeleteFile(ctx, file, cfg.DryRun); err != nil {
-				log.Error("failed to delete file",
-					zap.String("file", file.Path),
-					zap.Error(err))
+		decision.File.Checksum = sum
+	}
+
+	return journalIntent(jrn, *decision)
+}
+
+// archiveDestination returns the directory a backup the policy gives up is
+// moved to instead of being deleted, if its tier is archived. Backups
+// deleted to recover free space are never archived.
+func archiveDestination(cfg *config.Config, decision retention.Decision) (string, bool) {
+	if decision.Reason == retention.ReasonEmergency {
+		return "", false
+	}
+
+	dest, ok := cfg.Archive.Tiers[string(decision.Tier)]
+
+	return dest, ok
+}
+
+// archiveOptions returns the options archiving f with, piping it through the
+// archive transform command if one is configured. The command sees the same
+// ARP_ variables as the pre_delete hook.
+func archiveOptions(
+	ctx context.Context,
+	hookRunner *hooks.Runner,
+	cfg *config.Config,
+	directory string,
+	runID string,
+	f file.Info,
+) []file.ArchiveOption {
+	if cfg.Archive.Transform == "" {
+		return nil
+	}
+
+	transform := func(in io.Reader, out io.Writer) error {
+		return hookRunner.Pipe(ctx, hooks.ArchiveTransform, cfg.Archive.Transform,
+			fileEnv(runID, directory, f), in, out)
+	}
+
+	return []file.ArchiveOption{file.WithTransform(transform, cfg.Archive.TransformSuffix)}
+}
+
+// removeEmptyParents removes the subdirectories of directory that deleting
+// path left empty, if remove_empty_dirs is set. Failures are only logged,
+// since the backup itself was deleted.
+func removeEmptyParents(log *logging.Logger, cfg *config.Config, directory, path string) {
+	if !cfg.RemoveEmptyDirs || cfg.DryRun {
+		return
+	}
+
+	removed, err := file.RemoveEmptyParents(directory, path)
+	for _, dir := range removed {
+		log.Info("removed empty directory", zap.String("directory", dir))
+	}
+
+	if err != nil {
+		log.Warn("failed to remove empty directories",
+			zap.String("file", path),
+			zap.Error(err))
+	}
+}
+
+// recordFailure adds a failed deletion to summary, counting it separately
+// when it failed after every retry
+func recordFailure(summary *notify.Summary, err error) {
+	summary.Errors = append(summary.Errors, err.Error())
+
+	if errors.Is(err, backend.ErrRetriesExhausted) {
+		summary.RetriesExhausted++
+	}
+}
+
+// recoverFreeSpace deletes the files relax returns, in order, while the
+// filesystem holding the directory has less than minFree bytes available.
+// relax is only called once space is short, as finding the files the policy
+// may give up is costly. In dry-run mode the space the deletions would free
+// is added to what the filesystem reports.
+func recoverFreeSpace(
+	ctx context.Context,
+	stop context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	cat *catalog.Catalog,
+	jrn *journal.Journal,
+	store backend.Backend,
+	directory string,
+	hookRunner *hooks.Runner,
+	rep *reporter,
+	relax func() []retention.Decision,
+	minFree int64,
+	summary *notify.Summary,
+) []error {
+	var (
+		errs  []error
+		freed int64
+	)
+
+	platform := files.NewPlatform()
+
+	// available returns the space available in the filesystem, with what a
+	// dry run would have freed
+	available := func() (int64, error) {
+		var stat files.FileSystemStats
+		if err := platform.Statfs(directory, &stat); err != nil {
+			log.Error("failed to check free space",
+				zap.String("directory", directory),
+				zap.Error(err))
+			summary.Errors = append(summary.Errors, err.Error())
+
+			return 0, err
+		}
+
+		free := int64(min(stat.AvailableBytes, math.MaxInt64))
+		if cfg.DryRun {
+			free += freed
+		}
+
+		return free, nil
+	}
+
+	free, err := available()
+	if err != nil {
+		return []error{err}
+	}
+
+	if free >= minFree {
+		return nil
+	}
+
+	for _, decision := range relax() {
+		if stop.Err() != nil {
+			return errs
+		}
+
+		log.Warn("deleting file to recover free space",
+			zap.String("file", decision.File.Path),
+			zap.Time("timestamp", decision.File.Timestamp),
+			zap.String("tier", string(decision.Tier)),
+			zap.Int64("available_bytes", free),
+			zap.Int64("min_free_bytes", minFree))
+
+		err := deleteFile(ctx, log, cfg, cat, jrn, store, directory, hookRunner, rep, decision)
+		if err != nil {
+			errs = append(errs, err)
+			recordFailure(summary, err)
+
+			if cfg.FailFast {
+				return errs
 			}
+
+			continue
 		}
 
+		freed += decision.File.Size
+		summary.Deleted++
+		summary.ReclaimedBytes += decision.File.Size
+
+		if free, err = available(); err != nil {
+			return append(errs, err)
+		}
+
+		if free >= minFree {
+			break
+		}
+	}
+
+	return errs
+}
+
+// saveCatalog records the outcome of the run in the catalog and writes it
+// back to disk, if one is kept
+func saveCatalog(cat *catalog.Catalog, summary notify.Summary, err error) error {
+	if cat == nil {
 		return nil
-	},
+	}
+
+	if err != nil && len(summary.Errors) == 0 {
+		summary.Errors = []string{err.Error()}
+	}
+
+	cat.FinishRun(summary)
+
+	if err := cat.Save(); err != nil {
+		return fmt.Errorf("failed to save catalog: %w", err)
+	}
+
+	return nil
+}
+
+// observeBackup records a decided file in the catalog, if one is kept. A
+// backup that cannot be cataloged is logged and does not stop the prune.
+func observeBackup(log *logging.Logger, cat *catalog.Catalog, f file.Info) {
+	if cat == nil {
+		return
+	}
+
+	if err := cat.Observe(f); err != nil {
+		log.Warn("failed to catalog backup",
+			zap.String("file", f.Path),
+			zap.Error(err))
+	}
+}
+
+// runPreDeleteHook runs the pre_delete hook for a single file. The hook is
+// not run in dry-run mode since nothing is actually deleted.
+func runPreDeleteHook(
+	ctx context.Context,
+	hookRunner *hooks.Runner,
+	cfg *config.Config,
+	directory string,
+	runID string,
+	f file.Info,
+) error {
+	if cfg.DryRun {
+		return nil
+	}
+
+	env := fileEnv(runID, directory, f)
+
+	return hookRunner.Run(ctx, hooks.PreDelete, cfg.Hooks.PreDelete, env)
+}
+
+// fileEnv returns a backup of the run runID as hook environment variables
+func fileEnv(runID, directory string, f file.Info) map[string]string {
+	return map[string]string{
+		"run_id":    runID,
+		"directory": directory,
+		"path":      f.Path,
+		"timestamp": f.Timestamp.Format(time.RFC3339),
+		"size":      strconv.FormatInt(f.Size, 10),
+		"tag":       f.Tag,
+	}
+}
+
+// summaryEnv returns the run summary as hook environment variables
+func summaryEnv(summary notify.Summary) map[string]string {
+	return map[string]string{
+		"run_id":          summary.RunID,
+		"directory":       summary.Directory,
+		"dry_run":         strconv.FormatBool(summary.DryRun),
+		"matched":         strconv.Itoa(summary.Matched),
+		"deleted":         strconv.Itoa(summary.Deleted),
+		"reclaimed_bytes": strconv.FormatInt(summary.ReclaimedBytes, 10),
+		"errors":          strconv.Itoa(len(summary.Errors)),
+	}
+}
+
+// sendNotifications delivers the run summary to every configured target.
+// Delivery failures are logged but never fail the run.
+func sendNotifications(
+	ctx context.Context,
+	log *logging.Logger,
+	cfg *config.Config,
+	summary notify.Summary,
+) {
+	for _, sender := range notify.FromConfig(cfg.Notifications) {
+		if err := sender.Send(ctx, summary); err != nil {
+			log.Warn("failed to send notification",
+				zap.String("sender", sender.Name()),
+				zap.Error(err))
+		}
+	}
 }
 
 func init() {
@@ -122,10 +1277,66 @@ func init() {
 		StringP("log-level", "l", "info", "Log level (debug, info, warn, error)")
 	pruneCmd.Flags().
 		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	pruneCmd.Flags().
+		Bool("fail-fast", false, "Stop at the first file that cannot be deleted")
+	pruneCmd.Flags().
+		Duration("timeout", 0, "Fail listing a directory or deleting a file that takes longer")
+	pruneCmd.Flags().Duration("list-timeout", 0,
+		"Fail listing a directory that takes longer, overriding --timeout")
+	pruneCmd.Flags().
+		BoolVarP(&pruneQuiet, "quiet", "q", false, "Only print errors")
+	pruneCmd.Flags().
+		BoolVarP(&pruneVerbose, "verbose", "v", false, "Print the reason for every decision")
+	pruneCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	pruneCmd.Flags().BoolVar(&pruneProgress, "progress", false,
+		"Show progress and an ETA, counting the backups first")
+	pruneCmd.Flags().BoolVar(&pruneResume, "resume", false,
+		"Continue an interrupted prune with the directories it did not finish")
+	pruneCmd.Flags().StringVar(&pruneSummaryFile, "summary-file", "",
+		"Write a JSON summary of the run to this path when it finishes")
+	pruneCmd.Flags().BoolVar(&pruneCheck, "check", false,
+		"Dry run that exits with status 5 when backups would be deleted")
 
-	// Bind flags to config
-	must.Must(viper.BindPFlag("dry_run", pruneCmd.Flags().Lookup("dry-run")))
-	must.Must(
-		viper.BindPFlag("log_level", pruneCmd.Flags().Lookup("log-level")),
-	)
+	// Retention overrides for one-off runs without a config file
+	pruneCmd.Flags().Int("hourly", 0, "Number of hourly backups to keep")
+	pruneCmd.Flags().Int("daily", 0, "Number of daily backups to keep")
+	pruneCmd.Flags().Int("weekly", 0, "Number of weekly backups to keep")
+	pruneCmd.Flags().Int("monthly", 0, "Number of monthly backups to keep")
+	pruneCmd.Flags().Int("yearly", 0, "Number of yearly backups to keep")
+	pruneCmd.Flags().Int("keep-count", 0, "Keep only the newest backups, by modification time")
+	pruneCmd.Flags().
+		StringSlice("directory", nil, "Directories containing the backups (repeatable)")
+	pruneCmd.Flags().String("pattern", "", "Pattern of the backup file names")
+	pruneCmd.Flags().String("policy", "", "Name of the policy to apply from the config policies")
+	pruneCmd.Flags().String("owner", "", "Only consider backups owned by this user")
+	pruneCmd.Flags().String("group", "", "Only consider backups owned by this group")
+
+	bindPruneFlags()
+}
+
+// bindPruneFlags binds the prune flags to their config keys so that flags
+// given on the command line take precedence over the config file
+func bindPruneFlags() {
+	flags := pruneCmd.Flags()
+
+	for key, flag := range map[string]string{
+		"dry_run":           "dry-run",
+		"log_level":         "log-level",
+		"fail_fast":         "fail-fast",
+		"operation_timeout": "timeout",
+		"list_timeout":      "list-timeout",
+		"retention.hourly":  "hourly",
+		"retention.daily":   "daily",
+		"retention.weekly":  "weekly",
+		"retention.monthly": "monthly",
+		"retention.yearly":  "yearly",
+		"keep_count":        "keep-count",
+		"directory":         "directory",
+		"file_pattern":      "pattern",
+		"policy":            "policy",
+		"owner":             "owner",
+		"group":             "group",
+	} {
+		must.Must(viper.BindPFlag(key, flags.Lookup(flag)))
+	}
 }
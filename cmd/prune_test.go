@@ -25,16 +25,51 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/retention/retentiontest"
 )
 
+// setContext sets the context of cmd, one of the package's shared commands,
+// for the duration of the test, so a canceled context never leaks into the
+// tests that run after it
+func setContext(t *testing.T, cmd *cobra.Command, ctx context.Context) {
+	t.Helper()
+
+	cmd.SetContext(ctx)
+	t.Cleanup(func() { cmd.SetContext(context.Background()) })
+}
+
+// setClock stops the clock of the commands at now for the duration of the
+// test and returns it, so the test can move it
+func setClock(t *testing.T, now time.Time) *retentiontest.Clock {
+	t.Helper()
+
+	fake := retentiontest.NewClock(now)
+	clock = fake.Now
+
+	t.Cleanup(func() { clock = time.Now })
+
+	return fake
+}
+
 func TestPruneCommand(t *testing.T) {
 	tmpDir := t.TempDir()
 	t.Chdir(tmpDir)
@@ -175,7 +210,7 @@ func TestPruneCommand(t *testing.T) {
 		cancel()
 
 		cmd := pruneCmd
-		cmd.SetContext(ctx)
+		setContext(t, cmd, ctx)
 		err = cmd.Flags().Set("config", configFile)
 		require.NoError(t, err)
 		err = cmd.RunE(cmd, nil)
@@ -184,6 +219,114 @@ func TestPruneCommand(t *testing.T) {
 	})
 }
 
+func TestPruneCommandHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use POSIX shell syntax")
+	}
+
+	tmpDir := t.TempDir()
+	hookOut := filepath.Join(t.TempDir(), "hooks.log")
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	writeConfig := func(t *testing.T, preRun string) string {
+		configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(tmpDir) + `"
+dry_run: true
+hooks:
+  pre_run: '` + preRun + `'
+  post_run: 'echo "$ARP_HOOK deleted=$ARP_DELETED" >> ` + hookOut + `'
+  on_error: 'echo "$ARP_HOOK $ARP_ERROR" >> ` + hookOut + `'
+`
+		configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+		err := os.WriteFile(configFile, []byte(configContent), 0o600)
+		require.NoError(t, err)
+
+		return configFile
+	}
+
+	run := func(t *testing.T, configFile string) error {
+		viper.Reset()
+		require.NoError(t, os.RemoveAll(hookOut))
+
+		cmd := pruneCmd
+		setContext(t, cmd, t.Context())
+		require.NoError(t, cmd.Flags().Set("config", configFile))
+
+		return cmd.RunE(cmd, nil)
+	}
+
+	t.Run("post_run receives summary", func(t *testing.T) {
+		err := run(t, writeConfig(t, "true"))
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(hookOut)
+		require.NoError(t, err)
+		require.Equal(t, "post_run deleted=1\n", string(content))
+	})
+
+	t.Run("failing pre_run aborts", func(t *testing.T) {
+		err := run(t, writeConfig(t, "exit 1"))
+		require.Error(t, err)
+
+		content, err := os.ReadFile(hookOut)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "on_error hook failed: pre_run")
+	})
+}
+
+func TestPruneCommandPreDeleteHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use POSIX shell syntax")
+	}
+
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-13-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(tmpDir) + `"
+hooks:
+  pre_delete: 'test -n "$ARP_RUN_ID" && case "$ARP_PATH" in *03-13*) exit 1;; esac'
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+
+	err = cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errPartialFailure)
+	require.Equal(t, exitCodePartialFailure, exitCode(err))
+
+	_, err = os.Stat(filepath.Join(tmpDir, "backup-2024-03-14-12-00.tar.gz"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = os.Stat(filepath.Join(tmpDir, "backup-2024-03-13-12-00.tar.gz"))
+	require.NoError(t, err, "vetoed file should be kept")
+}
+
 func TestPruneCommandFlags(t *testing.T) {
 	viper.Reset()
 	t.Run("dry run flag", func(t *testing.T) {
@@ -202,4 +345,1009 @@ func TestPruneCommandFlags(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "debug", viper.GetString("log_level"))
 	})
+	t.Run("timeout flag", func(t *testing.T) {
+		cmd := pruneCmd
+		require.NoError(t, cmd.Flags().Set("timeout", "30s"))
+		t.Cleanup(func() { require.NoError(t, cmd.Flags().Set("timeout", "0s")) })
+		require.NoError(t, viper.BindPFlag("operation_timeout", cmd.Flags().Lookup("timeout")))
+		require.Equal(t, 30*time.Second, viper.GetDuration("operation_timeout"))
+	})
+	t.Run("list timeout flag", func(t *testing.T) {
+		cmd := pruneCmd
+		require.NoError(t, cmd.Flags().Set("list-timeout", "10m"))
+		t.Cleanup(func() { require.NoError(t, cmd.Flags().Set("list-timeout", "0s")) })
+		require.NoError(t, viper.BindPFlag("list_timeout", cmd.Flags().Lookup("list-timeout")))
+		require.Equal(t, 10*time.Minute, viper.GetDuration("list_timeout"))
+	})
+	t.Run("owner flags", func(t *testing.T) {
+		cmd := pruneCmd
+		require.NoError(t, cmd.Flags().Set("owner", "backup"))
+		require.NoError(t, cmd.Flags().Set("group", "1000"))
+		t.Cleanup(func() {
+			require.NoError(t, cmd.Flags().Set("owner", ""))
+			require.NoError(t, cmd.Flags().Set("group", ""))
+		})
+		require.NoError(t, viper.BindPFlag("owner", cmd.Flags().Lookup("owner")))
+		require.NoError(t, viper.BindPFlag("group", cmd.Flags().Lookup("group")))
+		require.Equal(t, "backup", viper.GetString("owner"))
+		require.Equal(t, "1000", viper.GetString("group"))
+	})
+}
+
+func TestPruneCommandRetentionFlags(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{
+		"db-2024-03-15.sql.gz",
+		"db-2024-03-14.sql.gz",
+		"db-2024-03-13.sql.gz",
+	} {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	viper.Reset()
+	bindPruneFlags()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+
+	for flag, value := range map[string]string{
+		"config":    "",
+		"dry-run":   "false",
+		"directory": tmpDir,
+		"pattern":   "db-{year}-{month}-{day}.sql.gz",
+		"daily":     "1",
+	} {
+		require.NoError(t, cmd.Flags().Set(flag, value))
+	}
+
+	t.Cleanup(func() {
+		for _, flag := range []string{"dry-run", "directory", "pattern", "daily"} {
+			f := cmd.Flags().Lookup(flag)
+			require.NoError(t, f.Value.Set(f.DefValue))
+			f.Changed = false
+		}
+
+		viper.Reset()
+	})
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "db-2024-03-15.sql.gz", entries[0].Name())
+}
+
+func TestPruneCommandMultipleDirectories(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+
+	for _, dir := range dirs {
+		for _, name := range []string{
+			"backup-2024-03-15-12-00.tar.gz",
+			"backup-2024-03-14-12-00.tar.gz",
+		} {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+			require.NoError(t, err)
+		}
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory:
+  - "` + filepath.ToSlash(dirs[0]) + `"
+  - "` + filepath.ToSlash(dirs[1]) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 2 kept, 2 deleted, 0 failed")
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "backup-2024-03-15-12-00.tar.gz", entries[0].Name())
+	}
+}
+
+func TestPruneCommandMinFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-13-12-00.tar.gz",
+		"backup-2024-02-13-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	// No filesystem has this much space available, so retention is relaxed all
+	// the way down to require_minimum
+	configContent := `retention:
+  daily: 3
+  monthly: 2
+require_minimum: 2
+min_free_space: 1000000TiB
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 2 kept, 2 deleted, 0 failed")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "backup-2024-03-14-12-00.tar.gz", entries[0].Name())
+	require.Equal(t, "backup-2024-03-15-12-00.tar.gz", entries[1].Name())
+}
+
+func TestRecoverFreeSpaceEnoughSpace(t *testing.T) {
+	var summary notify.Summary
+
+	// With enough space available the relaxed files are never worked out
+	errs := recoverFreeSpace(
+		t.Context(), t.Context(), logging.NewDefault(), &config.Config{}, nil, nil, nil,
+		t.TempDir(), nil, newReporter(&bytes.Buffer{}, false, false),
+		func() []retention.Decision {
+			t.Fatal("relax called with enough free space")
+			return nil
+		}, 1, &summary,
+	)
+	require.Empty(t, errs)
+	require.Zero(t, summary.Deleted)
+}
+
+func TestPruneCommandKeepCount(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// The names carry no date, so the backups are ordered by mtime
+	for i, name := range []string{"backup-b.tar.gz", "backup-c.tar.gz", "backup-a.tar.gz"} {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(name), 0o600)
+		require.NoError(t, err)
+
+		mtime := now.Add(-time.Duration(i) * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	configContent := `keep_count: 2
+file_pattern: 'backup-\w+\.tar\.gz'
+pattern_syntax: regex
+directory: "` + filepath.ToSlash(dir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 2 kept, 1 deleted, 0 failed")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "backup-b.tar.gz", entries[0].Name())
+	require.Equal(t, "backup-c.tar.gz", entries[1].Name())
+}
+
+func TestPruneCommandStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := setClock(t, time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC)).Now()
+	old := now.Add(-48 * time.Hour)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0o750))
+
+	for _, name := range []string{
+		"backup-2024-03-15.tar.gz",
+		"backup-2024-03-16.tar.gz.tmp",
+		"fresh.partial",
+		filepath.Join("nested", ".in-progress-backup"),
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	for _, name := range []string{
+		"backup-2024-03-16.tar.gz.tmp",
+		filepath.Join("nested", ".in-progress-backup"),
+	} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), old, old))
+	}
+
+	recent := now.Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "fresh.partial"), recent, recent))
+
+	configContent := `retention:
+  daily: 7
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+stale_files:
+  min_age: 24h
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "backup-2024-03-16.tar.gz.tmp (stale)")
+	require.Contains(t, out.String(), "Summary: 1 kept, 2 deleted, 0 failed")
+
+	require.FileExists(t, filepath.Join(dir, "backup-2024-03-15.tar.gz"))
+	require.FileExists(t, filepath.Join(dir, "fresh.partial"))
+	require.NoFileExists(t, filepath.Join(dir, "backup-2024-03-16.tar.gz.tmp"))
+	require.NoFileExists(t, filepath.Join(dir, "nested", ".in-progress-backup"))
+}
+
+func TestPruneCommandOrphanedCompanions(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-14.tar.gz",
+		"backup-2024-03-14.tar.gz.sha256",
+		"backup-2024-03-15.tar.gz",
+		"backup-2024-03-15.tar.gz.sha256",
+		"removed-by-hand.tar.gz.md5",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+companion_files:
+  delete_orphans: true
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "removed-by-hand.tar.gz.md5 (orphaned)")
+	require.Contains(t, out.String(), "Summary: 1 kept, 3 deleted, 0 failed")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "backup-2024-03-15.tar.gz", entries[0].Name())
+	require.Equal(t, "backup-2024-03-15.tar.gz.sha256", entries[1].Name())
+}
+
+func TestPruneCommandRemoveEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, day := range []string{"14", "15"} {
+		path := filepath.Join(dir, "2024", "03", day)
+		require.NoError(t, os.MkdirAll(path, 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(path, "backup.tar.gz"), []byte(day), 0o600))
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2023", "12", "31"), 0o750))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "2023", "12", "31", "backup.tar.gz"), []byte("31"), 0o600))
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "{year}/{month}/{day}/backup.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+remove_empty_dirs: true
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	require.FileExists(t, filepath.Join(dir, "2024", "03", "15", "backup.tar.gz"))
+	require.NoDirExists(t, filepath.Join(dir, "2024", "03", "14"))
+	require.NoDirExists(t, filepath.Join(dir, "2023"))
+	require.DirExists(t, dir)
+}
+
+func TestPruneCommandArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "cold")
+
+	for _, name := range []string{
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-15-06-00.tar.gz",
+		"backup-2024-03-15-12-00.tar.gz",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+archive:
+  tiers:
+    expired: "` + filepath.ToSlash(archive) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 1 kept, 2 deleted, 0 failed")
+	require.Contains(t, out.String(), "Archived: 1")
+
+	// The superseded backup of the newest day is deleted, the expired one
+	// archived
+	require.FileExists(t, filepath.Join(archive, "backup-2024-03-14-12-00.tar.gz"))
+	require.NoFileExists(t, filepath.Join(archive, "backup-2024-03-15-06-00.tar.gz"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "backup-2024-03-15-12-00.tar.gz", entries[0].Name())
+}
+
+func TestPruneCommandArchiveTransform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("transform tests use POSIX shell syntax")
+	}
+
+	dir := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "cold")
+
+	for _, name := range []string{
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-15-12-00.tar.gz",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+archive:
+  tiers:
+    expired: "` + filepath.ToSlash(archive) + `"
+  transform: 'test -n "$ARP_RUN_ID" && printf "%s\n" "$ARP_PATH" && cat'
+  transform_suffix: .enc
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Archived: 1")
+
+	old := filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz")
+	require.NoFileExists(t, old)
+
+	content, err := os.ReadFile(filepath.Join(archive, "backup-2024-03-14-12-00.tar.gz.enc"))
+	require.NoError(t, err)
+	require.Equal(t, old+"\nbackup-2024-03-14-12-00.tar.gz", string(content))
+}
+
+func TestPruneCommandCompress(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-13-12-00.tar",
+		"backup-2024-03-14-12-00.tar",
+		"backup-2024-03-15-12-00.tar",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 2
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar"
+directory: "` + filepath.ToSlash(dir) + `"
+compress_after: 24h
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	// The newest backup is younger than compress_after and is left as it is
+	clock := setClock(t, time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC))
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 2 kept, 1 deleted, 0 failed")
+	require.Contains(t, out.String(), "Compressed: 1")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "backup-2024-03-14-12-00.tar.gz", entries[0].Name())
+	require.Equal(t, "backup-2024-03-15-12-00.tar", entries[1].Name())
+
+	// Once it is old enough, the newest backup is compressed too
+	clock.Advance(24 * time.Hour)
+	out.Reset()
+	viper.Reset()
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Compressed: 1")
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, "backup-2024-03-15-12-00.tar.gz", entries[1].Name())
+
+	// A later run finds the compressed backups and leaves them alone
+	out.Reset()
+	viper.Reset()
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 2 kept, 0 deleted, 0 failed")
+	require.NotContains(t, out.String(), "Compressed:")
+}
+
+func TestPruneCommandPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-13-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `retention:
+  daily: 3
+policies:
+  strict:
+    retention:
+      daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("policy", "strict"))
+	require.NoError(t, viper.BindPFlag("policy", cmd.Flags().Lookup("policy")))
+
+	t.Cleanup(func() {
+		require.NoError(t, cmd.Flags().Set("policy", ""))
+	})
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 1 kept, 2 deleted, 0 failed")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "backup-2024-03-15-12-00.tar.gz", entries[0].Name())
+}
+
+func TestPruneCommandSubdirectories(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{"databases/pg", "databases/mysql", "logs/nginx", "."} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o750))
+
+		for _, name := range []string{
+			"backup-2024-03-15-12-00.tar.gz",
+			"backup-2024-03-14-12-00.tar.gz",
+			"backup-2024-03-13-12-00.tar.gz",
+		} {
+			err := os.WriteFile(filepath.Join(root, dir, name), []byte(name), 0o600)
+			require.NoError(t, err)
+		}
+	}
+
+	configContent := `retention:
+  daily: 2
+policies:
+  strict:
+    retention:
+      daily: 3
+  logs:
+    retention:
+      daily: 1
+subdirectories:
+  - path: databases/*
+    policy: strict
+  - path: logs/*
+    policy: logs
+  - path: databases/pg
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+match_basename: true
+directory: "` + filepath.ToSlash(root) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "Summary: 7 kept, 2 deleted, 0 failed")
+
+	for dir, want := range map[string]int{
+		"databases/pg":    3,
+		"databases/mysql": 3,
+		"logs/nginx":      1,
+		// Outside the selected subdirectories
+		".": 3,
+	} {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		require.NoError(t, err)
+
+		files := 0
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files++
+			}
+		}
+
+		require.Equal(t, want, files, dir)
+	}
+
+	viper.Reset()
+
+	overlapping := filepath.Join(t.TempDir(), "overlapping.yaml")
+	err = os.WriteFile(overlapping, []byte(strings.Replace(configContent,
+		"- path: databases/pg", `- path: "*"`, 1)), 0o600)
+	require.NoError(t, err)
+
+	require.NoError(t, cmd.Flags().Set("config", overlapping))
+	require.ErrorIs(t, cmd.RunE(cmd, nil), errOverlappingSubdirectories)
+}
+
+func TestPruneCommandLogDecisions(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(t.TempDir(), "prune.log")
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+log_file: "` + filepath.ToSlash(logFile) + `"
+log_decisions: summary
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0o600))
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	logged, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	require.Contains(t, string(logged), `"directory summary"`)
+	require.NotContains(t, string(logged), `"deleted file"`)
+	require.NoFileExists(t, filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"))
+}
+
+func TestPruneCommandSummaryFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("summary-file", summaryFile))
+
+	t.Cleanup(func() {
+		require.NoError(t, cmd.Flags().Set("summary-file", ""))
+	})
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	var summary runSummary
+
+	data, err := os.ReadFile(summaryFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &summary))
+
+	require.Equal(t, dir, summary.Directory)
+	require.Equal(t, 2, summary.Matched)
+	require.Equal(t, 1, summary.Deleted)
+	require.Equal(t, int64(len("backup-2024-03-14-12-00.tar.gz")), summary.ReclaimedBytes)
+	require.Empty(t, summary.Errors)
+	require.Zero(t, summary.ExitCode)
+	require.False(t, summary.StartedAt.IsZero())
+	require.Len(t, summary.RunID, 36)
+	require.Equal(t, []tierStats{
+		{Directory: dir, Tier: "daily", Requested: 1, Filled: 1},
+	}, summary.Tiers)
+	require.ElementsMatch(t, []fileRecord{
+		{
+			Path:   filepath.Join(dir, "backup-2024-03-15-12-00.tar.gz"),
+			Action: "keep",
+			Reason: "daily",
+		},
+		{
+			Path:   filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"),
+			Action: "delete",
+			Reason: "expired",
+		},
+	}, summary.Files)
+
+	t.Run("failed run", func(t *testing.T) {
+		viper.Reset()
+		require.NoError(t, cmd.Flags().Set("config", filepath.Join(dir, "missing.yaml")))
+
+		require.Error(t, cmd.RunE(cmd, nil))
+
+		data, err := os.ReadFile(summaryFile)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &summary))
+		require.Equal(t, exitCodeError, summary.ExitCode)
+		require.Len(t, summary.Errors, 1)
+		require.Contains(t, summary.Errors[0], "failed to load config")
+		require.Len(t, summary.RunID, 36)
+	})
+}
+
+func TestPruneCommandSummarySigning(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg command not available")
+	}
+
+	gnupgHome := t.TempDir()
+	t.Setenv("GNUPGHOME", gnupgHome)
+
+	out, err := exec.CommandContext(t.Context(), "gpg", "--batch", "--passphrase", "",
+		"--quick-gen-key", "Backups <backups@example.com>", "ed25519", "sign", "never",
+	).CombinedOutput()
+	if err != nil {
+		t.Skip("gpg cannot generate a key:", string(out))
+	}
+
+	dir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dir, "backup-2024-03-15-12-00.tar.gz"), nil, 0o600)
+	require.NoError(t, err)
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+summary_signing_key: backups@example.com
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err = os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("summary-file", summaryFile))
+
+	t.Cleanup(func() {
+		require.NoError(t, cmd.Flags().Set("summary-file", ""))
+	})
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+
+	verify := func() error {
+		return exec.CommandContext(t.Context(), "gpg", "--batch", "--verify",
+			summaryFile+".asc", summaryFile).Run()
+	}
+
+	require.NoError(t, verify())
+
+	// A summary changed after the run no longer matches its signature
+	data, err := os.ReadFile(summaryFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(summaryFile,
+		bytes.Replace(data, []byte(`"deleted": 0`), []byte(`"deleted": 9`), 1), 0o600))
+	require.Error(t, verify())
+
+	t.Run("unknown key", func(t *testing.T) {
+		viper.Reset()
+		t.Setenv("ARP_SUMMARY_SIGNING_KEY", "nobody@example.com")
+
+		err := cmd.RunE(cmd, nil)
+		require.ErrorContains(t, err, "failed to sign summary file")
+		require.FileExists(t, summaryFile)
+	})
+}
+
+func TestPruneCommandFailOnWarnings(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-13-45-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+fail_on_warnings: warning
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	summaryFile := filepath.Join(t.TempDir(), "summary.json")
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("summary-file", summaryFile))
+
+	t.Cleanup(func() {
+		require.NoError(t, cmd.Flags().Set("summary-file", ""))
+	})
+
+	err = cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errWarnings)
+	require.Equal(t, exitCodeWarnings, exitCode(err))
+	require.Contains(t, out.String(), "Warnings: 1")
+
+	var summary runSummary
+
+	data, err := os.ReadFile(summaryFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &summary))
+
+	require.Equal(t, exitCodeWarnings, summary.ExitCode)
+	require.Len(t, summary.Warnings, 1)
+	require.Equal(t, "warning", summary.Warnings[0].Severity)
+	require.Contains(t, summary.Warnings[0].Message, "backup-2024-13-45-12-00.tar.gz")
+}
+
+func TestPruneCommandRequireMountpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+require_mountpoint: true
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+
+	err = cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errNotMounted)
+	require.FileExists(t, filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"))
+
+	t.Run("filesystem type", func(t *testing.T) {
+		root, err := filepath.Abs(string(filepath.Separator))
+		require.NoError(t, err)
+
+		require.NoError(t, checkMountpoints("", []string{root}))
+
+		err = checkMountpoints("nosuchfs", []string{root})
+		require.ErrorIs(t, err, errNotMounted)
+		require.ErrorContains(t, err, "not nosuchfs")
+	})
+}
+
+func TestPruneCommandSentinelFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	configContent := `retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "` + filepath.ToSlash(dir) + `"
+sentinel_file: .backup-root
+`
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(configContent), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+
+	err = cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errMissingSentinel)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.FileExists(t, filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".backup-root"), nil, 0o600))
+
+	viper.Reset()
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.NoFileExists(t, filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"))
+	require.FileExists(t, filepath.Join(dir, ".backup-root"))
+}
+
+func TestPruneCommandCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`retention:
+  daily: 1
+file_pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz"
+directory: "`+filepath.ToSlash(dir)+`"
+`), 0o600))
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&bytes.Buffer{})
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.Flags().Set("check", "true"))
+
+	t.Cleanup(func() {
+		require.NoError(t, cmd.Flags().Set("check", "false"))
+	})
+
+	viper.Reset()
+
+	err := cmd.RunE(cmd, nil)
+	require.ErrorIs(t, err, errPending)
+	require.Equal(t, exitCodePendingDeletions, exitCode(err))
+
+	// A check is a dry run, so the backlog is still there
+	require.FileExists(t, filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz"))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "backup-2024-03-14-12-00.tar.gz")))
+
+	viper.Reset()
+	require.NoError(t, cmd.RunE(cmd, nil))
 }
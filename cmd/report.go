@@ -0,0 +1,399 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
+)
+
+// ANSI escape sequences used when writing to a terminal
+const (
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBold   = "\x1b[1m"
+	ansiReset  = "\x1b[0m"
+)
+
+// reclaimTiers is the order in which the footer lists reclaimed bytes
+var reclaimTiers = []retention.Reason{
+	retention.ReasonHourly,
+	retention.ReasonDaily,
+	retention.ReasonWeekly,
+	retention.ReasonMonthly,
+	retention.ReasonYearly,
+	retention.ReasonThinning,
+	retention.ReasonKeepCount,
+	retention.ReasonExpired,
+	retention.ReasonDuplicate,
+}
+
+// reporter prints the decision for every file of a prune run followed by a
+// summary footer. Output is color-coded when written to a terminal and plain
+// otherwise, so it stays easy to parse when piped. In quiet mode only failed
+// deletions are printed, in verbose mode every decision includes the reason
+// the policy gave for it. The footer breaks the reclaimed bytes down by the
+// tier the deleted files fell into. With record set every file is also
+// collected in files, for the summary file.
+type reporter struct {
+	w         io.Writer
+	color     bool
+	quiet     bool
+	verbose   bool
+	kept      int
+	reclaimed map[retention.Reason]int64
+	progress  *progress
+	record    bool
+	files     []fileRecord
+	// signingKey is the GPG key the summary file is signed with, if any
+	signingKey string
+	// runID identifies the run reported in logs, the journal and reports
+	runID string
+}
+
+// newReporter creates a reporter writing to w for a new run
+func newReporter(w io.Writer, quiet, verbose bool) *reporter {
+	return &reporter{
+		w:       w,
+		color:   isTerminal(w),
+		quiet:   quiet,
+		verbose: verbose,
+		runID:   newRunID(),
+	}
+}
+
+// newRunID returns a random, version 4 UUID identifying a run
+func newRunID() string {
+	var id [16]byte
+
+	// crypto/rand.Read never fails
+	_, _ = rand.Read(id[:])
+
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// keep reports a file retained by the policy
+func (r *reporter) keep(d retention.Decision) {
+	r.kept++
+	r.progress.observe(false, 0)
+	r.recordFile(fileRecord{Path: d.File.Path, Action: "keep", Reason: string(d.Reason)}, nil)
+
+	if !r.quiet {
+		r.line(ansiGreen, "keep", r.explain(d))
+	}
+}
+
+// remove reports a deleted file, or one that would be deleted in a dry run
+func (r *reporter) remove(d retention.Decision, dryRun bool) {
+	r.removed(d, "delete", r.explain(d), dryRun)
+}
+
+// archive reports a file moved to the archive directory dest instead of
+// being deleted, or one that would be moved in a dry run
+func (r *reporter) archive(d retention.Decision, dest string, dryRun bool) {
+	r.removed(d, "archive", r.explain(d)+" -> "+dest, dryRun)
+}
+
+// removed reports a file the policy gave up with verb, which is prefixed
+// with "would" in a dry run
+func (r *reporter) removed(d retention.Decision, verb, text string, dryRun bool) {
+	if r.reclaimed == nil {
+		r.reclaimed = map[retention.Reason]int64{}
+	}
+
+	r.reclaimed[d.Tier] += d.File.Size
+	r.recordFile(fileRecord{
+		Path:     d.File.Path,
+		Action:   verb,
+		Reason:   string(d.Reason),
+		Checksum: d.File.Checksum,
+	}, nil)
+
+	// Files deleted to recover free space were reported as kept first
+	if d.Reason == retention.ReasonEmergency {
+		r.kept--
+	} else {
+		r.progress.observe(true, d.File.Size)
+	}
+
+	if r.quiet {
+		return
+	}
+
+	if dryRun {
+		verb = "would " + verb
+	}
+
+	r.line(ansiRed, verb, text)
+}
+
+// clean reports a file deleted outside the retention policy, such as a
+// leftover temporary file, with what it was deleted as
+func (r *reporter) clean(what string, f file.Info, dryRun bool) {
+	r.recordFile(fileRecord{Path: f.Path, Action: "delete", Reason: what}, nil)
+
+	if r.quiet {
+		return
+	}
+
+	verb := "delete"
+	if dryRun {
+		verb = "would delete"
+	}
+
+	r.line(ansiRed, verb, fmt.Sprintf("%s (%s)", f.Path, what))
+}
+
+// compress reports a kept file compressed into target, or one that would be
+// compressed in a dry run
+func (r *reporter) compress(f file.Info, target string, dryRun bool) {
+	r.recordFile(fileRecord{Path: f.Path, Action: "compress"}, nil)
+
+	if r.quiet {
+		return
+	}
+
+	verb := "compress"
+	if dryRun {
+		verb = "would compress"
+	}
+
+	r.line(ansiYellow, verb, f.Path+" -> "+target)
+}
+
+// warn reports a problem that did not fail the run
+func (r *reporter) warn(w notify.Warning) {
+	if r.quiet {
+		return
+	}
+
+	color := ansiYellow
+	if w.Severity == string(file.SeverityError) {
+		color = ansiRed
+	}
+
+	r.line(color, w.Severity, w.Message)
+}
+
+// fail reports a file that could not be deleted
+func (r *reporter) fail(path string, err error) {
+	r.progress.observe(false, 0)
+	r.recordFile(fileRecord{Path: path, Action: "failed"}, err)
+	r.line(ansiBold+ansiRed, "failed", fmt.Sprintf("%s: %v", path, err))
+}
+
+// recordFile collects what happened to a file, if the reporter records
+func (r *reporter) recordFile(rec fileRecord, err error) {
+	if !r.record {
+		return
+	}
+
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	r.files = append(r.files, rec)
+}
+
+// footer prints the totals of the run
+func (r *reporter) footer(summary notify.Summary) {
+	r.progress.finish()
+
+	if r.quiet {
+		return
+	}
+
+	deleted, reclaimed := "deleted", "reclaimed"
+	if summary.DryRun {
+		deleted, reclaimed = "would be deleted", "would be reclaimed"
+	}
+
+	_, _ = fmt.Fprintf(r.w, "%s: %d kept, %d %s, %d failed, %s %s\n",
+		r.paint(ansiBold, "Summary"),
+		r.kept,
+		summary.Deleted,
+		deleted,
+		len(summary.Errors),
+		units.FormatBytes(summary.ReclaimedBytes),
+		reclaimed,
+	)
+
+	if len(summary.Warnings) > 0 {
+		_, _ = fmt.Fprintf(r.w, "%s: %d\n", r.paint(ansiBold, "Warnings"), len(summary.Warnings))
+	}
+
+	if summary.RetriesExhausted > 0 {
+		_, _ = fmt.Fprintf(r.w, "%s: %d\n",
+			r.paint(ansiBold, "Retries exhausted"), summary.RetriesExhausted)
+	}
+
+	if summary.Archived > 0 {
+		_, _ = fmt.Fprintf(r.w, "%s: %d\n", r.paint(ansiBold, "Archived"), summary.Archived)
+	}
+
+	if summary.Compressed > 0 {
+		_, _ = fmt.Fprintf(r.w, "%s: %d\n", r.paint(ansiBold, "Compressed"), summary.Compressed)
+	}
+
+	if summary.DryRun {
+		r.tierStats(summary)
+	}
+
+	var tiers []string
+
+	for _, tier := range reclaimTiers {
+		if size := r.reclaimed[tier]; size > 0 {
+			tiers = append(tiers, fmt.Sprintf("%s %s", tier, units.FormatBytes(size)))
+		}
+	}
+
+	if len(tiers) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(r.w, "%s: %s\n", r.paint(ansiBold, "By tier"), strings.Join(tiers, ", "))
+}
+
+// tierStats prints how many periods each retention tier filled, one line per
+// directory and tag, followed by the suggestions for the tiers left short.
+// Directories are only named when there is more than one.
+func (r *reporter) tierStats(summary notify.Summary) {
+	var (
+		groups      []string
+		filled      = map[string][]string{}
+		suggestions []string
+	)
+
+	multiple := slices.ContainsFunc(summary.Tiers, func(t notify.Tier) bool {
+		return t.Directory != summary.Tiers[0].Directory
+	})
+
+	for _, t := range summary.Tiers {
+		var scope []string
+		if multiple {
+			scope = append(scope, t.Directory)
+		}
+
+		if t.Tag != "" {
+			scope = append(scope, "tag "+t.Tag)
+		}
+
+		group := strings.Join(scope, ", ")
+		if _, ok := filled[group]; !ok {
+			groups = append(groups, group)
+		}
+
+		filled[group] = append(filled[group],
+			fmt.Sprintf("%s %d/%d", t.Name, t.Filled, t.Requested))
+
+		switch {
+		case t.Suggestion == "":
+		case multiple:
+			suggestions = append(suggestions, t.Directory+": "+t.Suggestion)
+		default:
+			suggestions = append(suggestions, t.Suggestion)
+		}
+	}
+
+	for _, group := range groups {
+		label := "Periods filled"
+		if group != "" {
+			label += " (" + group + ")"
+		}
+
+		_, _ = fmt.Fprintf(r.w, "%s: %s\n",
+			r.paint(ansiBold, label), strings.Join(filled[group], ", "))
+	}
+
+	for _, suggestion := range suggestions {
+		_, _ = fmt.Fprintf(r.w, "%s: %s\n", r.paint(ansiYellow, "Suggestion"), suggestion)
+	}
+}
+
+// explain appends the decision reason, and the tier slot if any, to the
+// path of a file in verbose mode
+func (r *reporter) explain(d retention.Decision) string {
+	switch {
+	case !r.verbose || d.Reason == "":
+		return d.File.Path
+	case d.Slot > 0:
+		return fmt.Sprintf("%s (%s #%d)", d.File.Path, d.Reason, d.Slot)
+	default:
+		return fmt.Sprintf("%s (%s)", d.File.Path, d.Reason)
+	}
+}
+
+// line prints a single decision line with a fixed width verb column
+func (r *reporter) line(color, verb, text string) {
+	r.progress.clear()
+	_, _ = fmt.Fprintf(r.w, "%s %s\n", r.paint(color, fmt.Sprintf("%-12s", verb)), text)
+}
+
+// paint wraps text in an ANSI color when color output is enabled
+func (r *reporter) paint(color, text string) string {
+	if !r.color {
+		return text
+	}
+
+	return color + text + ansiReset
+}
+
+// isTerminal reports whether w is a terminal that should receive colored
+// output. The NO_COLOR convention and TERM=dumb disable colors.
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return isTTY(w)
+}
+
+// isTTY reports whether w is a terminal device
+func isTTY(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
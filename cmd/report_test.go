@@ -0,0 +1,196 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+)
+
+func TestReporter(t *testing.T) {
+	decision := func(path string, reason retention.Reason, slot int) retention.Decision {
+		return retention.Decision{File: file.Info{Path: path}, Reason: reason, Slot: slot}
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := newReporter(&buf, false, false)
+		require.False(t, rep.color)
+
+		rep.keep(decision("a.tar.gz", retention.ReasonDaily, 0))
+		rep.remove(decision("b.tar.gz", retention.ReasonExpired, 0), false)
+		rep.fail("c.tar.gz", errors.New("permission denied"))
+		rep.footer(notify.Summary{
+			Deleted:        1,
+			ReclaimedBytes: 3 << 20,
+			Errors:         []string{"permission denied"},
+		})
+
+		require.Equal(t, `keep         a.tar.gz
+delete       b.tar.gz
+failed       c.tar.gz: permission denied
+Summary: 1 kept, 1 deleted, 1 failed, 3.0 MiB reclaimed
+`, buf.String())
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := newReporter(&buf, false, false)
+		rep.remove(decision("b.tar.gz", retention.ReasonExpired, 0), true)
+		rep.footer(notify.Summary{DryRun: true, Deleted: 1, ReclaimedBytes: 512})
+
+		require.Equal(t, `would delete b.tar.gz
+Summary: 0 kept, 1 would be deleted, 0 failed, 512 B would be reclaimed
+`, buf.String())
+	})
+
+	t.Run("tier statistics", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := newReporter(&buf, false, false)
+		rep.footer(notify.Summary{
+			DryRun: true,
+			Tiers: []notify.Tier{
+				{Directory: "/a", Name: "daily", Requested: 7, Filled: 7},
+				{
+					Directory:  "/a",
+					Name:       "weekly",
+					Requested:  4,
+					Filled:     2,
+					Suggestion: "weekly: 4 requested but only 2 weeks of data exist",
+				},
+				{Directory: "/a", Tag: "db", Name: "daily", Requested: 7, Filled: 7},
+			},
+		})
+
+		require.Equal(t, `Summary: 0 kept, 0 would be deleted, 0 failed, 0 B would be reclaimed
+Periods filled: daily 7/7, weekly 2/4
+Periods filled (tag db): daily 7/7
+Suggestion: weekly: 4 requested but only 2 weeks of data exist
+`, buf.String())
+
+		buf.Reset()
+		rep.footer(notify.Summary{
+			DryRun: true,
+			Tiers: []notify.Tier{
+				{Directory: "/a", Name: "daily", Requested: 7, Filled: 7},
+				{
+					Directory:  "/b",
+					Name:       "daily",
+					Requested:  7,
+					Filled:     3,
+					Suggestion: "daily: 7 requested but only 3 days of data exist",
+				},
+			},
+		})
+
+		require.Equal(t, `Summary: 0 kept, 0 would be deleted, 0 failed, 0 B would be reclaimed
+Periods filled (/a): daily 7/7
+Periods filled (/b): daily 3/7
+Suggestion: /b: daily: 7 requested but only 3 days of data exist
+`, buf.String())
+	})
+
+	t.Run("verbose", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := newReporter(&buf, false, true)
+		rep.keep(decision("a.tar.gz", retention.ReasonDaily, 2))
+		rep.keep(decision("b.tar.gz", retention.ReasonPinned, 0))
+		rep.remove(decision("c.tar.gz", retention.ReasonSuperseded, 0), false)
+
+		require.Equal(t, `keep         a.tar.gz (daily #2)
+keep         b.tar.gz (pinned)
+delete       c.tar.gz (superseded)
+`, buf.String())
+	})
+
+	t.Run("reclaimed by tier", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := newReporter(&buf, true, false)
+
+		for _, d := range []retention.Decision{
+			{File: file.Info{Path: "a", Size: 1024}, Tier: retention.ReasonDaily},
+			{File: file.Info{Path: "b", Size: 2048}, Tier: retention.ReasonExpired},
+			{File: file.Info{Path: "c", Size: 1024}, Tier: retention.ReasonHourly},
+			{File: file.Info{Path: "d", Size: 1024}, Tier: retention.ReasonDaily},
+		} {
+			d.Delete = true
+			rep.remove(d, false)
+		}
+
+		rep.quiet = false
+		rep.footer(notify.Summary{Deleted: 4, ReclaimedBytes: 5120})
+
+		require.Equal(t, `Summary: 0 kept, 4 deleted, 0 failed, 5.0 KiB reclaimed
+By tier: hourly 1.0 KiB, daily 2.0 KiB, expired 2.0 KiB
+`, buf.String())
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := newReporter(&buf, true, false)
+		rep.keep(decision("a.tar.gz", retention.ReasonDaily, 0))
+		rep.remove(decision("b.tar.gz", retention.ReasonExpired, 0), false)
+		rep.fail("c.tar.gz", errors.New("permission denied"))
+		rep.footer(notify.Summary{Deleted: 1})
+
+		require.Equal(t, "failed       c.tar.gz: permission denied\n", buf.String())
+	})
+
+	t.Run("color", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		rep := &reporter{w: &buf, color: true}
+		rep.keep(decision("a.tar.gz", retention.ReasonDaily, 0))
+		rep.remove(decision("b.tar.gz", retention.ReasonExpired, 0), false)
+
+		require.Equal(t,
+			ansiGreen+"keep        "+ansiReset+" a.tar.gz\n"+
+				ansiRed+"delete      "+ansiReset+" b.tar.gz\n",
+			buf.String())
+	})
+}
+
+func TestNewRunID(t *testing.T) {
+	id := newRunID()
+	require.Regexp(t,
+		`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	require.NotEqual(t, id, newRunID())
+	require.NotEqual(t, newReporter(io.Discard, false, false).runID,
+		newReporter(io.Discard, false, false).runID)
+}
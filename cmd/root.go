@@ -29,12 +29,17 @@
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var cfgFile string
 
+// clock tells the current time for every check relative to now, such as
+// freshness.max_age, compress_after and stale_files.min_age
+var clock = time.Now
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "apply-retention-policy",
@@ -53,10 +58,11 @@
 // Execute adds all child commands to the root command and sets flags
 // appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands may return an exitError to select a specific exit code.
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -66,5 +72,8 @@ func init() {
 	// will be global for your application.
 	rootCmd.PersistentFlags().
 		StringVar(&cfgFile, "config", "",
-			"config file (default is $HOME/.apply-retention-policy.yaml)")
+			"config file or https:// URL (default is the first found of ./retention-policy.yaml, "+
+				"$XDG_CONFIG_HOME/apply-retention-policy/config.yaml, "+
+				"$HOME/.apply-retention-policy/retention-policy.yaml and "+
+				"/etc/apply-retention-policy/retention-policy.yaml)")
 }
@@ -0,0 +1,202 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+//go:generate go test -run TestScenarios -update
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+var updateScenarios = flag.Bool("update", false,
+	"rewrite the expected results of the scenarios in testdata/scenarios")
+
+// scenario is an end-to-end test read from testdata/scenarios. The files are
+// created empty in a fresh directory, which config is applied to with the
+// directory key added; expected lists the files prune keeps and deletes.
+type scenario struct {
+	Description string   `mapstructure:"description"`
+	Config      string   `mapstructure:"config"`
+	Files       []string `mapstructure:"files"`
+	Expected    struct {
+		Kept    []string `mapstructure:"kept"`
+		Deleted []string `mapstructure:"deleted"`
+	} `mapstructure:"expected"`
+}
+
+func TestScenarios(t *testing.T) {
+	// Absolute, since every scenario runs in a directory of its own
+	dir, err := filepath.Abs(filepath.Join("testdata", "scenarios"))
+	require.NoError(t, err)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".yaml"), func(t *testing.T) {
+			sc := readScenario(t, path)
+			kept, deleted := runScenario(t, sc)
+
+			if *updateScenarios {
+				writeExpected(t, path, kept, deleted)
+				return
+			}
+
+			require.Equal(t, sc.Expected.Kept, kept, sc.Description)
+			require.Equal(t, sc.Expected.Deleted, deleted, sc.Description)
+		})
+	}
+}
+
+// readScenario reads the scenario fixture at path
+func readScenario(t *testing.T, path string) scenario {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+
+	var sc scenario
+	require.NoError(t, v.Unmarshal(&sc))
+	require.NotEmpty(t, sc.Files, "scenario lists no files")
+
+	return sc
+}
+
+// runScenario prunes the files of sc and returns the paths of the files
+// kept and deleted, sorted
+func runScenario(t *testing.T, sc scenario) (kept, deleted []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for _, name := range sc.Files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+	}
+
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	config := sc.Config + "\ndirectory: " + strconv.Quote(filepath.ToSlash(dir)) + "\n"
+	require.NoError(t, os.WriteFile(configFile, []byte(config), 0o600))
+
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	viper.Reset()
+
+	var out bytes.Buffer
+
+	cmd := pruneCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Flags().Set("config", configFile))
+	require.NoError(t, cmd.RunE(cmd, nil), out.String())
+
+	kept = []string{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		kept = append(kept, filepath.ToSlash(rel))
+
+		return err
+	})
+	require.NoError(t, err)
+
+	deleted = []string{}
+
+	for _, name := range sc.Files {
+		if !slices.Contains(kept, name) {
+			deleted = append(deleted, name)
+		}
+	}
+
+	slices.Sort(kept)
+	slices.Sort(deleted)
+
+	return kept, deleted
+}
+
+// writeExpected replaces the expected section at the end of the fixture at
+// path with kept and deleted
+func writeExpected(t *testing.T, path string, kept, deleted []string) {
+	t.Helper()
+
+	// File names written without quotes
+	plain := regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	fixture := string(data)
+	if i := strings.Index(fixture, "\nexpected:"); i >= 0 {
+		fixture = fixture[:i+1]
+	}
+
+	var b strings.Builder
+
+	b.WriteString(fixture)
+	b.WriteString("expected:\n")
+
+	for _, list := range []struct {
+		key   string
+		names []string
+	}{{"kept", kept}, {"deleted", deleted}} {
+		if len(list.names) == 0 {
+			b.WriteString("  " + list.key + ": []\n")
+			continue
+		}
+
+		b.WriteString("  " + list.key + ":\n")
+
+		for _, name := range list.names {
+			if !plain.MatchString(name) {
+				name = strconv.Quote(name)
+			}
+
+			b.WriteString("    - " + name + "\n")
+		}
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o600))
+}
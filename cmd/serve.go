@@ -0,0 +1,502 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/catalog"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/hooks"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+const (
+	// serveReadHeaderTimeout bounds how long a client may take to send the
+	// headers of a request
+	serveReadHeaderTimeout = 10 * time.Second
+	// maxPolicyBytes is the largest policy PUT /v1/policy reads
+	maxPolicyBytes = 64 << 10
+	// maxAgentBytes is the largest inventory or report an agent may send
+	maxAgentBytes = 64 << 20
+)
+
+var (
+	// errNoToken is returned by serve and agent when no API token is
+	// configured
+	errNoToken = errors.New("api.token must be set")
+	// errBusy is reported when a prune or plan is requested while another
+	// one is running
+	errBusy = errors.New("a prune is already running")
+	// errPolicyOverridden is reported when PUT /v1/policy would not change
+	// how backups are pruned, because the config applies other retention
+	// settings instead of the top-level ones
+	errPolicyOverridden = errors.New("the config overrides the top-level retention policy")
+)
+
+var serveListen string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to prune, plan and change the policy remotely",
+	Long: `Serve a JSON API over HTTP, so a central backup management UI can drive
+the tool remotely. Every request must carry the token configured with
+api.token (or ARP_API_TOKEN) as "Authorization: Bearer <token>".
+
+  POST /v1/prune          run a prune, with hooks and notifications, and
+                          return its summary
+  GET  /v1/plan           return what a prune would keep and delete now
+  GET  /v1/history        list the runs recorded in the catalog
+  GET  /v1/history/{id}   show a run and every deletion it made
+  GET  /v1/policy         show the active retention policy
+  PUT  /v1/policy         replace the active retention policy
+  GET  /v1/agents         list the agents that reported to this server
+
+The server also coordinates agents, see "apply-retention-policy agent --help".
+
+Only one prune or plan runs at a time; others are refused with 409
+Conflict. A policy set with PUT /v1/policy lasts until the server stops,
+keeps the configured thinning tiers, and is refused with 409 Conflict when
+a named policy, tag_retention or a subdirectories rule with a policy
+would override it.
+SIGINT or SIGTERM stops a running prune after the current deletion, as for
+the prune command, and shuts the server down.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if cfg.API.Token == "" {
+			return errNoToken
+		}
+
+		log, err := logging.New(cfg.LogLevel, loggerOptions(cfg)...)
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer log.SyncQuietly()
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", serveListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+
+		log.Info("serving API", zap.String("address", listener.Addr().String()))
+
+		return serveAPI(ctx, listener, newAPIServer(cfg, log))
+	},
+}
+
+// serveAPI serves the API on listener until ctx is done, then waits for the
+// requests in progress to finish. Requests see ctx as their parent, so a
+// running prune stops after its current deletion.
+func serveAPI(ctx context.Context, listener net.Listener, api *apiServer) error {
+	srv := &http.Server{
+		Handler:           api.handler(),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	err := srv.Shutdown(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+
+	return nil
+}
+
+// apiServer handles the requests of the serve command. cfg is the
+// configuration loaded at startup with the policy set through the API
+// applied, and agents the status of every agent by name; running is held
+// while a prune or plan runs.
+type apiServer struct {
+	log     *logging.Logger
+	mu      sync.Mutex
+	cfg     config.Config
+	agents  map[string]agentStatus
+	running sync.Mutex
+}
+
+// apiPolicy is the retention policy shown and replaced by /v1/policy
+type apiPolicy struct {
+	Hourly    int `json:"hourly"`
+	Daily     int `json:"daily"`
+	Weekly    int `json:"weekly"`
+	Monthly   int `json:"monthly"`
+	Yearly    int `json:"yearly"`
+	KeepCount int `json:"keep_count"`
+}
+
+// apiRun is a catalog run with the deletions it made, as returned by
+// /v1/history/{id}
+type apiRun struct {
+	catalog.Run
+
+	Events []catalog.Event `json:"events"`
+}
+
+// apiError is the body of every failed request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// newAPIServer creates an API server starting from cfg
+func newAPIServer(cfg *config.Config, log *logging.Logger) *apiServer {
+	return &apiServer{log: log, cfg: *cfg}
+}
+
+// handler returns the handler serving every API endpoint behind token
+// authentication
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/prune", s.handlePrune)
+	mux.HandleFunc("GET /v1/plan", s.handlePlan)
+	mux.HandleFunc("GET /v1/history", s.handleHistory)
+	mux.HandleFunc("GET /v1/history/{id}", s.handleRun)
+	mux.HandleFunc("GET /v1/policy", s.handleGetPolicy)
+	mux.HandleFunc("PUT /v1/policy", s.handlePutPolicy)
+	mux.HandleFunc("GET /v1/agents", s.handleAgents)
+	mux.HandleFunc("POST /v1/agents/{name}/inventory", s.handleInventory)
+	mux.HandleFunc("POST /v1/agents/{name}/report", s.handleReport)
+
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the configured bearer token
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	want := []byte("Bearer " + s.cfg.API.Token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="apply-retention-policy"`)
+			s.fail(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// config returns a copy of the active configuration
+func (s *apiServer) config() config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cfg
+}
+
+// handlePrune runs a prune as the prune command does and returns its
+// summary. The run stops after the current deletion if the client goes
+// away.
+func (s *apiServer) handlePrune(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
+
+	s.run(w, r, func(
+		ctx context.Context, log *logging.Logger, rep *reporter,
+	) (notify.Summary, error) {
+		return pruneWithHooks(ctx, r.Context(), log, &cfg, rep)
+	})
+}
+
+// handlePlan returns the decisions a dry run makes now, without running
+// hooks, recording the run or sending notifications
+func (s *apiServer) handlePlan(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
+	cfg.DryRun = true
+	cfg.Catalog = ""
+
+	s.run(w, r, func(
+		ctx context.Context, log *logging.Logger, rep *reporter,
+	) (notify.Summary, error) {
+		return prune(ctx, r.Context(), log, &cfg, hooks.NewRunner(), rep)
+	})
+}
+
+// run calls do unless another prune or plan is running, and replies with
+// the summary of the run it made. do logs with the run's ID, like the prune
+// command.
+func (s *apiServer) run(
+	w http.ResponseWriter,
+	r *http.Request,
+	do func(context.Context, *logging.Logger, *reporter) (notify.Summary, error),
+) {
+	if !s.running.TryLock() {
+		s.fail(w, http.StatusConflict, errBusy)
+		return
+	}
+	defer s.running.Unlock()
+
+	started := time.Now()
+
+	rep := newReporter(io.Discard, true, false)
+	rep.record = true
+
+	log := s.log.With(zap.String("run_id", rep.runID))
+
+	summary, err := do(context.WithoutCancel(r.Context()), log, rep)
+	if err != nil {
+		log.Warn("run requested through the API failed", zap.Error(err))
+	}
+
+	summary.RunID = rep.runID
+
+	s.reply(w, http.StatusOK, newRunSummary(summary, rep.files, started, err))
+}
+
+// handleHistory lists the runs recorded in the catalog, the newest limit
+// of them when the limit parameter is given
+func (s *apiServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+
+		limit, err = strconv.Atoi(raw)
+		if err != nil {
+			s.fail(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", raw))
+			return
+		}
+	}
+
+	cat, ok := s.catalog(w)
+	if !ok {
+		return
+	}
+
+	runs := newest(cat.Runs(), limit)
+	if runs == nil {
+		runs = []catalog.Run{}
+	}
+
+	s.reply(w, http.StatusOK, runs)
+}
+
+// handleRun shows a single run with every deletion decision it made
+func (s *apiServer) handleRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("%w: %s", errRunNotFound, r.PathValue("id")))
+		return
+	}
+
+	cat, ok := s.catalog(w)
+	if !ok {
+		return
+	}
+
+	run, ok := cat.Run(id)
+	if !ok {
+		s.fail(w, http.StatusNotFound, fmt.Errorf("%w: %d", errRunNotFound, id))
+		return
+	}
+
+	events := slices.DeleteFunc(cat.History(), func(e catalog.Event) bool {
+		return e.Run != id
+	})
+	if events == nil {
+		events = []catalog.Event{}
+	}
+
+	s.reply(w, http.StatusOK, apiRun{Run: run, Events: events})
+}
+
+// catalog opens the configured catalog, replying with an error when there
+// is none or it cannot be read
+func (s *apiServer) catalog(w http.ResponseWriter) (*catalog.Catalog, bool) {
+	cfg := s.config()
+
+	if cfg.Catalog == "" {
+		s.fail(w, http.StatusNotFound, errNoCatalog)
+		return nil, false
+	}
+
+	cat, err := catalog.Open(cfg.Catalog)
+	if err != nil {
+		s.fail(w, http.StatusInternalServerError, fmt.Errorf("failed to open catalog: %w", err))
+		return nil, false
+	}
+
+	return cat, true
+}
+
+// handleGetPolicy shows the active retention policy
+func (s *apiServer) handleGetPolicy(w http.ResponseWriter, _ *http.Request) {
+	cfg := s.config()
+
+	s.reply(w, http.StatusOK, apiPolicy{
+		Hourly:    cfg.Retention.Hourly,
+		Daily:     cfg.Retention.Daily,
+		Weekly:    cfg.Retention.Weekly,
+		Monthly:   cfg.Retention.Monthly,
+		Yearly:    cfg.Retention.Yearly,
+		KeepCount: cfg.KeepCount,
+	})
+}
+
+// handlePutPolicy replaces the active retention policy, after checking the
+// configuration it results in. The thinning tiers of the config are kept.
+func (s *apiServer) handlePutPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy apiPolicy
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPolicyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&policy); err != nil {
+		s.fail(w, decodeStatus(err), fmt.Errorf("invalid policy: %w", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.cfg
+	if err := policyOverride(&cfg); err != nil {
+		s.fail(w, http.StatusConflict, err)
+		return
+	}
+
+	cfg.Retention = config.RetentionPolicy{
+		Hourly:   policy.Hourly,
+		Daily:    policy.Daily,
+		Weekly:   policy.Weekly,
+		Monthly:  policy.Monthly,
+		Yearly:   policy.Yearly,
+		Thinning: cfg.Retention.Thinning,
+	}
+	cfg.KeepCount = policy.KeepCount
+
+	if err := cfg.Validate(); err != nil {
+		s.fail(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	s.cfg = cfg
+
+	s.log.Info("retention policy updated through the API",
+		zap.Any("retention", cfg.Retention), zap.Int("keep_count", cfg.KeepCount))
+
+	s.reply(w, http.StatusOK, policy)
+}
+
+// policyOverride returns why a policy set through the API would not apply
+// to every backup of cfg, or nil when it would. A named policy replaces the
+// top-level retention when it is selected, tag_retention replaces it for
+// tagged backups and a subdirectories rule with a policy for the
+// directories it matches.
+func policyOverride(cfg *config.Config) error {
+	switch {
+	case cfg.Policy != "":
+		return fmt.Errorf("%w: the named policy %q is selected", errPolicyOverridden, cfg.Policy)
+	case len(cfg.TagRetention) > 0:
+		return fmt.Errorf("%w: tag_retention is set", errPolicyOverridden)
+	}
+
+	for _, rule := range cfg.Subdirectories {
+		if rule.Policy != "" {
+			return fmt.Errorf("%w: subdirectories %q use the named policy %q",
+				errPolicyOverridden, rule.Path, rule.Policy)
+		}
+	}
+
+	return nil
+}
+
+// reply writes v as the JSON body of a response with status
+func (s *apiServer) reply(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Warn("failed to write API response", zap.Error(err))
+	}
+}
+
+// fail replies with status and err as the error of the body
+func (s *apiServer) fail(w http.ResponseWriter, status int, err error) {
+	s.reply(w, status, apiError{Error: err.Error()})
+}
+
+// decodeStatus returns the status refusing a request body that failed to
+// decode with err
+func decodeStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+	serveCmd.Flags().
+		StringVar(&serveListen, "listen", "127.0.0.1:8080", "Address to serve the API on")
+}
@@ -0,0 +1,329 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/catalog"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// apiRequest sends a request with the token test-token to the API at base
+// and decodes the JSON response into v
+func apiRequest(t *testing.T, base, method, path, body string, v any) int {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), method, base+path,
+		strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer test-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, resp.Body.Close()) }()
+
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+
+	return resp.StatusCode
+}
+
+func TestServeAPI(t *testing.T) {
+	dir := t.TempDir()
+
+	names := []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-12-00.tar.gz",
+		"backup-2024-03-13-12-00.tar.gz",
+	}
+
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	cfg := &config.Config{
+		Retention:      config.RetentionPolicy{Daily: 1},
+		FilePattern:    "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz",
+		Directories:    []string{dir},
+		RequireMinimum: config.DefaultRequireMinimum,
+		Catalog:        filepath.Join(t.TempDir(), "catalog.json"),
+		API:            config.APIConfig{Token: "test-token"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	api := newAPIServer(cfg, &logging.Logger{Logger: zap.New(core)})
+	srv := httptest.NewServer(api.handler())
+	t.Cleanup(srv.Close)
+
+	t.Run("token required", func(t *testing.T) {
+		for _, header := range []string{"", "Bearer wrong", "test-token"} {
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet,
+				srv.URL+"/v1/policy", nil)
+			require.NoError(t, err)
+
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			require.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		var plan runSummary
+		require.Equal(t, http.StatusOK,
+			apiRequest(t, srv.URL, http.MethodGet, "/v1/plan", "", &plan))
+		require.True(t, plan.DryRun)
+		require.Equal(t, 3, plan.Matched)
+		require.Equal(t, 2, plan.Deleted)
+		require.Len(t, plan.Files, 3)
+
+		for _, name := range names {
+			require.FileExists(t, filepath.Join(dir, name))
+		}
+
+		require.NoFileExists(t, cfg.Catalog)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		var policy apiPolicy
+		require.Equal(t, http.StatusOK,
+			apiRequest(t, srv.URL, http.MethodGet, "/v1/policy", "", &policy))
+		require.Equal(t, apiPolicy{Daily: 1}, policy)
+
+		var apiErr apiError
+		require.Equal(t, http.StatusBadRequest,
+			apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", `{"dayly": 2}`, &apiErr))
+		require.Contains(t, apiErr.Error, "invalid policy")
+
+		require.Equal(t, http.StatusUnprocessableEntity,
+			apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", `{"daily": -1}`, &apiErr))
+
+		huge := `{"daily": 2` + strings.Repeat(" ", maxPolicyBytes) + `}`
+		require.Equal(t, http.StatusRequestEntityTooLarge,
+			apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", huge, &apiErr))
+
+		require.Equal(t, http.StatusOK,
+			apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", `{"daily": 2}`, &policy))
+		require.Equal(t, apiPolicy{Daily: 2}, policy)
+	})
+
+	t.Run("busy", func(t *testing.T) {
+		api.running.Lock()
+		defer api.running.Unlock()
+
+		var apiErr apiError
+		require.Equal(t, http.StatusConflict,
+			apiRequest(t, srv.URL, http.MethodPost, "/v1/prune", "", &apiErr))
+		require.Equal(t, errBusy.Error(), apiErr.Error)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		logs.TakeAll()
+
+		var summary runSummary
+		require.Equal(t, http.StatusOK,
+			apiRequest(t, srv.URL, http.MethodPost, "/v1/prune", "", &summary))
+		require.False(t, summary.DryRun)
+		require.Equal(t, 1, summary.Deleted)
+		require.Zero(t, summary.ExitCode)
+
+		// Everything logged during the run carries its ID
+		require.NotEmpty(t, summary.RunID)
+		require.NotZero(t, logs.Len())
+
+		for _, entry := range logs.All() {
+			require.Equal(t, summary.RunID, entry.ContextMap()["run_id"], entry.Message)
+		}
+
+		// The policy set through the API keeps two daily backups
+		require.FileExists(t, filepath.Join(dir, names[0]))
+		require.FileExists(t, filepath.Join(dir, names[1]))
+		require.NoFileExists(t, filepath.Join(dir, names[2]))
+	})
+
+	t.Run("history", func(t *testing.T) {
+		var runs []catalog.Run
+		require.Equal(t, http.StatusOK,
+			apiRequest(t, srv.URL, http.MethodGet, "/v1/history", "", &runs))
+		require.Len(t, runs, 1)
+		require.Equal(t, 1, runs[0].Deleted)
+
+		var run apiRun
+		require.Equal(t, http.StatusOK,
+			apiRequest(t, srv.URL, http.MethodGet, "/v1/history/1", "", &run))
+		require.Equal(t, 1, run.ID)
+		require.Len(t, run.Events, 1)
+		require.Equal(t, filepath.Join(dir, names[2]), run.Events[0].Path)
+
+		var apiErr apiError
+		for _, path := range []string{"/v1/history/2", "/v1/history/latest"} {
+			require.Equal(t, http.StatusNotFound,
+				apiRequest(t, srv.URL, http.MethodGet, path, "", &apiErr))
+			require.Contains(t, apiErr.Error, errRunNotFound.Error())
+		}
+
+		require.Equal(t, http.StatusBadRequest,
+			apiRequest(t, srv.URL, http.MethodGet, "/v1/history?limit=all", "", &apiErr))
+	})
+}
+
+func TestServeAPIPolicyThinning(t *testing.T) {
+	thinning := []config.ThinningTier{{Every: 5, Count: 2}}
+	cfg := &config.Config{
+		Retention:   config.RetentionPolicy{Yearly: 1, Thinning: thinning},
+		FilePattern: "*",
+		Directories: []string{t.TempDir()},
+		API:         config.APIConfig{Token: "test-token"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	api := newAPIServer(cfg, logging.NewDefault())
+	srv := httptest.NewServer(api.handler())
+	t.Cleanup(srv.Close)
+
+	var policy apiPolicy
+	require.Equal(t, http.StatusOK,
+		apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", `{"yearly": 3}`, &policy))
+
+	active := api.config()
+	require.Equal(t, config.RetentionPolicy{Yearly: 3, Thinning: thinning}, active.Retention)
+}
+
+func TestServeAPIPolicyOverridden(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+	}{
+		{
+			name: "named policy",
+			modify: func(cfg *config.Config) {
+				cfg.Policy = "prod"
+			},
+		},
+		{
+			name: "tag_retention",
+			modify: func(cfg *config.Config) {
+				cfg.TagRetention = config.TagPolicies{"db": {Daily: 7}}
+			},
+		},
+		{
+			name: "subdirectories",
+			modify: func(cfg *config.Config) {
+				cfg.Subdirectories = []config.SubdirectoryPolicy{{Path: "db*", Policy: "prod"}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Retention:   config.RetentionPolicy{Daily: 1},
+				FilePattern: "*",
+				Directories: []string{t.TempDir()},
+				Policies: map[string]config.NamedPolicy{
+					"prod": {Retention: config.RetentionPolicy{Daily: 30}},
+				},
+				API: config.APIConfig{Token: "test-token"},
+			}
+			tt.modify(cfg)
+			require.NoError(t, cfg.Validate())
+
+			api := newAPIServer(cfg, logging.NewDefault())
+			srv := httptest.NewServer(api.handler())
+			t.Cleanup(srv.Close)
+
+			var apiErr apiError
+			require.Equal(t, http.StatusConflict,
+				apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", `{"daily": 2}`, &apiErr))
+			require.Contains(t, apiErr.Error, errPolicyOverridden.Error())
+
+			active := api.config()
+			require.Equal(t, cfg.Retention, active.Retention)
+		})
+	}
+
+	// A subdirectories rule without a policy uses the top-level settings
+	cfg := &config.Config{
+		Retention:      config.RetentionPolicy{Daily: 1},
+		FilePattern:    "*",
+		Directories:    []string{t.TempDir()},
+		Subdirectories: []config.SubdirectoryPolicy{{Path: "logs*"}},
+		API:            config.APIConfig{Token: "test-token"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	srv := httptest.NewServer(newAPIServer(cfg, logging.NewDefault()).handler())
+	t.Cleanup(srv.Close)
+
+	var policy apiPolicy
+	require.Equal(t, http.StatusOK,
+		apiRequest(t, srv.URL, http.MethodPut, "/v1/policy", `{"daily": 2}`, &policy))
+}
+
+func TestServeCommandRequiresToken(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+	err := os.WriteFile(configFile, []byte(`file_pattern: "backup-{year}.tar.gz"
+directory: "`+filepath.ToSlash(t.TempDir())+`"
+`), 0o600)
+	require.NoError(t, err)
+
+	viper.Reset()
+	require.NoError(t, serveCmd.Flags().Set("config", configFile))
+	require.ErrorIs(t, serveCmd.RunE(serveCmd, nil), errNoToken)
+}
+
+func TestServeAPIShutdown(t *testing.T) {
+	listener, err := (&net.ListenConfig{}).Listen(t.Context(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	api := newAPIServer(&config.Config{}, logging.NewDefault())
+	require.NoError(t, serveAPI(ctx, listener, api))
+}
@@ -0,0 +1,221 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+)
+
+// defaultServiceName is the name a service is installed under by default
+const defaultServiceName = "apply-retention-policy"
+
+var (
+	// errServiceUnsupported is returned by the service commands on systems
+	// without a supported service manager
+	errServiceUnsupported = errors.New("services are only supported with systemd and on Windows")
+	// errNoConfigFile is returned by service install when no config file
+	// was found for the service to use
+	errNoConfigFile = errors.New("no config file to install the service with")
+	// errServiceInterval is returned for an --interval that is not positive
+	errServiceInterval = errors.New("service interval must be positive")
+)
+
+var (
+	serviceName     string
+	serviceInterval time.Duration
+)
+
+// serviceSpec describes the service install registers: prune run by
+// executable every interval with a config file
+type serviceSpec struct {
+	name       string
+	executable string
+	config     string
+	interval   time.Duration
+	// eventSource is the Windows Event Log source to register, if any
+	eventSource string
+}
+
+// serviceManager installs, removes, starts and stops the service of the
+// platform's service manager
+type serviceManager interface {
+	Install(ctx context.Context, spec serviceSpec) error
+	Uninstall(ctx context.Context) error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// serviceCmd groups the service subcommands
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run prune periodically as a systemd or Windows service",
+	Long: `Install, uninstall, start and stop a service that runs prune with the
+current config file every --interval.
+
+With systemd, install writes a Type=notify service running prune and a
+timer triggering it to /etc/systemd/system and enables the timer. On
+Windows, install registers a service with the service control manager that
+runs prune itself, and the log_event_source, if set, as an event log
+source. Installing and uninstalling need root or administrator rights.`,
+}
+
+// serviceInstallCmd represents the service install command
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the service with the current config file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		spec, err := newServiceSpec()
+		if err != nil {
+			return err
+		}
+
+		return runService(cmd, "installed", func(ctx context.Context, m serviceManager) error {
+			return m.Install(ctx, spec)
+		})
+	},
+}
+
+// serviceUninstallCmd represents the service uninstall command
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runService(cmd, "uninstalled", func(ctx context.Context, m serviceManager) error {
+			return m.Uninstall(ctx)
+		})
+	},
+}
+
+// serviceStartCmd represents the service start command
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the installed service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runService(cmd, "started", func(ctx context.Context, m serviceManager) error {
+			return m.Start(ctx)
+		})
+	},
+}
+
+// serviceStopCmd represents the service stop command
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the installed service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runService(cmd, "stopped", func(ctx context.Context, m serviceManager) error {
+			return m.Stop(ctx)
+		})
+	},
+}
+
+// runService applies action to the service named by --name and reports it
+func runService(
+	cmd *cobra.Command, done string, action func(context.Context, serviceManager) error,
+) error {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	m, err := newServiceManager(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if err := action(ctx, m); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Service %s %s\n", serviceName, done)
+
+	return nil
+}
+
+// newServiceSpec loads and validates the current config and describes the
+// service running prune with it
+func newServiceSpec() (serviceSpec, error) {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return serviceSpec{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if serviceInterval <= 0 {
+		return serviceSpec{}, fmt.Errorf("%w: %s", errServiceInterval, serviceInterval)
+	}
+
+	configFile := cfgFile
+	if configFile == "" {
+		configFile = viper.ConfigFileUsed()
+	}
+
+	if configFile == "" {
+		return serviceSpec{}, errNoConfigFile
+	}
+
+	// URLs are passed on as they are, paths are made absolute
+	if !strings.Contains(configFile, "://") {
+		if configFile, err = filepath.Abs(configFile); err != nil {
+			return serviceSpec{}, fmt.Errorf("failed to resolve config file: %w", err)
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return serviceSpec{}, fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	return serviceSpec{
+		name:        serviceName,
+		executable:  executable,
+		config:      configFile,
+		interval:    serviceInterval,
+		eventSource: cfg.LogEventSource,
+	}, nil
+}
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd, serviceStartCmd, serviceStopCmd)
+
+	serviceCmd.PersistentFlags().
+		StringVar(&serviceName, "name", defaultServiceName, "name of the service")
+	serviceInstallCmd.Flags().
+		DurationVar(&serviceInterval, "interval", 24*time.Hour, "how often the service runs prune")
+}
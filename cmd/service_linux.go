@@ -0,0 +1,153 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// systemdUnitDir is where service install writes the systemd units
+const systemdUnitDir = "/etc/systemd/system"
+
+// systemdManager installs the service as a systemd service running prune
+// once and a timer triggering it every interval
+type systemdManager struct {
+	name    string
+	unitDir string
+	// systemctl runs systemctl with args
+	systemctl func(ctx context.Context, args ...string) error
+}
+
+// newServiceManager returns the systemd manager of the service name
+func newServiceManager(name string) (serviceManager, error) {
+	return &systemdManager{name: name, unitDir: systemdUnitDir, systemctl: runSystemctl}, nil
+}
+
+// runSystemctl runs systemctl, returning its output with its error
+func runSystemctl(ctx context.Context, args ...string) error {
+	// #nosec G204 - the arguments are unit names and systemctl verbs
+	out, err := exec.CommandContext(ctx, "systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s",
+			strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Install writes the service and timer units and enables the timer
+func (m *systemdManager) Install(ctx context.Context, spec serviceSpec) error {
+	units := map[string]string{
+		m.name + ".service": systemdService(spec),
+		m.name + ".timer":   systemdTimer(spec),
+	}
+
+	for name, content := range units {
+		// #nosec G306 - units are world-readable like those of packages
+		if err := os.WriteFile(filepath.Join(m.unitDir, name), []byte(content), 0o644); err != nil {
+			return fmt.Errorf("failed to write unit: %w", err)
+		}
+	}
+
+	if err := m.systemctl(ctx, "daemon-reload"); err != nil {
+		return err
+	}
+
+	return m.systemctl(ctx, "enable", m.name+".timer")
+}
+
+// Uninstall disables and stops the timer and removes both units
+func (m *systemdManager) Uninstall(ctx context.Context) error {
+	if err := m.systemctl(ctx, "disable", "--now", m.name+".timer"); err != nil {
+		return err
+	}
+
+	for _, name := range []string{m.name + ".timer", m.name + ".service"} {
+		err := os.Remove(filepath.Join(m.unitDir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove unit: %w", err)
+		}
+	}
+
+	return m.systemctl(ctx, "daemon-reload")
+}
+
+// Start starts the timer, which triggers a prune at once if one is due
+func (m *systemdManager) Start(ctx context.Context) error {
+	return m.systemctl(ctx, "start", m.name+".timer")
+}
+
+// Stop stops the timer and a prune in progress
+func (m *systemdManager) Stop(ctx context.Context) error {
+	return m.systemctl(ctx, "stop", m.name+".timer", m.name+".service")
+}
+
+// systemdService returns the unit of the service running prune once. prune
+// reports readiness and feeds the watchdog itself.
+func systemdService(spec serviceSpec) string {
+	return fmt.Sprintf(`[Unit]
+Description=Apply the retention policy of %[1]s
+Documentation=https://github.com/TotallyNotRobots/apply-retention-policy
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=%[2]s prune --config %[3]s
+`, spec.config, systemdQuote(spec.executable), systemdQuote(spec.config))
+}
+
+// systemdTimer returns the unit of the timer triggering the service every
+// interval, first a few minutes after boot
+func systemdTimer(spec serviceSpec) string {
+	return fmt.Sprintf(`[Unit]
+Description=Apply the retention policy of %[1]s every %[2]s
+
+[Timer]
+OnBootSec=5min
+OnUnitActiveSec=%[3]ds
+
+[Install]
+WantedBy=timers.target
+`, spec.config, spec.interval, int64(spec.interval.Seconds()))
+}
+
+// systemdQuote quotes an ExecStart argument, escaping the characters
+// systemd would otherwise expand
+func systemdQuote(arg string) string {
+	return `"` + strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`%`, `%%`,
+		`$`, `$$`,
+	).Replace(arg) + `"`
+}
@@ -0,0 +1,128 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemdManager(t *testing.T) {
+	unitDir := t.TempDir()
+
+	var calls []string
+
+	m := &systemdManager{
+		name:    "arp",
+		unitDir: unitDir,
+		systemctl: func(_ context.Context, args ...string) error {
+			calls = append(calls, strings.Join(args, " "))
+			return nil
+		},
+	}
+
+	ctx := t.Context()
+
+	require.NoError(t, m.Install(ctx, serviceSpec{
+		name:       "arp",
+		executable: "/usr/local/bin/apply-retention-policy",
+		config:     "/etc/arp/100% retention.yaml",
+		interval:   6 * time.Hour,
+	}))
+
+	service, err := os.ReadFile(filepath.Join(unitDir, "arp.service"))
+	require.NoError(t, err)
+	require.Contains(t, string(service), "Type=notify\n")
+	require.Contains(t, string(service), `ExecStart="/usr/local/bin/apply-retention-policy" `+
+		`prune --config "/etc/arp/100%% retention.yaml"`)
+
+	timer, err := os.ReadFile(filepath.Join(unitDir, "arp.timer"))
+	require.NoError(t, err)
+	require.Contains(t, string(timer), "OnUnitActiveSec=21600s\n")
+	require.Contains(t, string(timer), "WantedBy=timers.target\n")
+
+	require.NoError(t, m.Start(ctx))
+	require.NoError(t, m.Stop(ctx))
+	require.NoError(t, m.Uninstall(ctx))
+
+	require.Equal(t, []string{
+		"daemon-reload",
+		"enable arp.timer",
+		"start arp.timer",
+		"stop arp.timer arp.service",
+		"disable --now arp.timer",
+		"daemon-reload",
+	}, calls)
+
+	entries, err := os.ReadDir(unitDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestSystemdQuote(t *testing.T) {
+	require.Equal(t, `"/opt/arp"`, systemdQuote("/opt/arp"))
+	require.Equal(t, `"a \"b\" \\ $$HOME 50%%"`, systemdQuote(`a "b" \ $HOME 50%`))
+}
+
+func TestNewServiceSpec(t *testing.T) {
+	viper.Reset()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+file_pattern: "backup-{year}-{month}-{day}.tar.gz"
+directory: /backups
+retention:
+  daily: 7
+log_event_source: arp
+`), 0o600))
+
+	cfgFile = configFile
+	serviceInterval = time.Hour
+
+	t.Cleanup(func() {
+		cfgFile = ""
+		serviceInterval = 24 * time.Hour
+	})
+
+	spec, err := newServiceSpec()
+	require.NoError(t, err)
+	require.Equal(t, defaultServiceName, spec.name)
+	require.Equal(t, configFile, spec.config)
+	require.Equal(t, time.Hour, spec.interval)
+	require.Equal(t, "arp", spec.eventSource)
+	require.True(t, filepath.IsAbs(spec.executable))
+
+	serviceInterval = 0
+
+	_, err = newServiceSpec()
+	require.ErrorIs(t, err, errServiceInterval)
+}
@@ -0,0 +1,218 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsManager registers the service with the service control manager
+type windowsManager struct {
+	name string
+}
+
+// newServiceManager returns the service control manager's service name
+func newServiceManager(name string) (serviceManager, error) {
+	return &windowsManager{name: name}, nil
+}
+
+// Install registers a service running service run, started automatically,
+// and the event log source of the config if it has one
+func (m *windowsManager) Install(_ context.Context, spec serviceSpec) error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	defer func() { _ = manager.Disconnect() }()
+
+	service, err := manager.CreateService(spec.name, spec.executable, mgr.Config{
+		DisplayName: spec.name,
+		Description: "Applies the retention policy of " + spec.config,
+		StartType:   mgr.StartAutomatic,
+	}, "service", "run",
+		"--name", spec.name,
+		"--config", spec.config,
+		"--interval", spec.interval.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer func() { _ = service.Close() }()
+
+	if spec.eventSource != "" {
+		// The source may already be registered, by a previous install
+		_ = eventlog.InstallAsEventCreate(spec.eventSource,
+			eventlog.Error|eventlog.Warning|eventlog.Info)
+	}
+
+	return nil
+}
+
+// Uninstall stops the service, if it is running, and deletes it
+func (m *windowsManager) Uninstall(_ context.Context) error {
+	return m.withService(func(service *mgr.Service) error {
+		_, _ = service.Control(svc.Stop)
+
+		if err := service.Delete(); err != nil {
+			return fmt.Errorf("failed to delete service: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Start starts the service
+func (m *windowsManager) Start(_ context.Context) error {
+	return m.withService(func(service *mgr.Service) error {
+		if err := service.Start(); err != nil {
+			return fmt.Errorf("failed to start service: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Stop asks the service to stop, which it does once the prune in progress
+// is interrupted
+func (m *windowsManager) Stop(_ context.Context) error {
+	return m.withService(func(service *mgr.Service) error {
+		if _, err := service.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// withService calls fn with the installed service
+func (m *windowsManager) withService(fn func(*mgr.Service) error) error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	defer func() { _ = manager.Disconnect() }()
+
+	service, err := manager.OpenService(m.name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", m.name, err)
+	}
+	defer func() { _ = service.Close() }()
+
+	return fn(service)
+}
+
+// pruneService is the svc.Handler running prune every interval until the
+// service control manager stops it
+type pruneService struct {
+	cmd      *cobra.Command
+	interval time.Duration
+}
+
+// Execute runs the prune loop and stops it on a stop or shutdown request
+func (s *pruneService) Execute(
+	_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status,
+) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		s.loop(ctx)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+
+			cancel()
+			<-done
+
+			return false, 0
+		default:
+		}
+	}
+
+	return false, 0
+}
+
+// loop prunes at once and then every interval. Failed runs are logged, to
+// the event log with log_event_source, and retried at the next interval.
+func (s *pruneService) loop(ctx context.Context) {
+	s.cmd.SetContext(ctx)
+
+	for {
+		_, _ = runPrune(s.cmd, newReporter(io.Discard, true, false))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// serviceRunCmd is what the installed Windows service runs
+var serviceRunCmd = &cobra.Command{
+	Use:    "run",
+	Short:  "Run prune every --interval as a Windows service",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if serviceInterval <= 0 {
+			return fmt.Errorf("%w: %s", errServiceInterval, serviceInterval)
+		}
+
+		err := svc.Run(serviceName, &pruneService{cmd: cmd, interval: serviceInterval})
+		if err != nil {
+			return fmt.Errorf("failed to run service: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceRunCmd)
+
+	serviceRunCmd.Flags().
+		DurationVar(&serviceInterval, "interval", 24*time.Hour, "how often to run prune")
+}
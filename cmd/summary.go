@@ -0,0 +1,187 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+)
+
+// runSummary is the JSON document written by prune --summary-file
+type runSummary struct {
+	RunID            string       `json:"run_id,omitempty"`
+	Directory        string       `json:"directory"`
+	DryRun           bool         `json:"dry_run"`
+	Matched          int          `json:"matched"`
+	Deleted          int          `json:"deleted"`
+	ReclaimedBytes   int64        `json:"reclaimed_bytes"`
+	Errors           []string     `json:"errors"`
+	RetriesExhausted int          `json:"retries_exhausted"`
+	Archived         int          `json:"archived"`
+	Compressed       int          `json:"compressed"`
+	Warnings         []warning    `json:"warnings"`
+	Tiers            []tierStats  `json:"tiers"`
+	StartedAt        time.Time    `json:"started_at"`
+	DurationSeconds  float64      `json:"duration_seconds"`
+	ExitCode         int          `json:"exit_code"`
+	Files            []fileRecord `json:"files"`
+}
+
+// warning is a problem raised during a prune run that did not fail it
+type warning struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// tierStats is how many periods a retention tier was asked to keep for the
+// backups of a directory and tag, and how many it found a backup for
+type tierStats struct {
+	Directory  string `json:"directory"`
+	Tag        string `json:"tag,omitempty"`
+	Tier       string `json:"tier"`
+	Requested  int    `json:"requested"`
+	Filled     int    `json:"filled"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// fileRecord is what happened to a single file during a prune run. Action
+// is keep, delete, archive, compress or failed; Reason is the reason the
+// policy gave, or what a file deleted outside the policy was deleted as.
+// Checksum is the SHA-256 of a backup taken just before it was deleted.
+type fileRecord struct {
+	Path     string `json:"path"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// writeSummaryFile writes the outcome of a prune run started at started, and
+// what happened to each of files, to path. err is the error the run failed
+// with, if any.
+func writeSummaryFile(
+	path string,
+	summary notify.Summary,
+	files []fileRecord,
+	started time.Time,
+	err error,
+) error {
+	data, marshalErr := json.MarshalIndent(newRunSummary(summary, files, started, err), "", "  ")
+	if marshalErr != nil {
+		return fmt.Errorf("failed to encode summary: %w", marshalErr)
+	}
+
+	if writeErr := os.WriteFile(path, append(data, '\n'), 0o600); writeErr != nil {
+		return fmt.Errorf("failed to write summary file: %w", writeErr)
+	}
+
+	return nil
+}
+
+// signSummaryFile writes a detached, ASCII-armored signature of the summary
+// file at path to path.asc, made by gpg with key, so the summary can be
+// checked with gpg --verify later. gpg finds key in its default home, or in
+// GNUPGHOME, and must be able to use it without prompting. The summary is
+// signed even after the run was interrupted, so signing is not cancelled.
+func signSummaryFile(path, key string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(context.Background(), "gpg", "--batch", "--yes",
+		"--local-user", key, "--armor", "--detach-sign", "--output", path+".asc",
+		"--", path) // #nosec G204
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to sign summary file: %w: %s",
+			err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return nil
+}
+
+// newRunSummary returns the summary document of a prune run started at
+// started. err is the error the run failed with, if any; it is listed among
+// the errors when the run failed before recording any.
+func newRunSummary(
+	summary notify.Summary,
+	files []fileRecord,
+	started time.Time,
+	err error,
+) runSummary {
+	doc := runSummary{
+		RunID:            summary.RunID,
+		Directory:        summary.Directory,
+		DryRun:           summary.DryRun,
+		Matched:          summary.Matched,
+		Deleted:          summary.Deleted,
+		ReclaimedBytes:   summary.ReclaimedBytes,
+		Errors:           summary.Errors,
+		RetriesExhausted: summary.RetriesExhausted,
+		Archived:         summary.Archived,
+		Compressed:       summary.Compressed,
+		StartedAt:        started.UTC(),
+		DurationSeconds:  time.Since(started).Seconds(),
+		ExitCode:         exitCode(err),
+		Warnings:         make([]warning, len(summary.Warnings)),
+		Tiers:            make([]tierStats, len(summary.Tiers)),
+		Files:            files,
+	}
+
+	for i, w := range summary.Warnings {
+		doc.Warnings[i] = warning{Severity: w.Severity, Message: w.Message}
+	}
+
+	for i, t := range summary.Tiers {
+		doc.Tiers[i] = tierStats{
+			Directory:  t.Directory,
+			Tag:        t.Tag,
+			Tier:       t.Name,
+			Requested:  t.Requested,
+			Filled:     t.Filled,
+			Suggestion: t.Suggestion,
+		}
+	}
+
+	if err != nil && len(doc.Errors) == 0 {
+		doc.Errors = []string{err.Error()}
+	}
+
+	if doc.Errors == nil {
+		doc.Errors = []string{}
+	}
+
+	if doc.Files == nil {
+		doc.Files = []fileRecord{}
+	}
+
+	return doc
+}
@@ -0,0 +1,237 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/backend"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// maxListedSkips limits how many example paths are printed per skip reason
+const maxListedSkips = 5
+
+// errNoFilesMatched is returned by verify when the pattern matched nothing
+var errNoFilesMatched = errors.New("no files matched the configured pattern")
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the config and file pattern against the backup directory",
+	Long: `Load the configuration, compile the file pattern and scan the backup
+directory without deleting anything. Reports how many files matched the
+pattern and how many entries were skipped, grouped by the reason they were
+skipped, so misconfiguration is caught before it causes either no pruning or
+over-pruning.
+
+Exits with a non-zero status if the pattern matched no files.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		directories, err := file.ExpandDirectories(cfg.Directories)
+		if err != nil {
+			return fmt.Errorf("failed to expand directories: %w", err)
+		}
+
+		if hourlyWithoutTime(cfg) {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(),
+				"Warning: hourly retention is configured but the pattern has neither "+
+					"{hour} nor {minute}, so every backup of a day falls in the same hour\n\n")
+		}
+
+		matched := 0
+
+		for i, directory := range directories {
+			result, err := scanDirectory(ctx, cfg, directory)
+			if err != nil {
+				return err
+			}
+
+			if i > 0 {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout())
+			}
+
+			pattern := cfg.FilePattern
+			if cfg.Backend == config.BackendBtrfs {
+				pattern = "snapper snapshots (btrfs)"
+			}
+
+			writeVerifyReport(cmd.OutOrStdout(), directory, pattern, result)
+
+			matched += len(result.Files)
+		}
+
+		if matched == 0 {
+			return errNoFilesMatched
+		}
+
+		return nil
+	},
+}
+
+// hourlyWithoutTime reports whether hourly retention is configured while the
+// files backend dates backups by a pattern without an {hour} or {minute}
+func hourlyWithoutTime(cfg *config.Config) bool {
+	if (cfg.Backend != "" && cfg.Backend != config.BackendFiles) || cfg.KeepCount > 0 ||
+		file.HasToken(cfg.FilePattern, "hour") || file.HasToken(cfg.FilePattern, "minute") {
+		return false
+	}
+
+	if cfg.Retention.Hourly > 0 {
+		return true
+	}
+
+	for _, policy := range cfg.TagRetention {
+		if policy.Hourly > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanDirectory scans a directory with the configured backend. Only the
+// files backend reports skipped entries, other backends list matches only.
+func scanDirectory(
+	ctx context.Context,
+	cfg *config.Config,
+	directory string,
+) (*file.ScanResult, error) {
+	store, err := backend.New(cfg, directory, &logging.Logger{Logger: zap.NewNop()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize backend: %w", err)
+	}
+	defer func() { _ = backend.Close(store) }()
+
+	if fileManager, ok := store.(*file.Manager); ok {
+		result, err := fileManager.Scan(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan directory: %w", err)
+		}
+
+		return result, nil
+	}
+
+	files, err := store.ListFiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	return &file.ScanResult{Files: files}, nil
+}
+
+// writeVerifyReport prints a human readable summary of a directory scan
+func writeVerifyReport(w io.Writer, directory, pattern string, result *file.ScanResult) {
+	_, _ = fmt.Fprintf(w, "Directory: %s\n", directory)
+	_, _ = fmt.Fprintf(w, "Pattern:   %s\n", pattern)
+	_, _ = fmt.Fprintf(w, "Matched:   %d files\n", len(result.Files))
+
+	if len(result.Files) > 0 {
+		// Files are sorted oldest first
+		oldest := result.Files[0]
+		newest := result.Files[len(result.Files)-1]
+
+		_, _ = fmt.Fprintf(w, "  oldest:  %s (%s)\n",
+			relPath(directory, oldest.Path), oldest.Timestamp.Format(time.RFC3339))
+		_, _ = fmt.Fprintf(w, "  newest:  %s (%s)\n",
+			relPath(directory, newest.Path), newest.Timestamp.Format(time.RFC3339))
+
+		pinned := 0
+
+		for _, f := range result.Files {
+			if f.Pinned {
+				pinned++
+			}
+		}
+
+		_, _ = fmt.Fprintf(w, "  pinned:  %d\n", pinned)
+	}
+
+	_, _ = fmt.Fprintf(w, "Skipped:   %d entries\n", len(result.Skipped))
+
+	byReason := map[file.SkipReason][]file.Skipped{}
+	for _, skipped := range result.Skipped {
+		byReason[skipped.Reason] = append(byReason[skipped.Reason], skipped)
+	}
+
+	reasons := make([]file.SkipReason, 0, len(byReason))
+	for reason := range byReason {
+		reasons = append(reasons, reason)
+	}
+
+	slices.Sort(reasons)
+
+	for _, reason := range reasons {
+		entries := byReason[reason]
+		_, _ = fmt.Fprintf(w, "  %s: %d\n", reason, len(entries))
+
+		for i, entry := range entries {
+			if i == maxListedSkips {
+				_, _ = fmt.Fprintf(w, "    ... and %d more\n", len(entries)-maxListedSkips)
+				break
+			}
+
+			_, _ = fmt.Fprintf(w, "    %s\n", relPath(directory, entry.Path))
+		}
+	}
+}
+
+// relPath returns path relative to dir, falling back to the full path
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+
+	return rel
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().
+		StringVarP(&cfgFile, "config", "c", "", "Path to config file")
+}
@@ -0,0 +1,122 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	for _, name := range []string{
+		"backup-2024-03-15-12-00.tar.gz",
+		"backup-2024-03-14-00-00.tar.gz",
+		"backup-2024-13-45-00-00.tar.gz",
+		"notes.txt",
+	} {
+		err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0o600)
+		require.NoError(t, err)
+	}
+
+	writeRetentionConfig := func(t *testing.T, retention, pattern string) string {
+		configContent := `retention:
+  ` + retention + `
+file_pattern: "` + pattern + `"
+directory: "` + filepath.ToSlash(tmpDir) + `"
+`
+		configFile := filepath.Join(t.TempDir(), "retention-policy.yaml")
+		err := os.WriteFile(configFile, []byte(configContent), 0o600)
+		require.NoError(t, err)
+
+		return configFile
+	}
+
+	writeConfig := func(t *testing.T, pattern string) string {
+		return writeRetentionConfig(t, "daily: 1", pattern)
+	}
+
+	t.Run("reports matched and skipped files", func(t *testing.T) {
+		viper.Reset()
+
+		configFile := writeConfig(t, "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz")
+
+		var out bytes.Buffer
+
+		cmd := verifyCmd
+		cmd.SetOut(&out)
+		err := cmd.Flags().Set("config", configFile)
+		require.NoError(t, err)
+		err = cmd.RunE(cmd, nil)
+		require.NoError(t, err)
+
+		report := out.String()
+		require.Contains(t, report, "Matched:   2 files")
+		require.Contains(t, report, "newest:  backup-2024-03-15-12-00.tar.gz")
+		require.Contains(t, report, "timestamp could not be parsed: 1")
+		require.Contains(t, report, "backup-2024-13-45-00-00.tar.gz")
+		require.Contains(t, report, "pattern did not match: 1")
+		require.Contains(t, report, "notes.txt")
+		require.NotContains(t, report, "Warning:")
+	})
+
+	t.Run("warns about hourly retention without a time", func(t *testing.T) {
+		viper.Reset()
+
+		configFile := writeRetentionConfig(t, "hourly: 24",
+			"backup-{year}-{month}-{day}-12-00.tar.gz")
+
+		var out bytes.Buffer
+
+		cmd := verifyCmd
+		cmd.SetOut(&out)
+		require.NoError(t, cmd.Flags().Set("config", configFile))
+		require.NoError(t, cmd.RunE(cmd, nil))
+		require.Contains(t, out.String(), "Warning: hourly retention is configured")
+	})
+
+	t.Run("no files matched", func(t *testing.T) {
+		viper.Reset()
+
+		configFile := writeConfig(t, "db-{year}{month}{day}.sql")
+
+		var out bytes.Buffer
+
+		cmd := verifyCmd
+		cmd.SetOut(&out)
+		err := cmd.Flags().Set("config", configFile)
+		require.NoError(t, err)
+		err = cmd.RunE(cmd, nil)
+		require.ErrorIs(t, err, errNoFilesMatched)
+		require.Contains(t, out.String(), "Matched:   0 files")
+	})
+}
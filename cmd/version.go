@@ -0,0 +1,159 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/buildinfo"
+)
+
+// updateCheckTimeout bounds the request made by version --check-update
+const updateCheckTimeout = 10 * time.Second
+
+// latestReleaseURL is the GitHub API endpoint describing the latest release
+var latestReleaseURL = "https://api.github.com/repos/" +
+	"TotallyNotRobots/apply-retention-policy/releases/latest"
+
+var versionCheckUpdate bool
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version, commit and build date of this binary",
+	Long: `Print the version of this binary with the git commit it was built from,
+when it was built and the Go version and platform it was built for.
+
+With --check-update the latest release is looked up on GitHub and reported
+when it is newer than this binary.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		info := buildinfo.Get()
+		writeVersion(cmd.OutOrStdout(), cmd.Root().Name(), info)
+
+		if !versionCheckUpdate {
+			return nil
+		}
+
+		latest, err := latestRelease(ctx)
+		if err != nil {
+			return err
+		}
+
+		writeUpdate(cmd.OutOrStdout(), info, latest)
+
+		return nil
+	},
+}
+
+// release is the part of a GitHub release version --check-update reads
+type release struct {
+	TagName string `json:"tag_name"`
+	URL     string `json:"html_url"`
+}
+
+// writeVersion prints the build information of the binary name
+func writeVersion(w io.Writer, name string, info buildinfo.Info) {
+	_, _ = fmt.Fprintf(w, "%s %s\n", name, info.Version)
+
+	for _, field := range []struct{ label, value string }{
+		{"Commit", info.Commit},
+		{"Built", info.Date},
+		{"Go", info.GoVersion},
+		{"Platform", info.Platform},
+	} {
+		value := field.value
+		if value == "" {
+			value = "unknown"
+		}
+
+		_, _ = fmt.Fprintf(w, "  %-9s %s\n", field.label+":", value)
+	}
+}
+
+// latestRelease looks up the latest release on GitHub
+func latestRelease(ctx context.Context) (release, error) {
+	ctx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return release{}, fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return release{}, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("failed to check for updates: %s: %s",
+			latestReleaseURL, resp.Status)
+	}
+
+	var latest release
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil {
+		return release{}, fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	return latest, nil
+}
+
+// writeUpdate reports whether latest is newer than the running build
+func writeUpdate(w io.Writer, info buildinfo.Info, latest release) {
+	switch {
+	case !info.IsRelease():
+		_, _ = fmt.Fprintf(w, "\nLatest release is %s: %s\n", latest.TagName, latest.URL)
+	case buildinfo.Newer(latest.TagName, info.Version):
+		_, _ = fmt.Fprintf(w, "\nA newer release is available: %s (%s)\n",
+			latest.TagName, latest.URL)
+	default:
+		_, _ = fmt.Fprintln(w, "\nThis is the latest release")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().
+		BoolVar(&versionCheckUpdate, "check-update", false,
+			"Check GitHub for a newer release")
+}
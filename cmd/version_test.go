@@ -0,0 +1,88 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/buildinfo"
+)
+
+func TestVersionCommand(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name": "v1.3.0", "html_url": "https://example.com/v1.3.0"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	url := latestReleaseURL
+	latestReleaseURL = srv.URL
+
+	t.Cleanup(func() {
+		latestReleaseURL = url
+		versionCheckUpdate = false
+	})
+
+	var out bytes.Buffer
+
+	cmd := versionCmd
+	setContext(t, cmd, t.Context())
+	cmd.SetOut(&out)
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Regexp(t, `^apply-retention-policy \S+\n  Commit: +\S+\n  Built: +\S+\n`+
+		`  Go: +go\S+\n  Platform: +\S+/\S+\n$`, out.String())
+
+	out.Reset()
+
+	versionCheckUpdate = true
+
+	require.NoError(t, cmd.RunE(cmd, nil))
+	require.Contains(t, out.String(), "\nLatest release is v1.3.0: https://example.com/v1.3.0\n")
+
+	latestReleaseURL = srv.URL + "/missing"
+	srv.Config.Handler = http.NotFoundHandler()
+
+	require.ErrorContains(t, cmd.RunE(cmd, nil), "404 Not Found")
+}
+
+func TestWriteUpdate(t *testing.T) {
+	latest := release{TagName: "v1.3.0", URL: "https://example.com/v1.3.0"}
+
+	for version, want := range map[string]string{
+		"v1.2.0": "\nA newer release is available: v1.3.0" +
+			" (https://example.com/v1.3.0)\n",
+		"v1.3.0":              "\nThis is the latest release\n",
+		buildinfo.Development: "\nLatest release is v1.3.0: https://example.com/v1.3.0\n",
+	} {
+		var out bytes.Buffer
+		writeUpdate(&out, buildinfo.Info{Version: version}, latest)
+		require.Equal(t, want, out.String(), version)
+	}
+}
@@ -0,0 +1,307 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package backend selects the storage backend a directory is pruned with.
+// Every backend lists the backups in a directory and deletes single backups;
+// the retention policy itself is backend agnostic.
+package backend
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/btrfs"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/kubernetes"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/manifest"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/rsnapshot"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/xtrabackup"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// ErrUnknownBackend is returned for a backend name that is not supported
+var ErrUnknownBackend = errors.New("unknown backend")
+
+// Backend lists and deletes the backups in a single directory. ListFiles
+// returns every backup sorted oldest first, WalkFiles streams them in no
+// particular order without holding them all in memory.
+type Backend interface {
+	ListFiles(ctx context.Context) ([]file.Info, error)
+	WalkFiles(ctx context.Context, fn func(file.Info) error) error
+	DeleteFile(ctx context.Context, f file.Info, dryRun bool) error
+}
+
+// Close releases what b holds open, such as the directory handle of the
+// files backend with hardened_listing set. Backends that hold nothing open
+// are left alone.
+func Close(b Backend) error {
+	if c, ok := b.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// Option configures the backend created by New
+type Option func(*options)
+
+// options holds what the Options passed to New configure
+type options struct {
+	warn func(file.Warning)
+}
+
+// WithWarnings calls fn with every warning raised while listing backups,
+// such as a backup that was skipped because its timestamp could not be
+// parsed. Only the files backend raises warnings.
+func WithWarnings(fn func(file.Warning)) Option {
+	return func(o *options) {
+		o.warn = fn
+	}
+}
+
+// New creates the backend configured in cfg for a directory. With
+// compute_sizes set, directory backends are wrapped to report cumulative
+// sizes; plain files already report their full size, hard-linked trees
+// size themselves by the space deleting them frees, and volume snapshots
+// report their restore size. With retry.attempts above one, deletions that
+// fail with a transient error are retried, and with operation_timeout set
+// every attempt must finish in time. Listing is bounded by list_timeout,
+// or by operation_timeout when it is not set.
+func New(
+	cfg *config.Config,
+	directory string,
+	log *logging.Logger,
+	opts ...Option,
+) (Backend, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	store, err := newBackend(cfg, directory, log, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ComputeSizes {
+		switch store.(type) {
+		case *file.Manager, *rsnapshot.Manager, *kubernetes.Snapshots:
+		default:
+			store = &sizing{Backend: store}
+		}
+	}
+
+	if cfg.OperationTimeout > 0 || cfg.ListTimeout > 0 {
+		store = &timeout{
+			Backend: store,
+			list:    cmp.Or(cfg.ListTimeout, cfg.OperationTimeout),
+			delete:  cfg.OperationTimeout,
+		}
+	}
+
+	if cfg.Retry.Attempts > 1 {
+		store = &retrying{Backend: store, log: log, retry: cfg.Retry, sleep: sleepContext}
+	}
+
+	return store, nil
+}
+
+// newBackend creates the backend named by cfg.Backend
+func newBackend(
+	cfg *config.Config,
+	directory string,
+	log *logging.Logger,
+	o options,
+) (Backend, error) {
+	switch cfg.Backend {
+	case config.BackendFiles, "":
+		return newFileManager(cfg, directory, log, o)
+	case config.BackendBtrfs:
+		return btrfs.NewSnapper(
+			directory,
+			btrfs.WithLogger(log),
+			btrfs.WithPins(cfg.Pins),
+		), nil
+	case config.BackendXtrabackup:
+		return xtrabackup.NewManager(
+			directory,
+			xtrabackup.WithLogger(log),
+			xtrabackup.WithPins(cfg.Pins),
+		), nil
+	case config.BackendRsnapshot:
+		opts := []rsnapshot.ManagerOption{rsnapshot.WithLogger(log), rsnapshot.WithPins(cfg.Pins)}
+		if cfg.ComputeSizes {
+			opts = append(opts, rsnapshot.WithSizes())
+		}
+
+		return rsnapshot.NewManager(directory, opts...), nil
+	case config.BackendVolumeSnapshot:
+		return kubernetes.NewSnapshots(
+			directory,
+			kubernetes.WithLogger(log),
+			kubernetes.WithPins(cfg.Pins),
+			kubernetes.WithLabelSelector(cfg.Kubernetes.LabelSelector),
+		)
+	case config.BackendManifest:
+		return manifest.NewInventory(
+			directory,
+			cfg.Manifest.Path,
+			manifest.WithLogger(log),
+			manifest.WithPins(cfg.Pins),
+			manifest.WithFormat(cfg.Manifest.Format),
+		), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, cfg.Backend)
+	}
+}
+
+// newFileManager creates the backend for plain backup files. With owner or
+// group set, only the files they own are listed, with timestamp_xattr set
+// files are dated by that extended attribute, with backup_info enabled
+// they are tagged by their backup-info.json, and with hardened_listing set
+// the directory is listed and pruned through a single handle.
+func newFileManager(
+	cfg *config.Config,
+	directory string,
+	log *logging.Logger,
+	o options,
+) (Backend, error) {
+	opts := []file.ManagerOption{
+		file.WithLogger(log),
+		file.WithWarnings(o.warn),
+		file.WithPins(cfg.Pins),
+		file.WithSyntax(file.Syntax(cfg.PatternSyntax)),
+	}
+	if cfg.KeepCount > 0 {
+		opts = append(opts, file.WithModTime())
+	}
+
+	if cfg.MatchBasename {
+		opts = append(opts, file.WithBasename())
+	}
+
+	if cfg.IgnoreCase {
+		opts = append(opts, file.WithIgnoreCase())
+	}
+
+	// Compressed backups must still match once the extension is added
+	if cfg.CompressAfter > 0 {
+		opts = append(opts, file.WithOptionalSuffixes(
+			file.CompressionSuffix(file.CompressGzip),
+			file.CompressionSuffix(file.CompressZstd),
+		))
+	}
+
+	if cfg.TimestampXattr != "" {
+		opts = append(opts, file.WithTimestampXattr(cfg.TimestampXattr))
+	}
+
+	if cfg.BackupInfo.Enabled {
+		opts = append(opts, file.WithBackupInfo(cfg.BackupInfo.Labels...))
+	}
+
+	if cfg.HardenedListing {
+		opts = append(opts, file.WithHardenedListing())
+	}
+
+	if cfg.Owner != "" || cfg.Group != "" {
+		uid, gid, err := file.LookupOwner(cfg.Owner, cfg.Group)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, file.WithOwner(uid, gid))
+	}
+
+	return file.NewManager(directory, cfg.FilePattern, opts...)
+}
+
+// sizeBatch is the number of streamed backups sized concurrently at a time
+const sizeBatch = 64
+
+// sizing wraps a backend and replaces the size of every listed backup with
+// its cumulative size
+type sizing struct {
+	Backend
+}
+
+// Close closes the wrapped backend
+func (s *sizing) Close() error {
+	return Close(s.Backend)
+}
+
+// ListFiles lists the backups of the wrapped backend and computes their
+// sizes concurrently
+func (s *sizing) ListFiles(ctx context.Context) ([]file.Info, error) {
+	files, err := s.Backend.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := file.ComputeSizes(ctx, files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// WalkFiles streams the backups of the wrapped backend, computing their
+// sizes concurrently in batches
+func (s *sizing) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	batch := make([]file.Info, 0, sizeBatch)
+
+	flush := func() error {
+		if err := file.ComputeSizes(ctx, batch); err != nil {
+			return err
+		}
+
+		for _, f := range batch {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+
+		batch = batch[:0]
+
+		return nil
+	}
+
+	err := s.Backend.WalkFiles(ctx, func(f file.Info) error {
+		batch = append(batch, f)
+		if len(batch) < sizeBatch {
+			return nil
+		}
+
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
@@ -0,0 +1,140 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// defaultBackoff is the delay before the first retry when none is configured
+const defaultBackoff = time.Second
+
+// ErrRetriesExhausted wraps the last error of a deletion that failed with a
+// transient error on every attempt
+var ErrRetriesExhausted = errors.New("giving up after retries")
+
+// retrying wraps a backend and retries deletions that fail with a transient
+// error, doubling the delay between attempts up to the configured maximum
+type retrying struct {
+	Backend
+
+	log   *logging.Logger
+	retry config.RetryConfig
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Close closes the wrapped backend
+func (r *retrying) Close() error {
+	return Close(r.Backend)
+}
+
+// DeleteFile deletes a backup, retrying transient failures. Permanent
+// failures are returned at once, and a deletion that still fails after the
+// last attempt is reported with ErrRetriesExhausted.
+func (r *retrying) DeleteFile(ctx context.Context, f file.Info, dryRun bool) error {
+	backoff := r.retry.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.Backend.DeleteFile(ctx, f, dryRun)
+		if err == nil || !retryable(err, r.retry.Retryable) {
+			return err
+		}
+
+		if attempt >= r.retry.Attempts {
+			return fmt.Errorf("%w (%d attempts): %w", ErrRetriesExhausted, attempt, err)
+		}
+
+		r.log.Warn("retrying failed deletion",
+			zap.String("path", f.Path),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		if err := r.sleep(ctx, backoff); err != nil {
+			return err
+		}
+
+		backoff *= 2
+		if r.retry.MaxBackoff > 0 {
+			backoff = min(backoff, r.retry.MaxBackoff)
+		}
+	}
+}
+
+// retryable reports whether err is a transient failure worth retrying: a
+// transient system error, a network timeout, or an error whose message
+// contains one of the configured patterns
+func retryable(err error, patterns []string) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) && isTransientErrno(errno) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	msg := err.Error()
+	for _, pattern := range patterns {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// flakyBackend fails the first failures deletions with err
+type flakyBackend struct {
+	Backend
+
+	err      error
+	failures int
+	calls    int
+}
+
+func (b *flakyBackend) DeleteFile(context.Context, file.Info, bool) error {
+	b.calls++
+	if b.calls <= b.failures {
+		return b.err
+	}
+
+	return nil
+}
+
+// newRetrying wraps store, recording the delays it sleeps for
+func newRetrying(store Backend, retry config.RetryConfig, delays *[]time.Duration) *retrying {
+	return &retrying{
+		Backend: store,
+		log:     logging.NewDefault(),
+		retry:   retry,
+		sleep: func(_ context.Context, d time.Duration) error {
+			*delays = append(*delays, d)
+			return nil
+		},
+	}
+}
+
+func TestRetrying_DeleteFile(t *testing.T) {
+	transient := errors.New("503 SlowDown: please reduce your request rate")
+	retry := config.RetryConfig{
+		Attempts:   4,
+		Backoff:    time.Second,
+		MaxBackoff: 3 * time.Second,
+		Retryable:  []string{"SlowDown"},
+	}
+
+	t.Run("recovers", func(t *testing.T) {
+		var delays []time.Duration
+
+		store := &flakyBackend{err: transient, failures: 3}
+		err := newRetrying(store, retry, &delays).DeleteFile(t.Context(), file.Info{}, false)
+		require.NoError(t, err)
+		require.Equal(t, 4, store.calls)
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, delays)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		var delays []time.Duration
+
+		store := &flakyBackend{err: transient, failures: 10}
+		err := newRetrying(store, retry, &delays).DeleteFile(t.Context(), file.Info{}, false)
+		require.ErrorIs(t, err, ErrRetriesExhausted)
+		require.ErrorIs(t, err, transient)
+		require.Equal(t, 4, store.calls)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		var delays []time.Duration
+
+		store := &flakyBackend{err: os.ErrPermission, failures: 10}
+		err := newRetrying(store, retry, &delays).DeleteFile(t.Context(), file.Info{}, false)
+		require.ErrorIs(t, err, os.ErrPermission)
+		require.NotErrorIs(t, err, ErrRetriesExhausted)
+		require.Equal(t, 1, store.calls)
+		require.Empty(t, delays)
+	})
+}
+
+// timeoutError is a network error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	require.True(t, retryable(timeoutError{}, nil))
+	require.True(t, retryable(errors.New("stale NFS file handle"), []string{"stale"}))
+	require.False(t, retryable(errors.New("stale NFS file handle"), nil))
+	require.False(t, retryable(os.ErrNotExist, nil))
+	require.False(t, retryable(context.DeadlineExceeded, []string{"deadline"}))
+}
+
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	require.ErrorIs(t, sleepContext(ctx, time.Hour), context.Canceled)
+	require.NoError(t, sleepContext(t.Context(), time.Millisecond))
+}
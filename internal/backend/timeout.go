@@ -0,0 +1,176 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// ErrOperationTimeout is returned when listing a directory or deleting a
+// backup takes longer than the configured list_timeout or
+// operation_timeout. It wraps context.DeadlineExceeded, so timed out
+// deletions are not retried.
+var ErrOperationTimeout = fmt.Errorf("operation timed out: %w", context.DeadlineExceeded)
+
+// timeout wraps a backend and fails listings that take longer than list
+// and deletions that take longer than delete, where zero means no limit.
+// Operations blocked in a system call, such as on a dead NFS mount, cannot
+// be interrupted; they are abandoned in the background instead of blocking
+// the run.
+type timeout struct {
+	Backend
+
+	list   time.Duration
+	delete time.Duration
+}
+
+// Close closes the wrapped backend
+func (t *timeout) Close() error {
+	return Close(t.Backend)
+}
+
+// ListFiles lists the backups of the wrapped backend within the timeout
+func (t *timeout) ListFiles(ctx context.Context) ([]file.Info, error) {
+	if t.list <= 0 {
+		return t.Backend.ListFiles(ctx)
+	}
+
+	var files []file.Info
+
+	err := run(ctx, t.list, "listing", func(ctx context.Context) error {
+		var err error
+
+		files, err = t.Backend.ListFiles(ctx)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// WalkFiles streams the backups of the wrapped backend. Only the time spent
+// waiting for the next backup counts towards the timeout, not the time fn
+// takes to handle one.
+func (t *timeout) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	if t.list <= 0 {
+		return t.Backend.WalkFiles(ctx, fn)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	listed := make(chan file.Info)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- t.Backend.WalkFiles(ctx, func(f file.Info) error {
+			select {
+			case listed <- f:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	remaining := t.list
+	timer := time.NewTimer(remaining)
+
+	defer timer.Stop()
+
+	for {
+		waiting := time.Now()
+
+		select {
+		case f := <-listed:
+			timer.Stop()
+
+			remaining -= time.Since(waiting)
+			if err := fn(f); err != nil {
+				return err
+			}
+
+			timer.Reset(remaining)
+		case err := <-done:
+			return err
+		case <-timer.C:
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return fmt.Errorf("%w: listing after %s", ErrOperationTimeout, t.list)
+		}
+	}
+}
+
+// DeleteFile deletes a backup with the wrapped backend within the timeout
+func (t *timeout) DeleteFile(ctx context.Context, f file.Info, dryRun bool) error {
+	if t.delete <= 0 {
+		return t.Backend.DeleteFile(ctx, f, dryRun)
+	}
+
+	return run(ctx, t.delete, "deleting "+f.Path, func(ctx context.Context) error {
+		return t.Backend.DeleteFile(ctx, f, dryRun)
+	})
+}
+
+// run runs op with a context that expires after limit, returning
+// ErrOperationTimeout without waiting for op once it has expired
+func run(
+	parent context.Context,
+	limit time.Duration,
+	what string,
+	op func(context.Context) error,
+) error {
+	ctx, cancel := context.WithTimeout(parent, limit)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() { done <- op(ctx) }()
+
+	var err error
+
+	select {
+	case err = <-done:
+		if err == nil || ctx.Err() == nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	if parentErr := parent.Err(); parentErr != nil {
+		return parentErr
+	}
+
+	return fmt.Errorf("%w: %s after %s", ErrOperationTimeout, what, limit)
+}
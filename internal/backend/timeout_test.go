@@ -0,0 +1,144 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// hangingBackend lists its files one at a time, then blocks listing and
+// deleting until release is closed, ignoring the context like a system call
+// on a dead mount would
+type hangingBackend struct {
+	files   []file.Info
+	release chan struct{}
+}
+
+func (b *hangingBackend) ListFiles(context.Context) ([]file.Info, error) {
+	<-b.release
+	return b.files, nil
+}
+
+func (b *hangingBackend) WalkFiles(_ context.Context, fn func(file.Info) error) error {
+	for _, f := range b.files {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	<-b.release
+
+	return nil
+}
+
+func (b *hangingBackend) DeleteFile(context.Context, file.Info, bool) error {
+	<-b.release
+	return nil
+}
+
+func TestTimeout(t *testing.T) {
+	files := []file.Info{{Path: "/backups/a.tar"}, {Path: "/backups/b.tar"}}
+	hanging := &hangingBackend{files: files, release: make(chan struct{})}
+	t.Cleanup(func() { close(hanging.release) })
+
+	store := &timeout{Backend: hanging, list: 50 * time.Millisecond, delete: 50 * time.Millisecond}
+
+	t.Run("listing", func(t *testing.T) {
+		_, err := store.ListFiles(t.Context())
+		require.ErrorIs(t, err, ErrOperationTimeout)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("walking", func(t *testing.T) {
+		var walked []file.Info
+
+		err := store.WalkFiles(t.Context(), func(f file.Info) error {
+			walked = append(walked, f)
+			// Handling a backup does not count towards the timeout
+			time.Sleep(40 * time.Millisecond)
+
+			return nil
+		})
+		require.ErrorIs(t, err, ErrOperationTimeout)
+		require.Equal(t, files, walked)
+	})
+
+	t.Run("deleting", func(t *testing.T) {
+		err := store.DeleteFile(t.Context(), files[0], false)
+		require.ErrorIs(t, err, ErrOperationTimeout)
+		require.ErrorContains(t, err, "deleting /backups/a.tar after 50ms")
+		require.False(t, retryable(err, nil))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		err := store.DeleteFile(ctx, files[0], false)
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotErrorIs(t, err, ErrOperationTimeout)
+	})
+}
+
+func TestTimeout_Finishes(t *testing.T) {
+	files := []file.Info{{Path: "/backups/a.tar"}}
+	released := &hangingBackend{files: files, release: make(chan struct{})}
+	close(released.release)
+
+	store := &timeout{Backend: released, list: time.Second, delete: time.Second}
+
+	listed, err := store.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, files, listed)
+
+	require.NoError(t, store.WalkFiles(t.Context(), func(file.Info) error { return nil }))
+	require.NoError(t, store.DeleteFile(t.Context(), files[0], false))
+}
+
+// contextBackend lists until its context is done, like a directory walk
+// checking for cancellation between entries
+type contextBackend struct {
+	Backend
+}
+
+func (contextBackend) ListFiles(ctx context.Context) ([]file.Info, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("%w: %w", file.ErrListFiles, ctx.Err())
+}
+
+func TestTimeout_ListOnly(t *testing.T) {
+	store := &timeout{Backend: contextBackend{}, list: 20 * time.Millisecond}
+
+	_, err := store.ListFiles(t.Context())
+	require.ErrorIs(t, err, ErrOperationTimeout)
+	require.ErrorContains(t, err, "listing after 20ms")
+}
@@ -0,0 +1,246 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package btrfs provides a backend for snapper-style btrfs snapshot
+// directories, where every snapshot lives in <directory>/<number>/snapshot
+// next to an info.xml file describing it.
+package btrfs
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+const (
+	// InfoFile is the snapper metadata file of a snapshot
+	InfoFile = "info.xml"
+	// SnapshotDir is the subvolume holding the snapshot contents
+	SnapshotDir = "snapshot"
+	// dateLayout is the layout of the UTC date in info.xml
+	dateLayout = "2006-01-02 15:04:05"
+)
+
+var (
+	// ErrListSnapshots is returned when the snapshot directory cannot be read
+	ErrListSnapshots = errors.New("failed to list snapshots")
+	// ErrDeleteSnapshot is returned when a snapshot cannot be deleted
+	ErrDeleteSnapshot = errors.New("failed to delete snapshot")
+)
+
+// CommandRunner runs an external command and returns its combined output
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Snapper lists and deletes snapper-style btrfs snapshots
+type Snapper struct {
+	logger    *logging.Logger
+	directory string
+	pins      []string
+	run       CommandRunner
+}
+
+// SnapperOption configures a Snapper
+type SnapperOption func(*Snapper)
+
+// WithLogger sets the logger for the snapper backend
+func WithLogger(logger *logging.Logger) SnapperOption {
+	return func(s *Snapper) {
+		s.logger = logger
+	}
+}
+
+// WithPins sets glob patterns, matched against snapshot numbers, of
+// snapshots that must never be deleted
+func WithPins(pins []string) SnapperOption {
+	return func(s *Snapper) {
+		s.pins = pins
+	}
+}
+
+// WithCommandRunner replaces the function used to run btrfs commands
+func WithCommandRunner(run CommandRunner) SnapperOption {
+	return func(s *Snapper) {
+		s.run = run
+	}
+}
+
+// NewSnapper creates a backend for the snapper snapshot directory, usually
+// a .snapshots directory at the root of a btrfs subvolume
+func NewSnapper(directory string, opts ...SnapperOption) *Snapper {
+	s := &Snapper{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		},
+		directory: directory,
+		run:       runCommand,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// info is the subset of snapper's info.xml used to date a snapshot
+type info struct {
+	Date string `xml:"date"`
+}
+
+// ListFiles returns every snapshot in the directory, oldest first. The path
+// of each entry is the numbered snapshot directory, its timestamp is read
+// from info.xml. Entries without readable metadata are skipped.
+func (s *Snapper) ListFiles(ctx context.Context) ([]file.Info, error) {
+	var snapshots []file.Info
+
+	err := s.WalkFiles(ctx, func(snapshot file.Info) error {
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(snapshots, func(a, b file.Info) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return snapshots, nil
+}
+
+// WalkFiles calls fn for every snapshot in the directory, in directory
+// order, like ListFiles but without holding every snapshot in memory
+func (s *Snapper) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	err := file.ForEachEntry(s.directory, func(entry os.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			return nil
+		}
+
+		path := filepath.Join(s.directory, entry.Name())
+
+		timestamp, err := readDate(filepath.Join(path, InfoFile))
+		if err != nil {
+			s.logger.Warn("skipping snapshot without usable metadata",
+				zap.String("snapshot", path),
+				zap.Error(err))
+
+			return nil
+		}
+
+		return fn(file.Info{
+			Path:      path,
+			Timestamp: timestamp,
+			Pinned:    file.IsPinned(s.pins, path, entry.Name()),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrListSnapshots, err)
+	}
+
+	return nil
+}
+
+// DeleteFile deletes the snapshot subvolume with btrfs subvolume delete and
+// then removes the numbered directory with its metadata. Snapshots that do
+// not reside under the snapper directory are refused with
+// file.ErrOutsideRoot.
+func (s *Snapper) DeleteFile(ctx context.Context, snapshot file.Info, dryRun bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		s.logger.Info("dry run: would delete snapshot",
+			zap.String("path", snapshot.Path),
+			zap.Time("timestamp", snapshot.Timestamp))
+
+		return nil
+	}
+
+	rel, err := file.RelativeToRoot(s.directory, snapshot.Path)
+	if err != nil {
+		return err
+	}
+
+	subvolume := filepath.Join(snapshot.Path, SnapshotDir)
+
+	if _, err := os.Lstat(subvolume); err == nil {
+		out, err := s.run(ctx, "btrfs", "subvolume", "delete", subvolume)
+		if err != nil {
+			return fmt.Errorf("%w %s: %w: %s",
+				ErrDeleteSnapshot, snapshot.Path, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if err := file.RemoveAllInRoot(s.directory, rel); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrDeleteSnapshot, snapshot.Path, err)
+	}
+
+	s.logger.Info("deleted snapshot",
+		zap.String("path", snapshot.Path),
+		zap.Time("timestamp", snapshot.Timestamp))
+
+	return nil
+}
+
+// readDate returns the UTC creation date recorded in an info.xml file
+func readDate(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var meta info
+	if err := xml.Unmarshal(data, &meta); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.ParseInLocation(dateLayout, strings.TrimSpace(meta.Date), time.UTC)
+}
+
+// runCommand runs an external command and returns its combined output
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
@@ -0,0 +1,147 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package btrfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// writeSnapshot creates a snapper snapshot directory with its metadata
+func writeSnapshot(t *testing.T, dir string, num int, date string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, fmt.Sprint(num))
+	require.NoError(t, os.MkdirAll(filepath.Join(path, SnapshotDir), 0o750))
+
+	info := fmt.Sprintf(`<?xml version="1.0"?>
+<snapshot>
+  <type>single</type>
+  <num>%d</num>
+  <date>%s</date>
+  <cleanup>timeline</cleanup>
+</snapshot>
+`, num, date)
+	require.NoError(t, os.WriteFile(filepath.Join(path, InfoFile), []byte(info), 0o600))
+
+	return path
+}
+
+func TestSnapper_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	writeSnapshot(t, dir, 2, "2024-03-15 12:00:00")
+	first := writeSnapshot(t, dir, 1, "2024-03-14 12:00:00")
+	writeSnapshot(t, dir, 3, "not a date")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "not-a-snapshot"), 0o750))
+	require.NoError(t, os.WriteFile(first+".hold", nil, 0o600))
+
+	snapper := NewSnapper(dir, WithPins([]string{"2"}))
+
+	snapshots, err := snapper.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+
+	require.Equal(t, first, snapshots[0].Path)
+	require.Equal(t, time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC), snapshots[0].Timestamp)
+	require.True(t, snapshots[0].Pinned)
+
+	require.Equal(t, filepath.Join(dir, "2"), snapshots[1].Path)
+	require.True(t, snapshots[1].Pinned)
+
+	_, err = NewSnapper(filepath.Join(dir, "missing")).ListFiles(t.Context())
+	require.ErrorIs(t, err, ErrListSnapshots)
+}
+
+func TestSnapper_DeleteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSnapshot(t, dir, 1, "2024-03-14 12:00:00")
+
+	var calls [][]string
+
+	run := func(_ context.Context, name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+
+		// Emulate btrfs removing the subvolume
+		return nil, os.Remove(args[len(args)-1])
+	}
+
+	snapper := NewSnapper(dir, WithCommandRunner(run))
+
+	snapshots, err := snapper.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	t.Run("dry run", func(t *testing.T) {
+		require.NoError(t, snapper.DeleteFile(t.Context(), snapshots[0], true))
+		require.Empty(t, calls)
+		require.DirExists(t, path)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, snapper.DeleteFile(t.Context(), snapshots[0], false))
+		require.Equal(t, [][]string{
+			{"btrfs", "subvolume", "delete", filepath.Join(path, SnapshotDir)},
+		}, calls)
+		require.NoDirExists(t, path)
+	})
+
+	t.Run("command failure", func(t *testing.T) {
+		failing := writeSnapshot(t, dir, 2, "2024-03-15 12:00:00")
+
+		snapper := NewSnapper(dir, WithCommandRunner(
+			func(context.Context, string, ...string) ([]byte, error) {
+				return []byte("ERROR: not a subvolume"), errors.New("exit status 1")
+			},
+		))
+
+		snapshots, err := snapper.ListFiles(t.Context())
+		require.NoError(t, err)
+
+		err = snapper.DeleteFile(t.Context(), snapshots[0], false)
+		require.ErrorIs(t, err, ErrDeleteSnapshot)
+		require.ErrorContains(t, err, "not a subvolume")
+		require.DirExists(t, failing)
+	})
+
+	t.Run("outside directory", func(t *testing.T) {
+		calls = nil
+		outside := writeSnapshot(t, t.TempDir(), 3, "2024-03-16 12:00:00")
+
+		err := snapper.DeleteFile(t.Context(), file.Info{Path: outside}, false)
+		require.ErrorIs(t, err, file.ErrOutsideRoot)
+		require.Empty(t, calls)
+		require.DirExists(t, outside)
+	})
+}
@@ -0,0 +1,148 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package buildinfo describes the build of the running binary. Release
+// builds set its version, commit and date with the linker's -X flag, and
+// other builds fall back to what the Go toolchain records about the module
+// and the checkout it was built from.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Development is the version of a build without version information
+const Development = "dev"
+
+// Set by release builds with -ldflags, such as
+// -X github.com/TotallyNotRobots/apply-retention-policy/internal/buildinfo.version=v1.2.3
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// Info describes a build
+type Info struct {
+	// Version is the release version, such as v1.2.3, or Development
+	Version string
+	// Commit is the git commit built from, suffixed with -dirty when the
+	// checkout had uncommitted changes
+	Commit string
+	// Date is when the binary was built, or when the commit was made if
+	// that is all that is known
+	Date string
+	// GoVersion is the version of the Go toolchain
+	GoVersion string
+	// Platform is the operating system and architecture, such as
+	// linux/amd64
+	Platform string
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		fill(&info, build)
+	}
+
+	if info.Version == "" {
+		info.Version = Development
+	}
+
+	return info
+}
+
+// fill completes info with what the toolchain recorded in build, leaving
+// the values set by the linker alone
+func fill(info *Info, build *debug.BuildInfo) {
+	if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+
+	settings := map[string]string{}
+	for _, s := range build.Settings {
+		settings[s.Key] = s.Value
+	}
+
+	if info.Commit == "" && settings["vcs.revision"] != "" {
+		info.Commit = settings["vcs.revision"]
+		if settings["vcs.modified"] == "true" {
+			info.Commit += "-dirty"
+		}
+	}
+
+	if info.Date == "" {
+		info.Date = settings["vcs.time"]
+	}
+}
+
+// IsRelease reports whether the version names a release, rather than a
+// development build or a pseudo-version
+func (i Info) IsRelease() bool {
+	_, ok := parseVersion(i.Version)
+	return ok
+}
+
+// Newer reports whether the release version latest is newer than the
+// release version current. Versions that are not releases are never newer.
+func Newer(latest, current string) bool {
+	l, okLatest := parseVersion(latest)
+	c, okCurrent := parseVersion(current)
+
+	return okLatest && okCurrent && slices.Compare(l, c) > 0
+}
+
+// parseVersion returns the major, minor and patch numbers of a release
+// version such as v1.2.3
+func parseVersion(v string) ([]int, bool) {
+	fields := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(fields) != 3 {
+		return nil, false
+	}
+
+	numbers := make([]int, len(fields))
+
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 || field != strconv.Itoa(n) {
+			return nil, false
+		}
+
+		numbers[i] = n
+	}
+
+	return numbers, true
+}
@@ -0,0 +1,106 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	version, commit, date = "v1.2.3", "abc123", "2024-03-15T00:00:00Z"
+
+	t.Cleanup(func() {
+		version, commit, date = "", "", ""
+	})
+
+	require.Equal(t, Info{
+		Version:   "v1.2.3",
+		Commit:    "abc123",
+		Date:      "2024-03-15T00:00:00Z",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}, Get())
+}
+
+func TestFill(t *testing.T) {
+	build := &debug.BuildInfo{
+		Main: debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-03-15T00:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	var info Info
+	fill(&info, build)
+	require.Equal(t, Info{Commit: "abc123-dirty", Date: "2024-03-15T00:00:00Z"}, info)
+
+	// Values set by the linker win
+	info = Info{Version: "v1.0.0", Commit: "def456"}
+	build.Main.Version = "v0.9.0"
+	fill(&info, build)
+	require.Equal(t, Info{
+		Version: "v1.0.0",
+		Commit:  "def456",
+		Date:    "2024-03-15T00:00:00Z",
+	}, info)
+
+	// go install records the module version
+	info = Info{}
+	fill(&info, &debug.BuildInfo{Main: debug.Module{Version: "v1.1.0"}})
+	require.Equal(t, Info{Version: "v1.1.0"}, info)
+}
+
+func TestNewer(t *testing.T) {
+	for _, tc := range []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.4", "v1.2.3", true},
+		{"v1.10.0", "v1.9.9", true},
+		{"v2.0.0", "v1.99.99", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "v1.3.0", false},
+		{"v1.3.0", Development, false},
+		{"v1.3.0", "v1.2.0-rc.1", false},
+		{"v1.3.0", "v0.0.0-20240315000000-abcdef123456", false},
+		{"latest", "v1.2.3", false},
+		{"v1.02.0", "v1.1.0", false},
+	} {
+		require.Equal(t, tc.want, Newer(tc.latest, tc.current), "%s > %s", tc.latest, tc.current)
+	}
+}
+
+func TestIsRelease(t *testing.T) {
+	require.True(t, Info{Version: "v1.2.3"}.IsRelease())
+	require.True(t, Info{Version: "1.2.3"}.IsRelease())
+	require.False(t, Info{Version: Development}.IsRelease())
+	require.False(t, Info{Version: "v1.2.3-rc.1"}.IsRelease())
+}
@@ -0,0 +1,480 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+// Package catalog keeps a persistent record of every prune run, every backup
+// a run has observed, with its checksum, and every deletion decision made
+// for it.
+// The catalog is a single JSON file that is loaded when a prune starts and
+// replaced atomically when it finishes. Runs, events and backups gone for
+// longer than the retention of the catalog are compacted away on every save,
+// so the file does not grow without bound.
+package catalog
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// formatVersion is the version of the catalog file format
+const formatVersion = 1
+
+// DefaultRetention is how long runs, events and backups that are gone are
+// kept in the catalog unless WithRetention says otherwise
+const DefaultRetention = 365 * 24 * time.Hour
+
+var (
+	// ErrOpenCatalog is returned when the catalog file cannot be read
+	ErrOpenCatalog = errors.New("failed to open catalog")
+	// ErrSaveCatalog is returned when the catalog file cannot be written
+	ErrSaveCatalog = errors.New("failed to save catalog")
+	// ErrUnsupportedVersion is returned for catalogs written by a newer
+	// release
+	ErrUnsupportedVersion = errors.New("unsupported catalog version")
+	// ErrObserveBackup is returned when a backup cannot be stat'ed or
+	// checksummed
+	ErrObserveBackup = errors.New("failed to observe backup")
+)
+
+// Backup is a backup the catalog has observed. Checksum is the SHA-256 of
+// the file and is empty for backups that are directories.
+type Backup struct {
+	Path      string    `json:"path"`
+	Timestamp time.Time `json:"timestamp"`
+	Tag       string    `json:"tag,omitempty"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	Checksum  string    `json:"checksum,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// Run records a single prune run and its outcome. IDs are assigned in
+// order starting at 1.
+type Run struct {
+	ID             int       `json:"id"`
+	Started        time.Time `json:"started"`
+	Finished       time.Time `json:"finished,omitzero"`
+	Directory      string    `json:"directory"`
+	DryRun         bool      `json:"dry_run,omitempty"`
+	Matched        int       `json:"matched"`
+	Deleted        int       `json:"deleted"`
+	ReclaimedBytes int64     `json:"reclaimed_bytes"`
+	Errors         []string  `json:"errors,omitempty"`
+}
+
+// Event records a deletion decision made for a backup during a run.
+// Checksum is the SHA-256 of the backup taken just before it was deleted,
+// if any, and Error is set when the deletion failed.
+type Event struct {
+	Run       int              `json:"run,omitempty"`
+	Time      time.Time        `json:"time"`
+	Path      string           `json:"path"`
+	Timestamp time.Time        `json:"timestamp"`
+	Reason    retention.Reason `json:"reason"`
+	Tier      retention.Reason `json:"tier,omitempty"`
+	DryRun    bool             `json:"dry_run,omitempty"`
+	Checksum  string           `json:"checksum,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// document is the on-disk layout of the catalog
+type document struct {
+	Version int      `json:"version"`
+	Runs    []Run    `json:"runs"`
+	Backups []Backup `json:"backups"`
+	Events  []Event  `json:"events"`
+}
+
+// Option is a function that configures a Catalog
+type Option func(*Catalog)
+
+// Catalog is the record of observed backups and deletion decisions. It is
+// not safe for concurrent use.
+type Catalog struct {
+	path      string
+	logger    *logging.Logger
+	now       func() time.Time
+	retention time.Duration
+	runs      []Run
+	backups   map[string]*Backup
+	events    []Event
+}
+
+// WithLogger sets the logger for the Catalog
+func WithLogger(logger *logging.Logger) Option {
+	return func(c *Catalog) {
+		c.logger = logger
+	}
+}
+
+// WithClock sets the function used to timestamp observations and events
+func WithClock(now func() time.Time) Option {
+	return func(c *Catalog) {
+		c.now = now
+	}
+}
+
+// WithRetention sets how long runs, events and backups that were deleted or
+// not seen since are kept, DefaultRetention by default
+func WithRetention(retention time.Duration) Option {
+	return func(c *Catalog) {
+		c.retention = retention
+	}
+}
+
+// Open loads the catalog stored at path. A missing file is an empty
+// catalog, created when the catalog is first saved.
+func Open(path string, opts ...Option) (*Catalog, error) {
+	c := &Catalog{
+		path: path,
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		}, // Default no-op logger
+		now:       time.Now,
+		retention: DefaultRetention,
+		backups:   map[string]*Backup{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenCatalog, err)
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrOpenCatalog, path, err)
+	}
+
+	if doc.Version > formatVersion {
+		return nil, fmt.Errorf("%w: %s: version %d", ErrUnsupportedVersion, path, doc.Version)
+	}
+
+	for _, b := range doc.Backups {
+		c.backups[b.Path] = &b
+	}
+
+	c.runs = doc.Runs
+	c.events = doc.Events
+
+	return c, nil
+}
+
+// StartRun starts recording a new run. Decisions recorded until the run is
+// finished belong to it.
+func (c *Catalog) StartRun(dryRun bool) int {
+	id := 1
+	if len(c.runs) > 0 {
+		id = c.runs[len(c.runs)-1].ID + 1
+	}
+
+	c.runs = append(c.runs, Run{
+		ID:      id,
+		Started: c.now(),
+		DryRun:  dryRun,
+	})
+
+	return id
+}
+
+// FinishRun records the outcome of the current run
+func (c *Catalog) FinishRun(summary notify.Summary) {
+	run := c.current()
+	if run == nil {
+		return
+	}
+
+	run.Finished = c.now()
+	run.Directory = summary.Directory
+	run.Matched = summary.Matched
+	run.Deleted = summary.Deleted
+	run.ReclaimedBytes = summary.ReclaimedBytes
+	run.Errors = summary.Errors
+}
+
+// current returns the run being recorded, or nil outside of a run
+func (c *Catalog) current() *Run {
+	if len(c.runs) == 0 || !c.runs[len(c.runs)-1].Finished.IsZero() {
+		return nil
+	}
+
+	return &c.runs[len(c.runs)-1]
+}
+
+// Observe records that a backup was seen. Only backups that are new to the
+// catalog, or whose size or modification time changed since they were last
+// seen, are stat'ed and checksummed. The size and modification time are
+// those the backup was listed with, or those of a stat for backends that
+// report no modification time.
+func (c *Catalog) Observe(f file.Info) error {
+	var info os.FileInfo
+
+	size, modTime := f.Size, f.ModTime
+	if modTime.IsZero() {
+		var err error
+
+		info, err = os.Stat(f.Path)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrObserveBackup, err)
+		}
+
+		size, modTime = info.Size(), info.ModTime()
+	}
+
+	now := c.now()
+
+	b, ok := c.backups[f.Path]
+	if !ok || b.Size != size || !b.ModTime.Equal(modTime) {
+		checksum, err := c.checksum(f.Path, info)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrObserveBackup, err)
+		}
+
+		c.logger.Debug("cataloged backup",
+			zap.String("file", f.Path),
+			zap.String("checksum", checksum))
+
+		b = &Backup{
+			Path:      f.Path,
+			Size:      size,
+			ModTime:   modTime,
+			Checksum:  checksum,
+			FirstSeen: now,
+		}
+		c.backups[f.Path] = b
+	}
+
+	b.Timestamp = f.Timestamp
+	b.Tag = f.Tag
+	b.LastSeen = now
+	b.DeletedAt = time.Time{}
+
+	return nil
+}
+
+// checksum returns the SHA-256 of the backup at path, described by info if
+// it was already stat'ed, or nothing for backups that are directories
+func (c *Catalog) checksum(path string, info os.FileInfo) (string, error) {
+	if info == nil {
+		var err error
+
+		info, err = os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", nil
+	}
+
+	return file.Checksum(path)
+}
+
+// Record appends a deletion decision to the history. err is the error the
+// deletion failed with, if any. Backups deleted outside of a dry run are
+// marked as deleted.
+func (c *Catalog) Record(d retention.Decision, dryRun bool, err error) {
+	c.RecordAt(d, c.now(), dryRun, err)
+}
+
+// RecordAt is Record for a deletion made at a given time, such as one a
+// crashed run started without recording it
+func (c *Catalog) RecordAt(d retention.Decision, at time.Time, dryRun bool, err error) {
+	event := Event{
+		Time:      at,
+		Path:      d.File.Path,
+		Timestamp: d.File.Timestamp,
+		Reason:    d.Reason,
+		Tier:      d.Tier,
+		DryRun:    dryRun,
+		Checksum:  d.File.Checksum,
+	}
+
+	if run := c.current(); run != nil {
+		event.Run = run.ID
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	c.events = append(c.events, event)
+
+	if b, ok := c.backups[d.File.Path]; ok && err == nil && !dryRun {
+		b.DeletedAt = at
+	}
+}
+
+// Backups returns every backup in the catalog, oldest first
+func (c *Catalog) Backups() []Backup {
+	backups := make([]Backup, 0, len(c.backups))
+	for _, path := range slices.Sorted(maps.Keys(c.backups)) {
+		backups = append(backups, *c.backups[path])
+	}
+
+	slices.SortStableFunc(backups, func(a, b Backup) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return backups
+}
+
+// Runs returns every recorded run, oldest first
+func (c *Catalog) Runs() []Run {
+	return slices.Clone(c.runs)
+}
+
+// Run returns the run with the given ID
+func (c *Catalog) Run(id int) (Run, bool) {
+	i, found := slices.BinarySearchFunc(c.runs, id, func(r Run, id int) int {
+		return cmp.Compare(r.ID, id)
+	})
+	if !found {
+		return Run{}, false
+	}
+
+	return c.runs[i], true
+}
+
+// History returns every recorded deletion decision in the order they were
+// made
+func (c *Catalog) History() []Event {
+	return slices.Clone(c.events)
+}
+
+// compact drops the runs and events older than the retention of the
+// catalog, and the backups deleted or last seen before it. The latest run
+// is always kept so run IDs keep counting up.
+func (c *Catalog) compact() {
+	if c.retention <= 0 {
+		return
+	}
+
+	cutoff := c.now().Add(-c.retention)
+
+	if len(c.runs) > 1 {
+		last := len(c.runs) - 1
+		c.runs = append(slices.DeleteFunc(c.runs[:last], func(r Run) bool {
+			return r.Started.Before(cutoff)
+		}), c.runs[last])
+	}
+
+	c.events = slices.DeleteFunc(c.events, func(e Event) bool {
+		return e.Time.Before(cutoff)
+	})
+
+	maps.DeleteFunc(c.backups, func(_ string, b *Backup) bool {
+		if b.DeletedAt.IsZero() {
+			return b.LastSeen.Before(cutoff)
+		}
+
+		return b.DeletedAt.Before(cutoff)
+	})
+}
+
+// Save compacts the catalog and writes it back to its file. The catalog is
+// written and synced to a temporary file that is renamed into place, so an
+// interrupted save or a crash never leaves a truncated catalog behind.
+func (c *Catalog) Save() error {
+	c.compact()
+
+	doc := document{
+		Version: formatVersion,
+		Runs:    c.runs,
+		Backups: make([]Backup, 0, len(c.backups)),
+		Events:  c.events,
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(c.backups)) {
+		doc.Backups = append(doc.Backups, *c.backups[path])
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	// Remove the temporary file on any failure below
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("%w: %w", ErrSaveCatalog, err)
+	}
+
+	committed = true
+
+	return nil
+}
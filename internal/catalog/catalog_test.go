@@ -0,0 +1,241 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package catalog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/notify"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+)
+
+// sha256 of "backup"
+const backupChecksum = "54d00d867758cef816bc4685f58e327b949712b07ebd17c3485f3ffc9e9f5133"
+
+func TestCatalog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "catalog.json")
+
+	backup := filepath.Join(dir, "backup-2024-03-15.tar.gz")
+	require.NoError(t, os.WriteFile(backup, []byte("backup"), 0o600))
+
+	now := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	f := file.Info{
+		Path:      backup,
+		Timestamp: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		Tag:       "nightly",
+	}
+
+	t.Run("missing file is empty", func(t *testing.T) {
+		cat, err := Open(path)
+		require.NoError(t, err)
+		require.Empty(t, cat.Backups())
+		require.Empty(t, cat.History())
+	})
+
+	t.Run("observe and save", func(t *testing.T) {
+		cat, err := Open(path, WithClock(clock))
+		require.NoError(t, err)
+		require.NoError(t, cat.Observe(f))
+
+		cat.Record(retention.Decision{
+			File:   f,
+			Delete: true,
+			Reason: retention.ReasonExpired,
+			Tier:   retention.ReasonExpired,
+		}, true, nil)
+
+		require.NoError(t, cat.Save())
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		cat, err := Open(path)
+		require.NoError(t, err)
+
+		backups := cat.Backups()
+		require.Len(t, backups, 1)
+		require.Equal(t, backup, backups[0].Path)
+		require.Equal(t, "nightly", backups[0].Tag)
+		require.Equal(t, int64(len("backup")), backups[0].Size)
+		require.Equal(t, backupChecksum, backups[0].Checksum)
+		require.True(t, backups[0].FirstSeen.Equal(now))
+		require.True(t, backups[0].DeletedAt.IsZero(), "dry runs do not delete")
+
+		history := cat.History()
+		require.Len(t, history, 1)
+		require.Equal(t, retention.ReasonExpired, history[0].Reason)
+		require.True(t, history[0].DryRun)
+	})
+
+	t.Run("unchanged backups are not checksummed again", func(t *testing.T) {
+		cat, err := Open(path, WithClock(func() time.Time { return now.Add(time.Hour) }))
+		require.NoError(t, err)
+
+		// Rewrite the backup without changing its size or modification time,
+		// so a new checksum could only come from reading it again
+		info, err := os.Stat(backup)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(backup, []byte("BACKUP"), 0o600))
+		require.NoError(t, os.Chtimes(backup, info.ModTime(), info.ModTime()))
+
+		require.NoError(t, cat.Observe(f))
+
+		second := cat.Backups()[0]
+		require.Equal(t, backupChecksum, second.Checksum)
+		require.True(t, second.FirstSeen.Equal(now))
+		require.True(t, second.LastSeen.Equal(now.Add(time.Hour)))
+	})
+
+	t.Run("deletion", func(t *testing.T) {
+		cat, err := Open(path, WithClock(clock))
+		require.NoError(t, err)
+
+		cat.Record(retention.Decision{File: f, Delete: true}, false, nil)
+		require.True(t, cat.Backups()[0].DeletedAt.Equal(now))
+		require.Len(t, cat.History(), 2)
+	})
+
+	t.Run("runs", func(t *testing.T) {
+		cat, err := Open(path, WithClock(clock))
+		require.NoError(t, err)
+		require.Empty(t, cat.Runs())
+
+		require.Equal(t, 1, cat.StartRun(false))
+		cat.Record(retention.Decision{File: f, Delete: true}, false, errors.New("busy"))
+		cat.FinishRun(notify.Summary{Matched: 1, Errors: []string{"busy"}})
+
+		require.Equal(t, 2, cat.StartRun(true))
+		require.NoError(t, cat.Save())
+
+		cat, err = Open(path)
+		require.NoError(t, err)
+
+		first, ok := cat.Run(1)
+		require.True(t, ok)
+		require.Equal(t, 1, first.Matched)
+		require.Equal(t, []string{"busy"}, first.Errors)
+		require.True(t, first.Finished.Equal(now))
+
+		second, ok := cat.Run(2)
+		require.True(t, ok)
+		require.True(t, second.DryRun)
+		require.True(t, second.Finished.IsZero(), "the run was never finished")
+
+		_, ok = cat.Run(3)
+		require.False(t, ok)
+
+		history := cat.History()
+		require.Equal(t, 0, history[0].Run, "decisions before the first run")
+		require.Equal(t, 1, history[len(history)-1].Run)
+		require.Equal(t, "busy", history[len(history)-1].Error)
+	})
+
+	t.Run("listed size and modification time", func(t *testing.T) {
+		listedPath := filepath.Join(t.TempDir(), "catalog.json")
+		listed := file.Info{
+			Path:    filepath.Join(dir, "listed.tar.gz"),
+			Size:    int64(len("backup")),
+			ModTime: now,
+		}
+		require.NoError(t, os.WriteFile(listed.Path, []byte("backup"), 0o600))
+
+		cat, err := Open(listedPath, WithClock(clock))
+		require.NoError(t, err)
+		require.NoError(t, cat.Observe(listed))
+		require.Equal(t, backupChecksum, cat.Backups()[0].Checksum)
+
+		// A backup listed as unchanged is not looked at again
+		require.NoError(t, os.Remove(listed.Path))
+		require.NoError(t, cat.Observe(listed))
+		require.True(t, cat.Backups()[0].ModTime.Equal(now))
+	})
+
+	t.Run("compaction", func(t *testing.T) {
+		compactPath := filepath.Join(t.TempDir(), "catalog.json")
+		day := 24 * time.Hour
+
+		at := now
+		cat, err := Open(compactPath,
+			WithClock(func() time.Time { return at }), WithRetention(30*day))
+		require.NoError(t, err)
+
+		require.Equal(t, 1, cat.StartRun(false))
+		require.NoError(t, cat.Observe(f))
+		cat.Record(retention.Decision{File: f, Delete: true}, false, nil)
+		cat.FinishRun(notify.Summary{})
+
+		gone := file.Info{Path: filepath.Join(dir, "gone.tar.gz"), Size: 1, ModTime: now}
+		require.NoError(t, os.WriteFile(gone.Path, []byte("1"), 0o600))
+		require.NoError(t, cat.Observe(gone))
+
+		// 31 days later the deleted backup, the backup no longer seen and
+		// the old decision are dropped, but the latest run is kept
+		at = now.Add(31 * day)
+
+		kept := file.Info{Path: filepath.Join(dir, "kept.tar.gz"), Size: 1, ModTime: at}
+		require.NoError(t, os.WriteFile(kept.Path, []byte("1"), 0o600))
+		require.NoError(t, cat.Observe(kept))
+		require.NoError(t, cat.Save())
+
+		cat, err = Open(compactPath)
+		require.NoError(t, err)
+		require.Len(t, cat.Backups(), 1)
+		require.Equal(t, kept.Path, cat.Backups()[0].Path)
+		require.Empty(t, cat.History())
+		require.Len(t, cat.Runs(), 1)
+		require.Equal(t, 2, cat.StartRun(false))
+	})
+
+	t.Run("missing backup", func(t *testing.T) {
+		cat, err := Open(path)
+		require.NoError(t, err)
+		require.ErrorIs(t, cat.Observe(file.Info{Path: filepath.Join(dir, "missing")}),
+			ErrObserveBackup)
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		newer := filepath.Join(dir, "newer.json")
+		require.NoError(t, os.WriteFile(newer, []byte(`{"version": 2}`), 0o600))
+
+		_, err := Open(newer)
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("corrupt file", func(t *testing.T) {
+		corrupt := filepath.Join(dir, "corrupt.json")
+		require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+		_, err := Open(corrupt)
+		require.ErrorIs(t, err, ErrOpenCatalog)
+	})
+}
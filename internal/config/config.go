@@ -30,87 +30,1117 @@
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/TotallyNotRobots/apply-retention-policy/internal/consts"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
 )
 
-// RetentionPolicy defines how many backups to keep for each time period
+// RetentionPolicy defines how many backups to keep for each time period.
+// Thinning adds experimental tiers beyond the yearly one, ordered by their
+// growing periods.
 type RetentionPolicy struct {
-	Hourly  int `mapstructure:"hourly"  yaml:"hourly"`
-	Daily   int `mapstructure:"daily"   yaml:"daily"`
-	Weekly  int `mapstructure:"weekly"  yaml:"weekly"`
-	Monthly int `mapstructure:"monthly" yaml:"monthly"`
-	Yearly  int `mapstructure:"yearly"  yaml:"yearly"`
+	Hourly   int            `mapstructure:"hourly"   yaml:"hourly"`
+	Daily    int            `mapstructure:"daily"    yaml:"daily"`
+	Weekly   int            `mapstructure:"weekly"   yaml:"weekly"`
+	Monthly  int            `mapstructure:"monthly"  yaml:"monthly"`
+	Yearly   int            `mapstructure:"yearly"   yaml:"yearly"`
+	Thinning []ThinningTier `mapstructure:"thinning" yaml:"thinning"`
 }
 
-// Config represents the application configuration
+// ThinningTier keeps one backup for each of the Count newest periods of
+// Every years among the backups older than the finer tiers keep, so very old
+// history is sampled ever more sparsely instead of being deleted.
+type ThinningTier struct {
+	Every int `mapstructure:"every" yaml:"every"`
+	Count int `mapstructure:"count" yaml:"count"`
+}
+
+// IsZero reports whether the policy keeps nothing in any tier
+func (r RetentionPolicy) IsZero() bool {
+	return r.Hourly == 0 && r.Daily == 0 && r.Weekly == 0 && r.Monthly == 0 &&
+		r.Yearly == 0 && len(r.Thinning) == 0
+}
+
+// TagPolicies maps a {tag} value to the retention policy used for it
+type TagPolicies map[string]RetentionPolicy
+
+// NamedPolicy is a set of retention settings selected by name with the
+// policy key. A selected policy replaces the top-level retention,
+// tag_retention and keep_count, and require_minimum when it sets one.
+type NamedPolicy struct {
+	Retention      RetentionPolicy `mapstructure:"retention"       yaml:"retention"`
+	KeepCount      int             `mapstructure:"keep_count"      yaml:"keep_count"`
+	TagRetention   TagPolicies     `mapstructure:"tag_retention"   yaml:"tag_retention"`
+	RequireMinimum *int            `mapstructure:"require_minimum" yaml:"require_minimum"`
+}
+
+// SubdirectoryPolicy prunes the subdirectories of every directory matching
+// the glob Path, relative to the directory, with the named policy Policy, or
+// with the top-level retention settings when Policy is empty
+type SubdirectoryPolicy struct {
+	Path   string `mapstructure:"path"   yaml:"path"`
+	Policy string `mapstructure:"policy" yaml:"policy"`
+}
+
+// SlackConfig configures the Slack incoming webhook notification sender.
+// The webhook URL is a credential; it is left out of logs.
+type SlackConfig struct {
+	WebhookURL string `mapstructure:"webhook_url" yaml:"webhook_url" json:"-"`
+	Channel    string `mapstructure:"channel"     yaml:"channel"`
+}
+
+// DiscordConfig configures the Discord webhook notification sender. The
+// webhook URL is a credential; it is left out of logs.
+type DiscordConfig struct {
+	WebhookURL string `mapstructure:"webhook_url" yaml:"webhook_url" json:"-"`
+}
+
+// NotificationsConfig defines where run summaries are sent after a prune
+type NotificationsConfig struct {
+	Slack   SlackConfig   `mapstructure:"slack"   yaml:"slack"`
+	Discord DiscordConfig `mapstructure:"discord" yaml:"discord"`
+}
+
+// FreshnessConfig configures the check-freshness command. A directory is
+// stale when its newest backup is older than MaxAge, and Notify sends an
+// alert to the configured notification targets when one is.
+type FreshnessConfig struct {
+	MaxAge time.Duration `mapstructure:"max_age" yaml:"max_age"`
+	Notify bool          `mapstructure:"notify"  yaml:"notify"`
+}
+
+// HooksConfig defines shell commands run around a prune. PreDelete runs once
+// per file before it is deleted and a non-zero exit keeps that file.
+type HooksConfig struct {
+	PreRun    string `mapstructure:"pre_run"    yaml:"pre_run"`
+	PostRun   string `mapstructure:"post_run"   yaml:"post_run"`
+	OnError   string `mapstructure:"on_error"   yaml:"on_error"`
+	PreDelete string `mapstructure:"pre_delete" yaml:"pre_delete"`
+}
+
+// KubernetesConfig selects the VolumeSnapshots managed by the volumesnapshot
+// backend, whose directories are namespaces
+type KubernetesConfig struct {
+	LabelSelector string `mapstructure:"label_selector" yaml:"label_selector"`
+}
+
+// ManifestConfig locates the manifest listing the backups of the manifest
+// backend. Path is relative to the directory unless it is absolute, and
+// Format is ManifestFormatJSON or ManifestFormatCSV, by default CSV for a
+// path ending in .csv and JSON otherwise.
+type ManifestConfig struct {
+	Path   string `mapstructure:"path"   yaml:"path"`
+	Format string `mapstructure:"format" yaml:"format"`
+}
+
+// BackupInfoConfig tags backups of the files backend by the
+// backup-info.json inside them or next to them instead of their names, for
+// backup tools that name backups with opaque identifiers. The tag is the
+// type of the backup, followed by the values of Labels joined with slashes.
+type BackupInfoConfig struct {
+	Enabled bool     `mapstructure:"enabled" yaml:"enabled"`
+	Labels  []string `mapstructure:"labels"  yaml:"labels"`
+}
+
+// APIConfig configures the API server started by the serve command. Token
+// is the bearer token every request must present; it is left out of logs.
+type APIConfig struct {
+	Token string `mapstructure:"token" yaml:"token" json:"-"`
+}
+
+// LogRotationConfig configures rotation of log_file. MaxSize is a size such
+// as "100MiB"; rotation is disabled when it is empty. MaxAge and MaxBackups
+// limit how long and how many rotated files are kept.
+type LogRotationConfig struct {
+	MaxSize    string        `mapstructure:"max_size"    yaml:"max_size"`
+	MaxAge     time.Duration `mapstructure:"max_age"     yaml:"max_age"`
+	MaxBackups int           `mapstructure:"max_backups" yaml:"max_backups"`
+}
+
+// RetryConfig configures retrying deletions that fail with a transient
+// error. Attempts is the total number of attempts, so retrying is disabled
+// below two. The delay starts at Backoff and doubles up to MaxBackoff.
+// Retryable lists extra error message substrings treated as transient.
+type RetryConfig struct {
+	Attempts   int           `mapstructure:"attempts"    yaml:"attempts"`
+	Backoff    time.Duration `mapstructure:"backoff"     yaml:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff" yaml:"max_backoff"`
+	Retryable  []string      `mapstructure:"retryable"   yaml:"retryable"`
+}
+
+// StaleFilesConfig configures deleting the temporary files failed backup
+// jobs leave behind, which never match the backup pattern. Files whose name
+// matches one of Patterns and that were last modified more than MinAge ago
+// are deleted; the cleanup is disabled while MinAge is zero.
+type StaleFilesConfig struct {
+	Patterns []string      `mapstructure:"patterns" yaml:"patterns"`
+	MinAge   time.Duration `mapstructure:"min_age"  yaml:"min_age"`
+}
+
+// CompanionFilesConfig configures deleting checksum and manifest files whose
+// backup no longer exists. A file named after a backup with one of Suffixes
+// appended is its companion, and orphaned companions are deleted after the
+// policy is applied when DeleteOrphans is set.
+type CompanionFilesConfig struct {
+	Suffixes      []string `mapstructure:"suffixes"       yaml:"suffixes"`
+	DeleteOrphans bool     `mapstructure:"delete_orphans" yaml:"delete_orphans"`
+}
+
+// ArchiveConfig configures moving backups to an archive instead of deleting
+// them. Tiers maps the tier a deleted backup fell into, keep_count, or
+// expired for backups outside every tier, to the directory it is moved to.
+// Backups of tiers without a destination are deleted as usual. Transform is
+// a shell command that reads a backup on stdin and writes what is stored in
+// the archive to stdout, such as an encryption tool; TransformSuffix is
+// added to the names of transformed backups.
+type ArchiveConfig struct {
+	Tiers           map[string]string `mapstructure:"tiers"            yaml:"tiers"`
+	Transform       string            `mapstructure:"transform"        yaml:"transform"`
+	TransformSuffix string            `mapstructure:"transform_suffix" yaml:"transform_suffix"`
+}
+
+// ValidationError reports every problem found in a configuration rather than
+// just the first one
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, err := range e.Problems {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Config represents the application configuration. The directory key
+// accepts either a single path or a list of paths, all of which are pruned
+// with the same pattern and policy. Backend selects how backups are found
+// and deleted and defaults to BackendFiles. ComputeSizes replaces the size
+// a backend reports for each backup with the cumulative size of everything
+// below it, for backends whose backups are directories. MinFreeSpace is a
+// size such as "10GiB"; when the filesystem holding a directory has less
+// space available after pruning, retention is relaxed until it recovers.
+// Catalog is the path of a file recording every observed backup and every
+// deletion decision; no catalog is kept when it is empty. CatalogRetention
+// is how long the catalog keeps runs, events and backups that are gone,
+// catalog.DefaultRetention when zero. KeepCount replaces
+// the retention tiers with keeping the newest KeepCount backups, ordered by
+// modification time rather than by a timestamp in their names. Policies
+// holds named policies, and Policy selects the one used in place of the
+// top-level retention settings. FilePattern is matched against the path
+// relative to the directory, or against the file name alone with
+// MatchBasename, and case-insensitively with IgnoreCase. DayBoundaryOffset
+// shifts timestamps before they are grouped into days, weeks, months and
+// years, so with -6h a backup finishing at 01:00 still counts towards the
+// previous day. Dedupe deletes backups byte-identical to a newer backup of
+// the same tag and day instead of giving them a retention slot. With the
+// volumesnapshot backend every directory names a Kubernetes namespace, and
+// Manifest locates the manifest read by the manifest backend.
+// LogJournal sends logs to the systemd journal instead of stderr. LogFormat
+// is "json" or "console", and LogFile writes logs to a file rotated as
+// configured by LogRotation. LogOutput is LogOutputStderr, the default, or
+// a syslog URL logs are sent to instead. LogEventSource is the Windows
+// Event Log source warnings, errors and the run summary are also reported
+// under. LogDecisions is LogDecisionsFile,
+// the default, or LogDecisionsSummary. Checkpoint is where an interrupted prune
+// records the directories it did not finish, by default in the user's cache
+// directory. Journal is the path of a file every deletion is recorded in
+// before it starts and once it finishes, so the next run can report the
+// deletions a crashed run left in flight; no journal is kept when it is
+// empty. Retry retries deletions that fail with transient errors.
+// StaleFiles deletes leftover temporary files before the policy is applied,
+// and CompanionFiles deletes checksum and manifest files left without their
+// backup after it. Archive moves backups the policy gives up to an archive
+// instead of deleting them. CompressAfter compresses the kept backups older
+// than it with CompressFormat, which defaults to CompressFormatGzip;
+// compression is disabled while it is zero. RemoveEmptyDirs removes the
+// subdirectories of a directory that deletions leave empty.
+// TimestampTiebreak decides which of several backups with exactly the same
+// timestamp is kept, and defaults to TiebreakName. PatternSyntax is how the
+// text around the tokens of FilePattern is read, and defaults to
+// PatternSyntaxTokens. TemplateVars lists the environment variables that
+// ${NAME} references in other values may expand, see expandTemplates.
+// Freshness configures when check-freshness reports backups as stale.
+// OperationTimeout bounds listing a directory and each single deletion, so
+// a hung filesystem fails the run instead of blocking it; zero means no
+// limit. ListTimeout bounds listing a directory in place of
+// OperationTimeout. MonthlyAnchor picks the backup the monthly tier keeps
+// for each month: MonthlyAnchorLast, the default, MonthlyAnchorFirst or a
+// day of the month, see MonthlyAnchorDay. FiscalYearStart is the month, from
+// 1 to 12, the years of the yearly tier begin in; zero means January.
+// Owner and Group, given by name or numeric ID, restrict the files backend
+// to the backups they own, so other users' files in a shared directory are
+// left alone. TimestampXattr names an extended attribute, such as
+// user.backup.timestamp, the files backend dates backups by instead of
+// their names or modification times. RequireMountpoint refuses to run
+// unless every directory is the mountpoint of a filesystem, of type
+// MountpointFstype if set, so a share that failed to mount is not mistaken
+// for an empty directory. SentinelFile names a file, such as .backup-root,
+// every directory must hold before it is pruned. ChecksumDeletions takes the
+// SHA-256 of every backup before it is deleted and records it in the
+// journal, the catalog and the summary file. SummarySigningKey is the GPG
+// key the summary file of prune --summary-file is signed with, named by its
+// ID or user ID rather than holding key material.
+// HardenedListing has the files backend open every directory once and list,
+// stat and delete its backups relative to that handle, on Linux only. API
+// configures the serve command.
 type Config struct {
-	Retention   RetentionPolicy `mapstructure:"retention"    yaml:"retention"`
-	FilePattern string          `mapstructure:"file_pattern" yaml:"file_pattern"`
-	Directory   string          `mapstructure:"directory"    yaml:"directory"`
-	DryRun      bool            `mapstructure:"dry_run"      yaml:"dry_run"`
-	LogLevel    string          `mapstructure:"log_level"    yaml:"log_level"`
+	Retention      RetentionPolicy        `mapstructure:"retention"       yaml:"retention"`
+	KeepCount      int                    `mapstructure:"keep_count"      yaml:"keep_count"`
+	Dedupe         bool                   `mapstructure:"dedupe"          yaml:"dedupe"`
+	Backend        string                 `mapstructure:"backend"         yaml:"backend"`
+	ComputeSizes   bool                   `mapstructure:"compute_sizes"   yaml:"compute_sizes"`
+	FilePattern    string                 `mapstructure:"file_pattern"    yaml:"file_pattern"`
+	Owner          string                 `mapstructure:"owner"           yaml:"owner"`
+	Group          string                 `mapstructure:"group"           yaml:"group"`
+	MatchBasename  bool                   `mapstructure:"match_basename"  yaml:"match_basename"`
+	IgnoreCase     bool                   `mapstructure:"ignore_case"     yaml:"ignore_case"`
+	Directories    []string               `mapstructure:"directory"       yaml:"directory"`
+	TagRetention   TagPolicies            `mapstructure:"tag_retention"   yaml:"tag_retention"`
+	RequireMinimum int                    `mapstructure:"require_minimum" yaml:"require_minimum"`
+	MinFreeSpace   string                 `mapstructure:"min_free_space"  yaml:"min_free_space"`
+	Pins           []string               `mapstructure:"pins"            yaml:"pins"`
+	DryRun         bool                   `mapstructure:"dry_run"         yaml:"dry_run"`
+	FailFast       bool                   `mapstructure:"fail_fast"       yaml:"fail_fast"`
+	Retry          RetryConfig            `mapstructure:"retry"           yaml:"retry"`
+	StaleFiles     StaleFilesConfig       `mapstructure:"stale_files"     yaml:"stale_files"`
+	CompanionFiles CompanionFilesConfig   `mapstructure:"companion_files" yaml:"companion_files"`
+	Archive        ArchiveConfig          `mapstructure:"archive"         yaml:"archive"`
+	CompressAfter  time.Duration          `mapstructure:"compress_after"  yaml:"compress_after"`
+	CompressFormat string                 `mapstructure:"compress_format" yaml:"compress_format"`
+	LogLevel       string                 `mapstructure:"log_level"       yaml:"log_level"`
+	LogJournal     bool                   `mapstructure:"log_journal"     yaml:"log_journal"`
+	LogFormat      string                 `mapstructure:"log_format"      yaml:"log_format"`
+	LogFile        string                 `mapstructure:"log_file"        yaml:"log_file"`
+	LogOutput      string                 `mapstructure:"log_output"      yaml:"log_output"`
+	LogRotation    LogRotationConfig      `mapstructure:"log_rotation"    yaml:"log_rotation"`
+	LogDecisions   string                 `mapstructure:"log_decisions"   yaml:"log_decisions"`
+	Notifications  NotificationsConfig    `mapstructure:"notifications"   yaml:"notifications"`
+	Freshness      FreshnessConfig        `mapstructure:"freshness"       yaml:"freshness"`
+	Hooks          HooksConfig            `mapstructure:"hooks"           yaml:"hooks"`
+	Kubernetes     KubernetesConfig       `mapstructure:"kubernetes"      yaml:"kubernetes"`
+	Manifest       ManifestConfig         `mapstructure:"manifest"        yaml:"manifest"`
+	BackupInfo     BackupInfoConfig       `mapstructure:"backup_info"     yaml:"backup_info"`
+	API            APIConfig              `mapstructure:"api"             yaml:"api"`
+	Catalog        string                 `mapstructure:"catalog"         yaml:"catalog"`
+	Checkpoint     string                 `mapstructure:"checkpoint"      yaml:"checkpoint"`
+	Journal        string                 `mapstructure:"journal"         yaml:"journal"`
+	Policies       map[string]NamedPolicy `mapstructure:"policies"        yaml:"policies"`
+	Policy         string                 `mapstructure:"policy"          yaml:"policy"`
+	Subdirectories []SubdirectoryPolicy   `mapstructure:"subdirectories"  yaml:"subdirectories"`
+	TemplateVars   []string               `mapstructure:"template_vars"   yaml:"template_vars"`
+
+	DayBoundaryOffset time.Duration `mapstructure:"day_boundary_offset" yaml:"day_boundary_offset"`
+	RemoveEmptyDirs   bool          `mapstructure:"remove_empty_dirs"   yaml:"remove_empty_dirs"`
+	TimestampTiebreak string        `mapstructure:"timestamp_tiebreak"  yaml:"timestamp_tiebreak"`
+	PatternSyntax     string        `mapstructure:"pattern_syntax"      yaml:"pattern_syntax"`
+	OperationTimeout  time.Duration `mapstructure:"operation_timeout"   yaml:"operation_timeout"`
+	TimestampXattr    string        `mapstructure:"timestamp_xattr"     yaml:"timestamp_xattr"`
+	ListTimeout       time.Duration `mapstructure:"list_timeout"        yaml:"list_timeout"`
+	MonthlyAnchor     string        `mapstructure:"monthly_anchor"      yaml:"monthly_anchor"`
+	FiscalYearStart   int           `mapstructure:"fiscal_year_start"   yaml:"fiscal_year_start"`
+	FailOnWarnings    string        `mapstructure:"fail_on_warnings"    yaml:"fail_on_warnings"`
+	RequireMountpoint bool          `mapstructure:"require_mountpoint"  yaml:"require_mountpoint"`
+	MountpointFstype  string        `mapstructure:"mountpoint_fstype"   yaml:"mountpoint_fstype"`
+	SentinelFile      string        `mapstructure:"sentinel_file"       yaml:"sentinel_file"`
+	ChecksumDeletions bool          `mapstructure:"checksum_deletions"  yaml:"checksum_deletions"`
+	SummarySigningKey string        `mapstructure:"summary_signing_key" yaml:"summary_signing_key"`
+	LogEventSource    string        `mapstructure:"log_event_source"    yaml:"log_event_source"`
+	HardenedListing   bool          `mapstructure:"hardened_listing"    yaml:"hardened_listing"`
+	CatalogRetention  time.Duration `mapstructure:"catalog_retention"   yaml:"catalog_retention"`
 }
 
-// LoadConfig loads the configuration from the specified file
+// DefaultRequireMinimum is the default number of files that always survive
+const DefaultRequireMinimum = 1
+
+// Backends a directory can be pruned with
+const (
+	// BackendFiles prunes plain backup files matched by file_pattern
+	BackendFiles = "files"
+	// BackendBtrfs prunes snapper-style btrfs snapshot directories
+	BackendBtrfs = "btrfs"
+	// BackendXtrabackup prunes xtrabackup backup directories without
+	// breaking incremental chains
+	BackendXtrabackup = "xtrabackup"
+	// BackendRsnapshot prunes hard-linked backup trees created by rsnapshot
+	// or rsync --link-dest
+	BackendRsnapshot = "rsnapshot"
+	// BackendRestic applies retention to a restic repository with
+	// restic forget --prune instead of deleting backups itself
+	BackendRestic = "restic"
+	// BackendBorg applies retention to a borg repository with borg prune
+	BackendBorg = "borg"
+	// BackendVolumeSnapshot prunes Kubernetes CSI VolumeSnapshots, treating
+	// every directory as a namespace
+	BackendVolumeSnapshot = "volumesnapshot"
+	// BackendManifest prunes the backups listed in a manifest written by the
+	// backup tool instead of walking the directory
+	BackendManifest = "manifest"
+)
+
+// Formats of the manifest read by the manifest backend
+const (
+	// ManifestFormatJSON is a JSON array of entries
+	ManifestFormatJSON = "json"
+	// ManifestFormatCSV is a CSV file with a header row
+	ManifestFormatCSV = "csv"
+)
+
+// Tiebreakers deciding which of several backups with the same timestamp is
+// preferred
+const (
+	// TiebreakName prefers the backup whose path sorts last
+	TiebreakName = "name"
+	// TiebreakLargest prefers the largest backup
+	TiebreakLargest = "largest"
+	// TiebreakNewestModTime prefers the most recently modified backup
+	TiebreakNewestModTime = "newest_mtime"
+)
+
+// Backups of a month the monthly tier can keep, besides a day of the month
+const (
+	// MonthlyAnchorLast keeps the last backup of every month
+	MonthlyAnchorLast = "last"
+	// MonthlyAnchorFirst keeps the first backup of every month
+	MonthlyAnchorFirst = "first"
+)
+
+// Syntaxes the text around the tokens of a file pattern can be written in
+const (
+	// PatternSyntaxTokens reads everything but the tokens as literal text
+	PatternSyntaxTokens = "tokens"
+	// PatternSyntaxGlob adds the shell wildcards *, ? and [...]
+	PatternSyntaxGlob = "glob"
+	// PatternSyntaxRegex reads the text as a regular expression
+	PatternSyntaxRegex = "regex"
+)
+
+// Formats kept backups can be compressed with
+const (
+	// CompressFormatGzip compresses with gzip, adding a .gz extension
+	CompressFormatGzip = "gzip"
+	// CompressFormatZstd compresses with the zstd command, adding a .zst
+	// extension
+	CompressFormatZstd = "zstd"
+)
+
+// Severities of the warnings raised during a prune that fail_on_warnings
+// can fail the run on
+const (
+	// FailOnWarning fails the run on any warning
+	FailOnWarning = "warning"
+	// FailOnError fails the run on warnings of error severity only, such as
+	// directories that could not be read
+	FailOnError = "error"
+)
+
+// How prune logs the decision made for every backup
+const (
+	// LogDecisionsFile logs a line per backup at info level
+	LogDecisionsFile = "file"
+	// LogDecisionsSummary logs the lines per backup at debug level only,
+	// and the progress of the run periodically instead
+	LogDecisionsSummary = "summary"
+)
+
+// LogOutputStderr is the default log_output, any other is a syslog URL
+const LogOutputStderr = "stderr"
+
+// EnvPrefix is the prefix of environment variables that override config
+// values, e.g. ARP_RETENTION_HOURLY for retention.hourly
+const EnvPrefix = "ARP"
+
+// LoadConfig loads the configuration from the specified file. Every value can
+// also be set through an ARP_* environment variable, which takes precedence
+// over the file. When no file is given and none is found in the default
+// locations the configuration is built from the environment alone.
+// References to template_vars and the date are expanded before the values
+// are decoded. configFile may also be an https:// URL, see remoteConfig.
 func LoadConfig(configFile string) (*Config, error) {
-	if configFile != "" {
-		viper.SetConfigFile(configFile)
-	} else {
-		viper.SetConfigName("retention-policy")
-		viper.SetConfigType("yaml")
-		viper.AddConfigPath(".")
-		viper.AddConfigPath("$HOME/.apply-retention-policy")
-		viper.AddConfigPath("/etc/apply-retention-policy")
+	viper.SetDefault("require_minimum", DefaultRequireMinimum)
+	viper.SetDefault("stale_files.patterns", []string{"*.tmp", "*.partial", ".in-progress-*"})
+	viper.SetDefault("companion_files.suffixes",
+		[]string{".md5", ".sha1", ".sha256", ".sha512", ".manifest"})
+
+	if err := bindEnv(); err != nil {
+		return nil, fmt.Errorf("failed to bind environment: %w", err)
 	}
 
+	if isRemote(configFile) {
+		cached, err := remoteConfig(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load remote config: %w", err)
+		}
+
+		configFile = cached
+	}
+
+	useConfigFile(configFile)
+
 	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		var notFound viper.ConfigFileNotFoundError
+		if configFile != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
 	}
 
+	problems := unknownKeys(viper.AllKeys())
+	problems = append(problems, expandTemplates(time.Now())...)
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+		problems = append(problems, fmt.Errorf("failed to unmarshal config: %w", err))
+	} else {
+		problems = append(problems, config.problems()...)
 	}
 
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config: %w", &ValidationError{Problems: problems})
 	}
 
+	config.applyPolicy()
+
 	return &config, nil
 }
 
+// UsePolicy selects the named policy name, replacing the top-level retention
+// settings with its own, and reports whether there is such a policy. The
+// config is left unchanged when there is not.
+func (c *Config) UsePolicy(name string) bool {
+	if _, ok := c.Policies[strings.ToLower(name)]; !ok {
+		return false
+	}
+
+	c.Policy = name
+	c.applyPolicy()
+
+	return true
+}
+
+// SubdirectoryPatterns returns the globs matching the subdirectories of
+// directory the subdirectories rules select, in the order of the rules
+func (c *Config) SubdirectoryPatterns(directory string) []string {
+	patterns := make([]string, len(c.Subdirectories))
+
+	for i, rule := range c.Subdirectories {
+		patterns[i] = filepath.Join(directory, filepath.FromSlash(rule.Path))
+	}
+
+	return patterns
+}
+
+// ForDirectory returns the config directory is pruned with: a copy using
+// the policy of the first subdirectories rule matching it below one of the
+// configured directories, or c itself when no rule does
+func (c *Config) ForDirectory(directory string) *Config {
+	for i, rule := range c.Subdirectories {
+		for _, root := range c.Directories {
+			pattern := c.SubdirectoryPatterns(root)[i]
+			if ok, _ := filepath.Match(pattern, filepath.Clean(directory)); !ok {
+				continue
+			}
+
+			if rule.Policy == "" {
+				return c
+			}
+
+			cfg := *c
+			cfg.UsePolicy(rule.Policy)
+
+			return &cfg
+		}
+	}
+
+	return c
+}
+
+// applyPolicy replaces the top-level retention settings with those of the
+// selected named policy, if any
+func (c *Config) applyPolicy() {
+	policy, ok := c.Policies[strings.ToLower(c.Policy)]
+	if !ok {
+		return
+	}
+
+	c.Retention = policy.Retention
+	c.TagRetention = policy.TagRetention
+	c.KeepCount = policy.KeepCount
+
+	if policy.RequireMinimum != nil {
+		c.RequireMinimum = *policy.RequireMinimum
+	}
+}
+
+// Validate checks if the retention counts are valid
+func (r RetentionPolicy) Validate() error {
+	return errors.Join(r.problems()...)
+}
+
+// problems returns every invalid retention count
+func (r RetentionPolicy) problems() []error {
+	var errs []error
+
+	if r.Hourly < 0 {
+		errs = append(errs, errors.New("hourly retention must be non-negative"))
+	}
+
+	if r.Daily < 0 {
+		errs = append(errs, errors.New("daily retention must be non-negative"))
+	}
+
+	if r.Weekly < 0 {
+		errs = append(errs, errors.New("weekly retention must be non-negative"))
+	}
+
+	if r.Monthly < 0 {
+		errs = append(errs, errors.New("monthly retention must be non-negative"))
+	}
+
+	if r.Yearly < 0 {
+		errs = append(errs, errors.New("yearly retention must be non-negative"))
+	}
+
+	return append(errs, r.thinningProblems()...)
+}
+
+// thinningProblems returns every invalid thinning tier. Each tier must span
+// more years than the yearly tier and the tier before it.
+func (r RetentionPolicy) thinningProblems() []error {
+	var errs []error
+
+	every := 1
+
+	for i, t := range r.Thinning {
+		if t.Every <= every {
+			errs = append(errs, fmt.Errorf(
+				"thinning tier %d must span more than %d years", i+1, every))
+		}
+
+		if t.Count < 0 {
+			errs = append(errs, fmt.Errorf("thinning tier %d count must be non-negative", i+1))
+		}
+
+		every = max(every, t.Every)
+	}
+
+	return errs
+}
+
+// problems returns every problem with the retention settings of a policy
+func (p NamedPolicy) problems() []error {
+	errs := p.Retention.problems()
+
+	for _, tag := range slices.Sorted(maps.Keys(p.TagRetention)) {
+		for _, err := range p.TagRetention[tag].problems() {
+			errs = append(errs, fmt.Errorf("tag %q: %w", tag, err))
+		}
+	}
+
+	if p.RequireMinimum != nil && *p.RequireMinimum < 0 {
+		errs = append(errs, errors.New("require_minimum must be non-negative"))
+	}
+
+	if p.KeepCount < 0 {
+		errs = append(errs, errors.New("keep_count must be non-negative"))
+	}
+
+	if p.KeepCount > 0 && (!p.Retention.IsZero() || len(p.TagRetention) > 0) {
+		errs = append(errs, errors.New("keep_count cannot be combined with retention tiers"))
+	}
+
+	return errs
+}
+
+// RetentionFor returns the retention policy that applies to files with the
+// given tag, falling back to the default policy for untagged files or tags
+// without an override. Tags are matched case-insensitively because viper
+// lowercases map keys.
+func (c *Config) RetentionFor(tag string) RetentionPolicy {
+	if policy, ok := c.TagRetention[strings.ToLower(tag)]; ok && tag != "" {
+		return policy
+	}
+
+	return c.Retention
+}
+
+// MinFreeBytes returns MinFreeSpace in bytes, or zero if it is not set or
+// invalid
+func (c *Config) MinFreeBytes() int64 {
+	if c.MinFreeSpace == "" {
+		return 0
+	}
+
+	size, _ := units.ParseBytes(c.MinFreeSpace)
+
+	return size
+}
+
+// MonthlyAnchorDay returns the day of the month MonthlyAnchor names, or zero
+// if it names none. In shorter months a later day means their last day.
+func (c *Config) MonthlyAnchorDay() int {
+	day, err := strconv.Atoi(c.MonthlyAnchor)
+	if err != nil || day < 1 || day > 31 {
+		return 0
+	}
+
+	return day
+}
+
+// YearStart returns the month the years of the yearly tier begin in
+func (c *Config) YearStart() time.Month {
+	return max(time.Month(c.FiscalYearStart), time.January)
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.Retention.Hourly < 0 {
-		return errors.New("hourly retention must be non-negative")
+	if problems := c.problems(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// problems returns every problem with the configuration
+func (c *Config) problems() []error {
+	errs := NamedPolicy{
+		Retention:      c.Retention,
+		KeepCount:      c.KeepCount,
+		TagRetention:   c.TagRetention,
+		RequireMinimum: &c.RequireMinimum,
+	}.problems()
+
+	for _, name := range slices.Sorted(maps.Keys(c.Policies)) {
+		for _, err := range c.Policies[name].problems() {
+			errs = append(errs, fmt.Errorf("policy %q: %w", name, err))
+		}
+	}
+
+	if c.DayBoundaryOffset <= -24*time.Hour || c.DayBoundaryOffset >= 24*time.Hour {
+		errs = append(errs, errors.New("day_boundary_offset must be within a day"))
+	}
+
+	switch c.PatternSyntax {
+	case "", PatternSyntaxTokens, PatternSyntaxGlob, PatternSyntaxRegex:
+	default:
+		errs = append(errs, fmt.Errorf("unknown pattern_syntax %q", c.PatternSyntax))
+	}
+
+	switch c.FailOnWarnings {
+	case "", FailOnWarning, FailOnError:
+	default:
+		errs = append(errs, fmt.Errorf("unknown fail_on_warnings %q", c.FailOnWarnings))
+	}
+
+	if c.MountpointFstype != "" && !c.RequireMountpoint {
+		errs = append(errs, errors.New("mountpoint_fstype requires require_mountpoint"))
+	}
+
+	if c.SentinelFile != "" && !filepath.IsLocal(c.SentinelFile) {
+		errs = append(errs, fmt.Errorf("sentinel_file %q must be relative to the directory",
+			c.SentinelFile))
+	}
+
+	if c.HardenedListing && runtime.GOOS != "linux" {
+		errs = append(errs, errors.New("hardened_listing is only supported on Linux"))
+	}
+
+	switch c.TimestampTiebreak {
+	case "", TiebreakName, TiebreakLargest, TiebreakNewestModTime:
+	default:
+		errs = append(errs, fmt.Errorf("unknown timestamp_tiebreak %q", c.TimestampTiebreak))
+	}
+
+	if c.FiscalYearStart < 0 || c.FiscalYearStart > 12 {
+		errs = append(errs, errors.New("fiscal_year_start must be a month from 1 to 12"))
+	}
+
+	switch c.MonthlyAnchor {
+	case "", MonthlyAnchorLast, MonthlyAnchorFirst:
+	default:
+		if c.MonthlyAnchorDay() == 0 {
+			errs = append(errs, fmt.Errorf("unknown monthly_anchor %q", c.MonthlyAnchor))
+		}
+	}
+
+	if _, ok := c.Policies[strings.ToLower(c.Policy)]; c.Policy != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown policy %q", c.Policy))
+	}
+
+	errs = append(errs, c.subdirectoryProblems()...)
+
+	switch c.Backend {
+	case "", BackendFiles:
+		if c.FilePattern == "" {
+			errs = append(errs, errors.New("file pattern must be specified"))
+		}
+	case BackendBtrfs, BackendXtrabackup, BackendRsnapshot:
+		// Backups are dated by their metadata, no pattern is needed, and
+		// are directories or subvolumes that are not moved around
+		errs = append(errs, c.unsupported(map[string]bool{
+			"archive":          len(c.Archive.Tiers) > 0,
+			"compress_after":   c.CompressAfter != 0,
+			"owner":            c.Owner != "",
+			"group":            c.Group != "",
+			"timestamp_xattr":  c.TimestampXattr != "",
+			"backup_info":      c.BackupInfo.Enabled,
+			"hardened_listing": c.HardenedListing,
+		})...)
+	case BackendRestic, BackendBorg:
+		// Only settings expressible as keep flags of restic forget and borg
+		// prune can be honoured, including the selected named policy, which
+		// is only applied after validation
+		errs = append(errs, c.unsupported(map[string]bool{
+			"tag_retention": len(c.TagRetention) > 0 ||
+				len(c.Policies[strings.ToLower(c.Policy)].TagRetention) > 0,
+			"retention.thinning": len(c.Retention.Thinning) > 0 ||
+				len(c.Policies[strings.ToLower(c.Policy)].Retention.Thinning) > 0,
+			"dedupe":              c.Dedupe,
+			"min_free_space":      c.MinFreeSpace != "",
+			"pins":                len(c.Pins) > 0,
+			"day_boundary_offset": c.DayBoundaryOffset != 0,
+			"subdirectories":      len(c.Subdirectories) > 0,
+			"require_mountpoint":  c.RequireMountpoint,
+			"sentinel_file":       c.SentinelFile != "",
+			"journal":             c.Journal != "",
+			"checksum_deletions":  c.ChecksumDeletions,
+			"monthly_anchor":      c.MonthlyAnchor != "" && c.MonthlyAnchor != MonthlyAnchorLast,
+			"fiscal_year_start":   c.FiscalYearStart > 1,
+			"stale_files":         c.StaleFiles.MinAge != 0,
+			"companion_files":     c.CompanionFiles.DeleteOrphans,
+			"remove_empty_dirs":   c.RemoveEmptyDirs,
+			"archive":             len(c.Archive.Tiers) > 0,
+			"compress_after":      c.CompressAfter != 0,
+			"operation_timeout":   c.OperationTimeout != 0,
+			"list_timeout":        c.ListTimeout != 0,
+			"owner":               c.Owner != "",
+			"group":               c.Group != "",
+			"timestamp_xattr":     c.TimestampXattr != "",
+			"backup_info":         c.BackupInfo.Enabled,
+			"hardened_listing":    c.HardenedListing,
+		})...)
+	case BackendVolumeSnapshot:
+		// Snapshots are API objects without file contents or a filesystem
+		errs = append(errs, c.unsupported(map[string]bool{
+			"dedupe":             c.Dedupe,
+			"min_free_space":     c.MinFreeSpace != "",
+			"stale_files":        c.StaleFiles.MinAge != 0,
+			"companion_files":    c.CompanionFiles.DeleteOrphans,
+			"remove_empty_dirs":  c.RemoveEmptyDirs,
+			"archive":            len(c.Archive.Tiers) > 0,
+			"compress_after":     c.CompressAfter != 0,
+			"owner":              c.Owner != "",
+			"group":              c.Group != "",
+			"timestamp_xattr":    c.TimestampXattr != "",
+			"subdirectories":     len(c.Subdirectories) > 0,
+			"backup_info":        c.BackupInfo.Enabled,
+			"require_mountpoint": c.RequireMountpoint,
+			"sentinel_file":      c.SentinelFile != "",
+			"journal":            c.Journal != "",
+			"checksum_deletions": c.ChecksumDeletions,
+			"hardened_listing":   c.HardenedListing,
+		})...)
+
+		for _, namespace := range c.Directories {
+			if strings.ContainsAny(namespace, "*?[/") {
+				errs = append(errs, fmt.Errorf("invalid namespace %q", namespace))
+			}
+		}
+	case BackendManifest:
+		errs = append(errs, c.manifestProblems()...)
+	default:
+		errs = append(errs, fmt.Errorf("unknown backend %q", c.Backend))
+	}
+
+	errs = append(errs, c.logProblems()...)
+
+	if c.Retry.Attempts < 0 || c.Retry.Backoff < 0 || c.Retry.MaxBackoff < 0 {
+		errs = append(errs, errors.New("retry settings must be non-negative"))
+	}
+
+	if c.OperationTimeout < 0 || c.ListTimeout < 0 {
+		errs = append(errs, errors.New("operation_timeout and list_timeout must be non-negative"))
+	}
+
+	if c.CatalogRetention < 0 {
+		errs = append(errs, errors.New("catalog_retention must be non-negative"))
+	}
+
+	errs = append(errs, c.cleanupProblems()...)
+	errs = append(errs, c.archiveProblems()...)
+
+	if c.MinFreeSpace != "" {
+		if _, err := units.ParseBytes(c.MinFreeSpace); err != nil {
+			errs = append(errs, fmt.Errorf("invalid min_free_space: %w", err))
+		}
+	}
+
+	if c.Freshness.MaxAge < 0 {
+		errs = append(errs, errors.New("freshness max_age must be non-negative"))
+	}
+
+	for _, pin := range c.Pins {
+		if _, err := path.Match(pin, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid pin %q: %w", pin, err))
+		}
+	}
+
+	if len(c.Directories) == 0 || slices.Contains(c.Directories, "") {
+		errs = append(errs, errors.New("directory must be specified"))
+	}
+
+	for _, directory := range c.Directories {
+		if _, err := filepath.Match(directory, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid directory glob %q: %w", directory, err))
+		}
+	}
+
+	if err := validateWebhookURL(c.Notifications.Slack.WebhookURL); err != nil {
+		errs = append(errs, fmt.Errorf("slack webhook_url: %w", err))
+	}
+
+	if err := validateWebhookURL(c.Notifications.Discord.WebhookURL); err != nil {
+		errs = append(errs, fmt.Errorf("discord webhook_url: %w", err))
+	}
+
+	return errs
+}
+
+// subdirectoryProblems returns every problem with the subdirectories rules
+func (c *Config) subdirectoryProblems() []error {
+	var errs []error
+
+	for _, rule := range c.Subdirectories {
+		_, err := path.Match(rule.Path, "")
+
+		switch {
+		case rule.Path == "" || path.IsAbs(rule.Path) || filepath.IsAbs(rule.Path) ||
+			slices.Contains(strings.Split(path.Clean(rule.Path), "/"), ".."):
+			errs = append(errs, fmt.Errorf("invalid subdirectories path %q", rule.Path))
+		case err != nil:
+			errs = append(errs, fmt.Errorf("invalid subdirectories path %q: %w", rule.Path, err))
+		}
+
+		if _, ok := c.Policies[strings.ToLower(rule.Policy)]; rule.Policy != "" && !ok {
+			errs = append(errs, fmt.Errorf("subdirectories path %q: unknown policy %q",
+				rule.Path, rule.Policy))
+		}
+	}
+
+	return errs
+}
+
+// logOutputProblems returns every problem with log_output
+func (c *Config) logOutputProblems() []error {
+	if c.LogOutput == "" || c.LogOutput == LogOutputStderr {
+		return nil
+	}
+
+	var errs []error
+
+	if _, _, err := logging.ParseSyslogURL(c.LogOutput); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log_output: %w", err))
+	}
+
+	if c.LogJournal || c.LogFile != "" {
+		errs = append(errs,
+			errors.New("log_output cannot be combined with log_journal or log_file"))
+	}
+
+	return errs
+}
+
+// logProblems returns every problem with the logging settings
+func (c *Config) logProblems() []error {
+	var errs []error
+
+	switch c.LogFormat {
+	case "", "json", "console":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log_format %q", c.LogFormat))
+	}
+
+	switch c.LogDecisions {
+	case "", LogDecisionsFile, LogDecisionsSummary:
+	default:
+		errs = append(errs, fmt.Errorf("unknown log_decisions %q", c.LogDecisions))
+	}
+
+	if c.LogJournal && (c.LogFile != "" || c.LogFormat != "") {
+		errs = append(errs,
+			errors.New("log_journal cannot be combined with log_file or log_format"))
+	}
+
+	errs = append(errs, c.logOutputProblems()...)
+
+	if c.LogRotation.MaxSize != "" {
+		if _, err := units.ParseBytes(c.LogRotation.MaxSize); err != nil {
+			errs = append(errs, fmt.Errorf("invalid log_rotation.max_size: %w", err))
+		}
+	}
+
+	if c.LogRotation.MaxAge < 0 || c.LogRotation.MaxBackups < 0 {
+		errs = append(errs, errors.New("log_rotation limits must be non-negative"))
+	}
+
+	return errs
+}
+
+// cleanupProblems returns every problem with the stale and companion file
+// cleanup settings
+func (c *Config) cleanupProblems() []error {
+	var errs []error
+
+	if c.StaleFiles.MinAge < 0 {
+		errs = append(errs, errors.New("stale_files.min_age must be non-negative"))
+	}
+
+	for _, pattern := range c.StaleFiles.Patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid stale_files pattern %q: %w", pattern, err))
+		}
+	}
+
+	for _, suffix := range c.CompanionFiles.Suffixes {
+		if suffix == "" || strings.ContainsAny(suffix, `/\`) {
+			errs = append(errs, fmt.Errorf("invalid companion_files suffix %q", suffix))
+		}
+	}
+
+	return append(errs, c.backupInfoProblems()...)
+}
+
+// backupInfoProblems returns every problem with the backup_info settings
+func (c *Config) backupInfoProblems() []error {
+	var errs []error
+
+	if len(c.BackupInfo.Labels) > 0 && !c.BackupInfo.Enabled {
+		errs = append(errs, errors.New("backup_info.labels requires backup_info.enabled"))
+	}
+
+	if slices.Contains(c.BackupInfo.Labels, "") {
+		errs = append(errs, errors.New("backup_info.labels must not be empty"))
+	}
+
+	return errs
+}
+
+// archiveProblems returns every problem with the archive and compression
+// settings
+func (c *Config) archiveProblems() []error {
+	var errs []error
+
+	if c.CompressAfter < 0 {
+		errs = append(errs, errors.New("compress_after must be non-negative"))
+	}
+
+	switch c.CompressFormat {
+	case "", CompressFormatGzip, CompressFormatZstd:
+	default:
+		errs = append(errs, fmt.Errorf("unknown compress_format %q", c.CompressFormat))
+	}
+
+	for _, tier := range slices.Sorted(maps.Keys(c.Archive.Tiers)) {
+		switch tier {
+		case "hourly", "daily", "weekly", "monthly", "yearly", "thinning", "keep_count",
+			"expired":
+		default:
+			errs = append(errs, fmt.Errorf("unknown archive tier %q", tier))
+		}
+
+		if c.Archive.Tiers[tier] == "" {
+			errs = append(errs, fmt.Errorf("archive tier %q has no destination", tier))
+		}
+	}
+
+	if c.Archive.TransformSuffix != "" && c.Archive.Transform == "" {
+		errs = append(errs, errors.New("archive transform_suffix requires a transform"))
+	}
+
+	if strings.ContainsAny(c.Archive.TransformSuffix, `/\`) {
+		errs = append(errs, fmt.Errorf("invalid archive transform_suffix %q",
+			c.Archive.TransformSuffix))
+	}
+
+	return errs
+}
+
+// manifestProblems returns every problem with the settings of the manifest
+// backend. Backups listed in a manifest are not matched by a pattern, and
+// are not moved around since their entries would go stale.
+func (c *Config) manifestProblems() []error {
+	errs := c.unsupported(map[string]bool{
+		"archive":          len(c.Archive.Tiers) > 0,
+		"compress_after":   c.CompressAfter != 0,
+		"owner":            c.Owner != "",
+		"group":            c.Group != "",
+		"timestamp_xattr":  c.TimestampXattr != "",
+		"backup_info":      c.BackupInfo.Enabled,
+		"hardened_listing": c.HardenedListing,
+	})
+
+	if c.Manifest.Path == "" {
+		errs = append(errs, errors.New("manifest path must be specified"))
 	}
 
-	if c.Retention.Daily < 0 {
-		return errors.New("daily retention must be non-negative")
+	switch c.Manifest.Format {
+	case "", ManifestFormatJSON, ManifestFormatCSV:
+	default:
+		errs = append(errs, fmt.Errorf("unknown manifest format %q", c.Manifest.Format))
 	}
 
-	if c.Retention.Weekly < 0 {
-		return errors.New("weekly retention must be non-negative")
+	return errs
+}
+
+// unsupported reports every setting in use that the configured backend
+// cannot honour
+func (c *Config) unsupported(inUse map[string]bool) []error {
+	var errs []error
+
+	for _, key := range slices.Sorted(maps.Keys(inUse)) {
+		if inUse[key] {
+			errs = append(errs, fmt.Errorf("%s is not supported by the %s backend", key, c.Backend))
+		}
 	}
 
-	if c.Retention.Monthly < 0 {
-		return errors.New("monthly retention must be non-negative")
+	return errs
+}
+
+// validateWebhookURL checks that a configured webhook URL is an absolute
+// http(s) URL. An empty URL means the sender is disabled and is accepted.
+func validateWebhookURL(raw string) error {
+	if raw == "" {
+		return nil
 	}
 
-	if c.Retention.Yearly < 0 {
-		return errors.New("yearly retention must be non-negative")
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
 	}
 
-	if c.FilePattern == "" {
-		return errors.New("file pattern must be specified")
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
 	}
 
-	if c.Directory == "" {
-		return errors.New("directory must be specified")
+	if u.Host == "" {
+		return errors.New("missing host")
 	}
 
 	return nil
@@ -25,12 +25,22 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -71,9 +81,10 @@ func TestLoadConfig(t *testing.T) {
 			"backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz",
 			cfg.FilePattern,
 		)
-		require.Equal(t, "/backups", cfg.Directory)
+		require.Equal(t, []string{"/backups"}, cfg.Directories)
 		require.True(t, cfg.DryRun)
 		require.Equal(t, "debug", cfg.LogLevel)
+		require.Equal(t, DefaultRequireMinimum, cfg.RequireMinimum)
 	})
 
 	t.Run("load from default locations", func(t *testing.T) {
@@ -101,6 +112,278 @@ func TestLoadConfig(t *testing.T) {
 		)
 	})
 
+	t.Run("load from the user config directory", func(t *testing.T) {
+		viper.Reset()
+		t.Chdir(t.TempDir())
+
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("USERPROFILE", home)
+		t.Setenv("APPDATA", filepath.Join(home, "AppData"))
+		t.Setenv("XDG_CONFIG_HOME", "")
+
+		homeConfig := filepath.Join(home, ".apply-retention-policy", "retention-policy.yaml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(homeConfig), 0o700))
+		require.NoError(t, os.WriteFile(homeConfig, []byte(configContent), 0o600))
+
+		_, err = LoadConfig("")
+		require.NoError(t, err)
+		require.Equal(t, homeConfig, viper.ConfigFileUsed())
+
+		userDir := filepath.Join(home, ".config")
+		if runtime.GOOS == "windows" {
+			userDir = filepath.Join(home, "AppData")
+		}
+
+		require.Equal(t, userDir, userConfigDir())
+
+		userConfig := filepath.Join(userDir, "apply-retention-policy", "config.yaml")
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(userConfig), 0o700))
+		require.NoError(t, os.WriteFile(userConfig, []byte(configContent), 0o600))
+
+		viper.Reset()
+
+		_, err = LoadConfig("")
+		require.NoError(t, err)
+		require.Equal(t, userConfig, viper.ConfigFileUsed())
+
+		xdg := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", xdg)
+
+		xdgConfig := filepath.Join(xdg, "apply-retention-policy", "config.yml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(xdgConfig), 0o700))
+		require.NoError(t, os.WriteFile(xdgConfig, []byte(configContent), 0o600))
+
+		viper.Reset()
+
+		cfg, err = LoadConfig("")
+		require.NoError(t, err)
+		require.Equal(t, xdgConfig, viper.ConfigFileUsed())
+		require.Equal(t, 3, cfg.Retention.Daily)
+	})
+
+	t.Run("environment overrides file", func(t *testing.T) {
+		viper.Reset()
+		t.Setenv("ARP_RETENTION_HOURLY", "7")
+		t.Setenv("ARP_DIRECTORY", "/srv/backups")
+
+		cfg, err = LoadConfig(configFile)
+		require.NoError(t, err)
+		require.Equal(t, 7, cfg.Retention.Hourly)
+		require.Equal(t, 3, cfg.Retention.Daily)
+		require.Equal(t, []string{"/srv/backups"}, cfg.Directories)
+	})
+
+	t.Run("environment only", func(t *testing.T) {
+		viper.Reset()
+		t.Chdir(t.TempDir())
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("ARP_RETENTION_DAILY", "5")
+		t.Setenv("ARP_FILE_PATTERN", "backup-{year}-{month}-{day}.tar.gz")
+		t.Setenv("ARP_DIRECTORY", "/backups")
+		t.Setenv("ARP_DRY_RUN", "true")
+		t.Setenv("ARP_PINS", "a.tar.gz,b.tar.gz")
+		t.Setenv("ARP_NOTIFICATIONS_SLACK_CHANNEL", "#ops")
+
+		cfg, err = LoadConfig("")
+		require.NoError(t, err)
+		require.Equal(t, 5, cfg.Retention.Daily)
+		require.Equal(t, "backup-{year}-{month}-{day}.tar.gz", cfg.FilePattern)
+		require.Equal(t, []string{"/backups"}, cfg.Directories)
+		require.True(t, cfg.DryRun)
+		require.Equal(t, []string{"a.tar.gz", "b.tar.gz"}, cfg.Pins)
+		require.Equal(t, "#ops", cfg.Notifications.Slack.Channel)
+		require.Equal(t, DefaultRequireMinimum, cfg.RequireMinimum)
+	})
+
+	t.Run("directory list", func(t *testing.T) {
+		viper.Reset()
+
+		listConfig := filepath.Join(tmpDir, "list.yaml")
+		err = os.WriteFile(listConfig, []byte(`file_pattern: "backup.tar.gz"
+directory:
+  - /backups/db
+  - /backups/files
+`), 0o600)
+		require.NoError(t, err)
+
+		cfg, err = LoadConfig(listConfig)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/backups/db", "/backups/files"}, cfg.Directories)
+	})
+
+	t.Run("templates", func(t *testing.T) {
+		viper.Reset()
+		t.Setenv("SITE", "ams1")
+		t.Setenv("SECRET", "hunter2")
+		t.Setenv("ARP_PINS", "${SITE}-a.tar.gz,b.tar.gz")
+
+		templateConfig := filepath.Join(tmpDir, "template.yaml")
+		err = os.WriteFile(templateConfig, []byte(`file_pattern: "backup-{year}.tar.gz"
+template_vars: [SITE]
+directory:
+  - /backups/${SITE}
+  - /archive/$${SITE}
+log_file: /var/log/prune-${date:2006}.log
+hooks:
+  pre_delete: echo "${ARP_PATH}"
+`), 0o600)
+		require.NoError(t, err)
+
+		cfg, err = LoadConfig(templateConfig)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/backups/ams1", "/archive/${SITE}"}, cfg.Directories)
+		require.Equal(t, []string{"ams1-a.tar.gz", "b.tar.gz"}, cfg.Pins)
+		require.Equal(t, "/var/log/prune-"+time.Now().Format("2006")+".log", cfg.LogFile)
+		require.Equal(t, `echo "${ARP_PATH}"`, cfg.Hooks.PreDelete)
+
+		viper.Reset()
+		t.Setenv("ARP_CATALOG", "/var/lib/${SECRET}/${UNSET}")
+		t.Setenv("ARP_TEMPLATE_VARS", "SITE,UNSET")
+
+		_, err = LoadConfig(templateConfig)
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0],
+			`catalog: variable "SECRET" is not listed in template_vars`)
+		require.EqualError(t, validationErr.Problems[1], `catalog: variable "UNSET" is not set`)
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		viper.Reset()
+
+		unknownConfig := filepath.Join(tmpDir, "unknown.yaml")
+		err = os.WriteFile(
+			unknownConfig,
+			[]byte(configContent+"file-pattern: \"backup.tar.gz\"\n"),
+			0o600,
+		)
+		require.NoError(t, err)
+
+		_, err = LoadConfig(unknownConfig)
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Len(t, validationErr.Problems, 1)
+		require.EqualError(
+			t,
+			validationErr.Problems[0],
+			`unknown key "file-pattern" (did you mean "file_pattern"?)`,
+		)
+	})
+
+	t.Run("named policy", func(t *testing.T) {
+		viper.Reset()
+
+		policyConfig := filepath.Join(tmpDir, "policies.yaml")
+		err = os.WriteFile(policyConfig, []byte(configContent+`policy: Dev
+day_boundary_offset: -6h
+policies:
+  dev:
+    keep_count: 3
+    require_minimum: 0
+  prod:
+    retention:
+      daily: 30
+      thinning:
+        - every: 5
+          count: 4
+    tag_retention:
+      nightly:
+        weekly: 8
+`), 0o600)
+		require.NoError(t, err)
+
+		cfg, err = LoadConfig(policyConfig)
+		require.NoError(t, err)
+		require.Equal(t, 3, cfg.KeepCount)
+		require.Equal(t, -6*time.Hour, cfg.DayBoundaryOffset)
+		require.Equal(t, RetentionPolicy{}, cfg.Retention)
+		require.Equal(t, 0, cfg.RequireMinimum)
+
+		viper.Reset()
+		t.Setenv("ARP_POLICY", "prod")
+
+		cfg, err = LoadConfig(policyConfig)
+		require.NoError(t, err)
+		require.Equal(t, RetentionPolicy{
+			Daily:    30,
+			Thinning: []ThinningTier{{Every: 5, Count: 4}},
+		}, cfg.Retention)
+		require.Equal(t, RetentionPolicy{Weekly: 8}, cfg.RetentionFor("nightly"))
+		require.Equal(t, DefaultRequireMinimum, cfg.RequireMinimum)
+
+		require.False(t, cfg.UsePolicy("staging"))
+		require.Equal(t, "prod", cfg.Policy)
+
+		require.True(t, cfg.UsePolicy("DEV"))
+		require.Equal(t, 3, cfg.KeepCount)
+		require.Equal(t, RetentionPolicy{}, cfg.Retention)
+		require.Equal(t, 0, cfg.RequireMinimum)
+	})
+
+	t.Run("subdirectory policies", func(t *testing.T) {
+		viper.Reset()
+
+		subdirConfig := filepath.Join(tmpDir, "subdirectories.yaml")
+		err = os.WriteFile(subdirConfig, []byte(configContent+`policies:
+  strict:
+    retention:
+      daily: 30
+subdirectories:
+  - path: databases/*
+    policy: Strict
+  - path: logs/*
+`), 0o600)
+		require.NoError(t, err)
+
+		cfg, err = LoadConfig(subdirConfig)
+		require.NoError(t, err)
+
+		root := cfg.Directories[0]
+		require.Equal(t, []string{
+			filepath.Join(root, "databases", "*"),
+			filepath.Join(root, "logs", "*"),
+		}, cfg.SubdirectoryPatterns(root))
+
+		strict := cfg.ForDirectory(filepath.Join(root, "databases", "pg"))
+		require.Equal(t, RetentionPolicy{Daily: 30}, strict.Retention)
+		require.NotEqual(t, RetentionPolicy{Daily: 30}, cfg.Retention)
+
+		require.Same(t, cfg, cfg.ForDirectory(filepath.Join(root, "logs", "nginx")))
+		require.Same(t, cfg, cfg.ForDirectory(filepath.Join(root, "media", "photos")))
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		viper.Reset()
+
+		policyConfig := filepath.Join(tmpDir, "unknown-policy.yaml")
+		err = os.WriteFile(policyConfig, []byte(configContent+`policy: staging
+policies:
+  dev:
+    retention:
+      dialy: 1
+`), 0o600)
+		require.NoError(t, err)
+
+		_, err = LoadConfig(policyConfig)
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(
+			t,
+			validationErr.Problems[0],
+			`unknown key "policies.dev.retention.dialy" `+
+				`(did you mean "policies.dev.retention.daily"?)`,
+		)
+		require.EqualError(t, validationErr.Problems[1], `unknown policy "staging"`)
+	})
+
 	t.Run("invalid config file", func(t *testing.T) {
 		_, err = LoadConfig("non-existent.yaml")
 		require.Error(t, err)
@@ -120,6 +403,99 @@ func TestLoadConfig(t *testing.T) {
 	})
 }
 
+func TestLoadConfigRemote(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	body := []byte(`file_pattern: "backup-{year}.tar.gz"
+directory: /backups
+retention:
+  daily: 4
+`)
+	sum := sha256.Sum256(body)
+	checksum := hex.EncodeToString(sum[:])
+
+	var requests, notModified int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(body)
+	}))
+
+	t.Run("fetch and revalidate", func(t *testing.T) {
+		for range 2 {
+			viper.Reset()
+
+			cfg, err := LoadConfig(server.URL + "/policy.yaml#sha256=" + checksum)
+			require.NoError(t, err)
+			require.Equal(t, 4, cfg.Retention.Daily)
+		}
+
+		require.Equal(t, 2, requests)
+		require.Equal(t, 1, notModified)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		viper.Reset()
+
+		_, err := LoadConfig(server.URL + "/other.yaml#sha256=" + strings.Repeat("0", 64))
+		require.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		server.Close()
+		viper.Reset()
+
+		cfg, err := LoadConfig(server.URL + "/policy.yaml#sha256=" + checksum)
+		require.NoError(t, err)
+		require.Equal(t, 4, cfg.Retention.Daily)
+
+		viper.Reset()
+
+		_, err = LoadConfig(server.URL + "/uncached.yaml#sha256=" + checksum)
+		require.ErrorContains(t, err, "failed to fetch config")
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		viper.Reset()
+
+		_, err := LoadConfig("s3://bucket/policy.yaml")
+		require.ErrorIs(t, err, ErrUnsupportedScheme)
+	})
+
+	t.Run("http without checksum", func(t *testing.T) {
+		viper.Reset()
+
+		_, err := LoadConfig("http://config.example.com/policy.yaml")
+		require.ErrorIs(t, err, ErrUnsupportedScheme)
+	})
+}
+
+func TestConfigSecretsNotEncoded(t *testing.T) {
+	cfg := Config{
+		Notifications: NotificationsConfig{
+			Slack:   SlackConfig{WebhookURL: "https://hooks.slack.com/services/secret"},
+			Discord: DiscordConfig{WebhookURL: "https://discord.com/api/webhooks/secret"},
+		},
+		API: APIConfig{Token: "secret"},
+	}
+
+	// The config is logged as JSON at the start of every prune
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+}
+
 func TestConfig_Validate(t *testing.T) {
 	t.Run("valid config", func(t *testing.T) {
 		cfg := &Config{
@@ -131,7 +507,7 @@ func TestConfig_Validate(t *testing.T) {
 				Yearly:  1,
 			},
 			FilePattern: "backup-{year}-{month}-{day}.tar.gz",
-			Directory:   "/backups",
+			Directories: []string{"/backups"},
 		}
 
 		err := cfg.Validate()
@@ -149,7 +525,7 @@ func TestConfig_Validate(t *testing.T) {
 				cfg: &Config{
 					Retention:   RetentionPolicy{Hourly: -1},
 					FilePattern: "backup.tar.gz",
-					Directory:   "/backups",
+					Directories: []string{"/backups"},
 				},
 				field: "hourly",
 			},
@@ -158,7 +534,7 @@ func TestConfig_Validate(t *testing.T) {
 				cfg: &Config{
 					Retention:   RetentionPolicy{Daily: -1},
 					FilePattern: "backup.tar.gz",
-					Directory:   "/backups",
+					Directories: []string{"/backups"},
 				},
 				field: "daily",
 			},
@@ -167,7 +543,7 @@ func TestConfig_Validate(t *testing.T) {
 				cfg: &Config{
 					Retention:   RetentionPolicy{Weekly: -1},
 					FilePattern: "backup.tar.gz",
-					Directory:   "/backups",
+					Directories: []string{"/backups"},
 				},
 				field: "weekly",
 			},
@@ -176,19 +552,207 @@ func TestConfig_Validate(t *testing.T) {
 				cfg: &Config{
 					Retention:   RetentionPolicy{Monthly: -1},
 					FilePattern: "backup.tar.gz",
-					Directory:   "/backups",
+					Directories: []string{"/backups"},
 				},
 				field: "monthly",
 			},
+			{
+				name: "negative require_minimum",
+				cfg: &Config{
+					RequireMinimum: -1,
+					FilePattern:    "backup.tar.gz",
+					Directories:    []string{"/backups"},
+				},
+				field: "require_minimum",
+			},
 			{
 				name: "negative yearly",
 				cfg: &Config{
 					Retention:   RetentionPolicy{Yearly: -1},
 					FilePattern: "backup.tar.gz",
-					Directory:   "/backups",
+					Directories: []string{"/backups"},
 				},
 				field: "yearly",
 			},
+			{
+				name: "negative keep_count",
+				cfg: &Config{
+					KeepCount:   -1,
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "keep_count",
+			},
+			{
+				name: "keep_count with tiers",
+				cfg: &Config{
+					KeepCount:   3,
+					Retention:   RetentionPolicy{Daily: 7},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "keep_count cannot be combined",
+			},
+			{
+				name: "thinning tier of a year",
+				cfg: &Config{
+					Retention:   RetentionPolicy{Thinning: []ThinningTier{{Every: 1, Count: 2}}},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "thinning tier 1 must span more than 1 years",
+			},
+			{
+				name: "thinning tiers out of order",
+				cfg: &Config{
+					Retention: RetentionPolicy{
+						Thinning: []ThinningTier{{Every: 5, Count: 2}, {Every: 2, Count: 2}},
+					},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "thinning tier 2 must span more than 5 years",
+			},
+			{
+				name: "negative thinning count",
+				cfg: &Config{
+					Retention:   RetentionPolicy{Thinning: []ThinningTier{{Every: 2, Count: -1}}},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "thinning tier 1 count",
+			},
+			{
+				name: "keep_count with thinning",
+				cfg: &Config{
+					KeepCount:   3,
+					Retention:   RetentionPolicy{Thinning: []ThinningTier{{Every: 2, Count: 1}}},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "keep_count cannot be combined",
+			},
+			{
+				name: "day boundary offset of a day",
+				cfg: &Config{
+					Retention:         RetentionPolicy{Daily: 1},
+					DayBoundaryOffset: -24 * time.Hour,
+					FilePattern:       "backup.tar.gz",
+					Directories:       []string{"/backups"},
+				},
+				field: "day_boundary_offset",
+			},
+			{
+				name: "negative count in named policy",
+				cfg: &Config{
+					Policies: map[string]NamedPolicy{
+						"dev": {Retention: RetentionPolicy{Daily: -1}},
+					},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: `policy "dev": daily`,
+			},
+			{
+				name: "subdirectories path outside the directory",
+				cfg: &Config{
+					Subdirectories: []SubdirectoryPolicy{{Path: "../other"}},
+					FilePattern:    "backup.tar.gz",
+					Directories:    []string{"/backups"},
+				},
+				field: `invalid subdirectories path "../other"`,
+			},
+			{
+				name: "subdirectories with unknown policy",
+				cfg: &Config{
+					Subdirectories: []SubdirectoryPolicy{{Path: "db/*", Policy: "strict"}},
+					FilePattern:    "backup.tar.gz",
+					Directories:    []string{"/backups"},
+				},
+				field: `subdirectories path "db/*": unknown policy "strict"`,
+			},
+			{
+				name: "backup_info labels without backup_info",
+				cfg: &Config{
+					BackupInfo:  BackupInfoConfig{Labels: []string{"env"}},
+					FilePattern: "backup.tar.gz",
+					Directories: []string{"/backups"},
+				},
+				field: "backup_info.labels requires backup_info.enabled",
+			},
+			{
+				name: "backup_info with btrfs backend",
+				cfg: &Config{
+					Backend:     BackendBtrfs,
+					BackupInfo:  BackupInfoConfig{Enabled: true},
+					Directories: []string{"/.snapshots"},
+				},
+				field: "backup_info",
+			},
+			{
+				name: "mountpoint_fstype without require_mountpoint",
+				cfg: &Config{
+					MountpointFstype: "nfs4",
+					FilePattern:      "backup.tar.gz",
+					Directories:      []string{"/backups"},
+				},
+				field: "mountpoint_fstype requires require_mountpoint",
+			},
+			{
+				name: "journal with borg backend",
+				cfg: &Config{
+					Backend:     BackendBorg,
+					Journal:     "/var/lib/apply-retention-policy/journal.jsonl",
+					Directories: []string{"/srv/borg"},
+				},
+				field: "journal",
+			},
+			{
+				name: "checksum_deletions with volumesnapshot backend",
+				cfg: &Config{
+					Backend:           BackendVolumeSnapshot,
+					ChecksumDeletions: true,
+					Directories:       []string{"backups"},
+				},
+				field: "checksum_deletions",
+			},
+			{
+				name: "require_mountpoint with restic backend",
+				cfg: &Config{
+					Backend:           BackendRestic,
+					RequireMountpoint: true,
+					Directories:       []string{"/srv/restic"},
+				},
+				field: "require_mountpoint",
+			},
+			{
+				name: "sentinel_file outside the directory",
+				cfg: &Config{
+					SentinelFile: "../.backup-root",
+					FilePattern:  "backup.tar.gz",
+					Directories:  []string{"/backups"},
+				},
+				field: `sentinel_file "../.backup-root" must be relative to the directory`,
+			},
+			{
+				name: "hardened_listing with rsnapshot backend",
+				cfg: &Config{
+					Backend:         BackendRsnapshot,
+					HardenedListing: true,
+					Directories:     []string{"/srv/rsnapshot"},
+				},
+				field: "hardened_listing",
+			},
+			{
+				name: "keep_count with tag retention",
+				cfg: &Config{
+					KeepCount:    3,
+					TagRetention: TagPolicies{"nightly": {Daily: 7}},
+					FilePattern:  "backup.tar.gz",
+					Directories:  []string{"/backups"},
+				},
+				field: "keep_count cannot be combined",
+			},
 		}
 
 		for _, tc := range testCases {
@@ -209,8 +773,8 @@ func TestConfig_Validate(t *testing.T) {
 			{
 				name: "missing file pattern",
 				cfg: &Config{
-					Retention: RetentionPolicy{Hourly: 1},
-					Directory: "/backups",
+					Retention:   RetentionPolicy{Hourly: 1},
+					Directories: []string{"/backups"},
 				},
 				msg: "file pattern must be specified",
 			},
@@ -232,6 +796,407 @@ func TestConfig_Validate(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("all problems reported", func(t *testing.T) {
+		cfg := &Config{
+			Retention: RetentionPolicy{Hourly: -1, Yearly: -1},
+		}
+		err := cfg.Validate()
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Len(t, validationErr.Problems, 4)
+	})
+
+	t.Run("backends", func(t *testing.T) {
+		cfg := &Config{
+			Backend:     BackendBtrfs,
+			Directories: []string{"/.snapshots"},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.Backend = "tape"
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `unknown backend "tape"`)
+	})
+
+	t.Run("repository backends", func(t *testing.T) {
+		cfg := &Config{
+			Backend:     BackendRestic,
+			Directories: []string{"/srv/restic"},
+			Retention:   RetentionPolicy{Daily: 7},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.Backend = BackendBorg
+		cfg.TagRetention = TagPolicies{"db": {Daily: 30}}
+		cfg.Dedupe = true
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0],
+			"dedupe is not supported by the borg backend")
+		require.EqualError(t, validationErr.Problems[1],
+			"tag_retention is not supported by the borg backend")
+	})
+
+	t.Run("logging", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/backups"},
+			LogFormat:   "console",
+			LogFile:     "/var/log/arp.log",
+			LogRotation: LogRotationConfig{MaxSize: "100MiB", MaxAge: 7 * 24 * time.Hour},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.LogFile = ""
+		cfg.LogOutput = "syslog+tcp://logs.example.com:601"
+		require.NoError(t, cfg.Validate())
+
+		cfg.LogFile = "/var/log/arp.log"
+		cfg.LogFormat = "xml"
+		cfg.LogJournal = true
+		cfg.LogRotation = LogRotationConfig{MaxSize: "lots", MaxBackups: -1}
+		cfg.LogOutput = "http://logs.example.com"
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 6)
+		require.EqualError(t, validationErr.Problems[0], `unknown log_format "xml"`)
+		require.ErrorIs(t, validationErr.Problems[2], logging.ErrSyslogURL)
+	})
+
+	t.Run("stale files", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/backups"},
+			StaleFiles:  StaleFilesConfig{Patterns: []string{"*.tmp"}, MinAge: time.Hour},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.StaleFiles.Patterns = []string{"[*.tmp"}
+		cfg.StaleFiles.MinAge = -time.Hour
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0],
+			"stale_files.min_age must be non-negative")
+	})
+
+	t.Run("companion files", func(t *testing.T) {
+		cfg := &Config{
+			Backend:        BackendRestic,
+			Directories:    []string{"/srv/restic"},
+			CompanionFiles: CompanionFilesConfig{Suffixes: []string{".sha256", "/md5"}},
+		}
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 1)
+		require.EqualError(t, validationErr.Problems[0], `invalid companion_files suffix "/md5"`)
+
+		cfg.CompanionFiles = CompanionFilesConfig{DeleteOrphans: true}
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.EqualError(t, validationErr.Problems[0],
+			"companion_files is not supported by the restic backend")
+	})
+
+	t.Run("unknown timestamp tiebreak", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern:       "backup.tar.gz",
+			Directories:       []string{"/backups"},
+			TimestampTiebreak: TiebreakLargest,
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.TimestampTiebreak = "oldest"
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `unknown timestamp_tiebreak "oldest"`)
+	})
+
+	t.Run("monthly anchor", func(t *testing.T) {
+		cfg := &Config{FilePattern: "backup.tar.gz", Directories: []string{"/backups"}}
+
+		for _, anchor := range []string{MonthlyAnchorLast, MonthlyAnchorFirst, "1", "31"} {
+			cfg.MonthlyAnchor = anchor
+			require.NoError(t, cfg.Validate(), anchor)
+		}
+
+		require.Equal(t, 31, cfg.MonthlyAnchorDay())
+
+		for _, anchor := range []string{"middle", "0", "32", "-1"} {
+			cfg.MonthlyAnchor = anchor
+			require.Zero(t, cfg.MonthlyAnchorDay(), anchor)
+
+			err := cfg.Validate()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), `unknown monthly_anchor "`+anchor+`"`)
+		}
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/backups"},
+			Owner:       "postgres",
+			Group:       "dba",
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.Backend = BackendBtrfs
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0],
+			"group is not supported by the btrfs backend")
+		require.EqualError(t, validationErr.Problems[1],
+			"owner is not supported by the btrfs backend")
+	})
+
+	t.Run("manifest backend", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/backups"},
+			Backend:     BackendManifest,
+			Manifest:    ManifestConfig{Format: "yaml"},
+			Owner:       "postgres",
+		}
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 3)
+		require.EqualError(t, validationErr.Problems[0],
+			"owner is not supported by the manifest backend")
+		require.EqualError(t, validationErr.Problems[1], "manifest path must be specified")
+		require.EqualError(t, validationErr.Problems[2], `unknown manifest format "yaml"`)
+
+		cfg.Owner = ""
+		cfg.Manifest = ManifestConfig{Path: "manifest.csv", Format: ManifestFormatCSV}
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("fiscal year start", func(t *testing.T) {
+		cfg := &Config{FilePattern: "backup.tar.gz", Directories: []string{"/backups"}}
+		require.Equal(t, time.January, cfg.YearStart())
+
+		cfg.FiscalYearStart = 4
+		require.NoError(t, cfg.Validate())
+		require.Equal(t, time.April, cfg.YearStart())
+
+		cfg.FiscalYearStart = 13
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "fiscal_year_start must be a month from 1 to 12")
+	})
+
+	t.Run("negative retry settings", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/backups"},
+			Retry:       RetryConfig{Attempts: 3, Backoff: time.Second},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.Retry.MaxBackoff = -time.Second
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "retry settings must be non-negative")
+	})
+
+	t.Run("volumesnapshot backend", func(t *testing.T) {
+		cfg := &Config{
+			Backend:     BackendVolumeSnapshot,
+			Directories: []string{"databases"},
+			Kubernetes:  KubernetesConfig{LabelSelector: "app=postgres"},
+		}
+		require.NoError(t, cfg.Validate())
+
+		cfg.Directories = []string{"data*"}
+		cfg.MinFreeSpace = "10GiB"
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0],
+			"min_free_space is not supported by the volumesnapshot backend")
+		require.EqualError(t, validationErr.Problems[1], `invalid namespace "data*"`)
+	})
+
+	t.Run("invalid directory glob", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/srv/backups/[customer"},
+		}
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid directory glob")
+	})
+
+	t.Run("invalid archive", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup-{year}.tar.gz",
+			Directories: []string{"/backups"},
+			Archive: ArchiveConfig{Tiers: map[string]string{
+				"expired": "/archive",
+				"weekly":  "",
+				"old":     "/archive/old",
+			}},
+		}
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0], `unknown archive tier "old"`)
+		require.EqualError(t, validationErr.Problems[1], `archive tier "weekly" has no destination`)
+
+		cfg.Archive.Tiers = map[string]string{"expired": "/archive"}
+		cfg.Archive.TransformSuffix = ".age"
+		require.EqualError(t, cfg.Validate(),
+			"archive transform_suffix requires a transform")
+
+		cfg.Archive.Transform = "age -r age1example"
+		cfg.Archive.TransformSuffix = "/x"
+		require.EqualError(t, cfg.Validate(),
+			`invalid archive transform_suffix "/x"`)
+
+		cfg.Archive = ArchiveConfig{Tiers: map[string]string{"expired": "/archive"}}
+		cfg.Backend = BackendBtrfs
+		require.EqualError(t, cfg.Validate(), "archive is not supported by the btrfs backend")
+	})
+
+	t.Run("invalid compression", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern:    "backup-{year}.tar",
+			Directories:    []string{"/backups"},
+			CompressAfter:  -time.Hour,
+			CompressFormat: "lz4",
+		}
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, cfg.Validate(), &validationErr)
+		require.Len(t, validationErr.Problems, 2)
+		require.EqualError(t, validationErr.Problems[0], "compress_after must be non-negative")
+		require.EqualError(t, validationErr.Problems[1], `unknown compress_format "lz4"`)
+	})
+
+	t.Run("negative operation_timeout", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern:      "backup-{year}.tar.gz",
+			Directories:      []string{"/backups"},
+			OperationTimeout: -time.Second,
+		}
+		require.ErrorContains(t, cfg.Validate(),
+			"operation_timeout and list_timeout must be non-negative")
+	})
+
+	t.Run("unknown log_decisions", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern:  "backup-{year}.tar.gz",
+			Directories:  []string{"/backups"},
+			LogDecisions: "none",
+		}
+		require.ErrorContains(t, cfg.Validate(), `unknown log_decisions "none"`)
+	})
+
+	t.Run("unknown pattern syntax", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern:   "backup-{year}.tar.gz",
+			Directories:   []string{"/backups"},
+			PatternSyntax: "shell",
+		}
+		require.ErrorContains(t, cfg.Validate(), `unknown pattern_syntax "shell"`)
+	})
+
+	t.Run("invalid pin pattern", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern: "backup.tar.gz",
+			Directories: []string{"/backups"},
+			Pins:        []string{"backup-[2024"},
+		}
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid pin")
+	})
+
+	t.Run("min free space", func(t *testing.T) {
+		cfg := &Config{
+			FilePattern:  "backup.tar.gz",
+			Directories:  []string{"/backups"},
+			MinFreeSpace: "10GiB",
+		}
+		require.NoError(t, cfg.Validate())
+		require.Equal(t, int64(10<<30), cfg.MinFreeBytes())
+
+		cfg.MinFreeSpace = "10 parsecs"
+		err := cfg.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid min_free_space")
+	})
+
+	t.Run("invalid webhook urls", func(t *testing.T) {
+		testCases := []struct {
+			name          string
+			notifications NotificationsConfig
+			msg           string
+		}{
+			{
+				name: "slack bad scheme",
+				notifications: NotificationsConfig{
+					Slack: SlackConfig{WebhookURL: "ftp://hooks.slack.com/x"},
+				},
+				msg: "slack webhook_url",
+			},
+			{
+				name: "discord missing host",
+				notifications: NotificationsConfig{
+					Discord: DiscordConfig{WebhookURL: "https:///api/webhooks"},
+				},
+				msg: "discord webhook_url",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := &Config{
+					FilePattern:   "backup.tar.gz",
+					Directories:   []string{"/backups"},
+					Notifications: tc.notifications,
+				}
+				err := cfg.Validate()
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.msg)
+			})
+		}
+	})
+}
+
+func TestConfig_RetentionFor(t *testing.T) {
+	cfg := &Config{
+		Retention: RetentionPolicy{Daily: 7},
+		TagRetention: map[string]RetentionPolicy{
+			"pre-release": {Monthly: 10},
+		},
+	}
+
+	require.Equal(t, RetentionPolicy{Daily: 7}, cfg.RetentionFor(""))
+	require.Equal(t, RetentionPolicy{Daily: 7}, cfg.RetentionFor("nightly"))
+	require.Equal(t, RetentionPolicy{Monthly: 10}, cfg.RetentionFor("pre-release"))
+	require.Equal(t, RetentionPolicy{Monthly: 10}, cfg.RetentionFor("Pre-Release"))
+
+	cfg.TagRetention["broken"] = RetentionPolicy{Weekly: -1}
+	cfg.FilePattern = "backup.tar.gz"
+	cfg.Directories = []string{"/backups"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `tag "broken"`)
 }
 
 func TestConfig_GetRetentionDuration(t *testing.T) {
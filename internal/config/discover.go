@@ -0,0 +1,128 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/viper"
+)
+
+// configName is the name, without extension, of the config file searched
+// for in the working directory and the system-wide locations
+const configName = "retention-policy"
+
+// userConfigName is the name of the config file in the user's config
+// directory, which is dedicated to this tool
+const userConfigName = "config"
+
+// searchLocation is a directory and the name of the config file looked for
+// in it
+type searchLocation struct {
+	dir  string
+	name string
+}
+
+// searchLocations returns the places a config file is looked for without
+// --config, most specific first: the working directory, the user's config
+// directory as package managers such as Homebrew and Scoop expect, the
+// dot directory in the user's home and /etc
+func searchLocations() []searchLocation {
+	locations := []searchLocation{{".", configName}}
+
+	if dir := userConfigDir(); dir != "" {
+		locations = append(locations,
+			searchLocation{filepath.Join(dir, "apply-retention-policy"), userConfigName})
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		locations = append(locations,
+			searchLocation{filepath.Join(home, ".apply-retention-policy"), configName})
+	}
+
+	return append(locations, searchLocation{"/etc/apply-retention-policy", configName})
+}
+
+// userConfigDir returns $XDG_CONFIG_HOME if it is set, %APPDATA% on Windows
+// and ~/.config otherwise, or "" if none is known
+func userConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+
+	if runtime.GOOS == "windows" {
+		return os.Getenv("APPDATA")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config")
+}
+
+// useConfigFile has viper read configFile or, without one, the first config
+// file found in searchLocations
+func useConfigFile(configFile string) {
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+		return
+	}
+
+	if found := findConfigFile(); found != "" {
+		// Found files are read as YAML whatever their extension
+		viper.SetConfigFile(found)
+		viper.SetConfigType("yaml")
+	}
+}
+
+// findConfigFile returns the first config file found in searchLocations,
+// with any extension viper can read or none, or "" if there is none
+func findConfigFile() string {
+	for _, location := range searchLocations() {
+		for _, ext := range viper.SupportedExts {
+			path := filepath.Join(location.dir, location.name+"."+ext)
+			if isFile(path) {
+				return path
+			}
+		}
+
+		if path := filepath.Join(location.dir, location.name); isFile(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// isFile reports whether path exists and is not a directory
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && !info.IsDir()
+}
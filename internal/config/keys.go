@@ -0,0 +1,204 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// maxSuggestionDistance is the largest edit distance between an unknown key
+// and a known one for the known key to be suggested
+const maxSuggestionDistance = 2
+
+// configField is a config key together with the type it decodes into
+type configField struct {
+	key string
+	typ reflect.Type
+}
+
+// bindEnv binds every config key to its ARP_* environment variable. Keys
+// have to be bound explicitly because viper only consults the environment
+// for keys it already knows about when unmarshalling. Map fields are skipped
+// as they cannot be set from a single environment variable.
+func bindEnv() error {
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+
+	for _, field := range configFields(reflect.TypeFor[Config](), "") {
+		if field.typ.Kind() == reflect.Map {
+			continue
+		}
+
+		if err := viper.BindEnv(field.key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configFields returns the dotted mapstructure keys of every leaf field in a
+// config struct. Nested structs are flattened, maps are returned as a single
+// field.
+func configFields(t reflect.Type, prefix string) []configField {
+	var fields []configField
+
+	for field := range t.Fields() {
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+
+		if field.Type.Kind() == reflect.Struct {
+			fields = append(fields, configFields(field.Type, key+".")...)
+			continue
+		}
+
+		fields = append(fields, configField{key: key, typ: field.Type})
+	}
+
+	return fields
+}
+
+// unknownKeys reports every key that does not correspond to a config field,
+// suggesting the closest known key where there is one
+func unknownKeys(keys []string) []error {
+	fields := configFields(reflect.TypeFor[Config](), "")
+
+	var errs []error
+
+	for _, key := range slices.Sorted(slices.Values(keys)) {
+		candidates, ok := knownKeys(fields, key)
+		if ok {
+			continue
+		}
+
+		if suggestion := suggest(key, candidates); suggestion != "" {
+			errs = append(
+				errs,
+				fmt.Errorf("unknown key %q (did you mean %q?)", key, suggestion),
+			)
+		} else {
+			errs = append(errs, fmt.Errorf("unknown key %q", key))
+		}
+	}
+
+	return errs
+}
+
+// knownKeys reports whether key is a valid config key. If it is not, the
+// keys it could have been meant as are returned instead. Keys below a map
+// field are checked against the fields of the map's element type, which may
+// hold maps of their own, unless the map holds plain values.
+func knownKeys(fields []configField, key string) ([]string, bool) {
+	candidates := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		if field.key == key {
+			return nil, true
+		}
+
+		if field.typ.Kind() != reflect.Map {
+			candidates = append(candidates, field.key)
+			continue
+		}
+
+		entry, ok := strings.CutPrefix(key, field.key+".")
+		if !ok {
+			candidates = append(candidates, field.key)
+			continue
+		}
+
+		// Every key is valid in a map of plain values
+		if field.typ.Elem().Kind() != reflect.Struct {
+			return nil, true
+		}
+
+		name, rest, _ := strings.Cut(entry, ".")
+
+		nested, ok := knownKeys(configFields(field.typ.Elem(), ""), rest)
+		if ok {
+			return nil, true
+		}
+
+		for _, candidate := range nested {
+			candidates = append(candidates, field.key+"."+name+"."+candidate)
+		}
+	}
+
+	return candidates, false
+}
+
+// suggest returns the candidate closest to key, or an empty string if none
+// is close enough. Dashes and underscores are treated as equivalent.
+func suggest(key string, candidates []string) string {
+	normalized := strings.ReplaceAll(key, "-", "_")
+
+	best, bestDistance := "", maxSuggestionDistance+1
+
+	for _, candidate := range candidates {
+		distance := editDistance(normalized, candidate)
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
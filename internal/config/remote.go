@@ -0,0 +1,252 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Errors loading a config from a URL
+var (
+	// ErrUnsupportedScheme is returned for config URLs that cannot be fetched
+	ErrUnsupportedScheme = errors.New("unsupported config URL scheme")
+	// ErrChecksumMismatch is returned when a fetched or cached config does not
+	// have the checksum pinned in the URL
+	ErrChecksumMismatch = errors.New("config checksum mismatch")
+)
+
+const (
+	// remoteTimeout bounds fetching a config from a URL
+	remoteTimeout = 30 * time.Second
+	// maxRemoteSize is the largest config accepted from a URL
+	maxRemoteSize = 1 << 20
+	// checksumPrefix starts the URL fragment pinning the config's SHA-256
+	checksumPrefix = "sha256="
+)
+
+// isRemote reports whether a --config value is a URL rather than a path
+func isRemote(configFile string) bool {
+	return strings.Contains(configFile, "://")
+}
+
+// remoteConfig fetches the config at rawURL and returns the path of its
+// cached copy, which viper then reads like any other config file. The copy
+// is revalidated with its ETag, and used as is when the server cannot be
+// reached so that runs keep working offline. A URL fragment such as
+// #sha256=<hex> pins the checksum both the download and the cached copy
+// must have. Plain http:// URLs are only accepted with a pinned checksum,
+// since anyone on the network path could otherwise rewrite the policy.
+func remoteConfig(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid config URL: %w", err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("%w: %s (use an https:// URL)", ErrUnsupportedScheme, u.Scheme)
+	}
+
+	checksum, ok := strings.CutPrefix(u.Fragment, checksumPrefix)
+	if !ok && u.Fragment != "" {
+		return "", fmt.Errorf("invalid config URL fragment %q, expected %s<hex>",
+			u.Fragment, checksumPrefix)
+	}
+
+	if u.Scheme == "http" && checksum == "" {
+		return "", fmt.Errorf("%w: http without a #%s<hex> fragment (use an https:// URL)",
+			ErrUnsupportedScheme, checksumPrefix)
+	}
+
+	u.Fragment = ""
+
+	cache, err := remoteCachePath(u)
+	if err != nil {
+		return "", err
+	}
+
+	if err := fetchRemote(u.String(), cache, checksum); err != nil {
+		return "", err
+	}
+
+	return cache, nil
+}
+
+// remoteCachePath returns where the config at u is cached, keeping its
+// extension so viper knows how to parse it
+func remoteCachePath(u *url.URL) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config cache: %w", err)
+	}
+
+	ext := path.Ext(u.Path)
+	if !slices.Contains(viper.SupportedExts, strings.TrimPrefix(ext, ".")) {
+		ext = ".yaml"
+	}
+
+	sum := sha256.Sum256([]byte(u.String()))
+
+	return filepath.Join(
+		dir, "apply-retention-policy", "config", hex.EncodeToString(sum[:16])+ext,
+	), nil
+}
+
+// fetchRemote downloads rawURL into cache unless the cached copy is still
+// current. The cached copy is kept when the server cannot be reached or
+// fails. Neither a download nor the cached copy is accepted unless it has
+// the pinned checksum, if any.
+func fetchRemote(rawURL, cache, checksum string) error {
+	cached, err := os.ReadFile(cache)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read cached config: %w", err)
+	}
+
+	haveCache := err == nil
+
+	resp, err := requestRemote(rawURL, cache, haveCache)
+
+	switch {
+	case haveCache && (err != nil || resp.StatusCode == http.StatusNotModified ||
+		resp.StatusCode >= http.StatusInternalServerError):
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		return verifyChecksum(rawURL, cached, checksum)
+	case err != nil:
+		return fmt.Errorf("failed to fetch config: %w", err)
+	}
+
+	data, err := readRemote(rawURL, resp)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyChecksum(rawURL, data, checksum); err != nil {
+		return err
+	}
+
+	return writeCache(cache, data, resp.Header.Get("ETag"))
+}
+
+// requestRemote requests rawURL, revalidating the cached copy with its ETag
+// when there is one
+func requestRemote(rawURL, cache string, haveCache bool) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if etag, err := os.ReadFile(cache + ".etag"); err == nil && haveCache {
+		req.Header.Set("If-None-Match", string(bytes.TrimSpace(etag)))
+	}
+
+	client := &http.Client{Timeout: remoteTimeout}
+
+	return client.Do(req)
+}
+
+// readRemote reads the config from a successful response and closes it
+func readRemote(rawURL string, resp *http.Response) ([]byte, error) {
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch config: %s: %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+
+	if len(data) > maxRemoteSize {
+		return nil, fmt.Errorf("failed to fetch config: %s is larger than %d bytes",
+			rawURL, maxRemoteSize)
+	}
+
+	return data, nil
+}
+
+// verifyChecksum checks data against the SHA-256 pinned for rawURL, if any
+func verifyChecksum(rawURL string, data []byte, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, checksum) {
+		return fmt.Errorf("%w: %s has sha256 %s", ErrChecksumMismatch, rawURL, actual)
+	}
+
+	return nil
+}
+
+// writeCache replaces the cached copy of a config and its ETag. The copy is
+// renamed into place so an interrupted run never leaves a truncated config.
+func writeCache(cache string, data []byte, etag string) error {
+	if err := os.MkdirAll(filepath.Dir(cache), 0o750); err != nil {
+		return fmt.Errorf("failed to cache config: %w", err)
+	}
+
+	tmp := cache + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to cache config: %w", err)
+	}
+
+	if err := os.Rename(tmp, cache); err != nil {
+		return fmt.Errorf("failed to cache config: %w", err)
+	}
+
+	if etag == "" {
+		if err := os.Remove(cache + ".etag"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to cache config: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := os.WriteFile(cache+".etag", []byte(etag), 0o600); err != nil {
+		return fmt.Errorf("failed to cache config: %w", err)
+	}
+
+	return nil
+}
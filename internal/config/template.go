@@ -0,0 +1,153 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// templateRegex finds ${NAME} references in config values. A reference
+// preceded by a second $ is escaped and kept literally.
+var templateRegex = regexp.MustCompile(`\$?\$\{[^}]*\}`)
+
+// templateDatePrefix starts a reference to the load time formatted with the
+// Go time layout that follows it, e.g. ${date:2006-01-02}
+const templateDatePrefix = "date:"
+
+// expandTemplates replaces ${NAME} references in every string value with the
+// environment variable NAME, which has to be listed in template_vars, and
+// ${date:LAYOUT} references with now formatted with LAYOUT. HOSTNAME falls
+// back to the host name when it is not exported, as most shells don't.
+// Hooks are left alone since the shell running them expands variables
+// itself.
+func expandTemplates(now time.Time) []error {
+	// Decoded like any other list so a comma-separated environment variable
+	// works too; a malformed value is reported when the config is decoded
+	var allowed []string
+	_ = viper.UnmarshalKey("template_vars", &allowed)
+
+	var errs []error
+
+	for _, key := range slices.Sorted(slices.Values(viper.AllKeys())) {
+		if key == "template_vars" || strings.HasPrefix(key, "hooks.") {
+			continue
+		}
+
+		changed := false
+		expand := func(s string) string {
+			expanded, refErrs := expandTemplate(s, allowed, now)
+			for _, err := range refErrs {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+
+			changed = changed || expanded != s
+
+			return expanded
+		}
+
+		if value := expandValue(viper.Get(key), expand); changed {
+			viper.Set(key, value)
+		}
+	}
+
+	return errs
+}
+
+// expandValue applies expand to a string value or to every string in a list
+func expandValue(value any, expand func(string) string) any {
+	switch value := value.(type) {
+	case string:
+		return expand(value)
+	case []string:
+		expanded := make([]string, len(value))
+		for i, s := range value {
+			expanded[i] = expand(s)
+		}
+
+		return expanded
+	case []any:
+		expanded := slices.Clone(value)
+		for i, v := range value {
+			if s, ok := v.(string); ok {
+				expanded[i] = expand(s)
+			}
+		}
+
+		return expanded
+	}
+
+	return value
+}
+
+// expandTemplate expands the references in a single value, reporting every
+// one that cannot be expanded
+func expandTemplate(s string, allowed []string, now time.Time) (string, []error) {
+	var errs []error
+
+	expanded := templateRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+
+		value, err := templateValue(ref[2:len(ref)-1], allowed, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		return value
+	})
+
+	return expanded, errs
+}
+
+// templateValue returns the value a single reference expands to
+func templateValue(name string, allowed []string, now time.Time) (string, error) {
+	if layout, ok := strings.CutPrefix(name, templateDatePrefix); ok {
+		return now.Format(layout), nil
+	}
+
+	if !slices.Contains(allowed, name) {
+		return "", fmt.Errorf("variable %q is not listed in template_vars", name)
+	}
+
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+
+	if name == "HOSTNAME" {
+		if hostname, err := os.Hostname(); err == nil {
+			return hostname, nil
+		}
+	}
+
+	return "", fmt.Errorf("variable %q is not set", name)
+}
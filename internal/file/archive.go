@@ -0,0 +1,172 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// Errors moving a backup to an archive
+var (
+	ErrArchiveFile   = files.ErrArchiveFile
+	ErrArchiveExists = files.ErrArchiveExists
+)
+
+// Transform rewrites a backup read from in to out on its way to an archive,
+// for example to encrypt it
+type Transform func(in io.Reader, out io.Writer) error
+
+// ArchiveOption is a function that configures ArchiveFile
+type ArchiveOption func(*archiveOptions)
+
+type archiveOptions struct {
+	transform Transform
+	suffix    string
+}
+
+// WithTransform pipes a backup through transform instead of moving it
+// as-is, adding suffix to its name in the archive
+func WithTransform(transform Transform, suffix string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.transform = transform
+		o.suffix = suffix
+	}
+}
+
+// ArchiveFile moves the backup f, listed below root, to the same path
+// relative to dest and returns its new path. Missing directories below dest
+// are created, and a file already in the archive is never replaced. The
+// backup is renamed when dest is on the same filesystem, and otherwise
+// copied with its mode and modification time before it is removed. A
+// transformed backup is always written anew under a temporary name, and
+// only removed once its replacement is in place.
+func ArchiveFile(root string, f Info, dest string, opts ...ArchiveOption) (string, error) {
+	var o archiveOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rel, err := RelativeToRoot(root, f.Path)
+	if err != nil {
+		return "", &files.PathError{Op: files.OpArchive, Path: f.Path, Err: err}
+	}
+
+	target := filepath.Join(dest, rel)
+
+	if o.transform != nil {
+		target += o.suffix
+		err = transformFile(f, target, o.transform)
+	} else {
+		err = moveFile(f, target)
+	}
+
+	if err != nil {
+		return "", &files.PathError{Op: files.OpArchive, Path: f.Path, Err: err}
+	}
+
+	return target, nil
+}
+
+// prepareMove checks that the regular file f can be moved to target, which
+// must not exist yet, and creates the missing parents of target
+func prepareMove(f Info, target string) (os.FileInfo, error) {
+	current, err := os.Lstat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.listed != nil && !os.SameFile(current, f.listed) {
+		return nil, ErrFileChanged
+	}
+
+	if !current.Mode().IsRegular() {
+		return nil, ErrNotRegularFile
+	}
+
+	if _, err := os.Lstat(target); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveExists, target)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// transformFile writes the regular file f through transform to target,
+// which must not exist yet, and then removes f
+func transformFile(f Info, target string, transform Transform) error {
+	current, err := prepareMove(f, target)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(filepath.Clean(f.Path))
+	if err != nil {
+		return err
+	}
+
+	err = writePartial(target, current, func(w io.Writer) error {
+		return transform(in, w)
+	})
+
+	// The backup is closed before it is removed, which Windows requires
+	_ = in.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(f.Path)
+}
+
+// moveFile moves the regular file f to target, which must not exist yet
+func moveFile(f Info, target string) error {
+	if _, err := prepareMove(f, target); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Path, target); err == nil {
+		return nil
+	}
+
+	// Renaming fails across filesystems, so the file is copied instead
+	if _, err := CopyFile(f.Path, target); err != nil {
+		return err
+	}
+
+	return os.Remove(f.Path)
+}
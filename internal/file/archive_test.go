@@ -0,0 +1,163 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+func TestArchiveFile(t *testing.T) {
+	dir := t.TempDir()
+	archive := t.TempDir()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "db"), 0o750))
+
+	for _, name := range []string{"db/a.tar.gz", "b.tar.gz", "c.tar.gz"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "db", "a.tar.gz"), mtime, mtime))
+
+	manager, err := NewManager(dir, "*.tar.gz", WithSyntax(SyntaxGlob), WithModTime(),
+		WithBasename())
+	require.NoError(t, err)
+
+	listed, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, listed, 3)
+
+	byName := map[string]Info{}
+	for _, f := range listed {
+		byName[filepath.Base(f.Path)] = f
+	}
+
+	t.Run("keeps the relative path", func(t *testing.T) {
+		target, err := ArchiveFile(dir, byName["a.tar.gz"], archive)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(archive, "db", "a.tar.gz"), target)
+		require.NoFileExists(t, filepath.Join(dir, "db", "a.tar.gz"))
+
+		info, err := os.Stat(target)
+		require.NoError(t, err)
+		require.True(t, info.ModTime().Equal(mtime))
+	})
+
+	t.Run("never replaces an archived file", func(t *testing.T) {
+		existing := filepath.Join(archive, "b.tar.gz")
+		require.NoError(t, os.WriteFile(existing, []byte("older"), 0o600))
+
+		_, err := ArchiveFile(dir, byName["b.tar.gz"], archive)
+		require.ErrorIs(t, err, ErrArchiveFile)
+		require.ErrorIs(t, err, ErrArchiveExists)
+		require.FileExists(t, filepath.Join(dir, "b.tar.gz"))
+
+		var pathErr *files.PathError
+		require.ErrorAs(t, err, &pathErr)
+		require.Equal(t, files.OpArchive, pathErr.Op)
+		require.Equal(t, byName["b.tar.gz"].Path, pathErr.Path)
+	})
+
+	t.Run("file changed since it was listed", func(t *testing.T) {
+		replacement := filepath.Join(dir, "replacement")
+		require.NoError(t, os.WriteFile(replacement, []byte("replaced"), 0o600))
+		require.NoError(t, os.Rename(replacement, filepath.Join(dir, "c.tar.gz")))
+
+		_, err := ArchiveFile(dir, byName["c.tar.gz"], archive)
+		require.ErrorIs(t, err, ErrFileChanged)
+	})
+}
+
+func TestArchiveFileTransform(t *testing.T) {
+	dir := t.TempDir()
+	archive := t.TempDir()
+
+	for _, name := range []string{"a.tar.gz", "b.tar.gz"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.tar.gz"), mtime, mtime))
+
+	manager, err := NewManager(dir, "*.tar.gz", WithSyntax(SyntaxGlob), WithModTime())
+	require.NoError(t, err)
+
+	files, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	byName := map[string]Info{}
+	for _, f := range files {
+		byName[filepath.Base(f.Path)] = f
+	}
+
+	upper := func(in io.Reader, out io.Writer) error {
+		content, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+
+		_, err = out.Write(bytes.ToUpper(content))
+
+		return err
+	}
+
+	t.Run("writes the transformed backup", func(t *testing.T) {
+		target, err := ArchiveFile(dir, byName["a.tar.gz"], archive, WithTransform(upper, ".enc"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(archive, "a.tar.gz.enc"), target)
+		require.NoFileExists(t, filepath.Join(dir, "a.tar.gz"))
+
+		content, err := os.ReadFile(target)
+		require.NoError(t, err)
+		require.Equal(t, "A.TAR.GZ", string(content))
+
+		info, err := os.Stat(target)
+		require.NoError(t, err)
+		require.True(t, info.ModTime().Equal(mtime))
+	})
+
+	t.Run("failed transform keeps the backup", func(t *testing.T) {
+		failing := func(io.Reader, io.Writer) error { return errors.New("no recipient") }
+
+		_, err := ArchiveFile(dir, byName["b.tar.gz"], archive, WithTransform(failing, ".enc"))
+		require.ErrorIs(t, err, ErrArchiveFile)
+		require.FileExists(t, filepath.Join(dir, "b.tar.gz"))
+
+		entries, err := os.ReadDir(archive)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+}
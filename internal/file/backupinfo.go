@@ -0,0 +1,237 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupInfoName is the name of the file describing a backup, stored inside
+// the backup archive or next to the backup with the name of the backup and
+// a dot in front
+const BackupInfoName = "backup-info.json"
+
+// backupInfoMaxEntries is the number of entries of a tar archive searched
+// for BackupInfoName. A tar archive can only be read from the start, so the
+// file has to be among the first entries to be found without reading the
+// whole backup.
+const backupInfoMaxEntries = 16
+
+// backupInfoMaxSize is the largest BackupInfoName that is read
+const backupInfoMaxSize = 64 << 10
+
+// ErrNoBackupInfo is returned when a backup has no readable BackupInfoName
+var ErrNoBackupInfo = errors.New("no " + BackupInfoName + " found")
+
+// BackupInfo describes a backup, written by the backup tool into the
+// archive or a sidecar file
+type BackupInfo struct {
+	// Type is the kind of backup, such as postgres, and is used as its tag
+	Type string `json:"type"`
+	// Labels are further properties of the backup, some of which can be
+	// added to its tag
+	Labels map[string]string `json:"labels"`
+	// Timestamp is when the backup was taken, as an RFC 3339 timestamp or
+	// Unix seconds, and replaces the timestamp in the name when set
+	Timestamp string `json:"timestamp"`
+}
+
+// WithBackupInfo tags files by the BackupInfoName describing them instead of
+// the {tag} in their name, for backup tools that name backups with opaque
+// identifiers. The tag is the type of the backup, followed by the values of
+// labels joined with slashes, such as postgres/prod for the label env. A
+// timestamp in the description dates the file, so the pattern needs no
+// {year}. Files without a readable description are skipped.
+func WithBackupInfo(labels ...string) ManagerOption {
+	return func(m *Manager) {
+		m.backupInfo = true
+		m.infoLabels = labels
+	}
+}
+
+// Tag returns the tag of a backup described by info, built from its type
+// and the values of labels
+func (info BackupInfo) Tag(labels []string) string {
+	parts := []string{info.Type}
+	for _, label := range labels {
+		parts = append(parts, info.Labels[label])
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// ReadBackupInfo reads the description of the backup at path from its
+// sidecar file, or from BackupInfoName inside the backup when it is a zip
+// archive or a tar archive, optionally gzip compressed. ErrNoBackupInfo is
+// returned when there is neither.
+func ReadBackupInfo(path string) (BackupInfo, error) {
+	sidecar, err := os.Open(filepath.Clean(path + "." + BackupInfoName))
+	if err == nil {
+		defer sidecar.Close()
+		return decodeBackupInfo(sidecar)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return BackupInfo{}, err
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	head, err := r.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return BackupInfo{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return zipBackupInfo(f)
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return BackupInfo{}, fmt.Errorf("%w: %w", ErrNoBackupInfo, err)
+		}
+		defer gz.Close()
+
+		return tarBackupInfo(gz)
+	default:
+		return tarBackupInfo(r)
+	}
+}
+
+// zipBackupInfo reads BackupInfoName from the zip archive f
+func zipBackupInfo(f *os.File) (BackupInfo, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	archive, err := zip.NewReader(f, stat.Size())
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("%w: %w", ErrNoBackupInfo, err)
+	}
+
+	for _, entry := range archive.File {
+		if !isBackupInfo(entry.Name) {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return BackupInfo{}, err
+		}
+		defer rc.Close()
+
+		return decodeBackupInfo(rc)
+	}
+
+	return BackupInfo{}, ErrNoBackupInfo
+}
+
+// tarBackupInfo reads BackupInfoName from the first entries of the tar
+// archive read from r
+func tarBackupInfo(r io.Reader) (BackupInfo, error) {
+	archive := tar.NewReader(r)
+
+	for range backupInfoMaxEntries {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return BackupInfo{}, fmt.Errorf("%w: %w", ErrNoBackupInfo, err)
+		}
+
+		if header.Typeflag == tar.TypeReg && isBackupInfo(header.Name) {
+			return decodeBackupInfo(archive)
+		}
+	}
+
+	return BackupInfo{}, ErrNoBackupInfo
+}
+
+// isBackupInfo reports whether the archive entry name is BackupInfoName at
+// the top level of the archive, or in its only top-level directory
+func isBackupInfo(name string) bool {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+
+	dir, base := path.Split(name)
+
+	return base == BackupInfoName && strings.Count(dir, "/") <= 1
+}
+
+// decodeBackupInfo decodes a BackupInfoName read from r
+func decodeBackupInfo(r io.Reader) (BackupInfo, error) {
+	var info BackupInfo
+
+	if err := json.NewDecoder(io.LimitReader(r, backupInfoMaxSize)).Decode(&info); err != nil {
+		return BackupInfo{}, fmt.Errorf("invalid %s: %w", BackupInfoName, err)
+	}
+
+	if info.Type == "" {
+		return BackupInfo{}, fmt.Errorf("invalid %s: no type", BackupInfoName)
+	}
+
+	return info, nil
+}
+
+// describe returns the tag of the file at path and the timestamp, or the
+// zero time when there is none, from its BackupInfoName
+func (m *Manager) describe(path string) (string, time.Time, error) {
+	info, err := ReadBackupInfo(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if info.Timestamp == "" {
+		return info.Tag(m.infoLabels), time.Time{}, nil
+	}
+
+	timestamp, err := parseStamp(info.Timestamp)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid %s: %w", BackupInfoName, err)
+	}
+
+	return info.Tag(m.infoLabels), timestamp, nil
+}
@@ -0,0 +1,183 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTar writes a tar archive of entries, in order, to w
+func writeTar(t *testing.T, w io.Writer, entries ...[2]string) {
+	t.Helper()
+
+	archive := tar.NewWriter(w)
+
+	for _, entry := range entries {
+		err := archive.WriteHeader(&tar.Header{
+			Name:     entry[0],
+			Mode:     0o600,
+			Size:     int64(len(entry[1])),
+			Typeflag: tar.TypeReg,
+		})
+		require.NoError(t, err)
+
+		_, err = archive.Write([]byte(entry[1]))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, archive.Close())
+}
+
+// createBackup creates the file path and calls write to fill it
+func createBackup(t *testing.T, path string, write func(w io.Writer)) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	write(f)
+	require.NoError(t, f.Close())
+}
+
+func TestReadBackupInfo(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	info := `{"type": "postgres", "labels": {"env": "prod"}}`
+
+	createBackup(t, filepath.Join(dir, "plain.tar"), func(w io.Writer) {
+		writeTar(t, w, [2]string{"backup-info.json", info}, [2]string{"data.sql", "data"})
+	})
+
+	createBackup(t, filepath.Join(dir, "nested.tar.gz"), func(w io.Writer) {
+		gz := gzip.NewWriter(w)
+		writeTar(t, gz,
+			[2]string{"./backup/data.sql", "data"},
+			[2]string{"./backup/backup-info.json", info})
+		require.NoError(t, gz.Close())
+	})
+
+	createBackup(t, filepath.Join(dir, "archive.zip"), func(w io.Writer) {
+		archive := zip.NewWriter(w)
+		entry, err := archive.Create("backup-info.json")
+		require.NoError(t, err)
+		_, err = entry.Write([]byte(info))
+		require.NoError(t, err)
+		require.NoError(t, archive.Close())
+	})
+
+	createBackup(t, filepath.Join(dir, "sidecar.bin"), func(w io.Writer) {
+		_, _ = w.Write([]byte("opaque"))
+	})
+	err := os.WriteFile(filepath.Join(dir, "sidecar.bin.backup-info.json"), []byte(info), 0o600)
+	require.NoError(t, err)
+
+	for _, name := range []string{"plain.tar", "nested.tar.gz", "archive.zip", "sidecar.bin"} {
+		got, err := ReadBackupInfo(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		require.Equal(t, BackupInfo{Type: "postgres", Labels: map[string]string{"env": "prod"}},
+			got, name)
+	}
+
+	// Too deep in the archive to be found without reading all of it
+	entries := make([][2]string, 0, backupInfoMaxEntries+1)
+	for i := range backupInfoMaxEntries {
+		entries = append(entries, [2]string{fmt.Sprintf("data-%d.sql", i), "data"})
+	}
+
+	createBackup(t, filepath.Join(dir, "late.tar"), func(w io.Writer) {
+		writeTar(t, w, append(entries, [2]string{"backup-info.json", info})...)
+	})
+
+	createBackup(t, filepath.Join(dir, "subdir.tar"), func(w io.Writer) {
+		writeTar(t, w, [2]string{"a/b/backup-info.json", info})
+	})
+
+	for _, name := range []string{"late.tar", "subdir.tar", "sidecar.bin.backup-info.json"} {
+		_, err = ReadBackupInfo(filepath.Join(dir, name))
+		require.ErrorIs(t, err, ErrNoBackupInfo, name)
+	}
+
+	createBackup(t, filepath.Join(dir, "untyped.tar"), func(w io.Writer) {
+		writeTar(t, w, [2]string{"backup-info.json", `{"labels": {"env": "prod"}}`})
+	})
+
+	_, err = ReadBackupInfo(filepath.Join(dir, "untyped.tar"))
+	require.ErrorContains(t, err, "no type")
+}
+
+func TestScanBackupInfo(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for name, info := range map[string]string{
+		"0b9f.tar": `{"type": "postgres", "labels": {"env": "prod"},` +
+			` "timestamp": "2025-01-02T03:04:05Z"}`,
+		"5c2e.tar": `{"type": "mysql", "timestamp": "1735700000"}`,
+		"7d41.tar": `{"type": "mysql", "timestamp": "yesterday"}`,
+		"9a03.tar": "",
+	} {
+		createBackup(t, filepath.Join(dir, name), func(w io.Writer) {
+			if info != "" {
+				writeTar(t, w, [2]string{"backup-info.json", info})
+			}
+		})
+	}
+
+	// The pattern needs no {year} when backups describe their timestamp
+	manager, err := NewManager(dir, "*.tar",
+		WithSyntax(SyntaxGlob), WithBackupInfo("env"))
+	require.NoError(t, err)
+
+	result, err := manager.Scan(t.Context())
+	require.NoError(t, err)
+	require.Len(t, result.Files, 2)
+
+	require.Equal(t, "mysql/", result.Files[0].Tag)
+	require.Equal(t, time.Unix(1735700000, 0).UTC(), result.Files[0].Timestamp)
+	require.Equal(t, "postgres/prod", result.Files[1].Tag)
+	require.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), result.Files[1].Timestamp)
+
+	skipped := map[string]SkipReason{}
+	for _, s := range result.Skipped {
+		skipped[filepath.Base(s.Path)] = s.Reason
+	}
+
+	require.Equal(t, map[string]SkipReason{
+		"7d41.tar": SkipNoBackupInfo,
+		"9a03.tar": SkipNoBackupInfo,
+	}, skipped)
+}
@@ -0,0 +1,183 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// ErrFileChanged is returned when a file to delete was replaced after it was
+// listed
+var ErrFileChanged = files.ErrFileChanged
+
+// StaleFiles returns the regular files in dir whose name matches one of
+// patterns and that were last modified before cutoff, such as the temporary
+// files a failed backup job leaves behind. Subdirectories are searched when
+// recursive is set; symlinks are never followed or returned.
+func StaleFiles(
+	ctx context.Context,
+	dir string,
+	patterns []string,
+	cutoff time.Time,
+	recursive bool,
+) ([]Info, error) {
+	return listFiles(ctx, dir, recursive, func(_ string, info os.FileInfo) (bool, error) {
+		return matchesAny(patterns, info.Name()) && info.ModTime().Before(cutoff), nil
+	})
+}
+
+// OrphanedCompanions returns the regular files in dir named after another
+// file with one of suffixes appended, such as a checksum or manifest, whose
+// primary file or directory no longer exists. Subdirectories are searched
+// when recursive is set; symlinks are never followed or returned.
+func OrphanedCompanions(
+	ctx context.Context,
+	dir string,
+	suffixes []string,
+	recursive bool,
+) ([]Info, error) {
+	return listFiles(ctx, dir, recursive, func(path string, info os.FileInfo) (bool, error) {
+		for _, suffix := range suffixes {
+			if suffix == "" || info.Name() == suffix || !strings.HasSuffix(path, suffix) {
+				continue
+			}
+
+			_, err := os.Lstat(strings.TrimSuffix(path, suffix))
+			if errors.Is(err, fs.ErrNotExist) {
+				return true, nil
+			}
+
+			if err != nil {
+				return false, err
+			}
+		}
+
+		return false, nil
+	})
+}
+
+// listFiles returns the regular files in dir, and below it when recursive
+// is set, that keep selects
+func listFiles(
+	ctx context.Context,
+	dir string,
+	recursive bool,
+	keep func(path string, info os.FileInfo) (bool, error),
+) ([]Info, error) {
+	var found []Info
+
+	visit := func(path string, d os.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		ok, err := keep(path, info)
+		if ok {
+			found = append(found, Info{
+				Path:      path,
+				Timestamp: info.ModTime(),
+				Size:      info.Size(),
+				listed:    info,
+			})
+		}
+
+		return err
+	}
+
+	var err error
+	if recursive {
+		err = walkDir(ctx, dir, visit)
+	} else {
+		err = ForEachEntry(dir, func(entry os.DirEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return visit(filepath.Join(dir, entry.Name()), entry)
+		})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListFiles, err)
+	}
+
+	return found, nil
+}
+
+// RemoveListed deletes f, a file listed below root, refusing to delete it if
+// it resides outside root or is no longer the file that was listed
+func RemoveListed(root string, f Info) error {
+	rel, err := RelativeToRoot(root, f.Path)
+	if err != nil {
+		return err
+	}
+
+	err = inRoot(root, func(r *os.Root) error {
+		current, err := r.Lstat(rel)
+		if err != nil {
+			return err
+		}
+
+		if f.listed != nil && !os.SameFile(current, f.listed) {
+			return fmt.Errorf("%w: %s", ErrFileChanged, f.Path)
+		}
+
+		return r.Remove(rel)
+	})
+	if err != nil {
+		return &files.PathError{Op: files.OpDelete, Path: f.Path, Err: err}
+	}
+
+	return nil
+}
+
+// matchesAny reports whether name matches one of the shell patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
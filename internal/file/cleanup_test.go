@@ -0,0 +1,126 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	cutoff := time.Now().Add(-time.Hour)
+	old := cutoff.Add(-time.Hour)
+	patterns := []string{"*.tmp", ".in-progress-*"}
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0o750))
+
+	for _, name := range []string{
+		"backup.tar.gz",
+		"old.tmp",
+		"new.tmp",
+		filepath.Join("nested", ".in-progress-1"),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o600))
+
+		if name != "new.tmp" {
+			require.NoError(t, os.Chtimes(path, old, old))
+		}
+	}
+
+	stale, err := StaleFiles(t.Context(), dir, patterns, cutoff, false)
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+	require.Equal(t, filepath.Join(dir, "old.tmp"), stale[0].Path)
+	require.Equal(t, int64(len("old.tmp")), stale[0].Size)
+
+	stale, err = StaleFiles(t.Context(), dir, patterns, cutoff, true)
+	require.NoError(t, err)
+	require.Len(t, stale, 2)
+
+	_, err = StaleFiles(t.Context(), filepath.Join(dir, "missing"), patterns, cutoff, true)
+	require.ErrorIs(t, err, ErrListFiles)
+}
+
+func TestRemoveListed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.tmp")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	stale, err := StaleFiles(t.Context(), dir, []string{"*.tmp"}, time.Now().Add(time.Hour), false)
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+
+	// A file put in place of the listed one is left alone
+	replacement := filepath.Join(dir, "replacement")
+	require.NoError(t, os.WriteFile(replacement, []byte("replaced"), 0o600))
+	require.NoError(t, os.Rename(replacement, path))
+	require.ErrorIs(t, RemoveListed(dir, stale[0]), ErrFileChanged)
+	require.FileExists(t, path)
+
+	stale, err = StaleFiles(t.Context(), dir, []string{"*.tmp"}, time.Now().Add(time.Hour), false)
+	require.NoError(t, err)
+	require.NoError(t, RemoveListed(dir, stale[0]))
+	require.NoFileExists(t, path)
+
+	outside := Info{Path: filepath.Join(t.TempDir(), "old.tmp")}
+	require.ErrorIs(t, RemoveListed(dir, outside), ErrOutsideRoot)
+}
+
+func TestOrphanedCompanions(t *testing.T) {
+	dir := t.TempDir()
+	suffixes := []string{".sha256", ".manifest"}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested", "snapshot"), 0o750))
+
+	for _, name := range []string{
+		"backup-1.tar.gz",
+		"backup-1.tar.gz.sha256",
+		"backup-2.tar.gz.sha256",
+		".sha256",
+		filepath.Join("nested", "snapshot.manifest"),
+		filepath.Join("nested", "gone.manifest"),
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	orphans, err := OrphanedCompanions(t.Context(), dir, suffixes, false)
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+	require.Equal(t, filepath.Join(dir, "backup-2.tar.gz.sha256"), orphans[0].Path)
+
+	orphans, err = OrphanedCompanions(t.Context(), dir, suffixes, true)
+	require.NoError(t, err)
+	require.Len(t, orphans, 2)
+	require.ElementsMatch(t, []string{
+		filepath.Join(dir, "backup-2.tar.gz.sha256"),
+		filepath.Join(dir, "nested", "gone.manifest"),
+	}, []string{orphans[0].Path, orphans[1].Path})
+}
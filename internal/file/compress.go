@@ -0,0 +1,193 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// Compression formats backups can be compressed with
+const (
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// ErrCompressFile is returned when a backup cannot be compressed
+var ErrCompressFile = files.ErrCompressFile
+
+// CompressionSuffix returns the extension a compression format adds to a
+// file name, or an empty string for an unknown format
+func CompressionSuffix(format string) string {
+	switch format {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// compressedMagic holds the leading bytes of common compressed formats:
+// gzip, zstd, xz, bzip2, zip and 7z
+func compressedMagic() [][]byte {
+	return [][]byte{
+		{0x1f, 0x8b},
+		{0x28, 0xb5, 0x2f, 0xfd},
+		{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		[]byte("BZh"),
+		[]byte("PK\x03\x04"),
+		{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c},
+	}
+}
+
+// IsCompressed reports whether the file at path is already compressed,
+// judged by its leading bytes rather than its name
+func IsCompressed(path string) (bool, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 6)
+
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	for _, magic := range compressedMagic() {
+		if bytes.HasPrefix(head[:n], magic) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CompressFile compresses the backup f with format into a file named after
+// it with the format's suffix, keeping its mode and modification time, and
+// then removes f. The compressed file is written under a temporary name
+// ending in .partial and renamed into place once complete, so an
+// interrupted run never leaves a truncated backup. gzip is compressed
+// in-process, zstd with the zstd command. The returned Info describes the
+// compressed file.
+func CompressFile(ctx context.Context, f Info, format string) (Info, error) {
+	compressed, err := compressFile(ctx, f, format)
+	if err != nil {
+		return Info{}, &files.PathError{Op: files.OpCompress, Path: f.Path, Err: err}
+	}
+
+	return compressed, nil
+}
+
+// compressFile does the work of CompressFile
+func compressFile(ctx context.Context, f Info, format string) (Info, error) {
+	suffix := CompressionSuffix(format)
+	if suffix == "" {
+		return Info{}, fmt.Errorf("unknown compression format %q", format)
+	}
+
+	info, err := os.Lstat(f.Path)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if f.listed != nil && !os.SameFile(info, f.listed) {
+		return Info{}, ErrFileChanged
+	}
+
+	if !info.Mode().IsRegular() {
+		return Info{}, ErrNotRegularFile
+	}
+
+	target := f.Path + suffix
+	if _, err := os.Lstat(target); err == nil {
+		return Info{}, fmt.Errorf("%w: %s", fs.ErrExist, target)
+	}
+
+	err = writePartial(target, info, func(w io.Writer) error {
+		return compressInto(ctx, w, f.Path, format)
+	})
+	if err != nil {
+		return Info{}, err
+	}
+
+	compressedInfo, err := os.Lstat(target)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if err := os.Remove(f.Path); err != nil {
+		return Info{}, err
+	}
+
+	f.Path, f.Size, f.listed = target, compressedInfo.Size(), compressedInfo
+
+	return f, nil
+}
+
+// compressInto writes the file at path compressed with format to w
+func compressInto(ctx context.Context, w io.Writer, path, format string) error {
+	if format == CompressZstd {
+		var stderr bytes.Buffer
+
+		cmd := exec.CommandContext(ctx, "zstd", "-q", "-c", "--", path)
+		cmd.Stdout, cmd.Stderr = w, &stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("zstd: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+
+		return nil
+	}
+
+	in, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gz := gzip.NewWriter(w)
+	gz.Name = filepath.Base(path)
+
+	if _, err := io.Copy(gz, in); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,109 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"a.tar", "b.tar", "b.tar.gz"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.tar"), mtime, mtime))
+
+	manager, err := NewManager(dir, "*.tar", WithSyntax(SyntaxGlob), WithModTime())
+	require.NoError(t, err)
+
+	files, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	byName := map[string]Info{}
+	for _, f := range files {
+		byName[filepath.Base(f.Path)] = f
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		compressed, err := CompressFile(t.Context(), byName["a.tar"], CompressGzip)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "a.tar.gz"), compressed.Path)
+		require.NoFileExists(t, filepath.Join(dir, "a.tar"))
+
+		info, err := os.Stat(compressed.Path)
+		require.NoError(t, err)
+		require.True(t, info.ModTime().Equal(mtime))
+		require.Equal(t, info.Size(), compressed.Size)
+
+		done, err := IsCompressed(compressed.Path)
+		require.NoError(t, err)
+		require.True(t, done)
+
+		f, err := os.Open(compressed.Path)
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		require.Equal(t, "a.tar", string(content))
+	})
+
+	t.Run("never replaces an existing file", func(t *testing.T) {
+		_, err := CompressFile(t.Context(), byName["b.tar"], CompressGzip)
+		require.ErrorIs(t, err, ErrCompressFile)
+		require.FileExists(t, filepath.Join(dir, "b.tar"))
+
+		content, err := os.ReadFile(filepath.Join(dir, "b.tar.gz"))
+		require.NoError(t, err)
+		require.Equal(t, "b.tar.gz", string(content))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := CompressFile(t.Context(), byName["b.tar"], "lz4")
+		require.ErrorIs(t, err, ErrCompressFile)
+		require.FileExists(t, filepath.Join(dir, "b.tar"))
+	})
+
+	t.Run("plain files are not compressed", func(t *testing.T) {
+		done, err := IsCompressed(filepath.Join(dir, "b.tar"))
+		require.NoError(t, err)
+		require.False(t, done)
+	})
+}
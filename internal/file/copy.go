@@ -0,0 +1,137 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyFile copies src to dst, preserving the file mode and modification
+// time. If dst is an existing directory the file is copied into it under its
+// original name. The copy is written to a temporary file and renamed into
+// place so a partially written copy is never visible at dst.
+func CopyFile(src, dst string) (string, error) {
+	if info, err := os.Stat(dst); err == nil && info.IsDir() {
+		dst = filepath.Join(dst, filepath.Base(src))
+	}
+
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", src, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	// Remove the temporary file on any failure below
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, in); err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to set mode on %s: %w", dst, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+
+	if err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return "", fmt.Errorf("failed to set times on %s: %w", dst, err)
+	}
+
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return "", fmt.Errorf("failed to move copy into place: %w", err)
+	}
+
+	committed = true
+
+	return dst, nil
+}
+
+// writePartial writes target with write, giving it the mode and modification
+// time of original. The content goes to a temporary file ending in .partial
+// that is renamed into place once complete, so an interrupted write never
+// leaves a truncated file at target.
+func writePartial(target string, original os.FileInfo, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.partial")
+	if err != nil {
+		return err
+	}
+
+	// Remove the temporary file on any failure below
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+
+	if err := tmp.Chmod(original.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(tmp.Name(), original.ModTime(), original.ModTime()); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+
+	committed = true
+
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup-20250101000000.zip")
+	require.NoError(t, os.WriteFile(src, []byte("payload"), 0o640))
+
+	mtime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(src, mtime, mtime))
+
+	t.Run("to file", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "restore.zip")
+
+		got, err := CopyFile(src, dst)
+		require.NoError(t, err)
+		require.Equal(t, dst, got)
+
+		content, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		require.Equal(t, "payload", string(content))
+
+		info, err := os.Stat(dst)
+		require.NoError(t, err)
+		require.True(t, mtime.Equal(info.ModTime()))
+	})
+
+	t.Run("into directory", func(t *testing.T) {
+		target := t.TempDir()
+
+		got, err := CopyFile(src, target)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(target, filepath.Base(src)), got)
+
+		entries, err := os.ReadDir(target)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "temporary file left behind")
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		_, err := CopyFile(filepath.Join(dir, "missing.zip"), t.TempDir())
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
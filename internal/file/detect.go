@@ -0,0 +1,164 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"cmp"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoPatternDetected is returned when no file name contains a timestamp
+var ErrNoPatternDetected = errors.New("no timestamp detected in file names")
+
+// timestampRegex finds date and optional time components in a file name.
+// Separators between components are optional so compact timestamps such as
+// 20240315T1200 are recognized too.
+var timestampRegex = regexp.MustCompile(
+	`(\d{4})([-_.]?)(\d{2})([-_.]?)(\d{2})` +
+		`(?:([-_T.]?)(\d{2})([-_:.]?)(\d{2})(?:([-_:.]?)(\d{2}))?)?`,
+)
+
+// PatternCandidate is a proposed file pattern and how many names it matches
+type PatternCandidate struct {
+	Pattern string
+	Matches int
+}
+
+// DetectPattern analyzes file names and proposes file patterns for them,
+// most common first. Names without a recognizable timestamp are ignored.
+func DetectPattern(names []string) ([]PatternCandidate, error) {
+	counts := map[string]int{}
+
+	for _, name := range names {
+		if pattern, ok := patternFor(name); ok {
+			counts[pattern]++
+		}
+	}
+
+	if len(counts) == 0 {
+		return nil, ErrNoPatternDetected
+	}
+
+	candidates := make([]PatternCandidate, 0, len(counts))
+	for pattern, matches := range counts {
+		candidates = append(candidates, PatternCandidate{Pattern: pattern, Matches: matches})
+	}
+
+	slices.SortFunc(candidates, func(a, b PatternCandidate) int {
+		if c := cmp.Compare(b.Matches, a.Matches); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+
+	return candidates, nil
+}
+
+// patternFor returns the file pattern for a single name, replacing the
+// first valid timestamp with placeholders
+func patternFor(name string) (string, bool) {
+	for _, loc := range timestampRegex.FindAllStringSubmatchIndex(name, -1) {
+		group := func(i int) string {
+			if loc[2*i] < 0 {
+				return ""
+			}
+
+			return name[loc[2*i]:loc[2*i+1]]
+		}
+
+		if !inRange(group(1), 1900, 2999) || !inRange(group(3), 1, 12) ||
+			!inRange(group(5), 1, 31) {
+			continue
+		}
+
+		var pattern strings.Builder
+
+		pattern.WriteString(name[:loc[0]])
+		pattern.WriteString("{year}" + group(2) + "{month}" + group(4) + "{day}")
+
+		// Anything after the date that is not a valid time stays literal
+		end := loc[11]
+
+		if loc[14] >= 0 && inRange(group(7), 0, 23) && inRange(group(9), 0, 59) {
+			pattern.WriteString(group(6) + "{hour}" + group(8) + "{minute}")
+			end = loc[19]
+
+			if loc[22] >= 0 && inRange(group(11), 0, 59) {
+				pattern.WriteString(group(10) + "{second}")
+				end = loc[23]
+			}
+		}
+
+		pattern.WriteString(name[end:])
+
+		return pattern.String(), true
+	}
+
+	return "", false
+}
+
+// NameTimestamp returns the first valid timestamp in a name, such as a
+// backup directory named after the time it was taken. Missing time
+// components are zero and, like timestamps parsed with a file pattern, the
+// timestamp is in UTC.
+func NameTimestamp(name string) (time.Time, bool) {
+	for _, m := range timestampRegex.FindAllStringSubmatch(name, -1) {
+		if !inRange(m[1], 1900, 2999) || !inRange(m[3], 1, 12) || !inRange(m[5], 1, 31) {
+			continue
+		}
+
+		clock := []string{"00", "00", "00"}
+		if inRange(m[7], 0, 23) && inRange(m[9], 0, 59) {
+			clock = []string{m[7], m[9], "00"}
+
+			if inRange(m[11], 0, 59) {
+				clock[2] = m[11]
+			}
+		}
+
+		timestamp, err := time.Parse(
+			"2006-01-02 15:04:05",
+			m[1]+"-"+m[3]+"-"+m[5]+" "+strings.Join(clock, ":"),
+		)
+		if err == nil {
+			return timestamp, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// inRange reports whether s is a number between lower and upper inclusive
+func inRange(s string, lower, upper int) bool {
+	n, err := strconv.Atoi(s)
+
+	return err == nil && n >= lower && n <= upper
+}
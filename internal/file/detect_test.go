@@ -0,0 +1,130 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPattern(t *testing.T) {
+	t.Run("single pattern", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			file    string
+			pattern string
+		}{
+			{
+				name:    "date",
+				file:    "backup-2024-03-15.tar.gz",
+				pattern: "backup-{year}-{month}-{day}.tar.gz",
+			},
+			{
+				name:    "date and time",
+				file:    "backup-2024-03-15-12-30.tar.gz",
+				pattern: "backup-{year}-{month}-{day}-{hour}-{minute}.tar.gz",
+			},
+			{
+				name:    "compact with seconds",
+				file:    "db_20240315T123045.sql",
+				pattern: "db_{year}{month}{day}T{hour}{minute}{second}.sql",
+			},
+			{
+				name:    "invalid time kept literal",
+				file:    "backup-2024-03-15-99.tar.gz",
+				pattern: "backup-{year}-{month}-{day}-99.tar.gz",
+			},
+			{
+				name:    "regex characters kept literal",
+				file:    "backup(1)-2024.03.15.zip",
+				pattern: "backup(1)-{year}.{month}.{day}.zip",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				candidates, err := DetectPattern([]string{tc.file})
+				require.NoError(t, err)
+				require.Equal(t, []PatternCandidate{{Pattern: tc.pattern, Matches: 1}}, candidates)
+
+				m, err := NewManager("", tc.pattern)
+				require.NoError(t, err)
+				require.Regexp(t, m.filePattern, tc.file)
+			})
+		}
+	})
+
+	t.Run("most common first", func(t *testing.T) {
+		candidates, err := DetectPattern([]string{
+			"backup-2024-03-15.tar.gz",
+			"backup-2024-03-14.tar.gz",
+			"backup-2024-03-13.tar.gz",
+			"db-20240315.sql",
+			"README.txt",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []PatternCandidate{
+			{Pattern: "backup-{year}-{month}-{day}.tar.gz", Matches: 3},
+			{Pattern: "db-{year}{month}{day}.sql", Matches: 1},
+		}, candidates)
+	})
+
+	t.Run("no timestamps", func(t *testing.T) {
+		_, err := DetectPattern([]string{"README.txt", "notes-1234.txt"})
+		require.ErrorIs(t, err, ErrNoPatternDetected)
+	})
+}
+
+func TestNameTimestamp(t *testing.T) {
+	testCases := []struct {
+		name      string
+		timestamp time.Time
+		ok        bool
+	}{
+		{name: "2024-03-15", timestamp: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), ok: true},
+		{
+			name:      "2024-03-15T12:30",
+			timestamp: time.Date(2024, 3, 15, 12, 30, 0, 0, time.UTC),
+			ok:        true,
+		},
+		{
+			name:      "host-20240315_123045",
+			timestamp: time.Date(2024, 3, 15, 12, 30, 45, 0, time.UTC),
+			ok:        true,
+		},
+		{name: "daily.0"},
+		{name: "backup-2024-13-45"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			timestamp, ok := NameTimestamp(tc.name)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.timestamp, timestamp)
+		})
+	}
+}
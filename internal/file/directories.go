@@ -0,0 +1,143 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNoDirectoryMatched is returned when a directory glob matches nothing
+var ErrNoDirectoryMatched = errors.New("directory glob matched no directories")
+
+// listBatchSize is the number of directory entries read at a time, so a
+// directory is listed in pages rather than read into memory at once
+const listBatchSize = 1024
+
+// extendedLengthPrefix is the Windows extended-length path prefix, whose
+// question mark must not be mistaken for a glob
+const extendedLengthPrefix = `\\?\`
+
+// ExpandDirectories expands glob patterns such as /srv/backups/*/daily into
+// the directories they match. Entries without glob characters are returned
+// as is. Matches that are not directories are ignored, and duplicates are
+// removed while keeping the order of the patterns. A glob matching no
+// directory is reported as ErrNoDirectoryMatched.
+func ExpandDirectories(patterns []string) ([]string, error) {
+	return expandDirectories(patterns, true)
+}
+
+// MatchDirectories expands glob patterns like ExpandDirectories, except
+// that patterns matching no directory are ignored, including entries
+// without glob characters
+func MatchDirectories(patterns []string) ([]string, error) {
+	return expandDirectories(patterns, false)
+}
+
+// expandDirectories expands patterns into the directories they match,
+// failing when a glob matches none if required is set
+func expandDirectories(patterns []string, required bool) ([]string, error) {
+	var directories []string
+
+	for _, pattern := range patterns {
+		if !hasGlobMeta(pattern) && required {
+			if !slices.Contains(directories, pattern) {
+				directories = append(directories, pattern)
+			}
+
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidPattern, pattern, err)
+		}
+
+		found := false
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+
+			found = true
+
+			if !slices.Contains(directories, match) {
+				directories = append(directories, match)
+			}
+		}
+
+		if !found && required {
+			return nil, fmt.Errorf("%w: %q", ErrNoDirectoryMatched, pattern)
+		}
+	}
+
+	return directories, nil
+}
+
+// hasGlobMeta reports whether path contains glob characters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(strings.TrimPrefix(path, extendedLengthPrefix), `*?[`)
+}
+
+// ForEachEntry calls fn for every entry of dir, in directory order. The
+// directory is read in pages of listBatchSize entries, so directories with
+// millions of entries are never held in memory at once.
+func ForEachEntry(dir string, fn func(os.DirEntry) error) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return forEachEntryIn(f, fn)
+}
+
+// forEachEntryIn is ForEachEntry for a directory that is already open
+func forEachEntryIn(f *os.File, fn func(os.DirEntry) error) error {
+	for {
+		entries, err := f.ReadDir(listBatchSize)
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandDirectories(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{"acme/daily", "globex/daily", "initech/weekly"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o750))
+	}
+
+	err := os.WriteFile(filepath.Join(root, "stray"), nil, 0o600)
+	require.NoError(t, err)
+
+	t.Run("glob", func(t *testing.T) {
+		dirs, err := ExpandDirectories([]string{filepath.Join(root, "*", "daily")})
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join(root, "acme", "daily"),
+			filepath.Join(root, "globex", "daily"),
+		}, dirs)
+	})
+
+	t.Run("files ignored", func(t *testing.T) {
+		dirs, err := ExpandDirectories([]string{filepath.Join(root, "*")})
+		require.NoError(t, err)
+		require.Len(t, dirs, 3)
+		require.NotContains(t, dirs, filepath.Join(root, "stray"))
+	})
+
+	t.Run("plain paths and duplicates", func(t *testing.T) {
+		acme := filepath.Join(root, "acme", "daily")
+		missing := filepath.Join(root, "missing")
+
+		dirs, err := ExpandDirectories([]string{
+			missing,
+			acme,
+			filepath.Join(root, "a*", "daily"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{missing, acme}, dirs)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		_, err := ExpandDirectories([]string{filepath.Join(root, "*", "hourly")})
+		require.ErrorIs(t, err, ErrNoDirectoryMatched)
+	})
+
+	t.Run("invalid glob", func(t *testing.T) {
+		_, err := ExpandDirectories([]string{filepath.Join(root, "[")})
+		require.ErrorIs(t, err, ErrInvalidPattern)
+	})
+
+	t.Run("match", func(t *testing.T) {
+		dirs, err := MatchDirectories([]string{
+			filepath.Join(root, "*", "hourly"),
+			filepath.Join(root, "missing"),
+			filepath.Join(root, "stray"),
+			filepath.Join(root, "initech", "weekly"),
+			filepath.Join(root, "*", "daily"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join(root, "initech", "weekly"),
+			filepath.Join(root, "acme", "daily"),
+			filepath.Join(root, "globex", "daily"),
+		}, dirs)
+	})
+}
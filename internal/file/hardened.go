@@ -0,0 +1,219 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// WithHardenedListing opens the backup directory once and lists, stats,
+// checks and deletes every backup relative to that handle, so renaming or
+// replacing the directory or one of its parents mid-run cannot redirect the
+// run to other files. Entries are looked up with Platform.StatAt, which is
+// only implemented on Linux; elsewhere listing fails with ErrNotImplemented.
+// The handle is held until Close.
+func WithHardenedListing() ManagerOption {
+	return func(m *Manager) {
+		m.hardened = true
+	}
+}
+
+// openRoot returns the handle of the backup directory, opening it on first
+// use. The handle is kept for the life of the Manager, so the listing and
+// the deletions that follow it operate on the same directory.
+func (m *Manager) openRoot() (*os.Root, error) {
+	m.rootMu.Lock()
+	defer m.rootMu.Unlock()
+
+	if m.root != nil {
+		return m.root, nil
+	}
+
+	r, err := os.OpenRoot(m.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	m.root = r
+
+	return r, nil
+}
+
+// Close releases the handle of the backup directory held in hardened mode.
+// A Manager used after Close opens the directory again.
+func (m *Manager) Close() error {
+	m.rootMu.Lock()
+	defer m.rootMu.Unlock()
+
+	if m.root == nil {
+		return nil
+	}
+
+	err := m.root.Close()
+	m.root = nil
+
+	return err
+}
+
+// withRoot calls fn with the backup directory opened as an os.Root, the
+// handle kept by openRoot in hardened mode or one opened for the call
+func (m *Manager) withRoot(fn func(*os.Root) error) error {
+	if !m.hardened {
+		return inRoot(m.directory, fn)
+	}
+
+	r, err := m.openRoot()
+	if err != nil {
+		return err
+	}
+
+	return fn(r)
+}
+
+// relative returns path relative to the backup directory. In hardened mode
+// the path is only checked lexically, since it is never resolved again;
+// otherwise it is resolved by RelativeToRoot.
+func (m *Manager) relative(path string) (string, error) {
+	if !m.hardened {
+		return RelativeToRoot(m.directory, path)
+	}
+
+	rel, err := filepath.Rel(m.directory, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s is not under %s", ErrOutsideRoot, path, m.directory)
+	}
+
+	return rel, nil
+}
+
+// checkWriteAccess checks that the backup at path, rel relative to the
+// backup directory, may be deleted. In hardened mode the check is made
+// relative to the handle of its parent directory rather than by path.
+func (m *Manager) checkWriteAccess(path, rel string) error {
+	if !m.hardened {
+		return m.platform.CheckWriteAccess(path)
+	}
+
+	return m.withRoot(func(r *os.Root) error {
+		dir, err := r.Open(filepath.Dir(rel))
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+
+		return m.platform.CheckWriteAccessAt(dir, filepath.Base(rel))
+	})
+}
+
+// lstat returns the file info of path without following a symlink, looked
+// up relative to the handle of the backup directory in hardened mode
+func (m *Manager) lstat(path string) (os.FileInfo, error) {
+	if !m.hardened {
+		return os.Lstat(path)
+	}
+
+	rel, err := m.relative(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := m.openRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Lstat(rel)
+}
+
+// walkRoot is walkTree for hardened mode: every directory below the backup
+// directory is opened relative to its handle and every entry is looked up
+// relative to the open directory holding it
+func (m *Manager) walkRoot(
+	ctx context.Context,
+	rel string,
+	fn func(path string, d os.DirEntry) error,
+	denied func(path string, err error),
+) error {
+	r, err := m.openRoot()
+	if err != nil {
+		return err
+	}
+
+	dir, err := r.Open(rel)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return forEachEntryIn(dir, func(entry os.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := filepath.Join(rel, entry.Name())
+		path := filepath.Join(m.directory, name)
+
+		if err := fn(path, &entryAt{DirEntry: entry, dir: dir, platform: m.platform}); err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		err := m.walkRoot(ctx, name, fn, denied)
+		if isDenied(err, name) {
+			denied(path, err)
+			return nil
+		}
+
+		return err
+	})
+}
+
+// entryAt is a directory entry whose file info is read relative to the open
+// directory holding it rather than by path
+type entryAt struct {
+	os.DirEntry
+
+	dir      *os.File
+	platform files.Platform
+}
+
+// Info returns the file info of the entry, without following a symlink
+func (e *entryAt) Info() (os.FileInfo, error) {
+	info, err := e.platform.StatAt(e.dir, e.Name())
+	if errors.Is(err, files.ErrNotImplemented) {
+		return nil, fmt.Errorf("hardened listing: %w", err)
+	}
+
+	return info, err
+}
@@ -0,0 +1,124 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// unstatablePlatform is a Platform that cannot stat relative to an open
+// directory
+type unstatablePlatform struct {
+	files.Platform
+}
+
+func (unstatablePlatform) StatAt(*os.File, string) (os.FileInfo, error) {
+	return nil, files.ErrNotImplemented
+}
+
+func TestHardenedListing(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("hardened listing is only implemented on Linux")
+	}
+
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "backups")
+
+	populate := func(t *testing.T, dir string) {
+		t.Helper()
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o750))
+
+		for _, name := range []string{
+			"backup-2024-01-01.zip",
+			"backup-2024-01-02.zip",
+			"backup-2024-01-02.zip" + HoldSuffix,
+			filepath.Join("sub", "backup-2024-01-03.zip"),
+		} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+		}
+	}
+	populate(t, dir)
+
+	m, err := NewManager(dir, "backup-{year}-{month}-{day}.zip",
+		WithBasename(), WithHardenedListing())
+	require.NoError(t, err)
+
+	listed, err := m.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, listed, 3)
+	require.False(t, listed[0].Pinned)
+	require.True(t, listed[1].Pinned)
+	require.Equal(t, filepath.Join(dir, "sub", "backup-2024-01-03.zip"), listed[2].Path)
+
+	// Swap the directory for another holding the same names mid-run
+	moved := filepath.Join(parent, "moved")
+	require.NoError(t, os.Rename(dir, moved))
+	populate(t, dir)
+
+	for _, f := range []Info{listed[0], listed[2]} {
+		require.NoError(t, m.DeleteFile(t.Context(), f, false))
+	}
+
+	// Only the directory that was listed is pruned
+	require.NoFileExists(t, filepath.Join(moved, "backup-2024-01-01.zip"))
+	require.NoFileExists(t, filepath.Join(moved, "sub", "backup-2024-01-03.zip"))
+	require.FileExists(t, filepath.Join(dir, "backup-2024-01-01.zip"))
+	require.FileExists(t, filepath.Join(dir, "sub", "backup-2024-01-03.zip"))
+
+	// Paths outside the directory are refused without being resolved
+	err = m.DeleteFile(t.Context(), Info{Path: filepath.Join(parent, "other.zip")}, false)
+	require.ErrorIs(t, err, ErrOutsideRoot)
+
+	// Closing releases the handle, and the next listing opens the directory
+	// at the configured path again
+	require.NoError(t, m.Close())
+	require.Nil(t, m.root)
+	require.NoError(t, m.Close())
+
+	listed, err = m.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, listed, 3)
+	require.NoError(t, m.Close())
+}
+
+func TestHardenedListingNotImplemented(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "backup-2024-01-01.zip"), nil, 0o600))
+
+	m, err := NewManager(dir, "backup-{year}-{month}-{day}.zip",
+		WithPlatform(unstatablePlatform{files.NewPlatform()}), WithHardenedListing())
+	require.NoError(t, err)
+
+	_, err = m.ListFiles(t.Context())
+	require.ErrorIs(t, err, files.ErrNotImplemented)
+}
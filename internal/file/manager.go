@@ -31,33 +31,91 @@
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
 	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
 )
 
-// Common errors
+// Common errors, defined in the files package so that code outside this
+// module can test for them
 var (
-	ErrInvalidPattern = errors.New("invalid file pattern")
-	ErrListFiles      = errors.New("failed to list files")
-	ErrParseTimestamp = errors.New("failed to parse timestamp")
-	ErrDeleteFile     = errors.New("failed to delete file")
-	ErrNotRegularFile = errors.New("not a regular file")
-	ErrAccessDenied   = errors.New("access denied")
+	ErrInvalidPattern = files.ErrInvalidPattern
+	ErrListFiles      = files.ErrListFiles
+	ErrParseTimestamp = files.ErrParseTimestamp
+	ErrDeleteFile     = files.ErrDeleteFile
+	ErrNotRegularFile = files.ErrNotRegularFile
+	ErrAccessDenied   = files.ErrAccessDenied
 )
 
+// HoldSuffix is appended to a backup's path to form its hold marker. A backup
+// with an existing hold marker is pinned and never deleted.
+const HoldSuffix = ".hold"
+
 // Info represents a backup file with its parsed timestamp
 type Info struct {
 	Path      string
 	Timestamp time.Time
 	Size      int64
+	// ModTime is the modification time of the file, where the backend
+	// reports one
+	ModTime time.Time
+	// Tag is the value captured by the {tag} pattern token, if any
+	Tag string
+	// Seq is the number captured by the {seq} pattern token, ordering
+	// versions of a backup that share a timestamp, or zero
+	Seq int
+	// Pinned files are protected from deletion by a hold marker or pin
+	Pinned bool
+	// DependsOn is the path of the backup this one cannot be restored
+	// without, such as the base of an incremental backup
+	DependsOn string
+	// Checksum is the hex encoded SHA-256 of the file, where it was taken
+	// before the file was deleted
+	Checksum string
+
+	// listed is the file info the file was listed with, used to confirm the
+	// path still names the same file when it is deleted
+	listed os.FileInfo
+}
+
+// SkipReason describes why a directory entry was not considered a backup
+type SkipReason string
+
+// Reasons reported for entries that are skipped while scanning
+const (
+	SkipNoMatch      SkipReason = "pattern did not match"
+	SkipSymlink      SkipReason = "symlink"
+	SkipNonRegular   SkipReason = "not a regular file"
+	SkipNoTimestamp  SkipReason = "timestamp could not be parsed"
+	SkipNoInfo       SkipReason = "file info unavailable"
+	SkipOwner        SkipReason = "owned by another user or group"
+	SkipNoBackupInfo SkipReason = "backup-info.json missing or invalid"
+	SkipAccessDenied SkipReason = "permission denied"
+)
+
+// Skipped records a directory entry that was ignored while scanning
+type Skipped struct {
+	Path   string
+	Reason SkipReason
+	Err    error
+}
+
+// ScanResult holds the matched backups and the entries that were skipped
+type ScanResult struct {
+	Files   []Info
+	Skipped []Skipped
 }
 
 // ManagerOption is a function that configures a Manager
@@ -66,8 +124,26 @@ type Info struct {
 // Manager handles file operations for the retention policy
 type Manager struct {
 	logger      *logging.Logger
+	platform    files.Platform
 	directory   string
 	filePattern *regexp.Regexp
+	pins        []string
+	modTime     bool
+	basename    bool
+	ignoreCase  bool
+	syntax      Syntax
+	suffixes    []string
+	uid         string
+	gid         string
+	xattr       string
+	backupInfo  bool
+	infoLabels  []string
+	warn        func(Warning)
+	hardened    bool
+
+	// rootMu guards root, the handle of the directory in hardened mode
+	rootMu sync.Mutex
+	root   *os.Root
 }
 
 // WithLogger sets the logger for the Manager
@@ -77,46 +153,76 @@ func WithLogger(logger *logging.Logger) ManagerOption {
 	}
 }
 
-// NewManager creates a new file manager
+// WithPlatform sets the platform used for filesystem permission checks
+func WithPlatform(platform files.Platform) ManagerOption {
+	return func(m *Manager) {
+		m.platform = platform
+	}
+}
+
+// WithPins sets glob patterns, relative to the directory, of files that are
+// pinned and must never be deleted
+func WithPins(pins []string) ManagerOption {
+	return func(m *Manager) {
+		m.pins = pins
+	}
+}
+
+// WithModTime dates files by their modification time instead of the
+// timestamp in their name, for patterns that carry no date
+func WithModTime() ManagerOption {
+	return func(m *Manager) {
+		m.modTime = true
+	}
+}
+
+// WithBasename matches the pattern against the file name alone rather than
+// the path relative to the directory, so backups in subdirectories match a
+// pattern that does not name the subdirectories
+func WithBasename() ManagerOption {
+	return func(m *Manager) {
+		m.basename = true
+	}
+}
+
+// WithIgnoreCase matches the pattern case-insensitively
+func WithIgnoreCase() ManagerOption {
+	return func(m *Manager) {
+		m.ignoreCase = true
+	}
+}
+
+// WithSyntax sets how the text around the tokens of the pattern is read,
+// SyntaxTokens by default
+func WithSyntax(syntax Syntax) ManagerOption {
+	return func(m *Manager) {
+		m.syntax = syntax
+	}
+}
+
+// WithOptionalSuffixes lets files carry one of suffixes after the part
+// matched by the pattern, such as the extension compressing a backup adds
+func WithOptionalSuffixes(suffixes ...string) ManagerOption {
+	return func(m *Manager) {
+		m.suffixes = suffixes
+	}
+}
+
+// NewManager creates a new file manager. The pattern is rejected with a
+// PatternTokenError if a token appears twice, or if it lacks {year} while
+// files are dated by their name rather than WithModTime,
+// WithTimestampXattr or WithBackupInfo.
 func NewManager(
 	directory, pattern string,
 	opts ...ManagerOption,
 ) (*Manager, error) {
-	// Convert the pattern to a regex pattern
-	// Replace {year}, {month}, etc. with regex patterns
-	regexPattern := pattern
-	regexPattern = strings.ReplaceAll(regexPattern, "{year}", `(?P<year>\d{4})`)
-	regexPattern = strings.ReplaceAll(
-		regexPattern,
-		"{month}",
-		`(?P<month>\d{2})`,
-	)
-	regexPattern = strings.ReplaceAll(regexPattern, "{day}", `(?P<day>\d{2})`)
-	regexPattern = strings.ReplaceAll(regexPattern, "{hour}", `(?P<hour>\d{2})`)
-	regexPattern = strings.ReplaceAll(
-		regexPattern,
-		"{minute}",
-		`(?P<minute>\d{2})`,
-	)
-	regexPattern = strings.ReplaceAll(
-		regexPattern,
-		"{second}",
-		`(?P<second>\d{2})`,
-	)
-	regexPattern = "^" + regexPattern + "$"
-
-	compiledPattern, err := regexp.Compile(regexPattern)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
-	}
-
 	// Create manager with default values
 	m := &Manager{
 		logger: &logging.Logger{
 			Logger: zap.NewNop(),
 		}, // Default no-op logger
-		directory:   directory,
-		filePattern: compiledPattern,
+		platform:  files.NewPlatform(),
+		directory: directory,
 	}
 
 	// Apply options
@@ -124,6 +230,40 @@ func NewManager(
 		opt(m)
 	}
 
+	if err := checkTokens(pattern, !m.modTime && m.xattr == "" && !m.backupInfo); err != nil {
+		return nil, err
+	}
+
+	// Replace {year}, {month}, etc. with regex patterns
+	regexPattern, err := patternRegex(pattern, m.syntax)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+	}
+
+	if len(m.suffixes) > 0 {
+		quoted := make([]string, len(m.suffixes))
+		for i, suffix := range m.suffixes {
+			quoted[i] = regexp.QuoteMeta(suffix)
+		}
+
+		regexPattern = fmt.Sprintf("%s(?:%s)?$",
+			strings.TrimSuffix(regexPattern, "$"), strings.Join(quoted, "|"))
+	}
+
+	if m.ignoreCase {
+		regexPattern = "(?i)" + regexPattern
+	}
+
+	compiledPattern, err := regexp.Compile(regexPattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+	}
+
+	m.filePattern = compiledPattern
+
+	// Normalize after options so a custom platform is honored
+	m.directory = m.platform.NormalizePath(m.directory)
+
 	return m, nil
 }
 
@@ -150,19 +290,32 @@ func (m *Manager) DeleteFile(
 		return nil
 	}
 
+	// Refuse anything that does not reside under the backup directory, such
+	// as a path reached through a symlinked parent directory
+	rel, err := m.relative(file.Path)
+	if err != nil {
+		return err
+	}
+
 	// Verify file safety before deletion
 	if err := m.isRegularFile(file.Path); err != nil {
 		return err
 	}
 
+	// Check permissions up front so read-only files are reported as access
+	// denied instead of being removed by a writable parent directory
+	if err := m.checkWriteAccess(file.Path, rel); err != nil {
+		return &files.PathError{Op: files.OpAccess, Path: file.Path, Err: err}
+	}
+
 	// Attempt to delete the file
-	if err := os.Remove(file.Path); err != nil {
+	if err := m.removeFile(rel, file.listed); err != nil {
 		// Check for permission denied
 		if os.IsPermission(err) {
-			return fmt.Errorf("%w %s: %w", ErrAccessDenied, file.Path, err)
+			return &files.PathError{Op: files.OpAccess, Path: file.Path, Err: err}
 		}
 
-		return fmt.Errorf("%w %s: %w", ErrDeleteFile, file.Path, err)
+		return &files.PathError{Op: files.OpDelete, Path: file.Path, Err: err}
 	}
 
 	// Log the successful deletion
@@ -177,38 +330,215 @@ func (m *Manager) DeleteFile(
 
 // ListFiles lists all files in the directory that match the pattern
 func (m *Manager) ListFiles(ctx context.Context) ([]Info, error) {
+	result, err := m.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Files, nil
+}
+
+// WalkFiles calls fn for every file in the directory that matches the
+// pattern, in directory order. Entries are read in pages and never
+// accumulated, so directories holding millions of backups are listed with
+// bounded memory.
+func (m *Manager) WalkFiles(ctx context.Context, fn func(Info) error) error {
+	return m.walk(ctx, fn, func(string, SkipReason, error) {})
+}
+
+// Scan walks the directory and returns the matching files together with
+// every entry that was skipped and the reason it was skipped
+func (m *Manager) Scan(ctx context.Context) (*ScanResult, error) {
+	result := &ScanResult{}
+
+	err := m.walk(ctx, func(f Info) error {
+		result.Files = append(result.Files, f)
+		return nil
+	}, result.skip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort files by timestamp (oldest first)
+	err = sortContext(ctx, result.Files, func(a, b Info) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListFiles, err)
+	}
+
+	err = sortContext(ctx, result.Skipped, func(a, b Skipped) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListFiles, err)
+	}
+
+	return result, nil
+}
+
+// walk lists the directory, calling found for every matching file and skip
+// for every entry that is not a backup. Skipped entries worth a warning are
+// also passed to the function set with WithWarnings.
+func (m *Manager) walk(
+	ctx context.Context,
+	found func(Info) error,
+	skip func(path string, reason SkipReason, err error),
+) error {
+	if m.warn != nil {
+		skip = m.warning(skip)
+	}
+
 	// Check for context cancellation first
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return ctx.Err()
 	default:
 	}
 
-	var files []Info
+	process := func(path string, d os.DirEntry) error {
+		return m.processFile(ctx, path, d, found, skip)
+	}
 
-	err := filepath.WalkDir(m.directory, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
+	denied := func(path string, err error) {
+		m.logger.Warn("permission denied",
+			zap.String("directory", path),
+			zap.Error(err))
+		skip(path, SkipAccessDenied, err)
+	}
+
+	var err error
+	if m.hardened {
+		err = m.walkRoot(ctx, ".", process, denied)
+	} else {
+		err = walkTree(ctx, m.directory, process, denied)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrListFiles, err)
+	}
+
+	return nil
+}
+
+// walkDir calls fn for every entry below dir, descending into
+// subdirectories but not into symlinks. The walk stops once ctx is done,
+// checked before every entry.
+func walkDir(ctx context.Context, dir string, fn func(path string, d os.DirEntry) error) error {
+	return walkTree(ctx, dir, fn, nil)
+}
+
+// walkTree is walkDir, except that a subdirectory that cannot be opened for
+// lack of permission is passed to denied, when set, instead of stopping the
+// walk
+func walkTree(
+	ctx context.Context,
+	dir string,
+	fn func(path string, d os.DirEntry) error,
+	denied func(path string, err error),
+) error {
+	return ForEachEntry(dir, func(entry os.DirEntry) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		return m.processFile(ctx, path, d, &files)
+		path := filepath.Join(dir, entry.Name())
+
+		if err := fn(path, entry); err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		err := walkTree(ctx, path, fn, denied)
+		if denied != nil && isDenied(err, path) {
+			denied(path, err)
+			return nil
+		}
+
+		return err
 	})
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrListFiles, err)
+}
+
+// isDenied reports whether err is the failure to open the directory path for
+// lack of permission, rather than an error from below it
+func isDenied(err error, path string) bool {
+	var pathErr *fs.PathError
+
+	return errors.As(err, &pathErr) && pathErr.Path == path && errors.Is(err, fs.ErrPermission)
+}
+
+// sortInBackground is the length from which sortContext sorts in the
+// background; shorter slices sort too quickly to be worth abandoning
+const sortInBackground = 10000
+
+// sortContext sorts s with cmp like slices.SortFunc, but returns ctx.Err()
+// soon after ctx is done instead of waiting for every comparison. Once ctx
+// is done the rest of the sort compares every element as equal, which takes
+// a fraction of the time, and s is no longer written to when sortContext
+// returns. The contents of s are undefined after an error.
+func sortContext[S ~[]E, E any](ctx context.Context, s S, cmp func(a, b E) int) error {
+	if len(s) < sortInBackground {
+		slices.SortFunc(s, cmp)
+		return ctx.Err()
 	}
 
-	// Sort files by timestamp (newest first)
-	slices.SortFunc(files, func(a, b Info) int {
-		return a.Timestamp.Compare(b.Timestamp)
-	})
+	var (
+		stopped atomic.Bool
+		done    = make(chan struct{})
+	)
+
+	go func() {
+		defer close(done)
+
+		slices.SortFunc(s, func(a, b E) int {
+			if stopped.Load() {
+				return 0
+			}
+
+			return cmp(a, b)
+		})
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		stopped.Store(true)
+		<-done
 
-	return files, nil
+		return ctx.Err()
+	}
+}
+
+// removeFile deletes rel, a path relative to the backup directory. Where
+// the platform supports it the file is confirmed to be the one that was
+// listed and unlinked relative to its open parent directory, which narrows
+// the window for it to be replaced to the moment between the check and the
+// unlink; elsewhere it is removed through an os.Root.
+func (m *Manager) removeFile(rel string, listed os.FileInfo) error {
+	return m.withRoot(func(r *os.Root) error {
+		dir, err := r.Open(filepath.Dir(rel))
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+
+		err = m.platform.RemoveFile(dir, filepath.Base(rel), listed)
+		if errors.Is(err, files.ErrNotImplemented) {
+			return r.Remove(rel)
+		}
+
+		return err
+	})
 }
 
 // isRegularFile checks if the file is a regular file
 func (m *Manager) isRegularFile(path string) error {
 	// Get file info
-	info, err := os.Lstat(path)
+	info, err := m.lstat(path)
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrDeleteFile, err)
 	}
@@ -222,12 +552,14 @@ func (m *Manager) isRegularFile(path string) error {
 	return nil
 }
 
-// processFile processes a single file and adds it to the files slice if it matches the pattern
+// processFile processes a single file and passes it to found if it matches
+// the pattern, or to skip with the reason it was skipped
 func (m *Manager) processFile(
 	ctx context.Context,
 	path string,
 	d os.DirEntry,
-	files *[]Info,
+	found func(Info) error,
+	skip func(path string, reason SkipReason, err error),
 ) error {
 	// Check for context cancellation
 	select {
@@ -241,6 +573,10 @@ func (m *Manager) processFile(
 		m.logger.Debug("ignoring dir or symlink",
 			zap.String("file", path))
 
+		if !d.IsDir() {
+			skip(path, SkipSymlink, nil)
+		}
+
 		return nil
 	}
 
@@ -251,20 +587,31 @@ func (m *Manager) processFile(
 	}
 
 	// Check if the file matches our pattern
-	matches := m.filePattern.FindStringSubmatch(relPath)
+	name := relPath
+	if m.basename {
+		name = filepath.Base(relPath)
+	}
+
+	matches := m.filePattern.FindStringSubmatch(name)
 	if matches == nil {
 		m.logger.Debug("file not matched",
 			zap.String("file", relPath))
+		skip(path, SkipNoMatch, nil)
 
 		return nil
 	}
 
 	// Get file info for size and type
 	info, err := d.Info()
+	if errors.Is(err, files.ErrNotImplemented) {
+		return fmt.Errorf("%s: %w", relPath, err)
+	}
+
 	if err != nil {
 		m.logger.Warn("failed to get file info",
 			zap.String("file", relPath),
 			zap.Error(err))
+		skip(path, SkipNoInfo, err)
 
 		return nil
 	}
@@ -274,27 +621,165 @@ func (m *Manager) processFile(
 		m.logger.Debug("skipping non-regular file",
 			zap.String("file", relPath),
 			zap.String("mode", info.Mode().String()))
+		skip(path, SkipNonRegular, nil)
 
 		return nil
 	}
 
-	// Parse the timestamp from the filename
-	timestamp, err := m.parseTimestamp(matches, m.filePattern.SubexpNames())
+	owned, err := m.ownedBy(info)
 	if err != nil {
-		m.logger.Warn("failed to parse timestamp from filename",
+		return fmt.Errorf("%s: %w", relPath, err)
+	}
+
+	if !owned {
+		m.logger.Debug("skipping file owned by another user or group",
+			zap.String("file", relPath))
+		skip(path, SkipOwner, nil)
+
+		return nil
+	}
+
+	tag := m.captured(matches, "tag")
+
+	var described time.Time
+
+	if m.backupInfo {
+		// Sidecar files describe a backup rather than being one
+		if strings.HasSuffix(path, "."+BackupInfoName) {
+			skip(path, SkipNoMatch, nil)
+			return nil
+		}
+
+		tag, described, err = m.describe(path)
+		if err != nil {
+			m.logger.Warn("failed to read backup info",
+				zap.String("file", relPath),
+				zap.Error(err))
+			skip(path, SkipNoBackupInfo, err)
+
+			return nil
+		}
+	}
+
+	timestamp, err := m.timestamp(path, matches, info, described)
+	if errors.Is(err, files.ErrNotImplemented) {
+		return fmt.Errorf("%s: %w", relPath, err)
+	}
+
+	if err != nil {
+		m.logger.Warn("failed to read timestamp",
 			zap.String("file", relPath),
 			zap.Error(err))
+		skip(path, SkipNoTimestamp, err)
 
 		return nil
 	}
 
-	*files = append(*files, Info{
+	return found(Info{
 		Path:      path,
 		Timestamp: timestamp,
 		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Tag:       tag,
+		Seq:       m.sequence(matches),
+		Pinned:    m.isPinned(path, relPath),
+		listed:    info,
 	})
+}
 
-	return nil
+// timestamp dates a matched file, by the timestamp described in its
+// backup-info.json if any, from its extended attribute or its modification
+// time when configured and from the timestamp in its name otherwise
+func (m *Manager) timestamp(
+	path string,
+	matches []string,
+	info os.FileInfo,
+	described time.Time,
+) (time.Time, error) {
+	switch {
+	case !described.IsZero():
+		return described, nil
+	case m.xattr != "":
+		return m.xattrTimestamp(path)
+	case m.modTime:
+		return info.ModTime(), nil
+	}
+
+	return m.parseTimestamp(matches, m.filePattern.SubexpNames())
+}
+
+// captured returns the value of a named pattern group, or "" if the pattern
+// has no such group
+func (m *Manager) captured(matches []string, name string) string {
+	if idx := m.filePattern.SubexpIndex(name); idx >= 0 {
+		return matches[idx]
+	}
+
+	return ""
+}
+
+// sequence returns the number captured by the {seq} token, or zero if the
+// pattern has none. The token matches at most nine digits, so it always
+// parses.
+func (m *Manager) sequence(matches []string) int {
+	seq, _ := strconv.Atoi(m.captured(matches, "seq"))
+	return seq
+}
+
+// IsPinned reports whether the backup at path, listed as name, matches one
+// of pins or has a hold marker next to it. It is the check every backend
+// listing backups from the filesystem shares.
+func IsPinned(pins []string, path, name string) bool {
+	if _, ok := matchPin(pins, name); ok {
+		return true
+	}
+
+	_, err := os.Lstat(path + HoldSuffix)
+
+	return err == nil
+}
+
+// matchPin returns the first of pins that name matches
+func matchPin(pins []string, name string) (string, bool) {
+	for _, pin := range pins {
+		// Patterns are validated when the config is loaded
+		if ok, _ := filepath.Match(filepath.FromSlash(pin), name); ok {
+			return pin, true
+		}
+	}
+
+	return "", false
+}
+
+// isPinned reports whether the file matches a configured pin or has a hold
+// marker next to it. Unlike IsPinned, the hold marker is looked up through
+// the hardened root when there is one.
+func (m *Manager) isPinned(path, relPath string) bool {
+	if pin, ok := matchPin(m.pins, relPath); ok {
+		m.logger.Debug("file pinned by config",
+			zap.String("file", relPath),
+			zap.String("pin", pin))
+
+		return true
+	}
+
+	if _, err := m.lstat(path + HoldSuffix); err == nil {
+		m.logger.Debug("file pinned by hold marker",
+			zap.String("file", relPath))
+
+		return true
+	}
+
+	return false
+}
+
+// skip records an entry that was not considered a backup
+func (r *ScanResult) skip(path string, reason SkipReason, err error) {
+	r.Skipped = append(r.Skipped, Skipped{
+		Path:   path,
+		Reason: reason,
+		Err:    err,
+	})
 }
 
 // parseTimestamp parses the timestamp from the regex matches
@@ -25,15 +25,19 @@
 package file
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -80,7 +84,7 @@ func TestNewManager(t *testing.T) {
 	})
 
 	t.Run("invalid pattern", func(t *testing.T) {
-		_, err := NewManager("/tmp", "backup-[invalid")
+		_, err := NewManager("/tmp", "backup-[invalid", WithSyntax(SyntaxRegex))
 		require.Error(t, err)
 		require.ErrorIs(t, err, ErrInvalidPattern)
 	})
@@ -225,6 +229,284 @@ func TestListFiles(t *testing.T) {
 	})
 }
 
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	dir := t.TempDir()
+
+	manager, err := NewManager(dir, testBackupPattern)
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"backup-20250101000001.zip",
+		"backup-20251301000001.zip",
+		"readme.txt",
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+		require.NoError(t, err)
+	}
+
+	result, err := manager.Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, result.Files, 1)
+	require.Equal(t, "backup-20250101000001.zip", filepath.Base(result.Files[0].Path))
+
+	reasons := map[string]SkipReason{}
+	for _, skipped := range result.Skipped {
+		reasons[filepath.Base(skipped.Path)] = skipped.Reason
+	}
+
+	require.Equal(t, map[string]SkipReason{
+		"backup-20251301000001.zip": SkipNoTimestamp,
+		"readme.txt":                SkipNoMatch,
+	}, reasons)
+}
+
+func TestWalkFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	manager, err := NewManager(dir, testBackupPattern)
+	require.NoError(t, err)
+
+	// More than one page of entries, plus a nested directory
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range listBatchSize + 10 {
+		timestamp := start.Add(time.Duration(i) * time.Minute)
+		name := "backup-" + timestamp.Format("20060102150405") + ".zip"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), nil, 0o600))
+
+	seen := map[string]bool{}
+
+	err = manager.WalkFiles(t.Context(), func(f Info) error {
+		require.False(t, seen[f.Path])
+		seen[f.Path] = true
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, seen, listBatchSize+10)
+
+	stop := errors.New("stop")
+	calls := 0
+
+	err = manager.WalkFiles(t.Context(), func(Info) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+}
+
+func TestScanPinned(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	dir := t.TempDir()
+
+	manager, err := NewManager(
+		dir,
+		testBackupPattern,
+		WithPins([]string{"backup-2025010[12]*.zip"}),
+	)
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"backup-20250101000001.zip",
+		"backup-20250103000001.zip",
+		"backup-20250103000001.zip" + HoldSuffix,
+		"backup-20250104000001.zip",
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+		require.NoError(t, err)
+	}
+
+	list, err := manager.ListFiles(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	pinned := map[string]bool{}
+	for _, f := range list {
+		pinned[filepath.Base(f.Path)] = f.Pinned
+	}
+
+	require.Equal(t, map[string]bool{
+		"backup-20250101000001.zip": true,
+		"backup-20250103000001.zip": true,
+		"backup-20250104000001.zip": false,
+	}, pinned)
+}
+
+func TestIsPinned(t *testing.T) {
+	dir := t.TempDir()
+	pins := []string{"backup-2025010[12]*.zip"}
+
+	held := filepath.Join(dir, "backup-20250103000001.zip")
+	require.NoError(t, os.WriteFile(held+HoldSuffix, nil, 0o600))
+
+	require.True(t, IsPinned(pins, filepath.Join(dir, "backup-20250101000001.zip"),
+		"backup-20250101000001.zip"))
+	require.True(t, IsPinned(pins, held, "backup-20250103000001.zip"))
+	require.False(t, IsPinned(pins, filepath.Join(dir, "backup-20250104000001.zip"),
+		"backup-20250104000001.zip"))
+	require.False(t, IsPinned(nil, filepath.Join(dir, "backup-20250101000001.zip"),
+		"backup-20250101000001.zip"))
+}
+
+func TestScanTag(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	manager, err := NewManager(dir, "{tag}-{year}{month}{day}.tar.gz")
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"nightly-20250101.tar.gz",
+		"pre-release-20250102.tar.gz",
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+		require.NoError(t, err)
+	}
+
+	list, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "nightly", list[0].Tag)
+	require.Equal(t, "pre-release", list[1].Tag)
+}
+
+func TestScanSequence(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	manager, err := NewManager(dir, "backup-{year}-{month}-{day}.{seq}.tar.gz")
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"backup-2024-03-15.1.tar.gz",
+		"backup-2024-03-15.12.tar.gz",
+		"backup-2024-03-15.tar.gz",
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+		require.NoError(t, err)
+	}
+
+	list, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+
+	seqs := map[string]int{}
+	for _, f := range list {
+		seqs[filepath.Base(f.Path)] = f.Seq
+	}
+
+	require.Equal(t, map[string]int{
+		"backup-2024-03-15.1.tar.gz":  1,
+		"backup-2024-03-15.12.tar.gz": 12,
+	}, seqs)
+}
+
+func TestScanModTime(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	manager, err := NewManager(dir, "backup-*.tar.gz", WithModTime(), WithSyntax(SyntaxGlob))
+	require.NoError(t, err)
+
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(dir, "backup-latest.tar.gz")
+
+	err = os.WriteFile(path, nil, 0o600)
+	require.NoError(t, err)
+
+	err = os.Chtimes(path, mtime, mtime)
+	require.NoError(t, err)
+
+	list, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.True(t, mtime.Equal(list[0].Timestamp))
+}
+
+func TestScanMatchOptions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "host1"), 0o750))
+
+	for _, name := range []string{
+		"backup-20250101.tar.gz",
+		"BACKUP-20250102.TAR.GZ",
+		filepath.Join("host1", "backup-20250103.tar.gz"),
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name string
+		opts []ManagerOption
+		want []string
+	}{
+		{
+			name: "relative path",
+			want: []string{"backup-20250101.tar.gz"},
+		},
+		{
+			name: "basename",
+			opts: []ManagerOption{WithBasename()},
+			want: []string{
+				"backup-20250101.tar.gz",
+				filepath.Join("host1", "backup-20250103.tar.gz"),
+			},
+		},
+		{
+			name: "ignore case",
+			opts: []ManagerOption{WithIgnoreCase()},
+			want: []string{"BACKUP-20250102.TAR.GZ", "backup-20250101.tar.gz"},
+		},
+		{
+			name: "basename ignoring case",
+			opts: []ManagerOption{WithBasename(), WithIgnoreCase()},
+			want: []string{
+				"BACKUP-20250102.TAR.GZ",
+				"backup-20250101.tar.gz",
+				filepath.Join("host1", "backup-20250103.tar.gz"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			manager, err := NewManager(dir, "backup-{year}{month}{day}.tar.gz", tc.opts...)
+			require.NoError(t, err)
+
+			list, err := manager.ListFiles(t.Context())
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(list))
+			for _, f := range list {
+				rel, err := filepath.Rel(dir, f.Path)
+				require.NoError(t, err)
+
+				got = append(got, rel)
+			}
+
+			require.ElementsMatch(t, tc.want, got)
+		})
+	}
+}
+
 // setupTestFile creates a test file and returns its path and info
 func setupTestFile(t *testing.T, dir, filename string) (string, Info) {
 	path := filepath.Clean(filepath.Join(dir, filename))
@@ -345,6 +627,54 @@ func testDeleteDirectory(ctx context.Context, t *testing.T, manager *Manager, di
 	require.ErrorIs(t, err, ErrNotRegularFile)
 }
 
+func testDeleteOutsideRoot(ctx context.Context, t *testing.T, manager *Manager) {
+	path, info := setupTestFile(t, t.TempDir(), "backup-202501010000.zip")
+
+	err := manager.DeleteFile(ctx, info, false)
+	require.ErrorIs(t, err, ErrOutsideRoot)
+	require.FileExists(t, path)
+}
+
+func testDeleteThroughSymlinkedDirectory(
+	ctx context.Context,
+	t *testing.T,
+	manager *Manager,
+	dir string,
+) {
+	outside := t.TempDir()
+	path, _ := setupTestFile(t, outside, "backup-202501010000.zip")
+
+	if err := os.Symlink(outside, filepath.Join(dir, "linked")); err != nil {
+		t.Skip("Symlink creation failed - may need elevated privileges")
+	}
+
+	err := manager.DeleteFile(ctx, Info{
+		Path:      filepath.Join(dir, "linked", "backup-202501010000.zip"),
+		Timestamp: time.Now(),
+	}, false)
+	require.ErrorIs(t, err, ErrOutsideRoot)
+	require.FileExists(t, path)
+}
+
+func testDeleteReplacedFile(ctx context.Context, t *testing.T, manager *Manager, dir string) {
+	path, _ := setupTestFile(t, dir, "backup-20250101000000.zip")
+
+	listed, err := manager.ListFiles(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	// Replace the file with a different one under the same name. The
+	// replacement is created first so it cannot reuse the inode.
+	replacement := filepath.Join(dir, "replacement")
+	require.NoError(t, os.WriteFile(replacement, []byte("replaced"), 0o600))
+	require.NoError(t, os.Rename(replacement, path))
+
+	err = manager.DeleteFile(ctx, listed[0], false)
+	require.ErrorIs(t, err, ErrDeleteFile)
+	require.ErrorIs(t, err, files.ErrFileChanged)
+	require.FileExists(t, path)
+}
+
 func testDeleteSymlink(ctx context.Context, t *testing.T, manager *Manager, dir string) {
 	if !checkSymlinkSupport() {
 		t.Skip("Symlinks not supported on this system")
@@ -388,6 +718,10 @@ func testDeleteReadOnlyFile(ctx context.Context, t *testing.T, manager *Manager,
 		t.Skipf("Skipping test: unable to chown file to another user: %v", chownErr)
 	}
 
+	if os.Geteuid() == 0 {
+		t.Skip("Skipping test: root bypasses file permission checks")
+	}
+
 	readOnlyInfo := Info{
 		Path:      readOnlyPath,
 		Timestamp: time.Now(),
@@ -633,6 +967,36 @@ func testDryRun(ctx context.Context, t *testing.T, manager *Manager, path string
 	require.NoError(t, err)
 }
 
+// denyPlatform is a Platform whose write access check always fails
+type denyPlatform struct {
+	files.Platform
+}
+
+func (denyPlatform) CheckWriteAccess(path string) error {
+	return fmt.Errorf("%w: %s", files.ErrNoWriteAccess, path)
+}
+
+func TestDeleteFileWriteAccessCheck(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	dir := t.TempDir()
+	manager, err := NewManager(
+		dir,
+		testBackupPattern,
+		WithPlatform(denyPlatform{files.NewPlatform()}),
+	)
+	require.NoError(t, err)
+
+	path, info := setupTestFile(t, dir, "backup-202501010000.zip")
+	err = manager.DeleteFile(ctx, info, false)
+	require.ErrorIs(t, err, ErrAccessDenied)
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	_, err = os.Stat(path)
+	require.NoError(t, err, "file should not be deleted without write access")
+}
+
 func TestDeleteFile(t *testing.T) {
 	t.Parallel()
 
@@ -670,6 +1034,14 @@ func TestDeleteFile(t *testing.T) {
 		require.NoError(t, err)
 		testDeleteFileWithOtherWrite(ctx, t, manager, dir)
 	})
+	t.Run("delete file outside directory", func(t *testing.T) {
+		ctx := t.Context()
+		dir := t.TempDir()
+		logger := &logging.Logger{Logger: zap.NewNop()}
+		manager, err := NewManager(dir, testBackupPattern, WithLogger(logger))
+		require.NoError(t, err)
+		testDeleteOutsideRoot(ctx, t, manager)
+	})
 	t.Run("context cancellation", func(t *testing.T) {
 		ctx := t.Context()
 		dir := t.TempDir()
@@ -702,6 +1074,14 @@ func TestDeleteFile(t *testing.T) {
 			require.NoError(t, err)
 			testDeleteSymlink(ctx, t, manager, dir)
 		})
+		t.Run("delete through symlinked directory", func(t *testing.T) {
+			ctx := t.Context()
+			dir := t.TempDir()
+			logger := &logging.Logger{Logger: zap.NewNop()}
+			manager, err := NewManager(dir, testBackupPattern, WithLogger(logger))
+			require.NoError(t, err)
+			testDeleteThroughSymlinkedDirectory(ctx, t, manager, dir)
+		})
 	}
 
 	aclSupport, _ := plat.CheckACLSupport()
@@ -724,6 +1104,18 @@ func TestDeleteFile(t *testing.T) {
 		})
 	}
 
+	// Only Linux confirms the file is still the one that was listed
+	if runtime.GOOS == "linux" {
+		t.Run("delete file replaced after listing", func(t *testing.T) {
+			ctx := t.Context()
+			dir := t.TempDir()
+			logger := &logging.Logger{Logger: zap.NewNop()}
+			manager, err := NewManager(dir, testBackupPattern, WithLogger(logger))
+			require.NoError(t, err)
+			testDeleteReplacedFile(ctx, t, manager, dir)
+		})
+	}
+
 	// Group write test may fail on Windows due to different permission model
 	if runtime.GOOS != "windows" {
 		t.Run("delete file with group write permission", func(t *testing.T) {
@@ -749,6 +1141,56 @@ func TestDeleteFile(t *testing.T) {
 	}
 }
 
+func TestWalkDirCancelled(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "backup.tar.gz"), nil, 0o600))
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	walked := 0
+	err := walkDir(ctx, dir, func(string, os.DirEntry) error {
+		walked++
+		cancel()
+
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, walked, "the walk should stop at the next entry")
+}
+
+func TestSortContext(t *testing.T) {
+	s := make([]int, sortInBackground)
+	for i := range s {
+		s[i] = len(s) - i
+	}
+
+	require.NoError(t, sortContext(t.Context(), s, cmp.Compare[int]))
+	require.True(t, slices.IsSorted(s))
+
+	// Cancel during the sort. The slice is the caller's again once
+	// sortContext returns, which the race detector checks by writing to it.
+	ctx, cancel := context.WithCancel(t.Context())
+
+	var once sync.Once
+
+	slow := func(a, b int) int {
+		once.Do(func() {
+			cancel()
+			time.Sleep(10 * time.Millisecond)
+		})
+
+		return cmp.Compare(a, b)
+	}
+
+	slices.Reverse(s)
+	require.ErrorIs(t, sortContext(ctx, s, slow), context.Canceled)
+
+	for i := range s {
+		s[i] = i
+	}
+}
+
 func TestParseTimestamp(t *testing.T) {
 	t.Parallel()
 	// Setup
@@ -0,0 +1,99 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// WithOwner only lists files owned by the numeric user ID uid and group ID
+// gid, so a shared directory can be pruned without touching the backups of
+// other users. An empty ID matches any owner.
+func WithOwner(uid, gid string) ManagerOption {
+	return func(m *Manager) {
+		m.uid = uid
+		m.gid = gid
+	}
+}
+
+// LookupOwner resolves a user and a group, given by name or numeric ID, to
+// the numeric IDs WithOwner expects. Empty names stay empty.
+func LookupOwner(owner, group string) (string, string, error) {
+	uid, err := lookupID(owner, func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+
+		return u.Uid, nil
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("owner %q: %w", owner, err)
+	}
+
+	gid, err := lookupID(group, func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+
+		return g.Gid, nil
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("group %q: %w", group, err)
+	}
+
+	return uid, gid, nil
+}
+
+// lookupID returns a numeric ID as is and looks up the ID of a name
+func lookupID(name string, lookup func(string) (string, error)) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return name, nil
+	}
+
+	return lookup(name)
+}
+
+// ownedBy reports whether a listed file has the owner WithOwner selected
+func (m *Manager) ownedBy(info os.FileInfo) (bool, error) {
+	if m.uid == "" && m.gid == "" {
+		return true, nil
+	}
+
+	uid, gid, err := m.platform.Owner(info)
+	if err != nil {
+		return false, err
+	}
+
+	return (m.uid == "" || uid == m.uid) && (m.gid == "" || gid == m.gid), nil
+}
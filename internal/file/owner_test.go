@@ -0,0 +1,146 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// ownerPlatform is a Platform reporting files whose name starts with
+// "alice" as owned by uid 1000 in group 100, and everything else as owned
+// by uid 2000 in group 200
+type ownerPlatform struct {
+	files.Platform
+}
+
+func (ownerPlatform) Owner(info os.FileInfo) (string, string, error) {
+	if strings.HasPrefix(info.Name(), "alice") {
+		return "1000", "100", nil
+	}
+
+	return "2000", "200", nil
+}
+
+func TestScanOwner(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"alice-20250101.zip", "bob-20250102.zip"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	for _, tc := range []struct {
+		name     string
+		uid, gid string
+		want     []string
+	}{
+		{name: "any owner", want: []string{"alice-20250101.zip", "bob-20250102.zip"}},
+		{name: "user", uid: "1000", want: []string{"alice-20250101.zip"}},
+		{name: "group", gid: "200", want: []string{"bob-20250102.zip"}},
+		{name: "user and group", uid: "1000", gid: "200"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			manager, err := NewManager(dir, "{tag}-{year}{month}{day}.zip",
+				WithPlatform(ownerPlatform{files.NewPlatform()}),
+				WithOwner(tc.uid, tc.gid))
+			require.NoError(t, err)
+
+			result, err := manager.Scan(t.Context())
+			require.NoError(t, err)
+
+			var listed []string
+			for _, f := range result.Files {
+				listed = append(listed, filepath.Base(f.Path))
+			}
+
+			require.Equal(t, tc.want, listed)
+			require.Len(t, result.Skipped, 2-len(tc.want))
+
+			for _, skipped := range result.Skipped {
+				require.Equal(t, SkipOwner, skipped.Reason)
+			}
+		})
+	}
+}
+
+func TestScanOwnerNotImplemented(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "backup-20250101.zip"), nil, 0o600))
+
+	manager, err := NewManager(dir, "backup-{year}{month}{day}.zip",
+		WithPlatform(noOwnerPlatform{files.NewPlatform()}),
+		WithOwner("1000", ""))
+	require.NoError(t, err)
+
+	_, err = manager.ListFiles(t.Context())
+	require.ErrorIs(t, err, ErrListFiles)
+	require.ErrorIs(t, err, files.ErrNotImplemented)
+}
+
+// noOwnerPlatform is a Platform without numeric file owners
+type noOwnerPlatform struct {
+	files.Platform
+}
+
+func (noOwnerPlatform) Owner(os.FileInfo) (string, string, error) {
+	return "", "", files.ErrNotImplemented
+}
+
+func TestLookupOwner(t *testing.T) {
+	uid, gid, err := LookupOwner("", "")
+	require.NoError(t, err)
+	require.Empty(t, uid)
+	require.Empty(t, gid)
+
+	uid, gid, err = LookupOwner("1000", "100")
+	require.NoError(t, err)
+	require.Equal(t, "1000", uid)
+	require.Equal(t, "100", gid)
+
+	_, _, err = LookupOwner("no-such-user-for-retention", "")
+	require.ErrorContains(t, err, `owner "no-such-user-for-retention"`)
+
+	_, _, err = LookupOwner("", "no-such-group-for-retention")
+	require.ErrorContains(t, err, `group "no-such-group-for-retention"`)
+
+	current, err := user.Current()
+	require.NoError(t, err)
+
+	uid, _, err = LookupOwner(current.Username, "")
+	require.NoError(t, err)
+	require.Equal(t, current.Uid, uid)
+}
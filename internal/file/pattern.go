@@ -0,0 +1,195 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// Syntax is how the text around the tokens of a file pattern is read
+type Syntax string
+
+// Syntaxes a file pattern can be written in
+const (
+	// SyntaxTokens reads everything but the tokens as literal text
+	SyntaxTokens Syntax = "tokens"
+	// SyntaxGlob adds the shell wildcards *, ? and [...] to SyntaxTokens,
+	// none of which match a path separator
+	SyntaxGlob Syntax = "glob"
+	// SyntaxRegex reads the text around the tokens as a regular expression
+	SyntaxRegex Syntax = "regex"
+)
+
+// ErrUnknownSyntax is returned for a pattern syntax that does not exist
+var ErrUnknownSyntax = errors.New("unknown pattern syntax")
+
+// Problems reported by PatternTokenError
+const (
+	TokenRepeated = files.TokenRepeated
+	TokenMissing  = files.TokenMissing
+)
+
+// PatternTokenError reports a token that makes a file pattern unusable. It is
+// files.PatternTokenError, which code outside this module can test for.
+type PatternTokenError = files.PatternTokenError
+
+// patternTokens returns the tokens of a file pattern with the regular
+// expression each one stands for, in the order they are replaced
+func patternTokens() [][2]string {
+	return [][2]string{
+		{"year", `(?P<year>\d{4})`},
+		{"month", `(?P<month>\d{2})`},
+		{"day", `(?P<day>\d{2})`},
+		{"hour", `(?P<hour>\d{2})`},
+		{"minute", `(?P<minute>\d{2})`},
+		{"second", `(?P<second>\d{2})`},
+		{"tag", `(?P<tag>[^/]+?)`},
+		{"seq", `(?P<seq>\d{1,9})`},
+	}
+}
+
+// patternRegex translates a file pattern written in syntax to an anchored
+// regular expression, replacing every token with its named group
+func patternRegex(pattern string, syntax Syntax) (string, error) {
+	var literal func(string) string
+
+	switch syntax {
+	case SyntaxTokens, "":
+		literal = regexp.QuoteMeta
+	case SyntaxGlob:
+		literal = globRegex
+	case SyntaxRegex:
+		literal = func(s string) string { return s }
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownSyntax, syntax)
+	}
+
+	var (
+		b    strings.Builder
+		text strings.Builder
+	)
+
+	b.WriteString("^")
+
+	for rest := pattern; rest != ""; {
+		token, ok := leadingToken(rest)
+		if !ok {
+			text.WriteByte(rest[0])
+			rest = rest[1:]
+
+			continue
+		}
+
+		b.WriteString(literal(text.String()))
+		b.WriteString(token[1])
+		text.Reset()
+
+		rest = rest[len(token[0])+2:]
+	}
+
+	b.WriteString(literal(text.String()))
+	b.WriteString("$")
+
+	return b.String(), nil
+}
+
+// leadingToken returns the token s starts with, if any
+func leadingToken(s string) ([2]string, bool) {
+	for _, token := range patternTokens() {
+		if strings.HasPrefix(s, "{"+token[0]+"}") {
+			return token, true
+		}
+	}
+
+	return [2]string{}, false
+}
+
+// globRegex translates the shell wildcards in s to a regular expression,
+// quoting everything else. Wildcards never match a path separator, and a
+// backslash makes the character after it literal.
+func globRegex(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*':
+			b.WriteString(`[^/]*`)
+		case '?':
+			b.WriteString(`[^/]`)
+		case '[':
+			end := strings.IndexByte(s[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+
+			class := s[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+
+			b.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+// HasToken reports whether pattern contains the named token, e.g. "hour"
+// for {hour}
+func HasToken(pattern, token string) bool {
+	return strings.Contains(pattern, "{"+token+"}")
+}
+
+// checkTokens returns a PatternTokenError for the first token that appears
+// more than once in pattern, or for a missing {year} when the timestamp is
+// taken from the file name
+func checkTokens(pattern string, needsYear bool) error {
+	for _, token := range patternTokens() {
+		if strings.Count(pattern, "{"+token[0]+"}") > 1 {
+			return &PatternTokenError{Pattern: pattern, Token: token[0], Problem: TokenRepeated}
+		}
+	}
+
+	if needsYear && !HasToken(pattern, "year") {
+		return &PatternTokenError{Pattern: pattern, Token: "year", Problem: TokenMissing}
+	}
+
+	return nil
+}
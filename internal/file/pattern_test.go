@@ -0,0 +1,158 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewManagerTokens(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		pattern string
+		opts    []ManagerOption
+		token   string
+		problem string
+	}{
+		{
+			name:    "repeated token",
+			pattern: "backup-{year}-{month}-{day}-{day}.tar.gz",
+			token:   "day",
+			problem: TokenRepeated,
+		},
+		{
+			name:    "repeated tag",
+			pattern: "{tag}/{tag}-{year}.tar.gz",
+			token:   "tag",
+			problem: TokenRepeated,
+		},
+		{
+			name:    "repeated seq",
+			pattern: "backup-{year}.{seq}.{seq}.tar.gz",
+			token:   "seq",
+			problem: TokenRepeated,
+		},
+		{
+			name:    "missing year",
+			pattern: "backup-{month}-{day}.tar.gz",
+			token:   "year",
+			problem: TokenMissing,
+		},
+		{
+			name:    "dated by modification time",
+			pattern: `backup-\w+\.tar\.gz`,
+			opts:    []ManagerOption{WithModTime()},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewManager("/tmp", tc.pattern, tc.opts...)
+			if tc.token == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidPattern)
+
+			var tokenErr *PatternTokenError
+			require.ErrorAs(t, err, &tokenErr)
+			require.Equal(t, tc.pattern, tokenErr.Pattern)
+			require.Equal(t, tc.token, tokenErr.Token)
+			require.Equal(t, tc.problem, tokenErr.Problem)
+		})
+	}
+}
+
+func TestHasToken(t *testing.T) {
+	require.True(t, HasToken("backup-{year}{month}{day}-{hour}.zip", "hour"))
+	require.False(t, HasToken("backup-{year}{month}{day}.zip", "hour"))
+}
+
+func TestNewManagerSyntax(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		pattern string
+		syntax  Syntax
+		file    string
+		match   bool
+	}{
+		{
+			name:    "tokens quote dots",
+			pattern: "backup-{year}{month}{day}.tar.gz",
+			file:    "backup-20240315xtarxgz",
+		},
+		{
+			name:    "tokens quote metacharacters",
+			pattern: "backup+(1)-{year}{month}{day}.zip",
+			file:    "backup+(1)-20240315.zip",
+			match:   true,
+		},
+		{
+			name:    "glob star",
+			pattern: "db-*-{year}{month}{day}.sql.gz",
+			syntax:  SyntaxGlob,
+			file:    "db-orders-20240315.sql.gz",
+			match:   true,
+		},
+		{
+			name:    "glob star stays in one directory",
+			pattern: "*-{year}{month}{day}.zip",
+			syntax:  SyntaxGlob,
+			file:    "host/app-20240315.zip",
+		},
+		{
+			name:    "glob negated class",
+			pattern: "[!x]?-{year}{month}{day}.zip",
+			syntax:  SyntaxGlob,
+			file:    "ab-20240315.zip",
+			match:   true,
+		},
+		{
+			name:    "glob escaped star",
+			pattern: `backup\*-{year}{month}{day}.zip`,
+			syntax:  SyntaxGlob,
+			file:    "backups-20240315.zip",
+		},
+		{
+			name:    "regex",
+			pattern: `backup-\w+-{year}{month}{day}\.zip`,
+			syntax:  SyntaxRegex,
+			file:    "backup-full-20240315.zip",
+			match:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewManager("/tmp", tc.pattern, WithSyntax(tc.syntax))
+			require.NoError(t, err)
+			require.Equal(t, tc.match, m.filePattern.MatchString(tc.file))
+		})
+	}
+
+	t.Run("unknown syntax", func(t *testing.T) {
+		_, err := NewManager("/tmp", "backup-{year}.zip", WithSyntax("shell"))
+		require.ErrorIs(t, err, ErrUnknownSyntax)
+	})
+}
@@ -0,0 +1,161 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// ErrOutsideRoot is returned when a backup to delete does not canonically
+// reside under the directory it belongs to
+var ErrOutsideRoot = files.ErrOutsideRoot
+
+// RelativeToRoot resolves path and returns it relative to root. Every
+// symlink in root and in the parent directories of path is followed, but
+// not the final element of path, so a symlink is judged by where it is
+// rather than where it points. Anything that does not reside below root,
+// including root itself, is rejected with ErrOutsideRoot.
+func RelativeToRoot(root, path string) (string, error) {
+	resolvedRoot, err := resolve(root)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrOutsideRoot, path, err)
+	}
+
+	parent, err := resolve(filepath.Dir(path))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrOutsideRoot, path, err)
+	}
+
+	rel, err := filepath.Rel(resolvedRoot, filepath.Join(parent, filepath.Base(path)))
+	if err != nil || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is not under %s", ErrOutsideRoot, path, root)
+	}
+
+	return rel, nil
+}
+
+// RemoveInRoot removes the file at rel, a path relative to root. The
+// removal is performed through an os.Root, so a parent directory replaced
+// by a symlink after the path was checked cannot redirect it outside root.
+func RemoveInRoot(root, rel string) error {
+	return inRoot(root, func(r *os.Root) error {
+		return r.Remove(rel)
+	})
+}
+
+// RemoveAllInRoot removes rel, a path relative to root, and everything
+// below it, with the same guarantees as RemoveInRoot
+func RemoveAllInRoot(root, rel string) error {
+	return inRoot(root, func(r *os.Root) error {
+		return r.RemoveAll(rel)
+	})
+}
+
+// inRoot opens root and calls fn with it
+func inRoot(root string, fn func(*os.Root) error) error {
+	r, err := os.OpenRoot(root)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return fn(r)
+}
+
+// resolve returns the absolute path of path with every symlink followed
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
+
+// RemoveEmptyParents removes the parent directories of path that are empty,
+// starting with the closest and walking upward until one is not empty. root
+// itself and anything outside it are never removed. The removed
+// directories are returned, closest first.
+func RemoveEmptyParents(root, path string) ([]string, error) {
+	rel, err := RelativeToRoot(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	err = inRoot(root, func(r *os.Root) error {
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			empty, err := isEmptyDir(r, dir)
+			if err != nil || !empty {
+				return err
+			}
+
+			if err := r.Remove(dir); err != nil {
+				return err
+			}
+
+			removed = append(removed, filepath.Join(root, dir))
+		}
+
+		return nil
+	})
+
+	return removed, err
+}
+
+// isEmptyDir reports whether dir, a path relative to r, is a directory
+// holding no entries. A symlink is never considered a directory.
+func isEmptyDir(r *os.Root, dir string) (bool, error) {
+	info, err := r.Lstat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil || !info.IsDir() {
+		return false, err
+	}
+
+	f, err := r.Open(dir)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = f.ReadDir(1)
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+
+	return false, err
+}
@@ -0,0 +1,136 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativeToRoot(t *testing.T) {
+	t.Parallel()
+
+	parent := t.TempDir()
+	root := filepath.Join(parent, "backups")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "daily"), 0o750))
+	require.NoError(t, os.MkdirAll(filepath.Join(parent, "backups-old"), 0o750))
+
+	tests := []struct {
+		name    string
+		path    string
+		rel     string
+		outside bool
+	}{
+		{
+			name: "file in root",
+			path: filepath.Join(root, "backup.zip"),
+			rel:  "backup.zip",
+		},
+		{
+			name: "file in subdirectory",
+			path: filepath.Join(root, "daily", "backup.zip"),
+			rel:  filepath.Join("daily", "backup.zip"),
+		},
+		{
+			name: "traversal back into root",
+			path: filepath.Join(root, "daily", "..", "backup.zip"),
+			rel:  "backup.zip",
+		},
+		{
+			name:    "root itself",
+			path:    root,
+			outside: true,
+		},
+		{
+			name:    "traversal out of root",
+			path:    filepath.Join(root, "..", "backup.zip"),
+			outside: true,
+		},
+		{
+			name:    "sibling sharing a prefix",
+			path:    filepath.Join(parent, "backups-old", "backup.zip"),
+			outside: true,
+		},
+		{
+			name:    "missing parent directory",
+			path:    filepath.Join(root, "missing", "backup.zip"),
+			outside: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rel, err := RelativeToRoot(root, tt.path)
+			if tt.outside {
+				require.ErrorIs(t, err, ErrOutsideRoot)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.rel, rel)
+		})
+	}
+
+	t.Run("symlinked root", func(t *testing.T) {
+		t.Parallel()
+
+		link := filepath.Join(t.TempDir(), "link")
+		if err := os.Symlink(root, link); err != nil {
+			t.Skip("Symlink creation failed - may need elevated privileges")
+		}
+
+		rel, err := RelativeToRoot(link, filepath.Join(root, "backup.zip"))
+		require.NoError(t, err)
+		require.Equal(t, "backup.zip", rel)
+	})
+}
+
+func TestRemoveEmptyParents(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	day := filepath.Join(root, "2024", "03", "15")
+	require.NoError(t, os.MkdirAll(day, 0o750))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "2024", "04"), 0o750))
+
+	// The backup in the day directory has just been deleted
+	removed, err := RemoveEmptyParents(root, filepath.Join(day, "backup.tar.gz"))
+	require.NoError(t, err)
+	require.Equal(t, []string{day, filepath.Join(root, "2024", "03")}, removed)
+	require.DirExists(t, filepath.Join(root, "2024", "04"))
+
+	// The root is never removed, even when left empty
+	removed, err = RemoveEmptyParents(root, filepath.Join(root, "backup.tar.gz"))
+	require.NoError(t, err)
+	require.Empty(t, removed)
+	require.DirExists(t, root)
+
+	_, err = RemoveEmptyParents(root, filepath.Join(t.TempDir(), "a", "backup.tar.gz"))
+	require.ErrorIs(t, err, ErrOutsideRoot)
+}
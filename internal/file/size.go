@@ -0,0 +1,122 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// ErrComputeSize is returned when the size of a backup cannot be computed
+var ErrComputeSize = files.ErrComputeSize
+
+// DirSize returns the total size of the regular files at or below path, so
+// a backup stored as a directory is sized like a single file. It stops once
+// ctx is done.
+func DirSize(ctx context.Context, path string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+
+	return size, err
+}
+
+// ComputeSizes replaces the size of every backup with its cumulative size
+// from DirSize. Backups are walked concurrently, one worker per CPU, since
+// walking large directory units dominates the time spent listing them.
+func ComputeSizes(ctx context.Context, backups []Info) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	jobs := make(chan int)
+
+	for range min(runtime.NumCPU(), len(backups)) {
+		wg.Go(func() {
+			for i := range jobs {
+				size, err := DirSize(ctx, backups[i].Path)
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, &files.PathError{
+						Op:   files.OpSize,
+						Path: backups[i].Path,
+						Err:  err,
+					})
+					mu.Unlock()
+
+					continue
+				}
+
+				backups[i].Size = size
+			}
+		})
+	}
+
+	for i := range backups {
+		if ctx.Err() != nil {
+			break
+		}
+
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "b"), make([]byte, 50), 0o600))
+
+	size, err := DirSize(t.Context(), dir)
+	require.NoError(t, err)
+	require.Equal(t, int64(150), size)
+
+	size, err = DirSize(t.Context(), filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	require.Equal(t, int64(100), size)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err = DirSize(ctx, dir)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestComputeSizes(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []Info
+
+	for i, name := range []string{"one", "two", "three"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.Mkdir(path, 0o750))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(path, "data"), make([]byte, (i+1)*10), 0o600))
+
+		files = append(files, Info{Path: path})
+	}
+
+	require.NoError(t, ComputeSizes(t.Context(), files))
+
+	for i, f := range files {
+		require.Equal(t, int64((i+1)*10), f.Size)
+	}
+
+	files = append(files, Info{Path: filepath.Join(dir, "missing")})
+	require.ErrorIs(t, ComputeSizes(t.Context(), files), ErrComputeSize)
+}
@@ -0,0 +1,132 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import "fmt"
+
+// Severity classifies how much a warning matters
+type Severity string
+
+// Severities of warnings, from least to most severe
+const (
+	// SeverityInfo is expected while listing, such as files that do not
+	// match the pattern, and is never reported as a warning
+	SeverityInfo Severity = "info"
+	// SeverityWarning means a file that looks like a backup was ignored
+	SeverityWarning Severity = "warning"
+	// SeverityError means backups may have been missed altogether
+	SeverityError Severity = "error"
+)
+
+// Warning is a problem found while listing backups that did not stop the
+// listing, such as a backup whose timestamp could not be parsed
+type Warning struct {
+	Severity Severity
+	Path     string
+	Message  string
+}
+
+func (w Warning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+// AtLeast reports whether s is as severe as other or more
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// rank orders severities, unknown ones below SeverityInfo
+func (s Severity) rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityError:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Severity returns how much an entry skipped for reason r matters
+func (r SkipReason) Severity() Severity {
+	switch r {
+	case SkipNoTimestamp, SkipNoBackupInfo:
+		return SeverityWarning
+	case SkipNoInfo, SkipAccessDenied:
+		return SeverityError
+	default:
+		return SeverityInfo
+	}
+}
+
+// Warning returns the warning for a skipped entry
+func (s Skipped) Warning() Warning {
+	msg := string(s.Reason)
+	if s.Err != nil {
+		msg = fmt.Sprintf("%s: %v", s.Reason, s.Err)
+	}
+
+	return Warning{Severity: s.Reason.Severity(), Path: s.Path, Message: msg}
+}
+
+// Warnings returns the warnings for the entries skipped while scanning,
+// leaving out those of SeverityInfo
+func (r *ScanResult) Warnings() []Warning {
+	var warnings []Warning
+
+	for _, s := range r.Skipped {
+		if w := s.Warning(); w.Severity.AtLeast(SeverityWarning) {
+			warnings = append(warnings, w)
+		}
+	}
+
+	return warnings
+}
+
+// WithWarnings calls fn with a warning for every entry skipped while
+// listing that is at least SeverityWarning, such as a backup whose
+// timestamp could not be parsed or a subdirectory that could not be read.
+// The listing itself carries on.
+func WithWarnings(fn func(Warning)) ManagerOption {
+	return func(m *Manager) {
+		m.warn = fn
+	}
+}
+
+// warning wraps skip to also pass the entries worth a warning to m.warn
+func (m *Manager) warning(
+	skip func(path string, reason SkipReason, err error),
+) func(path string, reason SkipReason, err error) {
+	return func(path string, reason SkipReason, err error) {
+		skip(path, reason, err)
+
+		w := Skipped{Path: path, Reason: reason, Err: err}.Warning()
+		if w.Severity.AtLeast(SeverityWarning) {
+			m.warn(w)
+		}
+	}
+}
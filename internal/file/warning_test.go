@@ -0,0 +1,122 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkippedWarning(t *testing.T) {
+	t.Parallel()
+
+	for reason, want := range map[SkipReason]Severity{
+		SkipNoMatch:      SeverityInfo,
+		SkipSymlink:      SeverityInfo,
+		SkipNonRegular:   SeverityInfo,
+		SkipOwner:        SeverityInfo,
+		SkipNoTimestamp:  SeverityWarning,
+		SkipNoBackupInfo: SeverityWarning,
+		SkipNoInfo:       SeverityError,
+		SkipAccessDenied: SeverityError,
+	} {
+		require.Equal(t, want, reason.Severity(), reason)
+	}
+
+	require.Equal(t, Warning{
+		Severity: SeverityWarning,
+		Path:     "/backups/x.tar",
+		Message:  "timestamp could not be parsed: bad month",
+	}, Skipped{
+		Path:   "/backups/x.tar",
+		Reason: SkipNoTimestamp,
+		Err:    errors.New("bad month"),
+	}.Warning())
+
+	require.True(t, SeverityError.AtLeast(SeverityWarning))
+	require.True(t, SeverityWarning.AtLeast(SeverityWarning))
+	require.False(t, SeverityInfo.AtLeast(SeverityWarning))
+	require.False(t, SeverityWarning.AtLeast(SeverityError))
+}
+
+func TestWalkFilesWarnings(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for _, name := range []string{
+		"backup-2024-03-15.tar.gz",
+		"backup-2024-13-45.tar.gz",
+		"notes.txt",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	locked := filepath.Join(dir, "locked")
+	require.NoError(t, os.Mkdir(locked, 0o700))
+
+	// Root and Windows read the directory regardless of its mode
+	denied := runtime.GOOS != "windows" && os.Geteuid() != 0
+	if denied {
+		require.NoError(t, os.Chmod(locked, 0))
+		t.Cleanup(func() { _ = os.Chmod(locked, 0o700) })
+	}
+
+	var warnings []Warning
+
+	manager, err := NewManager(dir, "backup-{year}-{month}-{day}.tar.gz",
+		WithWarnings(func(w Warning) { warnings = append(warnings, w) }))
+	require.NoError(t, err)
+
+	files := 0
+	err = manager.WalkFiles(t.Context(), func(Info) error {
+		files++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, files)
+
+	severities := map[string]Severity{}
+	for _, w := range warnings {
+		severities[filepath.Base(w.Path)] = w.Severity
+	}
+
+	want := map[string]Severity{"backup-2024-13-45.tar.gz": SeverityWarning}
+	if denied {
+		want["locked"] = SeverityError
+	}
+
+	require.Equal(t, want, severities)
+
+	result, err := manager.Scan(t.Context())
+	require.NoError(t, err)
+	require.Len(t, result.Skipped, len(want)+1)
+	require.Len(t, result.Warnings(), len(want))
+}
@@ -0,0 +1,66 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithTimestampXattr dates files by the extended attribute name, such as
+// user.backup.timestamp, instead of the timestamp in their name, for backup
+// tools that stamp metadata rather than encoding dates in file names. The
+// attribute holds an RFC 3339 timestamp or Unix seconds.
+func WithTimestampXattr(name string) ManagerOption {
+	return func(m *Manager) {
+		m.xattr = name
+	}
+}
+
+// xattrTimestamp reads the timestamp of a file from its extended attribute
+func (m *Manager) xattrTimestamp(path string) (time.Time, error) {
+	value, err := m.platform.Getxattr(path, m.xattr)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return parseStamp(strings.TrimSpace(string(value)))
+}
+
+// parseStamp parses an RFC 3339 timestamp or a number of Unix seconds
+func parseStamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", ErrParseTimestamp, err)
+	}
+
+	return ts, nil
+}
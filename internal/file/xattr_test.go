@@ -0,0 +1,139 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+)
+
+// xattrPlatform is a Platform whose files carry the extended attributes in
+// values, keyed by file name and then attribute name
+type xattrPlatform struct {
+	files.Platform
+
+	values map[string]map[string]string
+}
+
+func (p xattrPlatform) Getxattr(path, name string) ([]byte, error) {
+	value, ok := p.values[filepath.Base(path)][name]
+	if !ok {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: os.ErrNotExist}
+	}
+
+	return []byte(value), nil
+}
+
+func TestScanTimestampXattr(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"db.dump", "web.dump", "new.dump", "bad.dump", "none.dump"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	platform := xattrPlatform{
+		Platform: files.NewPlatform(),
+		values: map[string]map[string]string{
+			"db.dump":  {"user.backup.timestamp": "2025-01-02T03:04:05+01:00"},
+			"web.dump": {"user.backup.timestamp": "1735700000\n"},
+			"new.dump": {"user.other": "2025-01-01T00:00:00Z"},
+			"bad.dump": {"user.backup.timestamp": "yesterday"},
+		},
+	}
+
+	// The pattern needs no {year} when files are dated by an attribute
+	manager, err := NewManager(dir, "{tag}.dump",
+		WithPlatform(platform),
+		WithTimestampXattr("user.backup.timestamp"))
+	require.NoError(t, err)
+
+	result, err := manager.Scan(t.Context())
+	require.NoError(t, err)
+
+	stamps := map[string]time.Time{}
+	for _, f := range result.Files {
+		stamps[filepath.Base(f.Path)] = f.Timestamp
+	}
+
+	require.Len(t, stamps, 2)
+	require.True(t, time.Date(2025, 1, 2, 2, 4, 5, 0, time.UTC).Equal(stamps["db.dump"]))
+	require.Equal(t, time.Unix(1735700000, 0).UTC(), stamps["web.dump"])
+
+	skipped := map[string]SkipReason{}
+	for _, s := range result.Skipped {
+		skipped[filepath.Base(s.Path)] = s.Reason
+	}
+
+	require.Equal(t, map[string]SkipReason{
+		"new.dump":  SkipNoTimestamp,
+		"bad.dump":  SkipNoTimestamp,
+		"none.dump": SkipNoTimestamp,
+	}, skipped)
+}
+
+func TestScanTimestampXattrNotImplemented(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db.dump"), nil, 0o600))
+
+	manager, err := NewManager(dir, "{tag}.dump",
+		WithPlatform(noXattrPlatform{files.NewPlatform()}),
+		WithTimestampXattr("user.backup.timestamp"))
+	require.NoError(t, err)
+
+	_, err = manager.ListFiles(t.Context())
+	require.ErrorIs(t, err, ErrListFiles)
+	require.ErrorIs(t, err, files.ErrNotImplemented)
+}
+
+// noXattrPlatform is a Platform without extended attributes
+type noXattrPlatform struct {
+	files.Platform
+}
+
+func (noXattrPlatform) Getxattr(string, string) ([]byte, error) {
+	return nil, files.ErrNotImplemented
+}
+
+func TestParseStamp(t *testing.T) {
+	ts, err := parseStamp("2025-01-02T03:04:05.5Z")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 5e8, time.UTC), ts)
+
+	ts, err = parseStamp("0")
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(0, 0).UTC(), ts)
+
+	_, err = parseStamp("2025-01-02")
+	require.ErrorIs(t, err, ErrParseTimestamp)
+}
@@ -0,0 +1,175 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package hooks runs user supplied shell commands around prune runs. Each
+// hook receives information about the run through environment variables.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// ErrHookFailed is returned when a hook command exits unsuccessfully
+var ErrHookFailed = errors.New("hook failed")
+
+// EnvPrefix is prepended to every environment variable passed to a hook
+const EnvPrefix = "ARP_"
+
+// Names of the supported hooks, exposed to commands as ARP_HOOK
+const (
+	PreRun    = "pre_run"
+	PostRun   = "post_run"
+	OnError   = "on_error"
+	PreDelete = "pre_delete"
+	// ArchiveTransform is not run around a prune but by Pipe, for each
+	// backup moved to an archive
+	ArchiveTransform = "archive_transform"
+)
+
+// RunnerOption is a function that configures a Runner
+type RunnerOption func(*Runner)
+
+// Runner executes hook commands through the platform shell
+type Runner struct {
+	logger *logging.Logger
+}
+
+// WithLogger sets the logger for the Runner
+func WithLogger(logger *logging.Logger) RunnerOption {
+	return func(r *Runner) {
+		r.logger = logger
+	}
+}
+
+// NewRunner creates a new hook runner
+func NewRunner(opts ...RunnerOption) *Runner {
+	r := &Runner{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		}, // Default no-op logger
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Run executes the command for the named hook. An empty command is a no-op.
+// Each entry in env is exported as ARP_<KEY> alongside ARP_HOOK=<name>, on
+// top of the current process environment.
+func (r *Runner) Run(
+	ctx context.Context,
+	name, command string,
+	env map[string]string,
+) error {
+	if command == "" {
+		return nil
+	}
+
+	shell, flag := shellCommand()
+
+	// #nosec G204 - hook commands come from the trusted config file
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Env = append(os.Environ(), Environ(name, env)...)
+
+	r.logger.Debug("running hook",
+		zap.String("hook", name),
+		zap.String("command", command))
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		r.logger.Info("hook output",
+			zap.String("hook", name),
+			zap.String("output", strings.TrimSpace(string(output))))
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrHookFailed, name, err)
+	}
+
+	return nil
+}
+
+// Pipe runs command like Run, but feeds it in on stdin and copies its
+// standard output to out. Only its standard error is logged.
+func (r *Runner) Pipe(
+	ctx context.Context,
+	name, command string,
+	env map[string]string,
+	in io.Reader,
+	out io.Writer,
+) error {
+	shell, flag := shellCommand()
+
+	var stderr bytes.Buffer
+
+	// #nosec G204 - hook commands come from the trusted config file
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Env = append(os.Environ(), Environ(name, env)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, &stderr
+
+	r.logger.Debug("running hook",
+		zap.String("hook", name),
+		zap.String("command", command))
+
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		r.logger.Info("hook output",
+			zap.String("hook", name),
+			zap.String("output", strings.TrimSpace(stderr.String())))
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrHookFailed, name, err)
+	}
+
+	return nil
+}
+
+// Environ converts hook variables into KEY=value pairs, adding the ARP_
+// prefix and upper-casing keys. The result is sorted for stable output.
+func Environ(name string, env map[string]string) []string {
+	vars := []string{EnvPrefix + "HOOK=" + name}
+
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		vars = append(vars, EnvPrefix+strings.ToUpper(key)+"="+env[key])
+	}
+
+	return vars
+}
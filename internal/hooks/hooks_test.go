@@ -0,0 +1,96 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package hooks
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnviron(t *testing.T) {
+	env := Environ(PostRun, map[string]string{
+		"deleted":   "3",
+		"directory": "/backups",
+	})
+
+	require.Equal(t, []string{
+		"ARP_HOOK=post_run",
+		"ARP_DELETED=3",
+		"ARP_DIRECTORY=/backups",
+	}, env)
+}
+
+func TestRunner_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use POSIX shell syntax")
+	}
+
+	runner := NewRunner()
+
+	t.Run("pipes stdin to stdout", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := runner.Pipe(t.Context(), ArchiveTransform, `printf '%s:' "$ARP_PATH"; tr a-z A-Z`,
+			map[string]string{"path": "a.tar"}, strings.NewReader("backup"), &out)
+		require.NoError(t, err)
+		require.Equal(t, "a.tar:BACKUP", out.String())
+
+		err = runner.Pipe(t.Context(), ArchiveTransform, "cat >/dev/null; exit 1", nil,
+			strings.NewReader("backup"), &out)
+		require.ErrorIs(t, err, ErrHookFailed)
+	})
+
+	t.Run("empty command", func(t *testing.T) {
+		require.NoError(t, runner.Run(t.Context(), PreRun, "", nil))
+	})
+
+	t.Run("receives environment", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "env.txt")
+
+		err := runner.Run(
+			t.Context(),
+			PostRun,
+			`echo "$ARP_HOOK $ARP_DELETED" > "`+out+`"`,
+			map[string]string{"deleted": "7"},
+		)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, "post_run 7\n", string(content))
+	})
+
+	t.Run("failing command", func(t *testing.T) {
+		err := runner.Run(t.Context(), PreRun, "exit 3", nil)
+		require.ErrorIs(t, err, ErrHookFailed)
+		require.Contains(t, err.Error(), PreRun)
+	})
+}
@@ -0,0 +1,269 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package journal keeps an append-only record of the deletions of a prune
+// run. An intent entry is written before each deletion starts and a done
+// entry once it finishes, each synced to disk before the run carries on, so
+// the next run can tell which deletions were in flight when a run crashed.
+// The journal is a file of JSON lines, emptied once a run has finished and
+// its outcome is recorded.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+)
+
+var (
+	// ErrReadJournal is returned when the journal file cannot be read
+	ErrReadJournal = errors.New("failed to read journal")
+	// ErrWriteJournal is returned when an entry cannot be written to the
+	// journal file
+	ErrWriteJournal = errors.New("failed to write journal")
+)
+
+// Op is the kind of a journal entry
+type Op string
+
+const (
+	// OpIntent is written before a deletion starts
+	OpIntent Op = "intent"
+	// OpDone is written once a deletion has finished, successfully or not
+	OpDone Op = "done"
+)
+
+// Entry is a single line of the journal. Checksum is set on an intent entry
+// when the backup was checksummed before its deletion, and Error on a done
+// entry when the deletion failed.
+type Entry struct {
+	Op        Op               `json:"op"`
+	Time      time.Time        `json:"time"`
+	RunID     string           `json:"run_id,omitempty"`
+	Path      string           `json:"path"`
+	Timestamp time.Time        `json:"timestamp,omitzero"`
+	Reason    retention.Reason `json:"reason,omitempty"`
+	Tier      retention.Reason `json:"tier,omitempty"`
+	Checksum  string           `json:"checksum,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// Decision returns the deletion decision the entry was written for
+func (e Entry) Decision() retention.Decision {
+	return retention.Decision{
+		File:   file.Info{Path: e.Path, Timestamp: e.Timestamp, Checksum: e.Checksum},
+		Delete: true,
+		Reason: e.Reason,
+		Tier:   e.Tier,
+	}
+}
+
+// Option is a function that configures a Journal
+type Option func(*Journal)
+
+// Journal appends the deletions of a run to the journal file. It is not
+// safe for concurrent use.
+type Journal struct {
+	file  *os.File
+	now   func() time.Time
+	runID string
+}
+
+// WithClock sets the function used to timestamp entries
+func WithClock(now func() time.Time) Option {
+	return func(j *Journal) {
+		j.now = now
+	}
+}
+
+// WithRunID sets the ID of the run every entry is written for
+func WithRunID(id string) Option {
+	return func(j *Journal) {
+		j.runID = id
+	}
+}
+
+// Pending returns the intent entries of the journal at path that have no
+// done entry, in the order they were written: the deletions that were in
+// flight when the run writing the journal crashed. A missing journal has
+// none, and a crash may leave its last line half written, which is ignored.
+func Pending(path string) ([]Entry, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadJournal, err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var (
+		order []string
+		torn  error
+	)
+
+	started := make(map[string]Entry)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if torn != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrReadJournal, path, torn)
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			torn = err
+			continue
+		}
+
+		switch e.Op {
+		case OpIntent:
+			if _, ok := started[e.Path]; !ok {
+				order = append(order, e.Path)
+			}
+
+			started[e.Path] = e
+		case OpDone:
+			delete(started, e.Path)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadJournal, err)
+	}
+
+	var pending []Entry
+
+	for _, path := range order {
+		if e, ok := started[path]; ok {
+			pending = append(pending, e)
+			delete(started, path)
+		}
+	}
+
+	return pending, nil
+}
+
+// Open opens the journal at path for appending, creating it and its
+// directory if needed. Entries already in the journal are kept until it is
+// cleared.
+func Open(path string, opts ...Option) (*Journal, error) {
+	j := &Journal{now: time.Now}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWriteJournal, err)
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWriteJournal, err)
+	}
+
+	j.file = f
+
+	return j, nil
+}
+
+// Intent records that the deletion of the backup d decided on is about to
+// start. The deletion must not start unless it returns nil.
+func (j *Journal) Intent(d retention.Decision) error {
+	return j.write(Entry{
+		Op:        OpIntent,
+		Time:      j.now(),
+		RunID:     j.runID,
+		Path:      d.File.Path,
+		Timestamp: d.File.Timestamp,
+		Reason:    d.Reason,
+		Tier:      d.Tier,
+		Checksum:  d.File.Checksum,
+	})
+}
+
+// Done records that the deletion of the backup d decided on has finished.
+// err is the error the deletion failed with, if any.
+func (j *Journal) Done(d retention.Decision, err error) error {
+	e := Entry{Op: OpDone, Time: j.now(), RunID: j.runID, Path: d.File.Path}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return j.write(e)
+}
+
+// write appends a line for e and syncs it to disk
+func (j *Journal) write(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteJournal, err)
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteJournal, err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteJournal, err)
+	}
+
+	return nil
+}
+
+// Clear empties the journal and closes it, once the outcome of every
+// deletion in it has been recorded
+func (j *Journal) Clear() error {
+	err := j.file.Truncate(0)
+	if err == nil {
+		err = j.file.Sync()
+	}
+
+	return errors.Join(wrapWrite(err), j.Close())
+}
+
+// Close closes the journal, leaving its entries for the next run
+func (j *Journal) Close() error {
+	return wrapWrite(j.file.Close())
+}
+
+// wrapWrite wraps a non-nil err in ErrWriteJournal
+func wrapWrite(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", ErrWriteJournal, err)
+}
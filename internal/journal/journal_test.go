@@ -0,0 +1,133 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.jsonl")
+
+	now := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	decision := func(path string) retention.Decision {
+		return retention.Decision{
+			File: file.Info{
+				Path:      path,
+				Timestamp: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+				Checksum:  "54d00d867758cef816bc4685f58e327b949712b07ebd17c3485f3ffc9e9f5133",
+			},
+			Delete: true,
+			Reason: retention.ReasonExpired,
+			Tier:   retention.ReasonExpired,
+		}
+	}
+
+	t.Run("missing journal has nothing pending", func(t *testing.T) {
+		pending, err := Pending(path)
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+
+	t.Run("crashed run", func(t *testing.T) {
+		j, err := Open(path, WithClock(clock), WithRunID("0192f0c4-6d1e-7000-8000-000000000001"))
+		require.NoError(t, err)
+
+		require.NoError(t, j.Intent(decision("a.tar.gz")))
+		require.NoError(t, j.Done(decision("a.tar.gz"), nil))
+		require.NoError(t, j.Intent(decision("b.tar.gz")))
+		require.NoError(t, j.Done(decision("b.tar.gz"), errors.New("permission denied")))
+		require.NoError(t, j.Intent(decision("c.tar.gz")))
+
+		// The process dies here, without clearing the journal
+		require.NoError(t, j.Close())
+
+		pending, err := Pending(path)
+		require.NoError(t, err)
+		require.Equal(t, []Entry{{
+			Op:        OpIntent,
+			Time:      now,
+			RunID:     "0192f0c4-6d1e-7000-8000-000000000001",
+			Path:      "c.tar.gz",
+			Timestamp: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			Reason:    retention.ReasonExpired,
+			Tier:      retention.ReasonExpired,
+			Checksum:  "54d00d867758cef816bc4685f58e327b949712b07ebd17c3485f3ffc9e9f5133",
+		}}, pending)
+		require.Equal(t, decision("c.tar.gz"), pending[0].Decision())
+	})
+
+	t.Run("reopened journal keeps pending entries until cleared", func(t *testing.T) {
+		j, err := Open(path, WithClock(clock))
+		require.NoError(t, err)
+
+		require.NoError(t, j.Intent(decision("d.tar.gz")))
+		require.NoError(t, j.Done(decision("d.tar.gz"), nil))
+
+		pending, err := Pending(path)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		require.Equal(t, "c.tar.gz", pending[0].Path)
+
+		require.NoError(t, j.Clear())
+
+		pending, err = Pending(path)
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+}
+
+func TestPending_TornEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	intent := `{"op":"intent","time":"2024-03-16T00:00:00Z","path":"a.tar.gz"}` + "\n"
+
+	t.Run("last line", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(intent+`{"op":"do`), 0o600))
+
+		pending, err := Pending(path)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		require.Equal(t, "a.tar.gz", pending[0].Path)
+	})
+
+	t.Run("earlier line", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`{"op":"do`+"\n"+intent), 0o600))
+
+		_, err := Pending(path)
+		require.ErrorIs(t, err, ErrReadJournal)
+	})
+}
@@ -0,0 +1,411 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package kubernetes provides a backend for CSI VolumeSnapshot objects. It
+// talks to the Kubernetes API server directly with the credentials of the
+// pod's service account, listing the snapshots of a namespace that match a
+// label selector and deleting the ones the retention policy rejects. Only
+// in-cluster service account authentication is supported; kubeconfig files,
+// client certificates and exec credential plugins are not.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
+)
+
+const (
+	// ServiceAccountDir holds the credentials mounted into every pod
+	ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// apiPath is the path of the VolumeSnapshot API group version
+	apiPath = "/apis/snapshot.storage.k8s.io/v1"
+	// pageSize is the number of snapshots requested per list call
+	pageSize = 500
+)
+
+var (
+	// ErrNotInCluster is returned when no API server is configured and the
+	// process is not running in a pod
+	ErrNotInCluster = errors.New("not running in a Kubernetes cluster")
+	// ErrListSnapshots is returned when the snapshots cannot be listed
+	ErrListSnapshots = errors.New("failed to list volume snapshots")
+	// ErrDeleteSnapshot is returned when a snapshot cannot be deleted
+	ErrDeleteSnapshot = errors.New("failed to delete volume snapshot")
+)
+
+// Snapshots lists and deletes the VolumeSnapshots of a namespace
+type Snapshots struct {
+	logger    *logging.Logger
+	client    *http.Client
+	server    string
+	token     string
+	tokenFile string
+	namespace string
+	selector  string
+	pins      []string
+}
+
+// SnapshotsOption configures Snapshots
+type SnapshotsOption func(*Snapshots)
+
+// WithLogger sets the logger for the snapshot backend
+func WithLogger(logger *logging.Logger) SnapshotsOption {
+	return func(s *Snapshots) {
+		s.logger = logger
+	}
+}
+
+// WithPins sets glob patterns, matched against snapshot names, of snapshots
+// that must never be deleted
+func WithPins(pins []string) SnapshotsOption {
+	return func(s *Snapshots) {
+		s.pins = pins
+	}
+}
+
+// WithLabelSelector limits the snapshots to those matching a label
+// selector such as "app=postgres,tier!=test"
+func WithLabelSelector(selector string) SnapshotsOption {
+	return func(s *Snapshots) {
+		s.selector = selector
+	}
+}
+
+// WithServer replaces the in-cluster API server, client and bearer token
+func WithServer(server string, client *http.Client, token string) SnapshotsOption {
+	return func(s *Snapshots) {
+		s.server = server
+		s.client = client
+		s.token = token
+	}
+}
+
+// NewSnapshots creates a backend for the VolumeSnapshots in namespace. The
+// API server and credentials are taken from the pod environment unless
+// WithServer is given.
+func NewSnapshots(namespace string, opts ...SnapshotsOption) (*Snapshots, error) {
+	s := &Snapshots{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		},
+		namespace: namespace,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.server != "" {
+		return s, nil
+	}
+
+	if err := s.inCluster(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// inCluster configures the API server address from the service environment
+// variables and authenticates with the mounted service account token. The
+// kubelet rotates the token, so it is read again for every request.
+func (s *Snapshots) inCluster() error {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return ErrNotInCluster
+	}
+
+	tokenFile := path.Join(ServiceAccountDir, "token")
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotInCluster, err)
+	}
+
+	ca, err := os.ReadFile(path.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotInCluster, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("%w: invalid service account CA certificate", ErrNotInCluster)
+	}
+
+	s.server = "https://" + net.JoinHostPort(host, port)
+	s.token = strings.TrimSpace(string(token))
+	s.tokenFile = tokenFile
+	s.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		},
+		Timeout: time.Minute,
+	}
+
+	return nil
+}
+
+// volumeSnapshot is the subset of a VolumeSnapshot object used to date,
+// size and identify it
+type volumeSnapshot struct {
+	Metadata struct {
+		Name              string    `json:"name"`
+		Namespace         string    `json:"namespace"`
+		CreationTimestamp time.Time `json:"creationTimestamp"`
+	} `json:"metadata"`
+	Status *struct {
+		CreationTime *time.Time `json:"creationTime"`
+		ReadyToUse   *bool      `json:"readyToUse"`
+		RestoreSize  string     `json:"restoreSize"`
+	} `json:"status"`
+}
+
+// ready reports whether the storage snapshot has been cut and can be
+// restored from
+func (v *volumeSnapshot) ready() bool {
+	return v.Status != nil && v.Status.ReadyToUse != nil && *v.Status.ReadyToUse
+}
+
+// volumeSnapshotList is one page of a VolumeSnapshot list
+type volumeSnapshotList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []volumeSnapshot `json:"items"`
+}
+
+// ListFiles returns every ready snapshot in the namespace, oldest first.
+// The path of each entry is namespace/name.
+func (s *Snapshots) ListFiles(ctx context.Context) ([]file.Info, error) {
+	var snapshots []file.Info
+
+	err := s.WalkFiles(ctx, func(snapshot file.Info) error {
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(snapshots, func(a, b file.Info) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return snapshots, nil
+}
+
+// WalkFiles calls fn for every ready snapshot in the namespace, one list
+// page at a time. Snapshots that are not ready to use yet are skipped, so a
+// snapshot still being taken is neither counted nor deleted.
+func (s *Snapshots) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	query := url.Values{"limit": {fmt.Sprint(pageSize)}}
+	if s.selector != "" {
+		query.Set("labelSelector", s.selector)
+	}
+
+	for {
+		var page volumeSnapshotList
+
+		err := s.do(ctx, http.MethodGet, s.collection()+"?"+query.Encode(), &page)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrListSnapshots, err)
+		}
+
+		for _, snapshot := range page.Items {
+			if !snapshot.ready() {
+				s.logger.Debug("skipping snapshot that is not ready to use",
+					zap.String("snapshot", snapshot.Metadata.Name))
+
+				continue
+			}
+
+			if err := fn(s.info(snapshot)); err != nil {
+				return err
+			}
+		}
+
+		if page.Metadata.Continue == "" {
+			return nil
+		}
+
+		query.Set("continue", page.Metadata.Continue)
+	}
+}
+
+// DeleteFile deletes a snapshot. Whether its content and the storage
+// snapshot are removed too depends on the deletionPolicy of its
+// VolumeSnapshotClass. Snapshots of another namespace are refused with
+// file.ErrOutsideRoot.
+func (s *Snapshots) DeleteFile(ctx context.Context, snapshot file.Info, dryRun bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		s.logger.Info("dry run: would delete volume snapshot",
+			zap.String("snapshot", snapshot.Path),
+			zap.Time("timestamp", snapshot.Timestamp))
+
+		return nil
+	}
+
+	namespace, name, ok := strings.Cut(snapshot.Path, "/")
+	if !ok || namespace != s.namespace || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("%w: %s", file.ErrOutsideRoot, snapshot.Path)
+	}
+
+	err := s.do(ctx, http.MethodDelete, s.collection()+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrDeleteSnapshot, snapshot.Path, err)
+	}
+
+	s.logger.Info("deleted volume snapshot",
+		zap.String("snapshot", snapshot.Path),
+		zap.Time("timestamp", snapshot.Timestamp))
+
+	return nil
+}
+
+// collection returns the API path of the namespace's VolumeSnapshots
+func (s *Snapshots) collection() string {
+	return apiPath + "/namespaces/" + url.PathEscape(s.namespace) + "/volumesnapshots"
+}
+
+// info converts a snapshot into a backup entry. It is dated by the time the
+// storage snapshot was cut, falling back to when the object was created.
+func (s *Snapshots) info(snapshot volumeSnapshot) file.Info {
+	timestamp := snapshot.Metadata.CreationTimestamp
+	if snapshot.Status.CreationTime != nil {
+		timestamp = *snapshot.Status.CreationTime
+	}
+
+	return file.Info{
+		Path:      s.namespace + "/" + snapshot.Metadata.Name,
+		Timestamp: timestamp.UTC(),
+		Size:      quantityBytes(snapshot.Status.RestoreSize),
+		Pinned:    s.isPinned(snapshot.Metadata.Name),
+	}
+}
+
+// bearerToken returns the token to authenticate with, read from the service
+// account token file again when there is one
+func (s *Snapshots) bearerToken() (string, error) {
+	if s.tokenFile == "" {
+		return s.token, nil
+	}
+
+	token, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
+
+// do sends an API request and decodes the JSON response into out, if given
+func (s *Snapshots) do(ctx context.Context, method, uri string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.server+uri, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	token, err := s.bearerToken()
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// isPinned reports whether a snapshot name matches a pin
+func (s *Snapshots) isPinned(name string) bool {
+	for _, pin := range s.pins {
+		if ok, _ := path.Match(pin, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quantityBytes converts a Kubernetes quantity such as "10Gi" or "500M"
+// into bytes, returning zero for quantities it cannot parse
+func quantityBytes(quantity string) int64 {
+	if quantity == "" {
+		return 0
+	}
+
+	// Binary suffixes such as Gi and decimal suffixes such as G map onto
+	// the IEC and SI byte units once a B is appended
+	if strings.IndexByte("ikMGT", quantity[len(quantity)-1]) >= 0 {
+		quantity += "B"
+	}
+
+	size, err := units.ParseBytes(quantity)
+	if err != nil {
+		return 0
+	}
+
+	return size
+}
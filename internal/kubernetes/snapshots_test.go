@@ -0,0 +1,175 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package kubernetes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// newAPIServer starts a fake API server for the databases namespace and
+// records every deleted snapshot name
+func newAPIServer(t *testing.T, deleted *[]string) *httptest.Server {
+	t.Helper()
+
+	// List pages served by the fake API server, keyed by continue token
+	pages := map[string]string{
+		"": `{"metadata": {"continue": "page2"}, "items": [
+			{"metadata": {"name": "db-nightly-2", "creationTimestamp": "2024-03-16T02:00:05Z"},
+			 "status": {"creationTime": "2024-03-16T02:00:00Z", "readyToUse": true,
+			            "restoreSize": "10Gi"}},
+			{"metadata": {"name": "db-nightly-3", "creationTimestamp": "2024-03-17T02:00:05Z"},
+			 "status": {"readyToUse": false}}
+		]}`,
+		"page2": `{"metadata": {}, "items": [
+			{"metadata": {"name": "db-keep", "creationTimestamp": "2024-03-15T02:00:00Z"},
+			 "status": {"readyToUse": true, "restoreSize": "500M"}}
+		]}`,
+	}
+
+	collection := apiPath + "/namespaces/databases/volumesnapshots"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+collection, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("labelSelector") != "app=postgres" {
+			http.Error(w, "unexpected selector", http.StatusBadRequest)
+			return
+		}
+
+		_, _ = w.Write([]byte(pages[r.URL.Query().Get("continue")]))
+	})
+	mux.HandleFunc("DELETE "+collection+"/{name}", func(w http.ResponseWriter, r *http.Request) {
+		*deleted = append(*deleted, r.PathValue("name"))
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestSnapshots_ListFiles(t *testing.T) {
+	var deleted []string
+
+	server := newAPIServer(t, &deleted)
+
+	snapshots, err := NewSnapshots("databases",
+		WithServer(server.URL, server.Client(), "secret"),
+		WithLabelSelector("app=postgres"),
+		WithPins([]string{"*-keep"}),
+	)
+	require.NoError(t, err)
+
+	list, err := snapshots.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []file.Info{
+		{
+			Path:      "databases/db-keep",
+			Timestamp: time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC),
+			Size:      500_000_000,
+			Pinned:    true,
+		},
+		{
+			Path:      "databases/db-nightly-2",
+			Timestamp: time.Date(2024, 3, 16, 2, 0, 0, 0, time.UTC),
+			Size:      10 << 30,
+		},
+	}, list)
+
+	t.Run("unauthorized", func(t *testing.T) {
+		snapshots, err := NewSnapshots("databases",
+			WithServer(server.URL, server.Client(), "wrong"),
+			WithLabelSelector("app=postgres"),
+		)
+		require.NoError(t, err)
+
+		_, err = snapshots.ListFiles(t.Context())
+		require.ErrorIs(t, err, ErrListSnapshots)
+		require.ErrorContains(t, err, "401 Unauthorized")
+	})
+
+	t.Run("rotated token", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0o600))
+
+		snapshots, err := NewSnapshots("databases",
+			WithServer(server.URL, server.Client(), ""),
+			WithLabelSelector("app=postgres"),
+		)
+		require.NoError(t, err)
+
+		snapshots.tokenFile = tokenFile
+
+		_, err = snapshots.ListFiles(t.Context())
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(tokenFile, []byte("rotated\n"), 0o600))
+
+		_, err = snapshots.ListFiles(t.Context())
+		require.ErrorContains(t, err, "401 Unauthorized")
+	})
+}
+
+func TestSnapshots_DeleteFile(t *testing.T) {
+	var deleted []string
+
+	server := newAPIServer(t, &deleted)
+
+	snapshots, err := NewSnapshots("databases", WithServer(server.URL, server.Client(), ""))
+	require.NoError(t, err)
+
+	snapshot := file.Info{Path: "databases/db-nightly-2"}
+
+	require.NoError(t, snapshots.DeleteFile(t.Context(), snapshot, true))
+	require.Empty(t, deleted)
+
+	require.NoError(t, snapshots.DeleteFile(t.Context(), snapshot, false))
+	require.Equal(t, []string{"db-nightly-2"}, deleted)
+
+	err = snapshots.DeleteFile(t.Context(), file.Info{Path: "other/db-nightly-2"}, false)
+	require.ErrorIs(t, err, file.ErrOutsideRoot)
+	require.Len(t, deleted, 1)
+}
+
+func TestNewSnapshots_NotInCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	_, err := NewSnapshots("databases")
+	require.ErrorIs(t, err, ErrNotInCluster)
+}
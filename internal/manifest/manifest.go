@@ -0,0 +1,490 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package manifest provides a backend for backups listed in a manifest file
+// written by the backup tool, in place of walking the backup directory.
+// Every entry of the manifest names a backup and when it was taken;
+// deleting a backup removes both the backup and its entry.
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// Formats a manifest can be written in
+const (
+	// FormatJSON is a JSON array of entries
+	FormatJSON = "json"
+	// FormatCSV is a CSV file whose header row names the columns path,
+	// timestamp and, optionally, size and tag
+	FormatCSV = "csv"
+)
+
+var (
+	// ErrReadManifest is returned when the manifest cannot be read or parsed
+	ErrReadManifest = errors.New("failed to read manifest")
+	// ErrWriteManifest is returned when the entry of a deleted backup cannot
+	// be removed from the manifest
+	ErrWriteManifest = errors.New("failed to update manifest")
+	// ErrDeleteBackup is returned when a backup cannot be deleted
+	ErrDeleteBackup = errors.New("failed to delete backup")
+)
+
+// Entry is a backup listed in a manifest. Path is relative to the backup
+// directory unless it is absolute, and Timestamp is in RFC 3339 format.
+// Size and Tag are optional.
+type Entry struct {
+	Path      string    `json:"path"`
+	Timestamp time.Time `json:"timestamp"`
+	Size      int64     `json:"size,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+}
+
+// Inventory lists and deletes the backups of a manifest
+type Inventory struct {
+	logger    *logging.Logger
+	directory string
+	path      string
+	format    string
+	pins      []string
+
+	// mu serializes rewriting the manifest
+	mu sync.Mutex
+}
+
+// InventoryOption configures an Inventory
+type InventoryOption func(*Inventory)
+
+// WithLogger sets the logger for the manifest backend
+func WithLogger(logger *logging.Logger) InventoryOption {
+	return func(inv *Inventory) {
+		inv.logger = logger
+	}
+}
+
+// WithPins sets glob patterns, matched against the paths in the manifest,
+// of backups that must never be deleted
+func WithPins(pins []string) InventoryOption {
+	return func(inv *Inventory) {
+		inv.pins = pins
+	}
+}
+
+// WithFormat sets the format of the manifest, FormatJSON or FormatCSV. By
+// default a manifest ending in .csv is read as CSV and any other as JSON.
+func WithFormat(format string) InventoryOption {
+	return func(inv *Inventory) {
+		inv.format = format
+	}
+}
+
+// NewInventory creates a backend for the backups in directory listed by the
+// manifest at path, which is relative to directory unless it is absolute
+func NewInventory(directory, path string, opts ...InventoryOption) *Inventory {
+	inv := &Inventory{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		},
+		directory: directory,
+		path:      path,
+	}
+
+	for _, opt := range opts {
+		opt(inv)
+	}
+
+	if !filepath.IsAbs(inv.path) {
+		inv.path = filepath.Join(directory, inv.path)
+	}
+
+	if inv.format == "" {
+		inv.format = FormatJSON
+		if strings.EqualFold(filepath.Ext(inv.path), ".csv") {
+			inv.format = FormatCSV
+		}
+	}
+
+	return inv
+}
+
+// ListFiles returns every backup in the manifest, oldest first
+func (inv *Inventory) ListFiles(ctx context.Context) ([]file.Info, error) {
+	var backups []file.Info
+
+	err := inv.WalkFiles(ctx, func(f file.Info) error {
+		backups = append(backups, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(backups, func(a, b file.Info) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return backups, nil
+}
+
+// WalkFiles calls fn for every backup in the manifest, in manifest order.
+// Backups are listed whether or not they still exist, so the entries of
+// backups removed by other means can still be pruned.
+func (inv *Inventory) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	doc, err := inv.read()
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrReadManifest, inv.path, err)
+	}
+
+	for _, entry := range doc.entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := inv.resolve(entry.Path)
+
+		err := fn(file.Info{
+			Path:      path,
+			Timestamp: entry.Timestamp,
+			Size:      entry.Size,
+			Tag:       entry.Tag,
+			Pinned:    file.IsPinned(inv.pins, path, filepath.FromSlash(entry.Path)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteFile deletes a backup, with everything below it if it is a
+// directory, and then removes its entry from the manifest. A backup that no
+// longer exists only has its entry removed. Backups that do not reside
+// under the directory are refused with file.ErrOutsideRoot.
+func (inv *Inventory) DeleteFile(ctx context.Context, f file.Info, dryRun bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		inv.logger.Info("dry run: would delete backup",
+			zap.String("path", f.Path),
+			zap.Time("timestamp", f.Timestamp))
+
+		return nil
+	}
+
+	if _, err := os.Lstat(f.Path); err == nil {
+		rel, err := file.RelativeToRoot(inv.directory, f.Path)
+		if err != nil {
+			return err
+		}
+
+		if err := file.RemoveAllInRoot(inv.directory, rel); err != nil {
+			return fmt.Errorf("%w %s: %w", ErrDeleteBackup, f.Path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w %s: %w", ErrDeleteBackup, f.Path, err)
+	}
+
+	if err := inv.removeEntry(f.Path); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrWriteManifest, inv.path, err)
+	}
+
+	inv.logger.Info("deleted backup",
+		zap.String("path", f.Path),
+		zap.Time("timestamp", f.Timestamp))
+
+	return nil
+}
+
+// resolve returns the path of a backup named by a manifest entry
+func (inv *Inventory) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(inv.directory, filepath.FromSlash(path))
+}
+
+// removeEntry rewrites the manifest without the entries of the backup at
+// path. The manifest is read again first, so entries the backup tool added
+// since it was listed are kept, and replaced atomically.
+func (inv *Inventory) removeEntry(path string) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	doc, err := inv.read()
+	if err != nil {
+		return err
+	}
+
+	kept := doc.without(func(entry Entry) bool {
+		return inv.resolve(entry.Path) == path
+	})
+
+	data, err := kept.encode()
+	if err != nil {
+		return err
+	}
+
+	return writeFile(inv.path, data)
+}
+
+// document is a parsed manifest. The raw form of every entry is kept, so
+// fields and columns this package does not know survive rewriting.
+type document struct {
+	format  string
+	entries []Entry
+	objects []json.RawMessage
+	header  []string
+	records [][]string
+}
+
+// read reads and parses the manifest
+func (inv *Inventory) read() (*document, error) {
+	data, err := os.ReadFile(inv.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if inv.format == FormatCSV {
+		return parseCSV(data)
+	}
+
+	return parseJSON(data)
+}
+
+// parseJSON parses a JSON manifest
+func parseJSON(data []byte) (*document, error) {
+	doc := &document{format: FormatJSON}
+
+	if err := json.Unmarshal(data, &doc.objects); err != nil {
+		return nil, err
+	}
+
+	for i, object := range doc.objects {
+		var entry Entry
+		if err := json.Unmarshal(object, &entry); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+
+		if err := entry.check(); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+
+		doc.entries = append(doc.entries, entry)
+	}
+
+	return doc, nil
+}
+
+// parseCSV parses a CSV manifest
+func parseCSV(data []byte) (*document, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	doc := &document{format: FormatCSV, header: records[0], records: records[1:]}
+
+	columns := map[string]int{}
+	for i, name := range doc.header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"path", "timestamp"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	for i, record := range doc.records {
+		entry, err := csvEntry(columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+
+		doc.entries = append(doc.entries, entry)
+	}
+
+	return doc, nil
+}
+
+// csvEntry reads an entry from a CSV record given the index of every column
+func csvEntry(columns map[string]int, record []string) (Entry, error) {
+	column := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	entry := Entry{Path: column("path"), Tag: column("tag")}
+
+	var err error
+	if entry.Timestamp, err = time.Parse(time.RFC3339Nano, column("timestamp")); err != nil {
+		return Entry{}, err
+	}
+
+	if size := column("size"); size != "" {
+		if entry.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return Entry{}, fmt.Errorf("invalid size %q: %w", size, err)
+		}
+	}
+
+	return entry, entry.check()
+}
+
+// check reports an entry missing its path or timestamp
+func (e Entry) check() error {
+	switch {
+	case e.Path == "":
+		return errors.New("missing path")
+	case e.Timestamp.IsZero():
+		return errors.New("missing timestamp")
+	}
+
+	return nil
+}
+
+// without returns the document without the entries matching drop
+func (d *document) without(drop func(Entry) bool) *document {
+	kept := &document{format: d.format, header: d.header}
+
+	for i, entry := range d.entries {
+		if drop(entry) {
+			continue
+		}
+
+		kept.entries = append(kept.entries, entry)
+
+		if d.format == FormatCSV {
+			kept.records = append(kept.records, d.records[i])
+		} else {
+			kept.objects = append(kept.objects, d.objects[i])
+		}
+	}
+
+	return kept
+}
+
+// encode returns the manifest in its format
+func (d *document) encode() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if d.format == FormatCSV {
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(append([][]string{d.header}, d.records...)); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	objects := d.objects
+	if objects == nil {
+		objects = []json.RawMessage{}
+	}
+
+	data, err := json.Marshal(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// writeFile replaces the file at path with data through a temporary file,
+// keeping the mode of the file it replaces
+func writeFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	// Remove the temporary file on any failure below
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	committed = true
+
+	return nil
+}
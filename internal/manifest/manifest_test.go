@@ -0,0 +1,204 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+const jsonManifest = `[
+  {"path": "db-1.tar", "timestamp": "2025-01-02T02:00:00Z", "size": 10, "sha256": "aa"},
+  {"path": "db-0.tar", "timestamp": "2025-01-01T02:00:00Z", "tag": "db"},
+  {"path": "gone.tar", "timestamp": "2024-12-31T02:00:00Z"}
+]
+`
+
+// writeBackups creates the named backups and the manifest in dir
+func writeBackups(t *testing.T, dir, manifest, content string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, manifest), []byte(content), 0o600))
+}
+
+func TestInventory_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeBackups(t, dir, "manifest.json", jsonManifest, "db-0.tar", "db-1.tar")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db-1.tar.hold"), nil, 0o600))
+
+	backups, err := NewInventory(dir, "manifest.json").ListFiles(t.Context())
+	require.NoError(t, err)
+
+	require.Equal(t, []file.Info{
+		{
+			Path:      filepath.Join(dir, "gone.tar"),
+			Timestamp: time.Date(2024, 12, 31, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			Path:      filepath.Join(dir, "db-0.tar"),
+			Timestamp: time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC),
+			Tag:       "db",
+		},
+		{
+			Path:      filepath.Join(dir, "db-1.tar"),
+			Timestamp: time.Date(2025, 1, 2, 2, 0, 0, 0, time.UTC),
+			Size:      10,
+			Pinned:    true,
+		},
+	}, backups)
+}
+
+func TestInventory_DeleteFile(t *testing.T) {
+	dir := t.TempDir()
+	writeBackups(t, dir, "manifest.json", jsonManifest, "db-0.tar", "db-1.tar")
+
+	inv := NewInventory(dir, filepath.Join(dir, "manifest.json"), WithPins([]string{"db-1*"}))
+
+	backups, err := inv.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.True(t, backups[2].Pinned)
+
+	// A dry run changes nothing
+	require.NoError(t, inv.DeleteFile(t.Context(), backups[1], true))
+	require.FileExists(t, backups[1].Path)
+
+	require.NoError(t, inv.DeleteFile(t.Context(), backups[1], false))
+	require.NoFileExists(t, backups[1].Path)
+
+	// A backup removed by other means only loses its entry
+	require.NoError(t, inv.DeleteFile(t.Context(), backups[0], false))
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	require.NoError(t, err)
+	require.JSONEq(t,
+		`[{"path": "db-1.tar", "timestamp": "2025-01-02T02:00:00Z", "size": 10, "sha256": "aa"}]`,
+		string(data))
+
+	remaining, err := inv.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, backups[2:], remaining)
+}
+
+func TestInventory_DeleteFileOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "other.tar")
+	require.NoError(t, os.WriteFile(outside, nil, 0o600))
+
+	writeBackups(t, dir, "manifest.json",
+		`[{"path": "`+filepath.ToSlash(outside)+`", "timestamp": "2025-01-01T00:00:00Z"}]`)
+
+	inv := NewInventory(dir, "manifest.json")
+
+	backups, err := inv.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	err = inv.DeleteFile(t.Context(), backups[0], false)
+	require.ErrorIs(t, err, file.ErrOutsideRoot)
+	require.FileExists(t, outside)
+}
+
+func TestInventory_CSV(t *testing.T) {
+	dir := t.TempDir()
+	writeBackups(t, dir, "backups.csv", "Timestamp,path,note\n"+
+		"2025-01-01T02:00:00Z,daily/db-0.tar,first\n"+
+		"2025-01-02T02:00:00+01:00,daily/db-1.tar,\"second, newest\"\n")
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "daily"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "daily", "db-0.tar"), nil, 0o600))
+
+	inv := NewInventory(dir, "backups.csv")
+
+	backups, err := inv.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	require.Equal(t, filepath.Join(dir, "daily", "db-0.tar"), backups[0].Path)
+	require.True(t, time.Date(2025, 1, 2, 1, 0, 0, 0, time.UTC).Equal(backups[1].Timestamp))
+
+	require.NoError(t, inv.DeleteFile(t.Context(), backups[0], false))
+	require.NoFileExists(t, backups[0].Path)
+
+	data, err := os.ReadFile(filepath.Join(dir, "backups.csv"))
+	require.NoError(t, err)
+	require.Equal(t,
+		"Timestamp,path,note\n2025-01-02T02:00:00+01:00,daily/db-1.tar,\"second, newest\"\n",
+		string(data))
+}
+
+func TestInventory_InvalidManifest(t *testing.T) {
+	for name, tc := range map[string]struct {
+		manifest, content, want string
+	}{
+		"missing":  {manifest: "absent.json", want: "absent.json"},
+		"not json": {manifest: "m.json", content: "{", want: "unexpected end"},
+		"no path": {
+			manifest: "m.json",
+			content:  `[{"timestamp": "2025-01-01T00:00:00Z"}]`,
+			want:     "entry 1: missing path",
+		},
+		"no timestamp": {
+			manifest: "m.json",
+			content:  `[{"path": "a"}]`,
+			want:     "entry 1: missing timestamp",
+		},
+		"csv without rows": {manifest: "m.csv", want: "missing header row"},
+		"csv no timestamp": {
+			manifest: "m.csv",
+			content:  "path\na\n",
+			want:     "missing timestamp column",
+		},
+		"csv bad timestamp": {
+			manifest: "m.csv",
+			content:  "path,timestamp\na,today\n",
+			want:     "row 2",
+		},
+		"csv bad size": {
+			manifest: "m.csv",
+			content:  "path,timestamp,size\na,2025-01-01T00:00:00Z,big\n",
+			want:     `row 2: invalid size "big"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.manifest != "absent.json" {
+				writeBackups(t, dir, tc.manifest, tc.content)
+			}
+
+			_, err := NewInventory(dir, tc.manifest).ListFiles(t.Context())
+			require.ErrorIs(t, err, ErrReadManifest)
+			require.ErrorContains(t, err, tc.want)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package notify
+
+import (
+	"context"
+)
+
+// Embed colors used to highlight the run outcome in Discord
+const (
+	discordColorOK    = 0x2ECC71
+	discordColorError = 0xE74C3C
+)
+
+// DiscordSender posts run summaries to a Discord webhook
+type DiscordSender struct {
+	webhook
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordPayload struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+// NewDiscordSender creates a sender for the given Discord webhook URL
+func NewDiscordSender(webhookURL string, opts ...SenderOption) *DiscordSender {
+	return &DiscordSender{
+		webhook: newWebhook(webhookURL, opts),
+	}
+}
+
+// Name implements Sender.Name
+func (s *DiscordSender) Name() string {
+	return "discord"
+}
+
+// Send implements Sender.Send
+func (s *DiscordSender) Send(ctx context.Context, summary Summary) error {
+	return s.post(ctx, s.payload(summary))
+}
+
+// Alert implements Sender.Alert
+func (s *DiscordSender) Alert(ctx context.Context, alert Alert) error {
+	return s.post(ctx, discordPayload{
+		Content: alert.Title,
+		Embeds: []discordEmbed{{
+			Title:       alert.Title,
+			Description: bulletList(alert.Problems),
+			Color:       discordColorError,
+		}},
+	})
+}
+
+func (s *DiscordSender) payload(summary Summary) discordPayload {
+	embed := discordEmbed{
+		Title:       title(summary),
+		Description: errorList(summary),
+		Color:       discordColorOK,
+	}
+
+	if failed(summary) {
+		embed.Color = discordColorError
+	}
+
+	for _, field := range summaryFields(summary) {
+		embed.Fields = append(embed.Fields, discordField{
+			Name:   field[0],
+			Value:  field[1],
+			Inline: true,
+		})
+	}
+
+	return discordPayload{
+		Content: title(summary),
+		Embeds:  []discordEmbed{embed},
+	}
+}
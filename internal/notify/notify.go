@@ -0,0 +1,268 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package notify delivers prune run summaries to external chat services.
+// Senders are configured from the notifications section of the config file.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/units"
+)
+
+// ErrSendFailed is returned when a webhook rejects a notification
+var ErrSendFailed = errors.New("failed to send notification")
+
+// defaultTimeout bounds how long a single webhook request may take
+const defaultTimeout = 10 * time.Second
+
+// maxListedErrors limits how many error messages are included in a message
+const maxListedErrors = 5
+
+// Summary describes the outcome of a prune run
+type Summary struct {
+	// RunID identifies the run in its logs, journal and reports
+	RunID          string
+	Directory      string
+	DryRun         bool
+	Matched        int
+	Deleted        int
+	ReclaimedBytes int64
+	Errors         []string
+	// RetriesExhausted counts the errors from deletions that still failed
+	// after every configured retry
+	RetriesExhausted int
+	// Archived counts the deleted backups that were moved to an archive
+	Archived int
+	// Compressed counts the kept backups that were compressed
+	Compressed int
+	// Warnings lists the problems that did not fail the run, such as
+	// backups that were skipped while listing
+	Warnings []Warning
+	// Tiers reports how many periods every retention tier was asked to keep
+	// and how many it found a backup for
+	Tiers []Tier
+}
+
+// Warning is a problem found during a run that did not fail it. Severity is
+// warning or error.
+type Warning struct {
+	Severity string
+	Message  string
+}
+
+// Tier reports the periods a retention tier was asked to keep for the
+// backups of a directory and tag, and how many of them it filled.
+// Suggestion describes how to tune a tier that was not filled.
+type Tier struct {
+	Directory  string
+	Tag        string
+	Name       string
+	Requested  int
+	Filled     int
+	Suggestion string
+}
+
+// Alert describes a problem found outside a prune run, such as backups that
+// stopped arriving
+type Alert struct {
+	Title string
+	// Problems lists what is wrong, one entry per affected directory
+	Problems []string
+}
+
+// Sender delivers a run summary to an external service
+type Sender interface {
+	// Name returns a short identifier for the sender, used in logs
+	Name() string
+	// Send delivers the summary
+	Send(ctx context.Context, summary Summary) error
+	// Alert delivers an alert, always highlighted as a failure
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// SenderOption is a function that configures a webhook sender
+type SenderOption func(*webhook)
+
+// WithHTTPClient sets the HTTP client used to deliver webhooks
+func WithHTTPClient(client *http.Client) SenderOption {
+	return func(w *webhook) {
+		w.client = client
+	}
+}
+
+// FromConfig builds a sender for every notification target that has a
+// webhook URL configured
+func FromConfig(cfg config.NotificationsConfig, opts ...SenderOption) []Sender {
+	var senders []Sender
+
+	if cfg.Slack.WebhookURL != "" {
+		senders = append(
+			senders,
+			NewSlackSender(cfg.Slack.WebhookURL, cfg.Slack.Channel, opts...),
+		)
+	}
+
+	if cfg.Discord.WebhookURL != "" {
+		senders = append(senders, NewDiscordSender(cfg.Discord.WebhookURL, opts...))
+	}
+
+	return senders
+}
+
+// webhook holds the state shared by all JSON webhook senders
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhook(url string, opts []SenderOption) webhook {
+	w := webhook{
+		url:    url,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+
+	for _, opt := range opts {
+		opt(&w)
+	}
+
+	return w
+}
+
+// post encodes the payload as JSON and posts it to the webhook URL
+func (w *webhook) post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		w.url,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: unexpected status %s", ErrSendFailed, resp.Status)
+	}
+
+	return nil
+}
+
+// title returns the headline used by all message formats
+func title(s Summary) string {
+	if s.DryRun {
+		return "Retention policy dry run: " + s.Directory
+	}
+
+	return "Retention policy applied: " + s.Directory
+}
+
+// failed reports whether the run should be highlighted as unsuccessful
+func failed(s Summary) bool {
+	return len(s.Errors) > 0
+}
+
+// summaryFields returns the name/value pairs rendered by every sender
+func summaryFields(s Summary) [][2]string {
+	deletedLabel := "Deleted"
+	if s.DryRun {
+		deletedLabel = "Would delete"
+	}
+
+	fields := [][2]string{
+		{"Matched", fmt.Sprintf("%d", s.Matched)},
+		{deletedLabel, fmt.Sprintf("%d", s.Deleted)},
+		{"Reclaimed", units.FormatBytes(s.ReclaimedBytes)},
+		{"Errors", fmt.Sprintf("%d", len(s.Errors))},
+	}
+
+	if len(s.Warnings) > 0 {
+		fields = append(fields, [2]string{"Warnings", fmt.Sprintf("%d", len(s.Warnings))})
+	}
+
+	if s.RetriesExhausted > 0 {
+		fields = append(fields,
+			[2]string{"Retries exhausted", fmt.Sprintf("%d", s.RetriesExhausted)})
+	}
+
+	if s.Archived > 0 {
+		fields = append(fields, [2]string{"Archived", fmt.Sprintf("%d", s.Archived)})
+	}
+
+	if s.Compressed > 0 {
+		fields = append(fields, [2]string{"Compressed", fmt.Sprintf("%d", s.Compressed)})
+	}
+
+	if s.RunID != "" {
+		fields = append(fields, [2]string{"Run ID", s.RunID})
+	}
+
+	return fields
+}
+
+// errorList renders the first few errors as a bulleted list
+func errorList(s Summary) string {
+	return bulletList(s.Errors)
+}
+
+// bulletList renders the first few messages as a bulleted list
+func bulletList(msgs []string) string {
+	var buf bytes.Buffer
+
+	for i, msg := range msgs {
+		if i == maxListedErrors {
+			fmt.Fprintf(&buf, "… and %d more\n", len(msgs)-maxListedErrors)
+			break
+		}
+
+		fmt.Fprintf(&buf, "• %s\n", msg)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,184 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+)
+
+func newTestServer(t *testing.T, status int, body *map[string]any) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestFromConfig(t *testing.T) {
+	t.Run("no targets", func(t *testing.T) {
+		require.Empty(t, FromConfig(config.NotificationsConfig{}))
+	})
+
+	t.Run("all targets", func(t *testing.T) {
+		senders := FromConfig(config.NotificationsConfig{
+			Slack:   config.SlackConfig{WebhookURL: "https://hooks.slack.test/x"},
+			Discord: config.DiscordConfig{WebhookURL: "https://discord.test/api/webhooks/x"},
+		})
+		require.Len(t, senders, 2)
+		require.Equal(t, "slack", senders[0].Name())
+		require.Equal(t, "discord", senders[1].Name())
+	})
+}
+
+func TestSlackSender(t *testing.T) {
+	var body map[string]any
+
+	server := newTestServer(t, http.StatusOK, &body)
+	sender := NewSlackSender(server.URL, "#backups", WithHTTPClient(server.Client()))
+
+	err := sender.Send(t.Context(), Summary{
+		RunID:          "3f1c2b7e-5a4d-4e8f-9b6a-0c1d2e3f4a5b",
+		Directory:      "/backups",
+		Matched:        10,
+		Deleted:        3,
+		ReclaimedBytes: 3 * 1024 * 1024,
+		Errors:         []string{"boom"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "#backups", body["channel"])
+	require.Equal(t, "Retention policy applied: /backups", body["text"])
+
+	attachments := body["attachments"].([]any)
+	require.Len(t, attachments, 1)
+
+	attachment := attachments[0].(map[string]any)
+	require.Equal(t, slackColorError, attachment["color"])
+	require.Contains(t, attachment["text"], "boom")
+
+	fields := attachment["fields"].([]any)
+	values := map[string]any{}
+
+	for _, f := range fields {
+		field := f.(map[string]any)
+		values[field["title"].(string)] = field["value"]
+	}
+
+	require.Equal(t, "10", values["Matched"])
+	require.Equal(t, "3", values["Deleted"])
+	require.Equal(t, "3.0 MiB", values["Reclaimed"])
+	require.Equal(t, "1", values["Errors"])
+	require.Equal(t, "3f1c2b7e-5a4d-4e8f-9b6a-0c1d2e3f4a5b", values["Run ID"])
+}
+
+func TestDiscordSender(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		var body map[string]any
+
+		server := newTestServer(t, http.StatusNoContent, &body)
+		sender := NewDiscordSender(server.URL, WithHTTPClient(server.Client()))
+
+		err := sender.Send(t.Context(), Summary{
+			Directory: "/backups",
+			DryRun:    true,
+			Matched:   2,
+			Deleted:   1,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Retention policy dry run: /backups", body["content"])
+
+		embed := body["embeds"].([]any)[0].(map[string]any)
+		require.InDelta(t, float64(discordColorOK), embed["color"], 0)
+
+		field := embed["fields"].([]any)[1].(map[string]any)
+		require.Equal(t, "Would delete", field["name"])
+		require.Equal(t, "1", field["value"])
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		var body map[string]any
+
+		server := newTestServer(t, http.StatusBadRequest, &body)
+		sender := NewDiscordSender(server.URL, WithHTTPClient(server.Client()))
+
+		err := sender.Send(t.Context(), Summary{Directory: "/backups"})
+		require.Error(t, err)
+		require.ErrorIs(t, err, ErrSendFailed)
+	})
+}
+
+func TestSenderAlert(t *testing.T) {
+	alert := Alert{
+		Title:    "Backups are stale",
+		Problems: []string{"/backups: newest backup is 3d ago"},
+	}
+
+	t.Run("slack", func(t *testing.T) {
+		var body map[string]any
+
+		server := newTestServer(t, http.StatusOK, &body)
+		sender := NewSlackSender(server.URL, "", WithHTTPClient(server.Client()))
+
+		require.NoError(t, sender.Alert(t.Context(), alert))
+		require.Equal(t, "Backups are stale", body["text"])
+
+		attachment := body["attachments"].([]any)[0].(map[string]any)
+		require.Equal(t, slackColorError, attachment["color"])
+		require.Equal(t, "• /backups: newest backup is 3d ago\n", attachment["text"])
+	})
+
+	t.Run("discord", func(t *testing.T) {
+		var body map[string]any
+
+		server := newTestServer(t, http.StatusNoContent, &body)
+		sender := NewDiscordSender(server.URL, WithHTTPClient(server.Client()))
+
+		require.NoError(t, sender.Alert(t.Context(), alert))
+		require.Equal(t, "Backups are stale", body["content"])
+
+		embed := body["embeds"].([]any)[0].(map[string]any)
+		require.InDelta(t, float64(discordColorError), embed["color"], 0)
+		require.NotContains(t, embed, "fields")
+	})
+}
+
+func TestErrorList(t *testing.T) {
+	errs := []string{"a", "b", "c", "d", "e", "f", "g"}
+	list := errorList(Summary{Errors: errs})
+	require.Contains(t, list, "• e\n")
+	require.NotContains(t, list, "• f\n")
+	require.Contains(t, list, "and 2 more")
+}
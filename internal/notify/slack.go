@@ -0,0 +1,118 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package notify
+
+import (
+	"context"
+)
+
+// Attachment colors used to highlight the run outcome in Slack
+const (
+	slackColorOK    = "good"
+	slackColorError = "danger"
+)
+
+// SlackSender posts run summaries to a Slack incoming webhook
+type SlackSender struct {
+	webhook
+	channel string
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text,omitempty"`
+	Fields []slackField `json:"fields,omitempty"`
+}
+
+type slackPayload struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// NewSlackSender creates a sender for the given Slack webhook URL. The
+// channel overrides the webhook's default channel when non-empty.
+func NewSlackSender(webhookURL, channel string, opts ...SenderOption) *SlackSender {
+	return &SlackSender{
+		webhook: newWebhook(webhookURL, opts),
+		channel: channel,
+	}
+}
+
+// Name implements Sender.Name
+func (s *SlackSender) Name() string {
+	return "slack"
+}
+
+// Send implements Sender.Send
+func (s *SlackSender) Send(ctx context.Context, summary Summary) error {
+	return s.post(ctx, s.payload(summary))
+}
+
+// Alert implements Sender.Alert
+func (s *SlackSender) Alert(ctx context.Context, alert Alert) error {
+	return s.post(ctx, slackPayload{
+		Channel: s.channel,
+		Text:    alert.Title,
+		Attachments: []slackAttachment{{
+			Color: slackColorError,
+			Title: alert.Title,
+			Text:  bulletList(alert.Problems),
+		}},
+	})
+}
+
+func (s *SlackSender) payload(summary Summary) slackPayload {
+	attachment := slackAttachment{
+		Color: slackColorOK,
+		Title: title(summary),
+		Text:  errorList(summary),
+	}
+
+	if failed(summary) {
+		attachment.Color = slackColorError
+	}
+
+	for _, field := range summaryFields(summary) {
+		attachment.Fields = append(attachment.Fields, slackField{
+			Title: field[0],
+			Value: field[1],
+			Short: true,
+		})
+	}
+
+	return slackPayload{
+		Channel:     s.channel,
+		Text:        title(summary),
+		Attachments: []slackAttachment{attachment},
+	}
+}
@@ -0,0 +1,192 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package repository applies the retention policy to deduplicating backup
+// repositories. Instead of deleting backups itself it runs restic forget or
+// borg prune with keep flags derived from the retention settings, so the
+// repository tool removes the snapshots and archives it no longer needs.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+var (
+	// ErrUnknownTool is returned for a repository tool that is not supported
+	ErrUnknownTool = errors.New("unknown repository tool")
+	// ErrForget is returned when the repository tool fails
+	ErrForget = errors.New("failed to apply retention to repository")
+)
+
+// CommandRunner runs an external command and returns its combined output
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Policy holds the retention settings translated into keep flags
+type Policy struct {
+	Retention      config.RetentionPolicy
+	KeepCount      int
+	RequireMinimum int
+}
+
+// Repository runs the forget command of a restic or borg repository
+type Repository struct {
+	logger   *logging.Logger
+	tool     string
+	location string
+	run      CommandRunner
+}
+
+// Option configures a Repository
+type Option func(*Repository)
+
+// WithLogger sets the logger for the repository
+func WithLogger(logger *logging.Logger) Option {
+	return func(r *Repository) {
+		r.logger = logger
+	}
+}
+
+// WithCommandRunner replaces the function used to run the repository tool
+func WithCommandRunner(run CommandRunner) Option {
+	return func(r *Repository) {
+		r.run = run
+	}
+}
+
+// New creates a repository at location managed by tool, which is
+// config.BackendRestic or config.BackendBorg. Credentials such as
+// RESTIC_PASSWORD or BORG_PASSPHRASE are passed on from the environment.
+func New(tool, location string, opts ...Option) (*Repository, error) {
+	if tool != config.BackendRestic && tool != config.BackendBorg {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTool, tool)
+	}
+
+	r := &Repository{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		},
+		tool:     tool,
+		location: location,
+		run:      runCommand,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Args returns the command line arguments, without the tool itself, that
+// apply policy to the repository. restic forget is run with --prune so
+// unreferenced data is removed as well; borg only marks the archives as
+// deleted and, from borg 1.2, needs a separate borg compact to free space.
+func (r *Repository) Args(policy Policy, dryRun bool) []string {
+	var args []string
+
+	switch r.tool {
+	case config.BackendRestic:
+		args = []string{"--repo", r.location, "forget", "--prune"}
+	case config.BackendBorg:
+		args = []string{"prune", "--list"}
+	}
+
+	args = append(args, keepFlags(policy)...)
+
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+
+	if r.tool == config.BackendBorg {
+		args = append(args, r.location)
+	}
+
+	return args
+}
+
+// Forget applies policy to the repository. The output of the repository
+// tool is logged, and included in the error when it fails.
+func (r *Repository) Forget(ctx context.Context, policy Policy, dryRun bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	args := r.Args(policy, dryRun)
+
+	r.logger.Info("applying retention to repository",
+		zap.String("tool", r.tool),
+		zap.String("repository", r.location),
+		zap.Strings("args", args))
+
+	out, err := r.run(ctx, r.tool, args...)
+	output := strings.TrimSpace(string(out))
+
+	if err != nil {
+		return fmt.Errorf("%w %s: %w: %s", ErrForget, r.location, err, output)
+	}
+
+	r.logger.Info("applied retention to repository",
+		zap.String("repository", r.location),
+		zap.Bool("dry_run", dryRun),
+		zap.String("output", output))
+
+	return nil
+}
+
+// keepFlags translates policy into the keep flags shared by restic and borg.
+// keep_count and require_minimum both keep the newest backups regardless of
+// their period, so the larger of the two becomes --keep-last.
+func keepFlags(policy Policy) []string {
+	var flags []string
+
+	add := func(flag string, n int) {
+		if n > 0 {
+			flags = append(flags, flag, strconv.Itoa(n))
+		}
+	}
+
+	add("--keep-last", max(policy.KeepCount, policy.RequireMinimum))
+	add("--keep-hourly", policy.Retention.Hourly)
+	add("--keep-daily", policy.Retention.Daily)
+	add("--keep-weekly", policy.Retention.Weekly)
+	add("--keep-monthly", policy.Retention.Monthly)
+	add("--keep-yearly", policy.Retention.Yearly)
+
+	return flags
+}
+
+// runCommand runs an external command and returns its combined output
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
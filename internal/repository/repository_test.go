@@ -0,0 +1,89 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+)
+
+func TestRepository_Args(t *testing.T) {
+	policy := Policy{
+		Retention:      config.RetentionPolicy{Daily: 7, Weekly: 4},
+		RequireMinimum: 2,
+	}
+
+	restic, err := New(config.BackendRestic, "/srv/restic")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"--repo", "/srv/restic", "forget", "--prune",
+		"--keep-last", "2", "--keep-daily", "7", "--keep-weekly", "4",
+	}, restic.Args(policy, false))
+
+	borg, err := New(config.BackendBorg, "/srv/borg")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"prune", "--list", "--keep-last", "5", "--dry-run", "/srv/borg",
+	}, borg.Args(Policy{KeepCount: 5, RequireMinimum: 1}, true))
+
+	_, err = New("tape", "/dev/st0")
+	require.ErrorIs(t, err, ErrUnknownTool)
+}
+
+func TestRepository_Forget(t *testing.T) {
+	var calls [][]string
+
+	run := func(_ context.Context, name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		return []byte("removed snapshot 1a2b3c4d\n"), nil
+	}
+
+	repo, err := New(config.BackendRestic, "/srv/restic", WithCommandRunner(run))
+	require.NoError(t, err)
+
+	policy := Policy{Retention: config.RetentionPolicy{Hourly: 24}}
+	require.NoError(t, repo.Forget(t.Context(), policy, false))
+	require.Equal(t, [][]string{
+		{"restic", "--repo", "/srv/restic", "forget", "--prune", "--keep-hourly", "24"},
+	}, calls)
+
+	t.Run("tool fails", func(t *testing.T) {
+		repo, err := New(config.BackendBorg, "/srv/borg", WithCommandRunner(
+			func(context.Context, string, ...string) ([]byte, error) {
+				return []byte("Repository /srv/borg does not exist.\n"), errors.New("exit status 2")
+			},
+		))
+		require.NoError(t, err)
+
+		err = repo.Forget(t.Context(), policy, false)
+		require.ErrorIs(t, err, ErrForget)
+		require.ErrorContains(t, err, "does not exist")
+	})
+}
@@ -0,0 +1,108 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// benchmarkSizes are the numbers of files the benchmarks apply a policy to
+var benchmarkSizes = []int{1_000, 100_000, 1_000_000}
+
+// benchmarkFiles returns n backups taken every ten minutes, oldest first
+func benchmarkFiles(n int) []file.Info {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := make([]file.Info, n)
+
+	for i := range files {
+		timestamp := start.Add(time.Duration(i) * 10 * time.Minute)
+		files[i] = file.Info{
+			Path:      fmt.Sprintf("backup-%d.tar.gz", i),
+			Timestamp: timestamp,
+		}
+	}
+
+	return files
+}
+
+// benchmarkPolicy returns a typical policy with every tier in use
+func benchmarkPolicy() *Policy {
+	return NewPolicy(&logging.Logger{Logger: zap.NewNop()}, &config.Config{
+		Retention: config.RetentionPolicy{
+			Hourly:  24,
+			Daily:   7,
+			Weekly:  4,
+			Monthly: 12,
+			Yearly:  10,
+		},
+		RequireMinimum: 1,
+	})
+}
+
+func BenchmarkPolicy_Apply(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			files := benchmarkFiles(n)
+			policy := benchmarkPolicy()
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				if _, err := policy.Apply(files); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPolicy_ApplyStream(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			files := benchmarkFiles(n)
+			policy := benchmarkPolicy()
+			walk := walkSlice(files)
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				err := policy.ApplyStream(b.Context(), walk, func(Decision) error {
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
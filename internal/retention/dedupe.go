@@ -0,0 +1,124 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// contentGroup is the tag, day and size shared by backups that may be
+// byte-identical to each other
+type contentGroup struct {
+	tag  string
+	day  int64
+	size int64
+}
+
+// duplicates finds every backup that is byte-identical to a newer backup of
+// the same tag and day, and returns the path of the newer backup keyed by
+// the path of the duplicate. Only backups sharing their tag, day and size
+// with another backup are hashed, so the files are listed twice: first to
+// count the backups in every group, then to hash those in shared groups.
+// Backups that cannot be hashed are treated as unique. Nothing is listed
+// unless dedupe is enabled.
+func (p *Policy) duplicates(ctx context.Context, walk WalkFunc) (map[string]string, error) {
+	duplicates := map[string]string{}
+	if !p.config.Dedupe {
+		return duplicates, nil
+	}
+
+	day := shifted(dayGrouper, p.config.DayBoundaryOffset)
+	group := func(f file.Info) contentGroup {
+		return contentGroup{tag: f.Tag, day: day(f), size: f.Size}
+	}
+
+	counts := map[contentGroup]int{}
+
+	err := walk(ctx, func(f file.Info) error {
+		counts[group(f)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shared := map[contentGroup][]file.Info{}
+
+	err = walk(ctx, func(f file.Info) error {
+		if g := group(f); counts[g] > 1 {
+			shared[g] = append(shared[g], f)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, files := range shared {
+		// The newest copy of every content is the one kept, ties are broken
+		// like those between periods so repeated listings agree
+		slices.SortFunc(files, p.before)
+
+		newest := map[string]string{}
+
+		for _, f := range files {
+			sum, err := file.Checksum(f.Path)
+			if err != nil {
+				if !errors.Is(err, file.ErrNotRegularFile) {
+					p.logger.Warn("failed to checksum backup, keeping it out of dedupe",
+						zap.String("file", f.Path),
+						zap.Error(err))
+				}
+
+				continue
+			}
+
+			if by, ok := newest[sum]; ok {
+				p.logger.Info("found duplicate backup",
+					zap.String("file", f.Path),
+					zap.String("duplicate_of", by))
+				duplicates[f.Path] = by
+
+				continue
+			}
+
+			newest[sum] = f.Path
+		}
+	}
+
+	return duplicates, nil
+}
+
+// duplicate returns the decision for a backup identical to a newer one
+func duplicate(f file.Info) Decision {
+	return Decision{File: f, Delete: true, Reason: ReasonDuplicate, Tier: ReasonDuplicate}
+}
@@ -0,0 +1,152 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// Gap is a period within the reach of a tier that holds no kept backup.
+// Start and End bound the period in wall-clock time, End excluded.
+type Gap struct {
+	Tag   string
+	Tier  Reason
+	Start time.Time
+	End   time.Time
+}
+
+// Gaps returns the periods without a kept backup among the count newest
+// periods of every tier, counted back from the period of the newest backup
+// result keeps for each tag. The tiers themselves skip periods without
+// backups and reach further back instead, so a gap is a backup the
+// configured cadence expected but never got. keep_count has no cadence and
+// the thinning tiers only begin where the others end, so neither reports
+// gaps. Gaps are ordered by tag, finest tier first, and then
+// newest first.
+func (p *Policy) Gaps(result *Result) []Gap {
+	kept := map[string][]time.Time{}
+
+	for _, d := range result.Decisions {
+		if !d.Delete {
+			kept[d.File.Tag] = append(kept[d.File.Tag], d.File.Timestamp)
+		}
+	}
+
+	var gaps []Gap
+
+	for _, tag := range slices.Sorted(maps.Keys(kept)) {
+		for _, t := range p.tiersFor(tag) {
+			if t.reason == ReasonKeepCount || t.reason == ReasonThinning || t.count == 0 {
+				continue
+			}
+
+			gaps = append(gaps, p.tierGaps(tag, t, kept[tag])...)
+		}
+	}
+
+	return gaps
+}
+
+// tierGaps returns the gaps of a single tier among the kept timestamps of
+// a tag, newest first
+func (p *Policy) tierGaps(tag string, t tier, kept []time.Time) []Gap {
+	// Like the tiers, only the daily and coarser periods are shifted
+	offset := p.config.DayBoundaryOffset
+	if t.reason == ReasonHourly {
+		offset = 0
+	}
+
+	yearStart := p.config.YearStart()
+	covered := map[int64]bool{}
+
+	for _, ts := range kept {
+		covered[periodStart(t.reason, ts.Add(offset), yearStart).Unix()] = true
+	}
+
+	newest := slices.MaxFunc(kept, time.Time.Compare)
+	start := periodStart(t.reason, newest.Add(offset), yearStart)
+
+	var gaps []Gap
+
+	for range t.count {
+		if !covered[start.Unix()] {
+			gaps = append(gaps, Gap{
+				Tag:   tag,
+				Tier:  t.reason,
+				Start: start.Add(-offset),
+				End:   addPeriods(t.reason, start, 1).Add(-offset),
+			})
+		}
+
+		start = addPeriods(t.reason, start, -1)
+	}
+
+	return gaps
+}
+
+// periodStart returns the start of the hour, day, ISO week, month or year
+// of a tier containing ts, with years beginning in the month yearStart
+func periodStart(reason Reason, ts time.Time, yearStart time.Month) time.Time {
+	year, month, day := ts.Date()
+
+	switch reason {
+	case ReasonHourly:
+		return time.Date(year, month, day, ts.Hour(), 0, 0, 0, ts.Location())
+	case ReasonWeekly:
+		// ISO weeks start on Monday
+		back := (int(ts.Weekday()) + 6) % 7
+		return time.Date(year, month, day-back, 0, 0, 0, 0, ts.Location())
+	case ReasonMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, ts.Location())
+	case ReasonYearly:
+		if month < yearStart {
+			year--
+		}
+
+		return time.Date(year, yearStart, 1, 0, 0, 0, 0, ts.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, ts.Location())
+	}
+}
+
+// addPeriods moves the start of a period of a tier n periods forward, or
+// backward for a negative n
+func addPeriods(reason Reason, start time.Time, n int) time.Time {
+	switch reason {
+	case ReasonHourly:
+		year, month, day := start.Date()
+		return time.Date(year, month, day, start.Hour()+n, 0, 0, 0, start.Location())
+	case ReasonWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case ReasonMonthly:
+		return start.AddDate(0, n, 0)
+	case ReasonYearly:
+		return start.AddDate(n, 0, 0)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
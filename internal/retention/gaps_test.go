@@ -0,0 +1,149 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+func TestPolicy_Gaps(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+
+	backup := func(tag string, year int, month time.Month, day, hour int) file.Info {
+		ts := time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+		return file.Info{Path: tag + ts.Format(time.RFC3339), Tag: tag, Timestamp: ts}
+	}
+
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("missing days and months", func(t *testing.T) {
+		files := []file.Info{backup("", 2024, time.January, 15, 12)}
+
+		for d := 1; d <= 15; d++ {
+			if d != 10 && d != 12 {
+				files = append(files, backup("", 2024, time.March, d, 12))
+			}
+		}
+
+		policy := NewPolicy(logger, &config.Config{
+			Retention: config.RetentionPolicy{Daily: 7, Monthly: 3},
+		})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		require.Equal(t, []Gap{
+			{Tier: ReasonDaily, Start: day(time.March, 12), End: day(time.March, 13)},
+			{Tier: ReasonDaily, Start: day(time.March, 10), End: day(time.March, 11)},
+			{Tier: ReasonMonthly, Start: day(time.February, 1), End: day(time.March, 1)},
+		}, policy.Gaps(result))
+	})
+
+	t.Run("every tag has its own cadence", func(t *testing.T) {
+		files := []file.Info{
+			backup("db", 2024, time.March, 15, 12),
+			backup("db", 2024, time.March, 13, 12),
+			backup("www", 2024, time.March, 14, 12),
+			backup("www", 2024, time.March, 4, 12),
+		}
+
+		policy := NewPolicy(logger, &config.Config{
+			Retention: config.RetentionPolicy{Daily: 2},
+			TagRetention: config.TagPolicies{
+				"www": {Weekly: 2},
+			},
+		})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		require.Equal(t, []Gap{
+			{Tag: "db", Tier: ReasonDaily, Start: day(time.March, 14), End: day(time.March, 15)},
+		}, policy.Gaps(result))
+	})
+
+	t.Run("day boundary offset", func(t *testing.T) {
+		// With days starting at 06:00 the backup at 03:00 belongs to the
+		// day before
+		files := []file.Info{
+			backup("", 2024, time.March, 15, 12),
+			backup("", 2024, time.March, 15, 3),
+		}
+
+		policy := NewPolicy(logger, &config.Config{
+			Retention:         config.RetentionPolicy{Daily: 3},
+			DayBoundaryOffset: -6 * time.Hour,
+		})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		require.Equal(t, []Gap{{
+			Tier:  ReasonDaily,
+			Start: day(time.March, 13).Add(6 * time.Hour),
+			End:   day(time.March, 14).Add(6 * time.Hour),
+		}}, policy.Gaps(result))
+	})
+
+	t.Run("fiscal years", func(t *testing.T) {
+		// Fiscal years starting in April: March 2024 belongs to 2023/24
+		files := []file.Info{
+			backup("", 2024, time.April, 1, 12),
+			backup("", 2022, time.May, 1, 12),
+		}
+
+		policy := NewPolicy(logger, &config.Config{
+			Retention:       config.RetentionPolicy{Yearly: 3},
+			FiscalYearStart: 4,
+		})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		require.Equal(t, []Gap{{
+			Tier:  ReasonYearly,
+			Start: time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC),
+			End:   day(time.April, 1),
+		}}, policy.Gaps(result))
+	})
+
+	t.Run("keep_count has no cadence", func(t *testing.T) {
+		policy := NewPolicy(logger, &config.Config{KeepCount: 3})
+
+		result, err := policy.Apply([]file.Info{backup("", 2024, time.March, 1, 12)})
+		require.NoError(t, err)
+		require.Empty(t, policy.Gaps(result))
+	})
+}
@@ -0,0 +1,205 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// FuzzPolicyInvariants applies random policies to random sets of backups and
+// checks the properties every result must have. go test runs the seed
+// corpus; go test -fuzz=FuzzPolicyInvariants explores further.
+func FuzzPolicyInvariants(f *testing.F) {
+	for seed := range uint64(32) {
+		f.Add(seed, uint16(1+seed*17), uint8(seed%7), uint8(seed%5), uint8(seed%3), false)
+	}
+
+	f.Add(uint64(99), uint16(200), uint8(0), uint8(0), uint8(2), true)
+
+	f.Fuzz(func(
+		t *testing.T,
+		seed uint64,
+		n uint16,
+		daily, monthly, minimum uint8,
+		keepCount bool,
+	) {
+		rng := rand.New(rand.NewPCG(seed, uint64(n)))
+		files := randomFiles(rng, 1+int(n)%400)
+
+		cfg := &config.Config{
+			Retention: config.RetentionPolicy{
+				Hourly:  rng.IntN(4),
+				Daily:   int(daily % 10),
+				Weekly:  rng.IntN(4),
+				Monthly: int(monthly % 13),
+				Yearly:  rng.IntN(3),
+			},
+			TagRetention:      config.TagPolicies{"db": {Daily: rng.IntN(10)}},
+			RequireMinimum:    int(minimum % 20),
+			DayBoundaryOffset: -time.Duration(rng.IntN(12)) * time.Hour,
+		}
+
+		if keepCount {
+			cfg.Retention = config.RetentionPolicy{}
+			cfg.TagRetention = nil
+			cfg.KeepCount = 1 + int(daily%10)
+		}
+
+		policy := NewPolicy(&logging.Logger{Logger: zap.NewNop()}, cfg)
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		checkDecisions(t, policy, files, result)
+		checkKeptPeriods(t, policy, result)
+		checkReapplied(t, policy, result)
+		checkOlderFileAdded(t, policy, files, result)
+	})
+}
+
+// checkDecisions checks that every file is decided once, that pinned files,
+// the dependencies of kept files and at least RequireMinimum files survive
+func checkDecisions(t *testing.T, policy *Policy, files []file.Info, result *Result) {
+	t.Helper()
+
+	require.Len(t, result.Decisions, len(files))
+
+	kept := map[string]bool{}
+	for _, d := range result.Decisions {
+		_, dup := kept[d.File.Path]
+		require.False(t, dup, "%s decided twice", d.File.Path)
+
+		kept[d.File.Path] = !d.Delete
+	}
+
+	for _, d := range result.Decisions {
+		require.False(t, d.Delete && d.File.Pinned, "pinned %s deleted", d.File.Path)
+
+		if !d.Delete && d.File.DependsOn != "" {
+			require.True(t, kept[d.File.DependsOn],
+				"%s kept without its dependency %s", d.File.Path, d.File.DependsOn)
+		}
+	}
+
+	require.GreaterOrEqual(t, len(result.Kept()), min(policy.config.RequireMinimum, len(files)))
+}
+
+// checkKeptPeriods checks that the policy never deletes more than it allows:
+// every tier keeps a backup in as many of its periods as it is configured
+// to, or in all of them if there are fewer, and the newest backup of a tag
+// is kept whenever one of its tiers keeps anything
+func checkKeptPeriods(t *testing.T, policy *Policy, result *Result) {
+	t.Helper()
+
+	byTag := map[string][]Decision{}
+	for _, d := range result.Decisions {
+		byTag[d.File.Tag] = append(byTag[d.File.Tag], d)
+	}
+
+	for tag, decisions := range byTag {
+		newest := decisions[0]
+
+		for _, d := range decisions {
+			if d.File.Timestamp.After(newest.File.Timestamp) {
+				newest = d
+			}
+		}
+
+		keeps := false
+
+		for _, tier := range policy.tiersFor(tag) {
+			periods := map[int64]bool{}
+			keptPeriods := map[int64]bool{}
+
+			for _, d := range decisions {
+				periods[tier.key(d.File)] = true
+
+				if !d.Delete {
+					keptPeriods[tier.key(d.File)] = true
+				}
+			}
+
+			require.GreaterOrEqual(t, len(keptPeriods), min(tier.count, len(periods)),
+				"tag %q: %s tier keeps too few periods", tag, tier.reason)
+
+			keeps = keeps || tier.count > 0
+		}
+
+		if keeps {
+			require.False(t, newest.Delete, "tag %q: newest backup deleted", tag)
+		}
+	}
+}
+
+// checkReapplied checks that applying the policy again to the backups it
+// kept deletes none of them
+func checkReapplied(t *testing.T, policy *Policy, result *Result) {
+	t.Helper()
+
+	again, err := policy.Apply(result.Kept())
+	require.NoError(t, err)
+	require.Empty(t, again.Deleted(), "reapplying the policy deletes kept backups")
+}
+
+// checkOlderFileAdded checks that a backup older than all the others does
+// not change the decisions of the others, save for those only kept to meet
+// RequireMinimum, which the new backup may meet instead, and their
+// dependencies
+func checkOlderFileAdded(t *testing.T, policy *Policy, files []file.Info, result *Result) {
+	t.Helper()
+
+	oldest := result.Decisions[0].File
+	added := file.Info{
+		Path:      "backup-added",
+		Timestamp: oldest.Timestamp.Add(-time.Minute),
+		Tag:       files[len(files)-1].Tag,
+	}
+
+	again, err := policy.Apply(append(files[:len(files):len(files)], added))
+	require.NoError(t, err)
+
+	decided := map[string]Decision{}
+	for _, d := range again.Decisions {
+		decided[d.File.Path] = d
+	}
+
+	for _, want := range result.Decisions {
+		if want.Reason == ReasonRequireMinimum || want.Reason == ReasonDependency {
+			continue
+		}
+
+		require.Equal(t, want, decided[want.File.Path],
+			"adding an older backup changed the decision on %s", want.File.Path)
+	}
+}
@@ -28,6 +28,10 @@
 package retention
 
 import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
 	"slices"
 	"time"
 
@@ -42,218 +46,761 @@
 type Policy struct {
 	logger *logging.Logger
 	config *config.Config
+	stats  func(TierStats)
+}
+
+// PolicyOption configures a Policy
+type PolicyOption func(*Policy)
+
+// WithTierStats calls fn with the statistics of every tier with a count, for
+// every tag, each time the policy is applied
+func WithTierStats(fn func(TierStats)) PolicyOption {
+	return func(p *Policy) {
+		p.stats = fn
+	}
 }
 
 // NewPolicy creates a new retention policy
-func NewPolicy(logger *logging.Logger, conf *config.Config) *Policy {
-	return &Policy{
+func NewPolicy(logger *logging.Logger, conf *config.Config, opts ...PolicyOption) *Policy {
+	p := &Policy{
 		logger: logger,
 		config: conf,
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
-// weekMultiplier is used to combine year and week numbers into a single integer
-// by multiplying the year by 100 and adding the week number
-const weekMultiplier = 100
+// Multipliers combining the fields of a calendar date into a single integer
+// that grows with the date, such as 20240229 for Feb 29 2024
+const (
+	// weekMultiplier combines a year and an ISO week number
+	weekMultiplier = 100
+	// monthMultiplier combines a year and a month
+	monthMultiplier = 100
+	// dayMultiplier combines a year and month with a day
+	dayMultiplier = 100
+)
 
-// grouper functions for different time periods
+// grouper functions for different time periods. Days and coarser periods
+// are keyed by the calendar date on the wall clock of the timestamp's
+// location rather than by an instant, so a day of 23 or 25 hours around a
+// DST change is still a single day, and hours are keyed by the instant the
+// hour began, so the hour repeated when clocks go back counts twice.
 var (
 	// hourGrouper groups files by hour
 	hourGrouper = func(f file.Info) int64 {
-		return time.Date(
-			f.Timestamp.Year(),
-			f.Timestamp.Month(),
-			f.Timestamp.Day(),
-			f.Timestamp.Hour(),
-			0,
-			0,
-			0,
-			f.Timestamp.Location(),
-		).Unix()
+		ts := f.Timestamp
+		elapsed := time.Duration(ts.Minute())*time.Minute +
+			time.Duration(ts.Second())*time.Second +
+			time.Duration(ts.Nanosecond())
+
+		return ts.Add(-elapsed).Unix()
 	}
 
 	// dayGrouper groups files by day
 	dayGrouper = func(f file.Info) int64 {
-		return time.Date(
-			f.Timestamp.Year(),
-			f.Timestamp.Month(),
-			f.Timestamp.Day(),
-			0,
-			0,
-			0,
-			0,
-			f.Timestamp.Location(),
-		).Unix()
+		year, month, day := f.Timestamp.Date()
+		return (int64(year)*monthMultiplier+int64(month))*dayMultiplier + int64(day)
 	}
 
 	// weekGrouper groups files by ISO week
-	weekGrouper = func(f file.Info) int {
+	weekGrouper = func(f file.Info) int64 {
 		year, week := f.Timestamp.ISOWeek()
-		return (year * weekMultiplier) + week
+		return int64(year*weekMultiplier + week)
 	}
 
 	// monthGrouper groups files by month
 	monthGrouper = func(f file.Info) int64 {
-		return time.Date(
-			f.Timestamp.Year(),
-			f.Timestamp.Month(),
-			1,
-			0,
-			0,
-			0,
-			0,
-			f.Timestamp.Location(),
-		).Unix()
-	}
-
-	// yearGrouper groups files by year
+		year, month, _ := f.Timestamp.Date()
+		return int64(year)*monthMultiplier + int64(month)
+	}
+
+	// yearGrouper groups files by calendar year
 	yearGrouper = func(f file.Info) int64 {
-		return time.Date(
-			f.Timestamp.Year(),
-			1,
-			1,
-			0,
-			0,
-			0,
-			0,
-			f.Timestamp.Location(),
-		).Unix()
+		return int64(f.Timestamp.Year())
+	}
+
+	// instantGrouper puts every file in a period of its own, unless it was
+	// taken at exactly the same instant as another
+	instantGrouper = func(f file.Info) int64 {
+		return f.Timestamp.UnixNano()
 	}
 )
 
-// Apply applies the retention policy to the given files
-func (p *Policy) Apply(files []file.Info) ([]file.Info, error) {
+// Reason explains why the policy kept or deleted a file
+type Reason string
+
+// Reasons a file is kept or deleted
+const (
+	// ReasonHourly means the file fills an hourly slot
+	ReasonHourly Reason = "hourly"
+	// ReasonDaily means the file fills a daily slot
+	ReasonDaily Reason = "daily"
+	// ReasonWeekly means the file fills a weekly slot
+	ReasonWeekly Reason = "weekly"
+	// ReasonMonthly means the file fills a monthly slot
+	ReasonMonthly Reason = "monthly"
+	// ReasonYearly means the file fills a yearly slot
+	ReasonYearly Reason = "yearly"
+	// ReasonThinning means the file fills a slot of a thinning tier, which
+	// samples the history older than the yearly tier keeps
+	ReasonThinning Reason = "thinning"
+	// ReasonKeepCount means the file is one of the newest keep_count files
+	ReasonKeepCount Reason = "keep_count"
+	// ReasonDuplicate means the file is byte-identical to a newer file of
+	// the same tag and day, and does not take a slot
+	ReasonDuplicate Reason = "duplicate"
+	// ReasonPinned means the file is pinned and is never deleted
+	ReasonPinned Reason = "pinned"
+	// ReasonRequireMinimum means the file is kept to satisfy require_minimum
+	ReasonRequireMinimum Reason = "require_minimum"
+	// ReasonSuperseded means a newer file was kept for the same period
+	ReasonSuperseded Reason = "superseded"
+	// ReasonExpired means the file is older than every retention slot
+	ReasonExpired Reason = "expired"
+	// ReasonDependency means a kept backup depends on the file
+	ReasonDependency Reason = "dependency"
+	// ReasonEmergency means the file was deleted to recover free space
+	ReasonEmergency Reason = "emergency"
+	// ReasonNew means the file was not listed when the policy was computed
+	// by ApplyStream and is left for the next run
+	ReasonNew Reason = "new"
+)
+
+// relaxOrder is the order in which Relax gives up kept files: first those
+// kept outside the tiers, then the tiers from the coarsest to the finest
+var relaxOrder = []Reason{
+	ReasonDuplicate,
+	ReasonExpired,
+	ReasonKeepCount,
+	ReasonThinning,
+	ReasonYearly,
+	ReasonMonthly,
+	ReasonWeekly,
+	ReasonDaily,
+	ReasonHourly,
+}
+
+// Decision records whether the policy deletes a file and why. Slot is the
+// 1-based position of the file within its tier, counting from the newest
+// period, and is zero for decisions not made by a tier. Tier is the tier
+// whose period the file fell into, even if a newer file superseded it or a
+// later rule overrode the tier's decision, and ReasonExpired for files
+// outside every tier.
+type Decision struct {
+	File   file.Info
+	Delete bool
+	Reason Reason
+	Slot   int
+	Tier   Reason
+}
+
+// Result is the outcome of applying a policy, with a decision for every
+// input file, oldest first
+type Result struct {
+	Decisions []Decision
+}
+
+// Deleted returns the files the policy deletes, oldest first
+func (r *Result) Deleted() []file.Info {
+	return r.files(true)
+}
+
+// Kept returns the files the policy keeps, oldest first
+func (r *Result) Kept() []file.Info {
+	return r.files(false)
+}
+
+// files returns the files whose decision matches deleted
+func (r *Result) files(deleted bool) []file.Info {
+	var files []file.Info
+
+	for _, d := range r.Decisions {
+		if d.Delete == deleted {
+			files = append(files, d.File)
+		}
+	}
+
+	return files
+}
+
+// tier is one of the hourly to yearly tiers of a retention policy, or a
+// thinning tier of every years beyond them, keeping one file of each of its
+// count newest periods. Without an anchor that is the newest file of the
+// period; otherwise it is the oldest file anchored reports true for, or the
+// newest file if it reports true for none.
+type tier struct {
+	reason   Reason
+	key      func(file.Info) int64
+	count    int
+	anchored func(file.Info) bool
+	every    int
+}
+
+// name identifies a tier in logs and statistics: its reason, followed by
+// the years of its periods for a thinning tier, such as thinning_5y
+func (t tier) name() string {
+	if t.every > 0 {
+		return fmt.Sprintf("%s_%dy", t.reason, t.every)
+	}
+
+	return string(t.reason)
+}
+
+// tiers returns the tiers of a retention policy, finest first. The daily
+// and coarser tiers shift every timestamp by day_boundary_offset before
+// grouping, so with an offset of -6h a day runs from 06:00 to 06:00. The
+// monthly tier keeps the file of each month monthly_anchor picks, and the
+// years of the yearly and thinning tiers begin in the month of
+// fiscal_year_start.
+func tiers(retention config.RetentionPolicy, conf *config.Config) []tier {
+	dayOffset := conf.DayBoundaryOffset
+	years := fiscalYearGrouper(conf.YearStart())
+
+	monthly := tier{
+		reason:   ReasonMonthly,
+		key:      shifted(monthGrouper, dayOffset),
+		count:    retention.Monthly,
+		anchored: anchoredOn(conf.MonthlyAnchor, dayOffset),
+	}
+
+	result := []tier{
+		{reason: ReasonHourly, key: hourGrouper, count: retention.Hourly},
+		{reason: ReasonDaily, key: shifted(dayGrouper, dayOffset), count: retention.Daily},
+		{reason: ReasonWeekly, key: shifted(weekGrouper, dayOffset), count: retention.Weekly},
+		monthly,
+		{reason: ReasonYearly, key: shifted(years, dayOffset), count: retention.Yearly},
+	}
+
+	for _, t := range retention.Thinning {
+		result = append(result, tier{
+			reason: ReasonThinning,
+			key:    shifted(spanGrouper(years, t.Every), dayOffset),
+			count:  t.Count,
+			every:  t.Every,
+		})
+	}
+
+	return result
+}
+
+// spanGrouper returns a grouper for spans of every years, keyed by the year
+// key of years divided by every, so the spans begin in the years divisible
+// by every
+func spanGrouper(years func(file.Info) int64, every int) func(file.Info) int64 {
+	return func(f file.Info) int64 {
+		return years(f) / int64(every)
+	}
+}
+
+// fiscalYearGrouper returns a grouper for years beginning in the given month,
+// keyed by the calendar year they begin in
+func fiscalYearGrouper(start time.Month) func(file.Info) int64 {
+	if start == time.January {
+		return yearGrouper
+	}
+
+	return func(f file.Info) int64 {
+		year, month, _ := f.Timestamp.Date()
+		if month < start {
+			year--
+		}
+
+		return int64(year)
+	}
+}
+
+// anchoredOn returns whether a file was taken on or after the day of its
+// month a monthly_anchor names, read from the wall clock shifted by
+// dayOffset like the groupers. It returns nil for the last backup of the
+// month, and reports every file for the first.
+func anchoredOn(anchor string, dayOffset time.Duration) func(file.Info) bool {
+	switch anchor {
+	case "", config.MonthlyAnchorLast:
+		return nil
+	case config.MonthlyAnchorFirst:
+		return func(file.Info) bool { return true }
+	}
+
+	day := (&config.Config{MonthlyAnchor: anchor}).MonthlyAnchorDay()
+
+	return func(f file.Info) bool {
+		t := wallClock(f.Timestamp).Add(dayOffset)
+		last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+		return t.Day() >= min(day, last)
+	}
+}
+
+// shifted returns a grouper that groups files as key would if their wall
+// clock read offset later. The wall clock is shifted rather than the instant,
+// so a day boundary of 06:00 stays at 06:00 on the days clocks change.
+func shifted(key func(file.Info) int64, offset time.Duration) func(file.Info) int64 {
+	if offset == 0 {
+		return key
+	}
+
+	return func(f file.Info) int64 {
+		f.Timestamp = wallClock(f.Timestamp).Add(offset)
+		return key(f)
+	}
+}
+
+// wallClock returns the date and time t reads in its location as the same
+// reading in UTC, where every day has 24 hours
+func wallClock(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, minute, sec := t.Clock()
+
+	return time.Date(year, month, day, hour, minute, sec, t.Nanosecond(), time.UTC)
+}
+
+// tiersFor returns the tiers used for files with the given tag. With
+// keep_count set a single tier keeps the newest files, whenever they were
+// taken.
+func (p *Policy) tiersFor(tag string) []tier {
+	if p.config.KeepCount > 0 {
+		return []tier{{reason: ReasonKeepCount, key: instantGrouper, count: p.config.KeepCount}}
+	}
+
+	return tiers(p.config.RetentionFor(tag), p.config)
+}
+
+// logSummary logs how many of the files of a tag each tier retained, and
+// reports the statistics of the tiers to WithTierStats
+func (p *Policy) logSummary(tag string, total int, tiers []tier, retained []int) {
+	kept := 0
+	for _, n := range retained {
+		kept += n
+	}
+
+	fields := []zap.Field{
+		zap.String("tag", tag),
+		zap.Int("total_files", total),
+		zap.Int("files_to_delete", total-kept),
+	}
+
+	for i, t := range tiers {
+		fields = append(fields, zap.Int(t.name()+"_retained", retained[i]))
+	}
+
+	p.logger.Info("retention policy summary", fields...)
+
+	if p.stats == nil {
+		return
+	}
+
+	for i, t := range tiers {
+		if t.count > 0 {
+			p.stats(TierStats{
+				Tag:       tag,
+				Tier:      t.reason,
+				Every:     t.every,
+				Requested: t.count,
+				Filled:    retained[i],
+			})
+		}
+	}
+}
+
+// newestFirst orders files by timestamp, newest first
+func newestFirst(a, b file.Info) int {
+	return b.Timestamp.Compare(a.Timestamp)
+}
+
+// before orders files newest first like newestFirst, putting the highest
+// {seq} sequence number first among files with the same timestamp, and
+// breaking the remaining ties with timestamp_tiebreak so the preferred file
+// comes first. Files that still tie, or whose backend reports no
+// modification time, are ordered by path, the path sorting last first.
+func (p *Policy) before(a, b file.Info) int {
+	if c := cmp.Or(newestFirst(a, b), cmp.Compare(b.Seq, a.Seq)); c != 0 {
+		return c
+	}
+
+	var c int
+
+	switch p.config.TimestampTiebreak {
+	case config.TiebreakLargest:
+		c = cmp.Compare(b.Size, a.Size)
+	case config.TiebreakNewestModTime:
+		c = b.ModTime.Compare(a.ModTime)
+	}
+
+	return cmp.Or(c, cmp.Compare(b.Path, a.Path))
+}
+
+// prefer orders the files of a period so the file the tier keeps comes
+// first: files the tier's anchor reports oldest first, then the rest newest
+// first. Ties are broken with timestamp_tiebreak either way.
+func (p *Policy) prefer(t tier, a, b file.Info) int {
+	if t.anchored == nil {
+		return p.before(a, b)
+	}
+
+	switch onA, onB := t.anchored(a), t.anchored(b); {
+	case onA && onB:
+		return cmp.Or(a.Timestamp.Compare(b.Timestamp), p.before(a, b))
+	case onA:
+		return -1
+	case onB:
+		return 1
+	}
+
+	return p.before(a, b)
+}
+
+// pick returns the index of the file the tier keeps for each of its periods,
+// given the index of the newest file of each period among files sorted by
+// before and the index where the files of older periods begin
+func (p *Policy) pick(t tier, files []file.Info, starts []int, end int) []int {
+	if t.anchored == nil {
+		return starts
+	}
+
+	picked := make([]int, len(starts))
+
+	for slot, start := range starts {
+		stop := end
+		if slot+1 < len(starts) {
+			stop = starts[slot+1]
+		}
+
+		picked[slot] = start
+		for i := start + 1; i < stop; i++ {
+			if p.prefer(t, files[i], files[picked[slot]]) < 0 {
+				picked[slot] = i
+			}
+		}
+	}
+
+	return picked
+}
+
+// tied reports whether only timestamp_tiebreak orders two files, because
+// they share both a timestamp and a sequence number
+func tied(a, b file.Info) bool {
+	return a.Timestamp.Equal(b.Timestamp) && a.Seq == b.Seq
+}
+
+// warnTie logs a file the tiers did not keep because it has the same
+// timestamp as the file kept for its period
+func (p *Policy) warnTie(kept, other file.Info) {
+	tiebreak := p.config.TimestampTiebreak
+	if tiebreak == "" {
+		tiebreak = config.TiebreakName
+	}
+
+	p.logger.Warn("backups share a timestamp, keeping one",
+		zap.String("file", other.Path),
+		zap.String("kept", kept.Path),
+		zap.Time("timestamp", kept.Timestamp),
+		zap.String("tiebreak", tiebreak))
+}
+
+// Apply applies the retention policy to the given files and returns a
+// decision for every file. Files are grouped by tag and each tag is
+// evaluated independently with its own retention.
+func (p *Policy) Apply(files []file.Info) (*Result, error) {
 	if len(files) == 0 {
-		return nil, nil
-	}
-
-	// Group files by time period
-	hourlyFiles := groupFilesByPeriod(
-		files,
-		hourGrouper,
-		p.config.Retention.Hourly,
-	)
-
-	dailyFiles := groupFilesByPeriod(
-		hourlyFiles.unselected,
-		dayGrouper,
-		p.config.Retention.Daily,
-	)
-
-	weeklyFiles := groupFilesByPeriod(
-		dailyFiles.unselected,
-		weekGrouper,
-		p.config.Retention.Weekly,
-	)
-
-	monthlyFiles := groupFilesByPeriod(
-		weeklyFiles.unselected,
-		monthGrouper,
-		p.config.Retention.Monthly,
-	)
-
-	yearlyFiles := groupFilesByPeriod(
-		monthlyFiles.unselected,
-		yearGrouper,
-		p.config.Retention.Yearly,
-	)
-
-	toDelete := slices.Concat(
-		hourlyFiles.toDelete,
-		dailyFiles.toDelete,
-		weeklyFiles.toDelete,
-		monthlyFiles.toDelete,
-		yearlyFiles.toDelete,
-		yearlyFiles.unselected,
-	)
-
-	// Log summary
-	p.logger.Info("retention policy summary",
-		zap.Int("total_files", len(files)),
-		zap.Int("files_to_delete", len(toDelete)),
-		zap.Int("hourly_retained", len(hourlyFiles.selected)),
-		zap.Int("daily_retained", len(dailyFiles.selected)),
-		zap.Int("weekly_retained", len(weeklyFiles.selected)),
-		zap.Int("monthly_retained", len(monthlyFiles.selected)),
-		zap.Int("yearly_retained", len(yearlyFiles.selected)))
-
-	return toDelete, nil
-}
-
-// groupFilesByTimePeriod groups files into time periods based on the given
-// duration. Files are sorted by timestamp in descending order and grouped by
-// their time period. Returns a slice of file groups, where each group contains
-// files from the same time period.
-func groupFilesByTimePeriod[T comparable](
-	files []file.Info,
-	grouper func(file.Info) T,
-) [][]file.Info {
-	var groups [][]file.Info
+		return &Result{}, nil
+	}
 
-	currentGroup := []file.Info{}
+	duplicates, err := p.duplicates(context.Background(), walkSlice(files))
+	if err != nil {
+		return nil, err
+	}
 
-	files = slices.Clone(files)
-	slices.SortFunc(files, func(a, b file.Info) int {
-		return b.Timestamp.Compare(a.Timestamp)
-	})
+	decisions := make([]Decision, 0, len(files))
 
+	byTag := map[string][]file.Info{}
 	for _, f := range files {
-		// If this is the first file or it's in the same period as the previous
-		// file
-		if len(currentGroup) == 0 ||
-			grouper(f) == grouper(currentGroup[0]) {
-			currentGroup = append(currentGroup, f)
-		} else {
-			// Start a new group
-			if len(currentGroup) > 0 {
-				groups = append(groups, currentGroup)
+		if _, ok := duplicates[f.Path]; ok {
+			decisions = append(decisions, duplicate(f))
+			continue
+		}
+
+		byTag[f.Tag] = append(byTag[f.Tag], f)
+	}
+
+	for _, tag := range slices.Sorted(maps.Keys(byTag)) {
+		decisions = p.applyTiers(decisions, tag, byTag[tag], p.tiersFor(tag))
+	}
+
+	p.excludePinned(decisions)
+	p.enforceMinimum(decisions)
+	p.keepDependencies(decisions)
+
+	// Every tag is decided newest first, so a single tag without duplicates
+	// only needs to be reversed
+	slices.Reverse(decisions)
+
+	if len(byTag) > 1 || len(duplicates) > 0 {
+		slices.SortStableFunc(decisions, func(a, b Decision) int {
+			return a.File.Timestamp.Compare(b.File.Timestamp)
+		})
+	}
+
+	return &Result{Decisions: decisions}, nil
+}
+
+// applyTiers runs the tiers of a policy over a set of files sharing a tag
+// and appends a decision for every file to decisions, newest first. The
+// files are sorted once, with ties broken by timestamp_tiebreak; each tier
+// then takes the files of its newest periods off the front of what the
+// finer tiers left, so every file is keyed at most once per tier.
+func (p *Policy) applyTiers(
+	decisions []Decision,
+	tag string,
+	files []file.Info,
+	tiers []tier,
+) []Decision {
+	slices.SortFunc(files, p.before)
+
+	rest := files
+	retained := make([]int, 0, len(tiers))
+
+	for _, t := range tiers {
+		starts, end := selectPeriods(rest, t.key, t.count)
+		picked := p.pick(t, rest, starts, end)
+
+		slot := -1
+
+		for i, f := range rest[:end] {
+			if slot+1 < len(starts) && starts[slot+1] == i {
+				slot++
+			}
+
+			if picked[slot] == i {
+				decisions = append(decisions, Decision{
+					File:   f,
+					Reason: t.reason,
+					Slot:   slot + 1,
+					Tier:   t.reason,
+				})
+
+				continue
 			}
 
-			currentGroup = []file.Info{f}
+			if kept := rest[picked[slot]]; tied(f, kept) {
+				p.warnTie(kept, f)
+			}
+
+			decisions = append(decisions, Decision{
+				File:   f,
+				Delete: true,
+				Reason: ReasonSuperseded,
+				Tier:   t.reason,
+			})
 		}
+
+		retained = append(retained, len(starts))
+		rest = rest[end:]
 	}
 
-	// Add the last group
-	if len(currentGroup) > 0 {
-		groups = append(groups, currentGroup)
+	for _, f := range rest {
+		decisions = append(decisions, Decision{
+			File:   f,
+			Delete: true,
+			Reason: ReasonExpired,
+			Tier:   ReasonExpired,
+		})
 	}
 
-	return groups
+	p.logSummary(tag, len(files), tiers, retained)
+
+	return decisions
 }
 
-type groupResult struct {
-	selected   []file.Info
-	toDelete   []file.Info
-	unselected []file.Info
+// excludePinned keeps every pinned file the tiers would have deleted
+func (p *Policy) excludePinned(decisions []Decision) {
+	for i, d := range decisions {
+		if !d.Delete || !d.File.Pinned {
+			continue
+		}
+
+		p.logger.Info("keeping pinned file",
+			zap.String("file", d.File.Path),
+			zap.Time("timestamp", d.File.Timestamp))
+
+		decisions[i].Delete = false
+		decisions[i].Reason = ReasonPinned
+	}
 }
 
-// groupFilesByPeriod groups files by the specified time period
-func groupFilesByPeriod[T comparable](
-	files []file.Info,
-	grouper func(file.Info) T,
-	keepCount int,
-) *groupResult {
-	groups := groupFilesByTimePeriod(files, grouper)
-
-	selected := []file.Info{}
-	unselected := []file.Info{}
-	toDelete := []file.Info{}
-
-	for _, group := range groups {
-		if len(selected) == keepCount {
-			unselected = append(unselected, group...)
-		} else {
-			selected = append(selected, group[0])
-
-			if len(group) > 1 {
-				toDelete = append(toDelete, group[1:]...)
+// enforceMinimum keeps the newest deleted files until at least
+// RequireMinimum files survive, so a prune can never delete every backup
+func (p *Policy) enforceMinimum(decisions []Decision) {
+	kept := 0
+
+	for _, d := range decisions {
+		if !d.Delete {
+			kept++
+		}
+	}
+
+	missing := p.config.RequireMinimum - kept
+	if missing <= 0 {
+		return
+	}
+
+	deleted := make([]int, 0, len(decisions)-kept)
+
+	for i, d := range decisions {
+		if d.Delete {
+			deleted = append(deleted, i)
+		}
+	}
+
+	missing = min(missing, len(deleted))
+
+	slices.SortFunc(deleted, func(a, b int) int {
+		return decisions[b].File.Timestamp.Compare(decisions[a].File.Timestamp)
+	})
+
+	for _, i := range deleted[:missing] {
+		p.logger.Warn("keeping file to satisfy require_minimum",
+			zap.String("file", decisions[i].File.Path),
+			zap.Time("timestamp", decisions[i].File.Timestamp),
+			zap.Int("require_minimum", p.config.RequireMinimum))
+
+		decisions[i].Delete = false
+		decisions[i].Reason = ReasonRequireMinimum
+	}
+}
+
+// keepDependencies keeps every backup a kept backup depends on, following
+// chains such as incremental backups back to their full base, so a prune
+// never leaves a backup that cannot be restored
+func (p *Policy) keepDependencies(decisions []Decision) {
+	if !slices.ContainsFunc(decisions, func(d Decision) bool {
+		return d.File.DependsOn != ""
+	}) {
+		return
+	}
+
+	byPath := make(map[string]int, len(decisions))
+	for i, d := range decisions {
+		byPath[d.File.Path] = i
+	}
+
+	for i := range decisions {
+		if decisions[i].Delete {
+			continue
+		}
+
+		for dep := decisions[i].File.DependsOn; dep != ""; {
+			j, ok := byPath[dep]
+			if !ok || !decisions[j].Delete {
+				break
 			}
+
+			p.logger.Info("keeping backup required by a kept backup",
+				zap.String("file", decisions[j].File.Path),
+				zap.String("required_by", decisions[i].File.Path))
+
+			decisions[j].Delete = false
+			decisions[j].Reason = ReasonDependency
+			dep = decisions[j].File.DependsOn
+		}
+	}
+}
+
+// selectPeriods finds the count newest periods among files sorted newest
+// first. It returns the index of the newest file of each of those periods
+// and the index where the files of older periods begin.
+func selectPeriods(
+	files []file.Info,
+	key func(file.Info) int64,
+	count int,
+) ([]int, int) {
+	if count == 0 || len(files) == 0 {
+		return nil, 0
+	}
+
+	starts := make([]int, 0, min(count, len(files)))
+	current := int64(0)
+
+	for i, f := range files {
+		k := key(f)
+		if len(starts) > 0 && k == current {
+			continue
+		}
+
+		if len(starts) == count {
+			return starts, i
+		}
+
+		starts = append(starts, i)
+		current = k
+	}
+
+	return starts, len(files)
+}
+
+// Relax returns the files kept in result that may be deleted to recover free
+// space, in the order they should be deleted: the oldest file kept outside
+// the tiers first, then the oldest thinning, yearly, monthly, weekly, daily
+// and hourly files. Pinned files are never returned, a file is only returned once no
+// remaining file depends on it, and RequireMinimum files always remain.
+// Every returned decision is marked for deletion with ReasonEmergency.
+func (p *Policy) Relax(result *Result) []Decision {
+	var remaining []Decision
+
+	for _, d := range result.Decisions {
+		if !d.Delete {
+			remaining = append(remaining, d)
+		}
+	}
+
+	candidates := slices.DeleteFunc(slices.Clone(remaining), func(d Decision) bool {
+		return d.File.Pinned
+	})
+
+	slices.SortStableFunc(candidates, func(a, b Decision) int {
+		if c := relaxRank(a) - relaxRank(b); c != 0 {
+			return c
 		}
+
+		return a.File.Timestamp.Compare(b.File.Timestamp)
+	})
+
+	var relaxed []Decision
+
+	for len(remaining) > p.config.RequireMinimum {
+		i := slices.IndexFunc(candidates, func(d Decision) bool {
+			return !slices.ContainsFunc(remaining, func(r Decision) bool {
+				return r.File.DependsOn == d.File.Path
+			})
+		})
+		if i < 0 {
+			break
+		}
+
+		d := candidates[i]
+		candidates = slices.Delete(candidates, i, i+1)
+		remaining = slices.DeleteFunc(remaining, func(r Decision) bool {
+			return r.File.Path == d.File.Path
+		})
+
+		d.Delete = true
+		d.Reason = ReasonEmergency
+		relaxed = append(relaxed, d)
 	}
 
-	return &groupResult{
-		selected:   selected,
-		toDelete:   toDelete,
-		unselected: unselected,
+	return relaxed
+}
+
+// relaxRank returns the position of the tier of a decision in relaxOrder
+func relaxRank(d Decision) int {
+	tier := d.Tier
+	if d.Reason == ReasonRequireMinimum || d.Reason == ReasonDependency {
+		tier = ReasonExpired
 	}
+
+	return slices.Index(relaxOrder, tier)
 }
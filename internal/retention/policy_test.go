@@ -25,11 +25,18 @@
 package retention
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"testing"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
 	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
@@ -55,9 +62,10 @@ func TestPolicy_Apply(t *testing.T) {
 	policy := NewPolicy(logger, cfg)
 
 	t.Run("empty file list", func(t *testing.T) {
-		toDelete, err := policy.Apply([]file.Info{})
+		result, err := policy.Apply([]file.Info{})
 		require.NoError(t, err)
-		require.Empty(t, toDelete)
+		require.Empty(t, result.Decisions)
+		require.Empty(t, result.Deleted())
 	})
 
 	t.Run("basic retention policy", func(t *testing.T) {
@@ -138,9 +146,11 @@ func TestPolicy_Apply(t *testing.T) {
 			},
 		}
 
-		toDelete, err := policy.Apply(files)
+		result, err := policy.Apply(files)
 		require.NoError(t, err)
 
+		toDelete := result.Deleted()
+
 		// Verify the correct files are marked for deletion
 		expectedToDelete := []string{
 			"backup-2024-03-15-08-00.tar.gz",
@@ -188,8 +198,10 @@ func TestPolicy_Apply(t *testing.T) {
 				},
 			}
 
-			toDelete, err := policy.Apply(files)
+			result, err := policy.Apply(files)
 			require.NoError(t, err)
+
+			toDelete := result.Deleted()
 			require.Empty(t, toDelete)
 		})
 
@@ -210,8 +222,10 @@ func TestPolicy_Apply(t *testing.T) {
 				},
 			}
 
-			toDelete, err := policy.Apply(files)
+			result, err := policy.Apply(files)
 			require.NoError(t, err)
+
+			toDelete := result.Deleted()
 			require.Empty(t, toDelete)
 		})
 
@@ -228,160 +242,859 @@ func TestPolicy_Apply(t *testing.T) {
 				},
 			}
 
-			toDelete, err := policy.Apply(files)
+			result, err := policy.Apply(files)
 			require.NoError(t, err)
+
+			toDelete := result.Deleted()
 			require.Empty(t, toDelete)
 		})
 	})
 }
 
-func TestPolicy_groupFilesByPeriod(t *testing.T) {
-	t.Run("basic grouping", func(t *testing.T) {
-		now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
-		files := []file.Info{
-			{Path: "file1", Timestamp: now},
-			{Path: "file2", Timestamp: now.Add(-30 * time.Minute)},
-			{Path: "file3", Timestamp: now.Add(-90 * time.Minute)},
-			{Path: "file4", Timestamp: now.Add(-120 * time.Minute)},
+func TestPolicy_RequireMinimum(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "backup-2024-03-15-12-00.tar.gz", Timestamp: now},
+		{Path: "backup-2024-03-14-12-00.tar.gz", Timestamp: now.Add(-24 * time.Hour)},
+		{Path: "backup-2024-03-13-12-00.tar.gz", Timestamp: now.Add(-48 * time.Hour)},
+	}
+
+	t.Run("zero retention keeps newest", func(t *testing.T) {
+		policy := NewPolicy(logger, &config.Config{RequireMinimum: 1})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		toDelete := result.Deleted()
+		require.Len(t, toDelete, 2)
+
+		for _, f := range toDelete {
+			require.NotEqual(t, "backup-2024-03-15-12-00.tar.gz", f.Path)
 		}
+	})
 
-		selected := groupFilesByPeriod(
-			files,
-			hourGrouper,
-			2,
-		)
+	t.Run("minimum larger than file set", func(t *testing.T) {
+		policy := NewPolicy(logger, &config.Config{RequireMinimum: 5})
 
-		require.Len(t, selected.selected, 2)
-		require.Empty(t, selected.toDelete)
-		require.Len(t, selected.unselected, 2)
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
 
-		require.Equal(t, "file1", selected.selected[0].Path)
-		require.Equal(t, "file2", selected.selected[1].Path)
-		require.Equal(t, "file3", selected.unselected[0].Path)
-		require.Equal(t, "file4", selected.unselected[1].Path)
+		toDelete := result.Deleted()
+		require.Empty(t, toDelete)
 	})
 
-	t.Run("empty input", func(t *testing.T) {
-		selected := groupFilesByPeriod(
-			[]file.Info{},
-			hourGrouper,
-			2,
-		)
-		require.Empty(t, selected.selected)
-		require.Empty(t, selected.toDelete)
-		require.Empty(t, selected.unselected)
+	t.Run("already satisfied by retention", func(t *testing.T) {
+		policy := NewPolicy(logger, &config.Config{
+			Retention:      config.RetentionPolicy{Daily: 2},
+			RequireMinimum: 1,
+		})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		toDelete := result.Deleted()
+		require.Len(t, toDelete, 1)
+		require.Equal(t, "backup-2024-03-13-12-00.tar.gz", toDelete[0].Path)
 	})
 
-	t.Run("keep all files", func(t *testing.T) {
-		now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
-		files := []file.Info{
-			{Path: "file1", Timestamp: now},
-			{Path: "file2", Timestamp: now.Add(-60 * time.Minute)},
+	t.Run("disabled", func(t *testing.T) {
+		policy := NewPolicy(logger, &config.Config{})
+
+		result, err := policy.Apply(files)
+		require.NoError(t, err)
+
+		toDelete := result.Deleted()
+		require.Len(t, toDelete, len(files))
+	})
+}
+
+func TestPolicy_Pinned(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "backup-2024-03-15-12-00.tar.gz", Timestamp: now},
+		{
+			Path:      "backup-2024-03-14-12-00.tar.gz",
+			Timestamp: now.Add(-24 * time.Hour),
+			Pinned:    true,
+		},
+		{Path: "backup-2024-03-13-12-00.tar.gz", Timestamp: now.Add(-48 * time.Hour)},
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention: config.RetentionPolicy{Daily: 1},
+	})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+
+	toDelete := result.Deleted()
+	require.Len(t, toDelete, 1)
+	require.Equal(t, "backup-2024-03-13-12-00.tar.gz", toDelete[0].Path)
+}
+
+func TestPolicy_Dependencies(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "old", Timestamp: now.Add(-72 * time.Hour)},
+		{Path: "full", Timestamp: now.Add(-48 * time.Hour)},
+		{Path: "inc1", Timestamp: now.Add(-24 * time.Hour), DependsOn: "full"},
+		{Path: "inc2", Timestamp: now, DependsOn: "inc1"},
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention: config.RetentionPolicy{Daily: 1},
+	})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+
+	reasons := make(map[string]Reason, len(result.Decisions))
+	for _, d := range result.Decisions {
+		reasons[d.File.Path] = d.Reason
+	}
+
+	require.Equal(t, map[string]Reason{
+		"old":  ReasonExpired,
+		"full": ReasonDependency,
+		"inc1": ReasonDependency,
+		"inc2": ReasonDaily,
+	}, reasons)
+
+	toDelete := result.Deleted()
+	require.Len(t, toDelete, 1)
+	require.Equal(t, "old", toDelete[0].Path)
+}
+
+func TestPolicy_Relax(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "hourly", Timestamp: now},
+		{Path: "daily", Timestamp: now.Add(-24 * time.Hour)},
+		{Path: "inc", Timestamp: now.Add(-48 * time.Hour), DependsOn: "full"},
+		{Path: "full", Timestamp: now.Add(-72 * time.Hour)},
+		{Path: "pinned", Timestamp: now.Add(-96 * time.Hour), Pinned: true},
+		{Path: "monthly", Timestamp: now.AddDate(0, -1, 0)},
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention:      config.RetentionPolicy{Hourly: 1, Daily: 3, Monthly: 2},
+		RequireMinimum: 2,
+	})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+	require.Empty(t, result.Deleted())
+
+	var order []string
+
+	for _, d := range policy.Relax(result) {
+		require.True(t, d.Delete)
+		require.Equal(t, ReasonEmergency, d.Reason)
+
+		order = append(order, d.File.Path)
+	}
+
+	// The full backup kept by dependency goes once its incremental is gone,
+	// and two files remain for require_minimum
+	require.Equal(t, []string{"monthly", "inc", "full", "daily"}, order)
+}
+
+func TestPolicy_KeepCount(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "a", Timestamp: now.Add(-time.Minute)},
+		{Path: "b", Timestamp: now},
+		{Path: "c", Timestamp: now.AddDate(-1, 0, 0)},
+		{Path: "d", Timestamp: now.Add(-time.Minute)},
+		{Path: "e", Timestamp: now.Add(-time.Hour)},
+	}
+
+	policy := NewPolicy(logger, &config.Config{KeepCount: 3})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+
+	decisions := make(map[string]Decision, len(result.Decisions))
+	for _, d := range result.Decisions {
+		decisions[d.File.Path] = d
+	}
+
+	// Files taken minutes apart fill a slot each, however close together;
+	// only a file from the very same instant is superseded, by the one whose
+	// path sorts last
+	require.Equal(t, ReasonKeepCount, decisions["b"].Reason)
+	require.Equal(t, 1, decisions["b"].Slot)
+	require.Equal(t, ReasonKeepCount, decisions["d"].Reason)
+	require.Equal(t, 2, decisions["d"].Slot)
+	require.Equal(t, ReasonSuperseded, decisions["a"].Reason)
+	require.Equal(t, ReasonKeepCount, decisions["a"].Tier)
+	require.Equal(t, ReasonKeepCount, decisions["e"].Reason)
+	require.Equal(t, 3, decisions["e"].Slot)
+	require.Equal(t, ReasonExpired, decisions["c"].Reason)
+
+	streamed := applyStream(t, policy, walkSlice(files))
+	for _, want := range result.Decisions {
+		require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+	}
+}
+
+func TestPolicy_DayBoundaryOffset(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	files := []file.Info{
+		{Path: "started", Timestamp: time.Date(2024, 3, 14, 23, 0, 0, 0, time.UTC)},
+		{Path: "finished", Timestamp: time.Date(2024, 3, 15, 1, 0, 0, 0, time.UTC)},
+		{Path: "previous", Timestamp: time.Date(2024, 3, 13, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		offset time.Duration
+		want   map[string]Reason
+	}{
+		{
+			name: "midnight",
+			want: map[string]Reason{
+				"finished": ReasonDaily,
+				"started":  ReasonDaily,
+				"previous": ReasonExpired,
+			},
+		},
+		{
+			name:   "six hours earlier",
+			offset: -6 * time.Hour,
+			want: map[string]Reason{
+				"finished": ReasonDaily,
+				"started":  ReasonSuperseded,
+				"previous": ReasonDaily,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := NewPolicy(logger, &config.Config{
+				Retention:         config.RetentionPolicy{Daily: 2},
+				DayBoundaryOffset: tc.offset,
+			})
+
+			result, err := policy.Apply(slices.Clone(files))
+			require.NoError(t, err)
+
+			reasons := make(map[string]Reason, len(result.Decisions))
+			for _, d := range result.Decisions {
+				reasons[d.File.Path] = d.Reason
+			}
+
+			require.Equal(t, tc.want, reasons)
+		})
+	}
+}
+
+func TestGroupers_DST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	at := func(ts time.Time) file.Info { return file.Info{Timestamp: ts.In(newYork)} }
+
+	t.Run("hour repeated when clocks go back", func(t *testing.T) {
+		// 01:30 EDT and 01:30 EST on Nov 3 2024 are an hour apart
+		first := at(time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC))
+		second := at(time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC))
+		require.Equal(t, first.Timestamp.Hour(), second.Timestamp.Hour())
+
+		require.NotEqual(t, hourGrouper(first), hourGrouper(second))
+		require.Less(t, hourGrouper(first), hourGrouper(second))
+		require.Equal(t, dayGrouper(first), dayGrouper(second))
+	})
+
+	t.Run("day of 23 hours when clocks go forward", func(t *testing.T) {
+		midnight := at(time.Date(2024, 3, 10, 0, 30, 0, 0, newYork))
+		lastHour := at(time.Date(2024, 3, 10, 23, 30, 0, 0, newYork))
+		dayBefore := at(time.Date(2024, 3, 9, 23, 30, 0, 0, newYork))
+
+		require.Equal(t, dayGrouper(midnight), dayGrouper(lastHour))
+		require.NotEqual(t, dayGrouper(dayBefore), dayGrouper(midnight))
+		require.Less(t, hourGrouper(midnight), hourGrouper(lastHour))
+	})
+
+	t.Run("day boundary offset follows the wall clock", func(t *testing.T) {
+		day := shifted(dayGrouper, -6*time.Hour)
+
+		// Only five hours passed since midnight at 06:30 on Mar 10 2024, but
+		// the backup was still taken after the 06:00 day boundary
+		after := at(time.Date(2024, 3, 10, 6, 30, 0, 0, newYork))
+		before := at(time.Date(2024, 3, 10, 5, 30, 0, 0, newYork))
+		previous := at(time.Date(2024, 3, 9, 7, 0, 0, 0, newYork))
+
+		require.Equal(t, dayGrouper(after), day(after))
+		require.Equal(t, day(previous), day(before))
+		require.NotEqual(t, day(before), day(after))
+	})
+}
+
+func TestGroupers_LeapDay(t *testing.T) {
+	at := func(month time.Month, day, hour int) file.Info {
+		return file.Info{Timestamp: time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)}
+	}
+
+	leapDay := at(time.February, 29, 12)
+	require.Less(t, dayGrouper(at(time.February, 28, 23)), dayGrouper(leapDay))
+	require.Less(t, dayGrouper(leapDay), dayGrouper(at(time.March, 1, 0)))
+	require.Equal(t, monthGrouper(at(time.February, 1, 0)), monthGrouper(leapDay))
+	require.Less(t, monthGrouper(leapDay), monthGrouper(at(time.March, 1, 0)))
+	require.Equal(t, yearGrouper(at(time.January, 1, 0)), yearGrouper(leapDay))
+
+	day := shifted(dayGrouper, -6*time.Hour)
+	require.Equal(t, dayGrouper(leapDay), day(at(time.March, 1, 5)))
+	require.Equal(t, dayGrouper(at(time.March, 1, 0)), day(at(time.March, 1, 6)))
+
+	// Feb 29 2024 is a Thursday of ISO week 9
+	require.Equal(t, weekGrouper(at(time.February, 26, 0)), weekGrouper(leapDay))
+	require.Equal(t, weekGrouper(at(time.March, 3, 23)), weekGrouper(leapDay))
+	require.NotEqual(t, weekGrouper(at(time.March, 4, 0)), weekGrouper(leapDay))
+}
+
+func TestPolicy_RepeatedHour(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	files := []file.Info{
+		{Path: "edt", Timestamp: time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC).In(newYork)},
+		{Path: "est", Timestamp: time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC).In(newYork)},
+		{Path: "before", Timestamp: time.Date(2024, 11, 3, 4, 30, 0, 0, time.UTC).In(newYork)},
+	}
+
+	policy := NewPolicy(&logging.Logger{Logger: zap.NewNop()}, &config.Config{
+		Retention: config.RetentionPolicy{Hourly: 2},
+	})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+
+	var kept []string
+	for _, f := range result.Kept() {
+		kept = append(kept, f.Path)
+	}
+
+	require.Equal(t, []string{"edt", "est"}, kept)
+}
+
+func TestPolicy_TimestampTiebreak(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "backup-b", Timestamp: now, Size: 20, ModTime: now.Add(time.Minute)},
+		{Path: "backup-a", Timestamp: now, Size: 30, ModTime: now.Add(time.Hour)},
+		{Path: "backup-c", Timestamp: now, Size: 10, ModTime: now},
+		{Path: "older", Timestamp: now.Add(-time.Hour)},
+	}
+
+	for _, tc := range []struct {
+		tiebreak string
+		want     string
+	}{
+		{tiebreak: "", want: "backup-c"},
+		{tiebreak: config.TiebreakName, want: "backup-c"},
+		{tiebreak: config.TiebreakLargest, want: "backup-a"},
+		{tiebreak: config.TiebreakNewestModTime, want: "backup-a"},
+	} {
+		t.Run(tc.tiebreak, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			policy := NewPolicy(&logging.Logger{Logger: zap.New(core)}, &config.Config{
+				Retention:         config.RetentionPolicy{Hourly: 2},
+				TimestampTiebreak: tc.tiebreak,
+			})
+
+			result, err := policy.Apply(slices.Clone(files))
+			require.NoError(t, err)
+
+			var kept []string
+
+			for _, d := range result.Decisions {
+				if !d.Delete {
+					kept = append(kept, d.File.Path)
+				}
+			}
+
+			require.Equal(t, []string{"older", tc.want}, kept)
+
+			// Every file losing the tie is reported with the one kept
+			ties := logs.FilterMessage("backups share a timestamp, keeping one").All()
+			require.Len(t, ties, 2)
+
+			for _, entry := range ties {
+				require.Equal(t, tc.want, entry.ContextMap()["kept"])
+			}
+
+			streamed := applyStream(t, policy, walkSlice(files))
+			for _, want := range result.Decisions {
+				require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+			}
+		})
+	}
+}
+
+func TestPolicy_Sequence(t *testing.T) {
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "backup-2024-03-15.2.tar.gz", Timestamp: day, Seq: 2},
+		{Path: "backup-2024-03-15.10.tar.gz", Timestamp: day, Seq: 10},
+		{Path: "backup-2024-03-15.1.tar.gz", Timestamp: day, Seq: 1},
+		{Path: "backup-2024-03-14.3.tar.gz", Timestamp: day.AddDate(0, 0, -1), Seq: 3},
+	}
+
+	core, logs := observer.New(zap.WarnLevel)
+	policy := NewPolicy(&logging.Logger{Logger: zap.New(core)}, &config.Config{
+		Retention: config.RetentionPolicy{Daily: 2},
+	})
+
+	result, err := policy.Apply(slices.Clone(files))
+	require.NoError(t, err)
+
+	var kept []string
+
+	for _, d := range result.Decisions {
+		if !d.Delete {
+			kept = append(kept, d.File.Path)
 		}
+	}
+
+	// The highest sequence wins, although its name sorts before .2
+	require.Equal(t, []string{"backup-2024-03-14.3.tar.gz", "backup-2024-03-15.10.tar.gz"}, kept)
+
+	// Files ordered by their sequence number do not tie
+	require.Zero(t, logs.FilterMessage("backups share a timestamp, keeping one").Len())
+
+	streamed := applyStream(t, policy, walkSlice(files))
+	for _, want := range result.Decisions {
+		require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+	}
+}
+
+func TestPolicy_MonthlyAnchor(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+
+	// Backups every five days at 02:00 up to March 26th, and extra ones on
+	// the evening of February 15th and on leap day
+	var files []file.Info
+
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	end := at(time.March, 30, 0)
+	for day := at(time.January, 1, 2); day.Before(end); day = day.AddDate(0, 0, 5) {
+		files = append(files, file.Info{Path: day.Format(time.DateOnly), Timestamp: day})
+	}
+
+	files = append(files,
+		file.Info{Path: "2024-02-15-evening", Timestamp: at(time.February, 15, 20)},
+		file.Info{Path: "2024-02-29", Timestamp: at(time.February, 29, 12)},
+	)
+
+	for _, tc := range []struct {
+		anchor string
+		offset time.Duration
+		want   []string
+	}{
+		{anchor: "", want: []string{"2024-01-31", "2024-02-29", "2024-03-26"}},
+		{
+			anchor: config.MonthlyAnchorLast,
+			want:   []string{"2024-01-31", "2024-02-29", "2024-03-26"},
+		},
+		{
+			anchor: config.MonthlyAnchorFirst,
+			want:   []string{"2024-01-01", "2024-02-05", "2024-03-01"},
+		},
+		// The first backup on or after the 15th, the evening one being later
+		{anchor: "15", want: []string{"2024-01-16", "2024-02-15", "2024-03-16"}},
+		// February has no 30th, so its last day counts, and without a backup
+		// on or after March 30th the last one before it is kept
+		{anchor: "30", want: []string{"2024-01-31", "2024-02-29", "2024-03-26"}},
+		// With days starting at 03:00 the 02:00 backup of February 15th
+		// still belongs to the 14th
+		{anchor: "15", offset: -3 * time.Hour, want: []string{
+			"2024-01-16", "2024-02-15-evening", "2024-03-16",
+		}},
+	} {
+		t.Run(tc.anchor+" "+tc.offset.String(), func(t *testing.T) {
+			policy := NewPolicy(logger, &config.Config{
+				Retention:         config.RetentionPolicy{Monthly: 3},
+				MonthlyAnchor:     tc.anchor,
+				DayBoundaryOffset: tc.offset,
+			})
+
+			result, err := policy.Apply(slices.Clone(files))
+			require.NoError(t, err)
+
+			var kept []string
+
+			for _, d := range result.Decisions {
+				if !d.Delete {
+					require.Equal(t, ReasonMonthly, d.Reason, d.File.Path)
+					kept = append(kept, d.File.Path)
+				}
+			}
+
+			require.Equal(t, tc.want, kept)
+
+			streamed := applyStream(t, policy, walkSlice(files))
+			for _, want := range result.Decisions {
+				require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+			}
+		})
+	}
+}
 
-		selected := groupFilesByPeriod(
-			files,
-			hourGrouper,
-			2,
-		)
+func TestPolicy_FiscalYear(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+
+	var files []file.Info
+
+	// Monthly backups from January 2022 to June 2024
+	for ts := time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC); ts.Year() < 2024 ||
+		ts.Month() <= time.June; ts = ts.AddDate(0, 1, 0) {
+		files = append(files, file.Info{Path: ts.Format("2006-01"), Timestamp: ts})
+	}
+
+	for _, tc := range []struct {
+		start int
+		want  []string
+	}{
+		{start: 0, want: []string{"2022-12", "2023-12", "2024-06"}},
+		{start: 1, want: []string{"2022-12", "2023-12", "2024-06"}},
+		// Fiscal years 2021/22 to 2024/25, the newest three kept
+		{start: 4, want: []string{"2023-03", "2024-03", "2024-06"}},
+	} {
+		t.Run(strconv.Itoa(tc.start), func(t *testing.T) {
+			policy := NewPolicy(logger, &config.Config{
+				Retention:       config.RetentionPolicy{Yearly: 3},
+				FiscalYearStart: tc.start,
+			})
+
+			result, err := policy.Apply(slices.Clone(files))
+			require.NoError(t, err)
+
+			var kept []string
+			for _, f := range result.Kept() {
+				kept = append(kept, f.Path)
+			}
 
-		require.Len(t, selected.selected, 2)
-		require.Empty(t, selected.toDelete)
-		require.Empty(t, selected.unselected)
+			require.Equal(t, tc.want, kept)
+
+			streamed := applyStream(t, policy, walkSlice(files))
+			for _, want := range result.Decisions {
+				require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+			}
+		})
+	}
+}
+
+func TestPolicy_Thinning(t *testing.T) {
+	var files []file.Info
+
+	// A backup on New Year's Day of each year from 2000 to 2024
+	for year := 2000; year <= 2024; year++ {
+		files = append(files, file.Info{
+			Path:      strconv.Itoa(year),
+			Timestamp: time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC),
+		})
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	policy := NewPolicy(&logging.Logger{Logger: zap.New(core)}, &config.Config{
+		Retention: config.RetentionPolicy{
+			Yearly:   3,
+			Thinning: []config.ThinningTier{{Every: 2, Count: 2}, {Every: 5, Count: 2}},
+		},
 	})
+
+	result, err := policy.Apply(slices.Clone(files))
+	require.NoError(t, err)
+
+	kept := make(map[string]Reason)
+
+	for _, d := range result.Decisions {
+		if !d.Delete {
+			kept[d.File.Path] = d.Reason
+		}
+	}
+
+	// 2022 to 2024 are yearly, 2021 and 2019 are the newest of 2020-21 and
+	// 2018-19, 2017 and 2014 the newest of 2015-19 and 2010-14
+	require.Equal(t, map[string]Reason{
+		"2024": ReasonYearly,
+		"2023": ReasonYearly,
+		"2022": ReasonYearly,
+		"2021": ReasonThinning,
+		"2019": ReasonThinning,
+		"2017": ReasonThinning,
+		"2014": ReasonThinning,
+	}, kept)
+
+	summary := logs.FilterMessage("retention policy summary").All()
+	require.Len(t, summary, 1)
+	require.EqualValues(t, 2, summary[0].ContextMap()["thinning_2y_retained"])
+	require.EqualValues(t, 2, summary[0].ContextMap()["thinning_5y_retained"])
+
+	streamed := applyStream(t, policy, walkSlice(files))
+	for _, want := range result.Decisions {
+		require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+	}
 }
 
-func TestGroupFilesByTimePeriod(t *testing.T) {
+func TestPolicy_Dedupe(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	dir := t.TempDir()
 	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
 
-	tests := []struct {
+	var files []file.Info
+
+	for _, backup := range []struct {
 		name     string
-		files    []file.Info
-		grouper  func(file.Info) int64
-		expected [][]file.Info
+		age      time.Duration
+		contents string
 	}{
+		{name: "noon", contents: "changed"},
+		{name: "eleven", age: time.Hour, contents: "same"},
+		{name: "ten", age: 2 * time.Hour, contents: "same"},
+		{name: "nine", age: 3 * time.Hour, contents: "gone"},
+		{name: "yesterday", age: 13 * time.Hour, contents: "same"},
+	} {
+		path := filepath.Join(dir, backup.name)
+		require.NoError(t, os.WriteFile(path, []byte(backup.contents), 0o600))
+
+		files = append(files, file.Info{
+			Path:      path,
+			Timestamp: now.Add(-backup.age),
+			Size:      int64(len(backup.contents)),
+		})
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention: config.RetentionPolicy{Hourly: 3},
+		Dedupe:    true,
+	})
+
+	result, err := policy.Apply(slices.Clone(files))
+	require.NoError(t, err)
+
+	reasons := make(map[string]Reason, len(result.Decisions))
+	for _, d := range result.Decisions {
+		reasons[filepath.Base(d.File.Path)] = d.Reason
+	}
+
+	// The copy of eleven gives up its slot to nine; yesterday has the same
+	// contents but falls on another day
+	require.Equal(t, map[string]Reason{
+		"noon":      ReasonHourly,
+		"eleven":    ReasonHourly,
+		"ten":       ReasonDuplicate,
+		"nine":      ReasonHourly,
+		"yesterday": ReasonExpired,
+	}, reasons)
+
+	streamed := applyStream(t, policy, walkSlice(files))
+	for _, want := range result.Decisions {
+		require.Equal(t, want, streamed[want.File.Path], want.File.Path)
+	}
+}
+
+func TestPolicy_TagRetention(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	var files []file.Info
+	for i := range 3 {
+		ts := now.AddDate(0, -i, 0)
+		for _, tag := range []string{"nightly", "pre-release"} {
+			files = append(files, file.Info{
+				Path:      fmt.Sprintf("%s-%s.tar.gz", tag, ts.Format("2006-01-02")),
+				Timestamp: ts,
+				Tag:       tag,
+			})
+		}
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention: config.RetentionPolicy{Monthly: 1},
+		TagRetention: map[string]config.RetentionPolicy{
+			"pre-release": {Monthly: 10},
+		},
+	})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+
+	toDelete := result.Deleted()
+
+	deleted := make([]string, 0, len(toDelete))
+	for _, f := range toDelete {
+		deleted = append(deleted, f.Path)
+	}
+
+	// Tags are evaluated independently: nightly keeps only the newest month
+	// while pre-release keeps all three
+	require.ElementsMatch(t, []string{
+		"nightly-2024-02-15.tar.gz",
+		"nightly-2024-01-15.tar.gz",
+	}, deleted)
+}
+
+func TestPolicy_Decisions(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "backup-2024-03-15-12-00.tar.gz", Timestamp: now},
+		{Path: "backup-2024-03-15-06-00.tar.gz", Timestamp: now.Add(-6 * time.Hour)},
+		{Path: "backup-2024-03-14-12-00.tar.gz", Timestamp: now.Add(-24 * time.Hour)},
+		{Path: "backup-2024-03-14-06-00.tar.gz", Timestamp: now.Add(-30 * time.Hour)},
 		{
-			name:     "empty files list",
-			files:    []file.Info{},
-			grouper:  hourGrouper,
-			expected: nil,
+			Path:      "backup-2024-03-13-12-00.tar.gz",
+			Timestamp: now.Add(-48 * time.Hour),
+			Pinned:    true,
 		},
+		{Path: "backup-2024-02-01-12-00.tar.gz", Timestamp: now.AddDate(0, -1, -14)},
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention:      config.RetentionPolicy{Daily: 2},
+		RequireMinimum: 4,
+	})
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+
+	decisions := result.Decisions
+
+	reasons := make(map[string]Reason, len(decisions))
+	tiers := make(map[string]Reason, len(decisions))
+	deleted := make(map[string]bool, len(decisions))
+
+	for _, d := range decisions {
+		reasons[d.File.Path] = d.Reason
+		tiers[d.File.Path] = d.Tier
+		deleted[d.File.Path] = d.Delete
+	}
+
+	require.Equal(t, map[string]Reason{
+		"backup-2024-03-15-12-00.tar.gz": ReasonDaily,
+		"backup-2024-03-15-06-00.tar.gz": ReasonRequireMinimum,
+		"backup-2024-03-14-12-00.tar.gz": ReasonDaily,
+		"backup-2024-03-14-06-00.tar.gz": ReasonSuperseded,
+		"backup-2024-03-13-12-00.tar.gz": ReasonPinned,
+		"backup-2024-02-01-12-00.tar.gz": ReasonExpired,
+	}, reasons)
+	require.Equal(t, map[string]Reason{
+		"backup-2024-03-15-12-00.tar.gz": ReasonDaily,
+		"backup-2024-03-15-06-00.tar.gz": ReasonDaily,
+		"backup-2024-03-14-12-00.tar.gz": ReasonDaily,
+		"backup-2024-03-14-06-00.tar.gz": ReasonDaily,
+		"backup-2024-03-13-12-00.tar.gz": ReasonExpired,
+		"backup-2024-02-01-12-00.tar.gz": ReasonExpired,
+	}, tiers)
+	require.Equal(t, map[string]bool{
+		"backup-2024-03-15-12-00.tar.gz": false,
+		"backup-2024-03-15-06-00.tar.gz": false,
+		"backup-2024-03-14-12-00.tar.gz": false,
+		"backup-2024-03-14-06-00.tar.gz": true,
+		"backup-2024-03-13-12-00.tar.gz": false,
+		"backup-2024-02-01-12-00.tar.gz": true,
+	}, deleted)
+
+	// Decisions are returned oldest first
+	require.True(t, slices.IsSortedFunc(decisions, func(a, b Decision) int {
+		return a.File.Timestamp.Compare(b.File.Timestamp)
+	}))
+
+	// Tier slots count from the newest period
+	newest := decisions[len(decisions)-1]
+	require.Equal(t, "backup-2024-03-15-12-00.tar.gz", newest.File.Path)
+	require.Equal(t, 1, newest.Slot)
+
+	for _, d := range decisions {
+		if d.File.Path == "backup-2024-03-14-12-00.tar.gz" {
+			require.Equal(t, 2, d.Slot)
+		}
+	}
+
+	require.Len(t, result.Kept(), 4)
+	require.Len(t, result.Deleted(), 2)
+}
+
+func TestSelectPeriods(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	// Files newest first, two per hour
+	files := []file.Info{
+		{Path: "file1", Timestamp: now.Add(-15 * time.Minute)},
+		{Path: "file2", Timestamp: now.Add(-30 * time.Minute)},
+		{Path: "file3", Timestamp: now.Add(-90 * time.Minute)},
+		{Path: "file4", Timestamp: now.Add(-95 * time.Minute)},
+		{Path: "file5", Timestamp: now.Add(-150 * time.Minute)},
+		{Path: "file6", Timestamp: now.Add(-155 * time.Minute)},
+	}
+
+	tests := []struct {
+		name   string
+		files  []file.Info
+		count  int
+		starts []int
+		end    int
+	}{
 		{
-			name: "single file",
-			files: []file.Info{
-				{Timestamp: now.Add(-30 * time.Minute)},
-			},
-			grouper: hourGrouper,
-			expected: [][]file.Info{
-				{{Timestamp: now.Add(-30 * time.Minute)}},
-			},
+			name:  "empty files list",
+			files: []file.Info{},
+			count: 2,
 		},
 		{
-			name: "files in same period",
-			files: []file.Info{
-				{Timestamp: now.Add(-30 * time.Minute)},
-				{Timestamp: now.Add(-45 * time.Minute)},
-				{Timestamp: now.Add(-15 * time.Minute)},
-			},
-			grouper: hourGrouper,
-			expected: [][]file.Info{
-				{
-					{Timestamp: now.Add(-15 * time.Minute)},
-					{Timestamp: now.Add(-30 * time.Minute)},
-					{Timestamp: now.Add(-45 * time.Minute)},
-				},
-			},
+			name:  "zero count",
+			files: files,
 		},
 		{
-			name: "files in different periods",
-			files: []file.Info{
-				{Timestamp: now.Add(-30 * time.Minute)},
-				{Timestamp: now.Add(-90 * time.Minute)},
-				{Timestamp: now.Add(-150 * time.Minute)},
-			},
-			grouper: hourGrouper,
-			expected: [][]file.Info{
-				{{Timestamp: now.Add(-30 * time.Minute)}},
-				{{Timestamp: now.Add(-90 * time.Minute)}},
-				{{Timestamp: now.Add(-150 * time.Minute)}},
-			},
+			name:   "single file",
+			files:  files[:1],
+			count:  2,
+			starts: []int{0},
+			end:    1,
 		},
 		{
-			name: "files in different periods with multiple files per period",
-			files: []file.Info{
-				{Timestamp: now.Add(-30 * time.Minute)},
-				{Timestamp: now.Add(-45 * time.Minute)},
-				{Timestamp: now.Add(-90 * time.Minute)},
-				{Timestamp: now.Add(-95 * time.Minute)},
-				{Timestamp: now.Add(-150 * time.Minute)},
-				{Timestamp: now.Add(-155 * time.Minute)},
-			},
-			grouper: hourGrouper,
-			expected: [][]file.Info{
-				{
-					{Timestamp: now.Add(-30 * time.Minute)},
-					{Timestamp: now.Add(-45 * time.Minute)},
-				},
-				{
-					{Timestamp: now.Add(-90 * time.Minute)},
-					{Timestamp: now.Add(-95 * time.Minute)},
-				},
-				{
-					{Timestamp: now.Add(-150 * time.Minute)},
-					{Timestamp: now.Add(-155 * time.Minute)},
-				},
-			},
+			name:   "files in same period",
+			files:  files[:2],
+			count:  2,
+			starts: []int{0},
+			end:    2,
+		},
+		{
+			name:   "more periods than count",
+			files:  files,
+			count:  2,
+			starts: []int{0, 2},
+			end:    4,
+		},
+		{
+			name:   "keep all periods",
+			files:  files,
+			count:  3,
+			starts: []int{0, 2, 4},
+			end:    6,
+		},
+		{
+			name:   "count beyond periods",
+			files:  files,
+			count:  10,
+			starts: []int{0, 2, 4},
+			end:    6,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			groups := groupFilesByTimePeriod(tt.files, tt.grouper)
-			if tt.expected == nil {
-				require.Nil(t, groups)
-			} else {
-				require.Equal(t, tt.expected, groups)
-			}
+			starts, end := selectPeriods(tt.files, hourGrouper, tt.count)
+			require.Equal(t, tt.starts, starts)
+			require.Equal(t, tt.end, end)
 		})
 	}
 }
@@ -0,0 +1,94 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import "fmt"
+
+// unit names the period a tier keeps a backup of
+func (r Reason) unit() string {
+	switch r {
+	case ReasonHourly:
+		return "hour"
+	case ReasonDaily:
+		return "day"
+	case ReasonWeekly:
+		return "week"
+	case ReasonMonthly:
+		return "month"
+	case ReasonYearly:
+		return "year"
+	case ReasonThinning:
+		return "period"
+	default:
+		return "backup"
+	}
+}
+
+// TierStats reports how many periods a tier of the policy for a tag was
+// asked to keep, and how many of them it found a backup for. A tier takes
+// its periods from the backups older than those the finer tiers kept, so
+// a tier is only short of periods when there is not enough history. Every
+// is the years of the periods of a thinning tier, and zero otherwise.
+type TierStats struct {
+	Tag       string
+	Tier      Reason
+	Every     int
+	Requested int
+	Filled    int
+}
+
+// Name identifies the tier: its reason, followed by the years of its periods
+// for a thinning tier, such as thinning_5y
+func (s TierStats) Name() string {
+	return tier{reason: s.Tier, every: s.Every}.name()
+}
+
+// Suggestion describes a tier that found fewer periods than requested, such
+// as "weekly: 4 requested but only 2 weeks of data exist", so the policy
+// can be tuned to the actual backup cadence. It is empty for a filled tier.
+func (s TierStats) Suggestion() string {
+	if s.Filled >= s.Requested {
+		return ""
+	}
+
+	unit, verb := s.Tier.unit(), "exist"
+	if s.Filled != 1 {
+		unit += "s"
+	} else {
+		verb = "exists"
+	}
+
+	if s.Tier != ReasonKeepCount {
+		unit += " of data"
+	}
+
+	tier := s.Name()
+	if s.Tag != "" {
+		tier = fmt.Sprintf("%s (tag %s)", tier, s.Tag)
+	}
+
+	return fmt.Sprintf("%s: %d requested but only %d %s %s",
+		tier, s.Requested, s.Filled, unit, verb)
+}
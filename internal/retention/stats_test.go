@@ -0,0 +1,134 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+func TestTierStatsSuggestion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stats    TierStats
+		expected string
+	}{
+		{
+			name:  "filled",
+			stats: TierStats{Tier: ReasonDaily, Requested: 7, Filled: 7},
+		},
+		{
+			name:     "short",
+			stats:    TierStats{Tier: ReasonWeekly, Requested: 4, Filled: 2},
+			expected: "weekly: 4 requested but only 2 weeks of data exist",
+		},
+		{
+			name:     "single period",
+			stats:    TierStats{Tier: ReasonMonthly, Requested: 12, Filled: 1},
+			expected: "monthly: 12 requested but only 1 month of data exists",
+		},
+		{
+			name:     "tagged",
+			stats:    TierStats{Tag: "nightly", Tier: ReasonYearly, Requested: 3, Filled: 0},
+			expected: "yearly (tag nightly): 3 requested but only 0 years of data exist",
+		},
+		{
+			name:     "keep count",
+			stats:    TierStats{Tier: ReasonKeepCount, Requested: 10, Filled: 4},
+			expected: "keep_count: 10 requested but only 4 backups exist",
+		},
+		{
+			name:     "thinning",
+			stats:    TierStats{Tier: ReasonThinning, Every: 5, Requested: 3, Filled: 1},
+			expected: "thinning_5y: 3 requested but only 1 period of data exists",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.stats.Suggestion())
+		})
+	}
+}
+
+func TestWithTierStats(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	// Two weeks of daily backups
+	var files []file.Info
+	for i := range 14 {
+		files = append(files, file.Info{
+			Path:      "backup-" + now.AddDate(0, 0, -i).Format(time.DateOnly),
+			Timestamp: now.AddDate(0, 0, -i),
+		})
+	}
+
+	cfg := &config.Config{
+		Retention: config.RetentionPolicy{Daily: 7, Weekly: 4},
+	}
+
+	for _, apply := range []struct {
+		name string
+		run  func(p *Policy) error
+	}{
+		{
+			name: "apply",
+			run: func(p *Policy) error {
+				_, err := p.Apply(files)
+				return err
+			},
+		},
+		{
+			name: "stream",
+			run: func(p *Policy) error {
+				return p.ApplyStream(t.Context(), walkSlice(files), func(Decision) error {
+					return nil
+				})
+			},
+		},
+	} {
+		t.Run(apply.name, func(t *testing.T) {
+			var stats []TierStats
+
+			logger := &logging.Logger{Logger: zap.NewNop()}
+			policy := NewPolicy(logger, cfg, WithTierStats(func(s TierStats) {
+				stats = append(stats, s)
+			}))
+			require.NoError(t, apply.run(policy))
+
+			require.Equal(t, []TierStats{
+				{Tier: ReasonDaily, Requested: 7, Filled: 7},
+				{Tier: ReasonWeekly, Requested: 4, Filled: 2},
+			}, stats)
+		})
+	}
+}
@@ -0,0 +1,447 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// WalkFunc lists files by calling fn once for every file, in any order.
+// ApplyStream calls it twice and expects the same files both times.
+type WalkFunc func(ctx context.Context, fn func(file.Info) error) error
+
+// walkSlice lists files from a slice, in order
+func walkSlice(files []file.Info) WalkFunc {
+	return func(_ context.Context, fn func(file.Info) error) error {
+		for _, f := range files {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// streamCandidate is the file a coarser tier prefers among the files of a
+// period that share its key and the keys of every tier in between
+type streamCandidate struct {
+	keys []int64
+	file file.Info
+}
+
+// streamGroup is a period kept by a tier together with the file the tier
+// keeps for it and the candidates of the coarser tiers
+type streamGroup struct {
+	key        int64
+	kept       file.Info
+	candidates []streamCandidate
+}
+
+// add adds a file to the group. A coarser period may split the group, and
+// the finer tiers in between may split it further, so a coarser tier may
+// later choose among any part of it. The group therefore holds the file
+// each coarser tier prefers for every combination of keys it sees, rather
+// than all of its files.
+func (g *streamGroup) add(t *streamTier, f file.Info) {
+	if t.policy.prefer(t.tier, f, g.kept) < 0 {
+		g.kept = f
+	}
+
+	keys := t.keys[:0]
+
+	for _, c := range t.coarser {
+		keys = append(keys, c.key(f))
+
+		i := slices.IndexFunc(g.candidates, func(cand streamCandidate) bool {
+			return slices.Equal(cand.keys, keys)
+		})
+
+		switch {
+		case i < 0:
+			g.candidates = append(g.candidates, streamCandidate{keys: slices.Clone(keys), file: f})
+		case t.policy.prefer(c, f, g.candidates[i].file) < 0:
+			g.candidates[i].file = f
+		}
+	}
+
+	t.keys = keys
+}
+
+// streamTier tracks the newest periods of a single tier, newest first. Only
+// the periods the tier keeps are held, so memory is bounded by the count.
+type streamTier struct {
+	tier
+
+	policy  *Policy
+	coarser []tier
+	groups  []streamGroup
+
+	// keys is reused by add to hold the coarser keys of a file
+	keys []int64
+}
+
+// newStreamTiers creates the stream state of a policy's tiers
+func newStreamTiers(p *Policy, tiers []tier) []*streamTier {
+	streams := make([]*streamTier, 0, len(tiers))
+	for i, t := range tiers {
+		streams = append(streams, &streamTier{tier: t, policy: p, coarser: tiers[i+1:]})
+	}
+
+	return streams
+}
+
+// search returns the position of the period of f among the groups and
+// whether the tier holds it
+func (t *streamTier) search(f file.Info) (int, bool) {
+	return slices.BinarySearchFunc(t.groups, t.key(f), func(g streamGroup, key int64) int {
+		return cmp.Compare(key, g.key)
+	})
+}
+
+// offer adds a file to the tier. It returns the files to pass on to the next
+// tier, either f itself if its period is older than every kept period or
+// the candidates of a period the tier no longer keeps, which are the only
+// files of it the coarser tiers may prefer.
+func (t *streamTier) offer(f file.Info) []file.Info {
+	i, found := t.search(f)
+
+	switch {
+	case found:
+		t.groups[i].add(t, f)
+		return nil
+	case i >= t.count:
+		return []file.Info{f}
+	}
+
+	t.groups = slices.Insert(t.groups, i, streamGroup{key: t.key(f), kept: f})
+	t.groups[i].add(t, f)
+
+	if len(t.groups) <= t.count {
+		return nil
+	}
+
+	evicted := t.groups[t.count]
+	t.groups = t.groups[:t.count]
+
+	passed := make([]file.Info, 0, len(evicted.candidates))
+
+	for _, c := range evicted.candidates {
+		if !slices.ContainsFunc(passed, func(f file.Info) bool { return f.Path == c.file.Path }) {
+			passed = append(passed, c.file)
+		}
+	}
+
+	return passed
+}
+
+// decide returns the decision of the tier for f, and false if f falls
+// outside the tier
+func (t *streamTier) decide(f file.Info) (Decision, bool) {
+	i, found := t.search(f)
+
+	switch {
+	case !found && i < t.count:
+		// The tier would have kept the period had f been listed before
+		return Decision{File: f, Reason: ReasonNew}, true
+	case !found:
+		return Decision{}, false
+	case f.Path == t.groups[i].kept.Path:
+		return Decision{File: f, Reason: t.reason, Slot: i + 1, Tier: t.reason}, true
+	case t.policy.prefer(t.tier, f, t.groups[i].kept) < 0:
+		return Decision{File: f, Reason: ReasonNew}, true
+	}
+
+	if tied(f, t.groups[i].kept) {
+		t.policy.warnTie(t.groups[i].kept, f)
+	}
+
+	return Decision{File: f, Delete: true, Reason: ReasonSuperseded, Tier: t.reason}, true
+}
+
+// streamState is the state ApplyStream builds while listing files the first
+// time. Besides the tiers of every tag, it remembers the files that depend
+// on another file so dependency chains can be followed.
+type streamState struct {
+	duplicates map[string]string
+	tags       map[string][]*streamTier
+	files      map[string]int
+	dependsOn  map[string]string
+	targets    map[string]bool
+	children   []file.Info
+}
+
+// decide returns the decision of the tiers for a file, before pins,
+// require_minimum and dependencies are considered
+func (s *streamState) decide(f file.Info) Decision {
+	if _, ok := s.duplicates[f.Path]; ok {
+		return duplicate(f)
+	}
+
+	tiers, ok := s.tags[f.Tag]
+	if !ok {
+		return Decision{File: f, Reason: ReasonNew}
+	}
+
+	for _, tier := range tiers {
+		if d, ok := tier.decide(f); ok {
+			return d
+		}
+	}
+
+	return Decision{File: f, Delete: true, Reason: ReasonExpired, Tier: ReasonExpired}
+}
+
+// ApplyStream applies the retention policy to the files listed by walk and
+// calls emit with the decision for every file, reaching the same decisions
+// as Apply. The files are listed twice: first to find the periods each tier
+// keeps, then to decide on every file as it is listed. Only the kept periods
+// with the files the coarser tiers may prefer from them, the newest
+// RequireMinimum deleted files and the files involved in dependency chains
+// are held in memory, never the full listing. Decisions
+// are emitted in listing order, except for files whose fate depends on
+// require_minimum or on dependencies, which are emitted after the listing.
+// A file missing from the first listing is kept with ReasonNew. With dedupe
+// enabled the files are listed twice more beforehand to find duplicates.
+func (p *Policy) ApplyStream(
+	ctx context.Context,
+	walk WalkFunc,
+	emit func(Decision) error,
+) error {
+	duplicates, err := p.duplicates(ctx, walk)
+	if err != nil {
+		return err
+	}
+
+	state, err := p.scanStream(ctx, walk, duplicates)
+	if err != nil {
+		return err
+	}
+
+	required := map[string]string{}
+
+	for _, child := range state.children {
+		if d := state.decide(child); !d.Delete || child.Pinned {
+			state.require(required, child)
+		}
+	}
+
+	var (
+		kept       int
+		candidates []Decision
+		deferred   []Decision
+	)
+
+	// settle decides on a file the tiers delete and require_minimum does not
+	// keep, deferring those a file kept by require_minimum may depend on
+	settle := func(d Decision) error {
+		if by, ok := required[d.File.Path]; ok {
+			return emit(p.keepDependency(d, by))
+		}
+
+		if state.targets[d.File.Path] {
+			deferred = append(deferred, d)
+			return nil
+		}
+
+		return emit(d)
+	}
+
+	err = walk(ctx, func(f file.Info) error {
+		d := state.decide(f)
+
+		if d.Delete && f.Pinned {
+			p.logger.Info("keeping pinned file",
+				zap.String("file", f.Path),
+				zap.Time("timestamp", f.Timestamp))
+
+			d.Delete = false
+			d.Reason = ReasonPinned
+		}
+
+		if !d.Delete {
+			kept++
+			state.require(required, f)
+
+			return emit(d)
+		}
+
+		// Hold on to the newest deleted files in case require_minimum
+		// needs them
+		i, _ := slices.BinarySearchFunc(candidates, d, func(c, target Decision) int {
+			return target.File.Timestamp.Compare(c.File.Timestamp)
+		})
+		candidates = slices.Insert(candidates, i, d)
+
+		if len(candidates) <= p.config.RequireMinimum {
+			return nil
+		}
+
+		evicted := candidates[len(candidates)-1]
+		candidates = candidates[:len(candidates)-1]
+
+		return settle(evicted)
+	})
+	if err != nil {
+		return err
+	}
+
+	missing := max(p.config.RequireMinimum-kept, 0)
+
+	for _, d := range candidates[:min(missing, len(candidates))] {
+		p.logger.Warn("keeping file to satisfy require_minimum",
+			zap.String("file", d.File.Path),
+			zap.Time("timestamp", d.File.Timestamp),
+			zap.Int("require_minimum", p.config.RequireMinimum))
+
+		d.Delete = false
+		d.Reason = ReasonRequireMinimum
+		state.require(required, d.File)
+
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range candidates[min(missing, len(candidates)):] {
+		if err := settle(d); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range deferred {
+		if by, ok := required[d.File.Path]; ok {
+			d = p.keepDependency(d, by)
+		}
+
+		if err := emit(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanStream lists the files the first time and builds the tiers of every
+// tag. Duplicates are counted but take no period.
+func (p *Policy) scanStream(
+	ctx context.Context,
+	walk WalkFunc,
+	duplicates map[string]string,
+) (*streamState, error) {
+	state := &streamState{
+		duplicates: duplicates,
+		tags:       map[string][]*streamTier{},
+		files:      map[string]int{},
+		dependsOn:  map[string]string{},
+		targets:    map[string]bool{},
+	}
+
+	err := walk(ctx, func(f file.Info) error {
+		tiers, ok := state.tags[f.Tag]
+		if !ok {
+			tiers = newStreamTiers(p, p.tiersFor(f.Tag))
+			state.tags[f.Tag] = tiers
+		}
+
+		state.files[f.Tag]++
+
+		if f.DependsOn != "" {
+			state.dependsOn[f.Path] = f.DependsOn
+			state.targets[f.DependsOn] = true
+			state.children = append(state.children, f)
+		}
+
+		if _, ok := duplicates[f.Path]; ok {
+			return nil
+		}
+
+		offered := []file.Info{f}
+		for _, tier := range tiers {
+			var passed []file.Info
+			for _, f := range offered {
+				passed = append(passed, tier.offer(f)...)
+			}
+
+			offered = passed
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range slices.Sorted(maps.Keys(state.tags)) {
+		tiers := state.tags[tag]
+
+		summary := make([]tier, len(tiers))
+		retained := make([]int, len(tiers))
+
+		for i, t := range tiers {
+			summary[i] = t.tier
+			retained[i] = len(t.groups)
+		}
+
+		p.logSummary(tag, state.files[tag], summary, retained)
+	}
+
+	return state, nil
+}
+
+// require records every file a kept file depends on, following the chain
+// through the files seen while scanning
+func (s *streamState) require(required map[string]string, f file.Info) {
+	by := f.Path
+
+	for dep := f.DependsOn; dep != ""; dep = s.dependsOn[dep] {
+		if _, ok := required[dep]; ok {
+			return
+		}
+
+		required[dep] = by
+		by = dep
+	}
+}
+
+// keepDependency keeps a file the tiers deleted because a kept file
+// depends on it
+func (p *Policy) keepDependency(d Decision, requiredBy string) Decision {
+	p.logger.Info("keeping backup required by a kept backup",
+		zap.String("file", d.File.Path),
+		zap.String("required_by", requiredBy))
+
+	d.Delete = false
+	d.Reason = ReasonDependency
+
+	return d
+}
@@ -0,0 +1,267 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// applyStream collects the decisions ApplyStream emits, keyed by path
+func applyStream(t *testing.T, policy *Policy, walk WalkFunc) map[string]Decision {
+	t.Helper()
+
+	decisions := map[string]Decision{}
+
+	err := policy.ApplyStream(t.Context(), walk, func(d Decision) error {
+		_, dup := decisions[d.File.Path]
+		require.False(t, dup, "duplicate decision for %s", d.File.Path)
+
+		decisions[d.File.Path] = d
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	return decisions
+}
+
+// randomFiles generates backups at distinct random times over three years,
+// clustered so that periods hold several files, some tagged, pinned or
+// depending on an older backup
+func randomFiles(rng *rand.Rand, n int) []file.Info {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	used := map[int]bool{}
+
+	var files []file.Info
+
+	for len(files) < n {
+		minute := rng.IntN(3 * 365 * 24 * 60)
+		if rng.IntN(2) == 0 {
+			// Cluster the newest files so the finer tiers have work to do
+			minute = 3*365*24*60 - rng.IntN(7*24*60)
+		}
+
+		if used[minute] {
+			continue
+		}
+
+		used[minute] = true
+
+		f := file.Info{
+			Path:      fmt.Sprintf("backup-%08d", minute),
+			Timestamp: start.Add(time.Duration(minute) * time.Minute),
+			Tag:       []string{"", "", "", "db"}[rng.IntN(4)],
+			Pinned:    rng.IntN(20) == 0,
+		}
+
+		if len(files) > 0 && rng.IntN(4) == 0 {
+			f.DependsOn = files[rng.IntN(len(files))].Path
+		}
+
+		files = append(files, f)
+	}
+
+	rng.Shuffle(len(files), func(i, j int) {
+		files[i], files[j] = files[j], files[i]
+	})
+
+	return files
+}
+
+// requireStreamMatches checks that ApplyStream reaches the decision Apply
+// does for every file
+func requireStreamMatches(t *testing.T, policy *Policy, files []file.Info, run string) {
+	t.Helper()
+
+	result, err := policy.Apply(slices.Clone(files))
+	require.NoError(t, err)
+
+	streamed := applyStream(t, policy, walkSlice(files))
+	require.Len(t, streamed, len(files), run)
+
+	for _, want := range result.Decisions {
+		got := streamed[want.File.Path]
+		require.Equal(t, want, got, "%s: %s", run, want.File.Path)
+	}
+}
+
+func TestPolicy_ApplyStreamMatchesApply(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Weeks that a coarser period splits, so the coarser tier must choose
+	// among every file of a week the weekly tier no longer keeps
+	at := func(loc *time.Location, days ...int) []file.Info {
+		var files []file.Info
+		for _, day := range days {
+			ts := time.Date(2024, 4, day, 12, 0, 0, 0, loc)
+			files = append(files, file.Info{Path: ts.Format("backup-2006-01-02"), Timestamp: ts})
+		}
+
+		return files
+	}
+
+	tests := []struct {
+		name  string
+		files []file.Info
+		cfg   *config.Config
+	}{
+		{
+			name:  "week across a month end",
+			files: at(time.UTC, 29, 30, 32, 40),
+			cfg: &config.Config{
+				Retention: config.RetentionPolicy{Weekly: 1, Monthly: 3},
+			},
+		},
+		{
+			name:  "week across a fiscal year start",
+			files: at(time.UTC, 29, 30, 32, 40),
+			cfg: &config.Config{
+				Retention:       config.RetentionPolicy{Weekly: 1, Yearly: 3},
+				FiscalYearStart: 5,
+			},
+		},
+		{
+			name: "week across a monthly anchor",
+			// 22:30 in New York is already the next day in UTC
+			files: []file.Info{
+				{Path: "backup-15", Timestamp: time.Date(2024, 4, 15, 22, 30, 0, 0, newYork)},
+				{Path: "backup-16", Timestamp: time.Date(2024, 4, 16, 22, 30, 0, 0, newYork)},
+				{Path: "backup-17", Timestamp: time.Date(2024, 4, 17, 22, 30, 0, 0, newYork)},
+				{Path: "backup-18", Timestamp: time.Date(2024, 4, 18, 22, 30, 0, 0, newYork)},
+				{Path: "backup-22", Timestamp: time.Date(2024, 4, 22, 22, 30, 0, 0, newYork)},
+			},
+			cfg: &config.Config{
+				Retention:     config.RetentionPolicy{Weekly: 1, Monthly: 2},
+				MonthlyAnchor: "17",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requireStreamMatches(t, NewPolicy(logger, tt.cfg), tt.files, tt.name)
+		})
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	locations := []*time.Location{time.UTC, newYork, time.FixedZone("UTC+13", 13*60*60)}
+
+	for i := range 200 {
+		files := randomFiles(rng, 1+rng.IntN(300))
+		loc := locations[rng.IntN(len(locations))]
+
+		for j := range files {
+			files[j].Timestamp = files[j].Timestamp.In(loc)
+
+			if i%2 == 1 {
+				// Without pins every other run leans on require_minimum
+				files[j].Pinned = false
+			}
+		}
+
+		cfg := &config.Config{
+			Retention: config.RetentionPolicy{
+				Hourly:  rng.IntN(6),
+				Daily:   rng.IntN(6),
+				Weekly:  rng.IntN(4),
+				Monthly: rng.IntN(4),
+				Yearly:  rng.IntN(3),
+			},
+			TagRetention: config.TagPolicies{
+				"db": {Daily: rng.IntN(10), Monthly: rng.IntN(3)},
+			},
+			RequireMinimum:  rng.IntN(30),
+			MonthlyAnchor:   []string{"", config.MonthlyAnchorFirst, "15"}[rng.IntN(3)],
+			FiscalYearStart: rng.IntN(13),
+		}
+
+		requireStreamMatches(t, NewPolicy(logger, cfg), files, fmt.Sprintf("run %d", i))
+	}
+}
+
+func TestPolicy_ApplyStreamNewFiles(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []file.Info{
+		{Path: "backup-2024-03-14-12-00.tar.gz", Timestamp: now.Add(-24 * time.Hour)},
+		{Path: "backup-2024-03-13-12-00.tar.gz", Timestamp: now.Add(-48 * time.Hour)},
+		{Path: "backup-2024-03-12-12-00.tar.gz", Timestamp: now.Add(-72 * time.Hour)},
+	}
+
+	// A backup written between the two listings must not be mistaken for
+	// one that expired
+	listings := 0
+	walk := func(ctx context.Context, fn func(file.Info) error) error {
+		listings++
+		if listings == 2 {
+			files = append(files, file.Info{Path: "backup-2024-03-15-12-00.tar.gz", Timestamp: now})
+		}
+
+		return walkSlice(files)(ctx, fn)
+	}
+
+	policy := NewPolicy(logger, &config.Config{
+		Retention: config.RetentionPolicy{Daily: 2},
+	})
+
+	decisions := applyStream(t, policy, walk)
+	require.Equal(t, 2, listings)
+	require.Len(t, decisions, 4)
+
+	require.False(t, decisions["backup-2024-03-15-12-00.tar.gz"].Delete)
+	require.Equal(t, ReasonNew, decisions["backup-2024-03-15-12-00.tar.gz"].Reason)
+	require.False(t, decisions["backup-2024-03-14-12-00.tar.gz"].Delete)
+	require.False(t, decisions["backup-2024-03-13-12-00.tar.gz"].Delete)
+	require.True(t, decisions["backup-2024-03-12-12-00.tar.gz"].Delete)
+}
+
+func TestPolicy_ApplyStreamWalkError(t *testing.T) {
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	policy := NewPolicy(logger, &config.Config{})
+
+	walkErr := fmt.Errorf("listing failed")
+	err := policy.ApplyStream(t.Context(), func(context.Context, func(file.Info) error) error {
+		return walkErr
+	}, func(Decision) error {
+		t.Fatal("no decision expected")
+		return nil
+	})
+	require.ErrorIs(t, err, walkErr)
+}
@@ -0,0 +1,284 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package rsnapshot provides a backend for directories of hard-linked
+// backup trees, as created by rsnapshot or by rsync --link-dest. Every
+// subdirectory is one backup: either an rsnapshot rotation such as daily.0,
+// dated by its modification time, or a tree named after the time it was
+// taken, such as 2024-03-15T12:00. Trees share unchanged files through hard
+// links, so the size of a tree is the space deleting it would free.
+package rsnapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/files"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+var (
+	// ErrListTrees is returned when the backup directory cannot be read
+	ErrListTrees = errors.New("failed to list backup trees")
+	// ErrDeleteTree is returned when a backup tree cannot be deleted
+	ErrDeleteTree = errors.New("failed to delete backup tree")
+)
+
+// rotationName matches the interval.N directories rsnapshot rotates, but
+// not its hidden .sync directory or the _delete.PID directories it leaves
+// behind while rotating
+var rotationName = regexp.MustCompile(`^[A-Za-z][\w-]*\.\d+$`)
+
+// Manager lists and deletes hard-linked backup trees
+type Manager struct {
+	logger    *logging.Logger
+	platform  files.Platform
+	directory string
+	pins      []string
+	sizes     bool
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithLogger sets the logger for the rsnapshot backend
+func WithLogger(logger *logging.Logger) ManagerOption {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// WithPlatform sets the platform used to count hard links
+func WithPlatform(platform files.Platform) ManagerOption {
+	return func(m *Manager) {
+		m.platform = platform
+	}
+}
+
+// WithPins sets glob patterns, matched against tree names, of trees that
+// must never be deleted
+func WithPins(pins []string) ManagerOption {
+	return func(m *Manager) {
+		m.pins = pins
+	}
+}
+
+// WithSizes walks every listed tree to report the space deleting it would
+// free, counting only the files no other tree links to
+func WithSizes() ManagerOption {
+	return func(m *Manager) {
+		m.sizes = true
+	}
+}
+
+// NewManager creates a backend for a directory of backup trees
+func NewManager(directory string, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		},
+		platform:  files.NewPlatform(),
+		directory: directory,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// ListFiles returns every backup tree, oldest first
+func (m *Manager) ListFiles(ctx context.Context) ([]file.Info, error) {
+	var trees []file.Info
+
+	err := m.WalkFiles(ctx, func(tree file.Info) error {
+		trees = append(trees, tree)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(trees, func(a, b file.Info) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return trees, nil
+}
+
+// WalkFiles calls fn for every backup tree, in directory order.
+// Directories that are neither rotations nor named after a timestamp are
+// ignored, as are symlinks.
+func (m *Manager) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	err := file.ForEachEntry(m.directory, func(entry os.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		path := filepath.Join(m.directory, entry.Name())
+
+		tree, ok, err := m.readTree(entry, path)
+		if err != nil {
+			m.logger.Warn("skipping unreadable backup tree",
+				zap.String("tree", path),
+				zap.Error(err))
+
+			return nil
+		}
+
+		if !ok {
+			m.logger.Debug("ignoring directory that is not a backup tree",
+				zap.String("directory", path))
+
+			return nil
+		}
+
+		return fn(tree)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrListTrees, err)
+	}
+
+	return nil
+}
+
+// readTree dates a backup tree and, when sizes are enabled, sizes it. It
+// reports false for a directory that is not a backup tree.
+func (m *Manager) readTree(entry os.DirEntry, path string) (file.Info, bool, error) {
+	tree := file.Info{
+		Path:   path,
+		Pinned: file.IsPinned(m.pins, path, entry.Name()),
+	}
+
+	switch timestamp, ok := file.NameTimestamp(entry.Name()); {
+	case rotationName.MatchString(entry.Name()):
+		// rsnapshot touches a rotation when it finishes syncing it
+		info, err := entry.Info()
+		if err != nil {
+			return file.Info{}, false, err
+		}
+
+		tree.Timestamp = info.ModTime()
+	case ok:
+		tree.Timestamp = timestamp
+	default:
+		return file.Info{}, false, nil
+	}
+
+	if m.sizes {
+		size, err := m.uniqueSize(path)
+		if err != nil {
+			return file.Info{}, false, err
+		}
+
+		tree.Size = size
+	}
+
+	return tree, true, nil
+}
+
+// uniqueSize returns the total size of the regular files in a tree that
+// have no other hard link, which is the space deleting the tree frees.
+// Where the platform reports no link counts every file is counted.
+func (m *Manager) uniqueSize(path string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if links, err := m.platform.LinkCount(info); err == nil && links > 1 {
+			return nil
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+
+	return size, err
+}
+
+// DeleteFile removes a backup tree. Trees that do not reside under the
+// backup directory are refused with file.ErrOutsideRoot. With sizes
+// enabled the tree is sized again first, since deleting other trees may
+// have left it the last link to some of its files.
+func (m *Manager) DeleteFile(ctx context.Context, tree file.Info, dryRun bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		m.logger.Info("dry run: would delete backup tree",
+			zap.String("path", tree.Path),
+			zap.Time("timestamp", tree.Timestamp),
+			zap.Int64("size", tree.Size))
+
+		return nil
+	}
+
+	rel, err := file.RelativeToRoot(m.directory, tree.Path)
+	if err != nil {
+		return err
+	}
+
+	size := tree.Size
+
+	if m.sizes {
+		if size, err = m.uniqueSize(tree.Path); err != nil {
+			return fmt.Errorf("%w %s: %w", ErrDeleteTree, tree.Path, err)
+		}
+	}
+
+	if err := file.RemoveAllInRoot(m.directory, rel); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrDeleteTree, tree.Path, err)
+	}
+
+	m.logger.Info("deleted backup tree",
+		zap.String("path", tree.Path),
+		zap.Time("timestamp", tree.Timestamp),
+		zap.Int64("size", size))
+
+	return nil
+}
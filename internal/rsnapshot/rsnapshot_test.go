@@ -0,0 +1,141 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package rsnapshot
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// writeTree creates a backup tree holding a file with the given contents
+func writeTree(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(path, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "unique"), []byte(contents), 0o600))
+
+	return path
+}
+
+func TestManager_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+	touched := time.Date(2024, 3, 16, 4, 0, 0, 0, time.UTC)
+
+	rotation := writeTree(t, dir, "daily.0", "")
+	require.NoError(t, os.Chtimes(rotation, touched, touched))
+
+	named := writeTree(t, dir, "2024-03-15T12:00", "")
+	require.NoError(t, os.WriteFile(named+file.HoldSuffix, nil, 0o600))
+
+	writeTree(t, dir, ".sync", "")
+	writeTree(t, dir, "_delete.1234", "")
+	writeTree(t, dir, "notes", "")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "weekly.0"), nil, 0o600))
+
+	trees, err := NewManager(dir).ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, trees, 2)
+
+	require.Equal(t, named, trees[0].Path)
+	require.Equal(t, time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC), trees[0].Timestamp)
+	require.True(t, trees[0].Pinned)
+
+	require.Equal(t, rotation, trees[1].Path)
+	require.True(t, touched.Equal(trees[1].Timestamp))
+	require.False(t, trees[1].Pinned)
+
+	_, err = NewManager(filepath.Join(dir, "missing")).ListFiles(t.Context())
+	require.ErrorIs(t, err, ErrListTrees)
+}
+
+func TestManager_Sizes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("link counts are not reported on Windows")
+	}
+
+	dir := t.TempDir()
+
+	older := writeTree(t, dir, "daily.1", "older")
+	newer := writeTree(t, dir, "daily.0", "newer!")
+
+	// The shared file is hard-linked between both trees, as rsync
+	// --link-dest does for unchanged files, and frees nothing on its own
+	shared := filepath.Join(older, "shared")
+	require.NoError(t, os.WriteFile(shared, []byte("unchanged"), 0o600))
+	require.NoError(t, os.Link(shared, filepath.Join(newer, "shared")))
+
+	manager := NewManager(dir, WithSizes())
+
+	trees, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, trees, 2)
+
+	sizes := map[string]int64{}
+	for _, tree := range trees {
+		sizes[tree.Path] = tree.Size
+	}
+
+	require.Equal(t, map[string]int64{older: 5, newer: 6}, sizes)
+
+	// Once the older tree is gone the newer one holds the last link
+	require.NoError(t, manager.DeleteFile(t.Context(), file.Info{Path: older}, false))
+	require.NoDirExists(t, older)
+
+	size, err := manager.uniqueSize(newer)
+	require.NoError(t, err)
+	require.Equal(t, int64(15), size)
+}
+
+func TestManager_DeleteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTree(t, dir, "daily.0", "contents")
+	manager := NewManager(dir)
+
+	t.Run("dry run", func(t *testing.T) {
+		require.NoError(t, manager.DeleteFile(t.Context(), file.Info{Path: path}, true))
+		require.DirExists(t, path)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, manager.DeleteFile(t.Context(), file.Info{Path: path}, false))
+		require.NoDirExists(t, path)
+	})
+
+	t.Run("outside directory", func(t *testing.T) {
+		outside := writeTree(t, t.TempDir(), "daily.0", "contents")
+
+		err := manager.DeleteFile(t.Context(), file.Info{Path: outside}, false)
+		require.ErrorIs(t, err, file.ErrOutsideRoot)
+		require.DirExists(t, outside)
+	})
+}
@@ -0,0 +1,137 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package systemd implements the sd_notify protocol, reporting readiness,
+// status and watchdog keep-alives to systemd when running as a service with
+// Type=notify or WatchdogSec set.
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Ready tells systemd that startup has finished
+	Ready = "READY=1"
+	// Stopping tells systemd that the service is shutting down
+	Stopping = "STOPPING=1"
+	// Watchdog is the keep-alive expected at least once per WatchdogSec
+	Watchdog = "WATCHDOG=1"
+)
+
+// ErrNotify is returned when a notification cannot be delivered
+var ErrNotify = errors.New("failed to notify systemd")
+
+// Notifier sends notifications to the socket systemd passed in
+// NOTIFY_SOCKET. Without one, every notification is silently dropped so the
+// same code runs outside of systemd.
+type Notifier struct {
+	socket   string
+	watchdog time.Duration
+}
+
+// NewNotifier creates a notifier from the environment systemd sets up for a
+// service. The watchdog interval is only honoured when WATCHDOG_PID, if
+// set, names this process.
+func NewNotifier() *Notifier {
+	n := &Notifier{socket: os.Getenv("NOTIFY_SOCKET")}
+
+	pid := os.Getenv("WATCHDOG_PID")
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err == nil && usec > 0 {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+
+	return n
+}
+
+// Enabled reports whether notifications are delivered anywhere
+func (n *Notifier) Enabled() bool {
+	return n.socket != ""
+}
+
+// Notify sends one or more newline separated assignments such as Ready
+func (n *Notifier) Notify(state string) error {
+	if n.socket == "" {
+		return nil
+	}
+
+	socket := n.socket
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotify, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotify, err)
+	}
+
+	return nil
+}
+
+// Status sets the free-form status shown by systemctl status
+func (n *Notifier) Status(status string) error {
+	return n.Notify("STATUS=" + status)
+}
+
+// StartWatchdog pings the watchdog at half the configured interval until
+// ctx is done. It does nothing when no watchdog is configured. Failed pings
+// are passed to onError.
+func (n *Notifier) StartWatchdog(ctx context.Context, onError func(error)) {
+	if n.socket == "" || n.watchdog <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(n.watchdog / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := n.Notify(Watchdog); err != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
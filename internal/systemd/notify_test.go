@@ -0,0 +1,114 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// listen creates a notification socket and points NOTIFY_SOCKET at it. The
+// socket lives in a short temporary directory because socket paths are
+// limited to about 100 bytes.
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on Windows")
+	}
+
+	dir, err := os.MkdirTemp("", "sd")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "notify")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	return conn
+}
+
+// receive reads the next notification from conn
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	return string(buf[:n])
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	conn := listen(t)
+
+	notifier := NewNotifier()
+	require.True(t, notifier.Enabled())
+
+	require.NoError(t, notifier.Notify(Ready))
+	require.Equal(t, "READY=1", receive(t, conn))
+
+	require.NoError(t, notifier.Status("pruning"))
+	require.Equal(t, "STATUS=pruning", receive(t, conn))
+}
+
+func TestNotifier_Watchdog(t *testing.T) {
+	conn := listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	NewNotifier().StartWatchdog(t.Context(), func(err error) {
+		t.Errorf("watchdog ping failed: %v", err)
+	})
+
+	require.Equal(t, Watchdog, receive(t, conn))
+	require.Equal(t, Watchdog, receive(t, conn))
+}
+
+func TestNotifier_Disabled(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("WATCHDOG_USEC", "20000")
+
+	notifier := NewNotifier()
+	require.False(t, notifier.Enabled())
+	require.NoError(t, notifier.Notify(Ready))
+
+	t.Setenv("NOTIFY_SOCKET", "/run/systemd/notify")
+	t.Setenv("WATCHDOG_PID", "1")
+	require.Zero(t, NewNotifier().watchdog)
+}
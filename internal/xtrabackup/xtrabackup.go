@@ -0,0 +1,308 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package xtrabackup provides a backend for directories of xtrabackup
+// backups, where every backup is a directory containing an
+// xtrabackup_checkpoints file. Incremental backups are linked to the backup
+// they were taken from through their LSNs so the retention policy never
+// breaks an incremental chain.
+package xtrabackup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+const (
+	// CheckpointsFile marks a directory as an xtrabackup backup
+	CheckpointsFile = "xtrabackup_checkpoints"
+	// InfoFile holds the start time of a backup
+	InfoFile = "xtrabackup_info"
+	// incrementalType is the backup_type of incremental backups
+	incrementalType = "incremental"
+	// startTimeLayout is the layout of start_time in xtrabackup_info
+	startTimeLayout = "2006-01-02 15:04:05"
+)
+
+var (
+	// ErrListBackups is returned when the backup directory cannot be read
+	ErrListBackups = errors.New("failed to list backups")
+	// ErrParseCheckpoints is returned for an unreadable checkpoints file
+	ErrParseCheckpoints = errors.New("failed to parse xtrabackup_checkpoints")
+	// ErrDeleteBackup is returned when a backup cannot be deleted
+	ErrDeleteBackup = errors.New("failed to delete backup")
+)
+
+// Manager lists and deletes xtrabackup backup directories
+type Manager struct {
+	logger    *logging.Logger
+	directory string
+	pins      []string
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithLogger sets the logger for the xtrabackup backend
+func WithLogger(logger *logging.Logger) ManagerOption {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// WithPins sets glob patterns, matched against backup directory names, of
+// backups that must never be deleted
+func WithPins(pins []string) ManagerOption {
+	return func(m *Manager) {
+		m.pins = pins
+	}
+}
+
+// NewManager creates a backend for a directory of xtrabackup backups
+func NewManager(directory string, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		logger: &logging.Logger{
+			Logger: zap.NewNop(),
+		},
+		directory: directory,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// backup is a parsed backup directory
+type backup struct {
+	info        file.Info
+	incremental bool
+	fromLSN     uint64
+	toLSN       uint64
+}
+
+// ListFiles returns every backup directory, oldest first. Each incremental
+// backup depends on the newest older backup whose to_lsn equals its
+// from_lsn. Directories without a checkpoints file are ignored.
+func (m *Manager) ListFiles(ctx context.Context) ([]file.Info, error) {
+	entries, err := os.ReadDir(m.directory)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListBackups, err)
+	}
+
+	var backups []backup
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if !entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(m.directory, entry.Name())
+
+		b, err := m.readBackup(path, entry.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			m.logger.Warn("skipping backup without usable metadata",
+				zap.String("backup", path),
+				zap.Error(err))
+
+			continue
+		}
+
+		backups = append(backups, b)
+	}
+
+	slices.SortFunc(backups, func(a, b backup) int {
+		return a.info.Timestamp.Compare(b.info.Timestamp)
+	})
+
+	files := make([]file.Info, len(backups))
+
+	for i, b := range backups {
+		if b.incremental {
+			b.info.DependsOn = m.base(backups[:i], b)
+		}
+
+		files[i] = b.info
+	}
+
+	return files, nil
+}
+
+// WalkFiles calls fn for every backup, oldest first. Linking incremental
+// backups to their base needs the LSNs of every backup, so the backups are
+// listed in full first; a directory holds few enough of them for that.
+func (m *Manager) WalkFiles(ctx context.Context, fn func(file.Info) error) error {
+	backups, err := m.ListFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backups {
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// base returns the path of the newest older backup an incremental backup
+// was taken from, or an empty string if its base is missing
+func (m *Manager) base(older []backup, incremental backup) string {
+	for i := len(older) - 1; i >= 0; i-- {
+		if older[i].toLSN == incremental.fromLSN {
+			return older[i].info.Path
+		}
+	}
+
+	m.logger.Warn("incremental backup has no base backup",
+		zap.String("backup", incremental.info.Path),
+		zap.Uint64("from_lsn", incremental.fromLSN))
+
+	return ""
+}
+
+// DeleteFile removes a backup directory. Directories that do not reside
+// under the backup directory are refused with file.ErrOutsideRoot.
+func (m *Manager) DeleteFile(ctx context.Context, b file.Info, dryRun bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		m.logger.Info("dry run: would delete backup",
+			zap.String("path", b.Path),
+			zap.Time("timestamp", b.Timestamp),
+			zap.Int64("size", b.Size))
+
+		return nil
+	}
+
+	rel, err := file.RelativeToRoot(m.directory, b.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := file.RemoveAllInRoot(m.directory, rel); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrDeleteBackup, b.Path, err)
+	}
+
+	m.logger.Info("deleted backup",
+		zap.String("path", b.Path),
+		zap.Time("timestamp", b.Timestamp),
+		zap.Int64("size", b.Size))
+
+	return nil
+}
+
+// readBackup parses the metadata of a single backup directory
+func (m *Manager) readBackup(path, name string) (backup, error) {
+	checkpoints, err := readKeyValues(filepath.Join(path, CheckpointsFile))
+	if err != nil {
+		return backup{}, err
+	}
+
+	b := backup{
+		info: file.Info{
+			Path:   path,
+			Pinned: file.IsPinned(m.pins, path, name),
+		},
+		incremental: checkpoints["backup_type"] == incrementalType,
+	}
+
+	if b.fromLSN, err = strconv.ParseUint(checkpoints["from_lsn"], 10, 64); err != nil {
+		return backup{}, fmt.Errorf("%w: from_lsn: %w", ErrParseCheckpoints, err)
+	}
+
+	if b.toLSN, err = strconv.ParseUint(checkpoints["to_lsn"], 10, 64); err != nil {
+		return backup{}, fmt.Errorf("%w: to_lsn: %w", ErrParseCheckpoints, err)
+	}
+
+	if b.info.Timestamp, err = startTime(path); err != nil {
+		return backup{}, err
+	}
+
+	return b, nil
+}
+
+// startTime returns the start_time recorded in xtrabackup_info, falling
+// back to the modification time of the checkpoints file for backups taken
+// without it
+func startTime(path string) (time.Time, error) {
+	info, err := readKeyValues(filepath.Join(path, InfoFile))
+	if err == nil && info["start_time"] != "" {
+		return time.ParseInLocation(startTimeLayout, info["start_time"], time.Local)
+	}
+
+	stat, err := os.Stat(filepath.Join(path, CheckpointsFile))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return stat.ModTime(), nil
+}
+
+// readKeyValues parses a file of "key = value" lines
+func readKeyValues(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	return values, scanner.Err()
+}
@@ -0,0 +1,130 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package xtrabackup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+)
+
+// writeBackup creates an xtrabackup backup directory with its metadata
+func writeBackup(
+	t *testing.T,
+	dir, name, backupType string,
+	fromLSN, toLSN int,
+	start string,
+) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(path, 0o750))
+
+	checkpoints := fmt.Sprintf("backup_type = %s\nfrom_lsn = %d\nto_lsn = %d\n",
+		backupType, fromLSN, toLSN)
+	require.NoError(t, os.WriteFile(
+		filepath.Join(path, CheckpointsFile), []byte(checkpoints), 0o600))
+
+	info := fmt.Sprintf("tool_name = xtrabackup\nstart_time = %s\n", start)
+	require.NoError(t, os.WriteFile(filepath.Join(path, InfoFile), []byte(info), 0o600))
+
+	return path
+}
+
+func TestManager_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	full := writeBackup(t, dir, "full", "full-backuped", 0, 100, "2024-03-14 00:00:00")
+	inc1 := writeBackup(t, dir, "inc1", "incremental", 100, 200, "2024-03-14 06:00:00")
+	inc2 := writeBackup(t, dir, "inc2", "incremental", 200, 300, "2024-03-14 12:00:00")
+	orphan := writeBackup(t, dir, "orphan", "incremental", 900, 950, "2024-03-14 18:00:00")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "not-a-backup"), 0o750))
+	require.NoError(t, os.WriteFile(full+".hold", nil, 0o600))
+
+	manager := NewManager(dir, WithPins([]string{"inc2"}))
+
+	backups, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 4)
+
+	require.Equal(t, full, backups[0].Path)
+	require.Equal(t, time.Date(2024, 3, 14, 0, 0, 0, 0, time.Local), backups[0].Timestamp)
+	require.Empty(t, backups[0].DependsOn)
+	require.True(t, backups[0].Pinned)
+
+	require.Equal(t, inc1, backups[1].Path)
+	require.Equal(t, full, backups[1].DependsOn)
+	require.False(t, backups[1].Pinned)
+
+	require.Equal(t, inc2, backups[2].Path)
+	require.Equal(t, inc1, backups[2].DependsOn)
+	require.True(t, backups[2].Pinned)
+
+	require.Equal(t, orphan, backups[3].Path)
+	require.Empty(t, backups[3].DependsOn)
+}
+
+func TestManager_ListFilesSkipsBrokenCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+
+	writeBackup(t, dir, "full", "full-backuped", 0, 100, "2024-03-14 00:00:00")
+
+	broken := filepath.Join(dir, "broken")
+	require.NoError(t, os.Mkdir(broken, 0o750))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(broken, CheckpointsFile), []byte("from_lsn = abc\n"), 0o600))
+
+	backups, err := NewManager(dir).ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+}
+
+func TestManager_DeleteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeBackup(t, dir, "full", "full-backuped", 0, 100, "2024-03-14 00:00:00")
+
+	manager := NewManager(dir)
+
+	backups, err := manager.ListFiles(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	require.NoError(t, manager.DeleteFile(t.Context(), backups[0], true))
+	require.DirExists(t, path)
+
+	require.NoError(t, manager.DeleteFile(t.Context(), backups[0], false))
+	require.NoDirExists(t, path)
+
+	outside := writeBackup(t, t.TempDir(), "full", "full-backuped", 0, 100, "2024-03-15 00:00:00")
+	err = manager.DeleteFile(t.Context(), file.Info{Path: outside}, false)
+	require.ErrorIs(t, err, file.ErrOutsideRoot)
+	require.DirExists(t, outside)
+}
@@ -0,0 +1,133 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package files
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned while listing and acting on backup files. PathError and
+// PatternTokenError match the relevant one with errors.Is.
+var (
+	ErrInvalidPattern = errors.New("invalid file pattern")
+	ErrListFiles      = errors.New("failed to list files")
+	ErrParseTimestamp = errors.New("failed to parse timestamp")
+	ErrDeleteFile     = errors.New("failed to delete file")
+	ErrNotRegularFile = errors.New("not a regular file")
+	ErrAccessDenied   = errors.New("access denied")
+	ErrArchiveFile    = errors.New("failed to archive file")
+	ErrArchiveExists  = errors.New("archive already holds a file of that name")
+	ErrCompressFile   = errors.New("failed to compress file")
+	ErrComputeSize    = errors.New("failed to compute size")
+	ErrOutsideRoot    = errors.New("path is outside the backup directory")
+	ErrFileOperation  = errors.New("file operation failed")
+)
+
+// Op is an operation on a backup file that a PathError reports
+type Op string
+
+// Operations reported by PathError
+const (
+	// OpDelete is deleting a file, matching ErrDeleteFile
+	OpDelete Op = "delete"
+	// OpAccess is checking that a file may be deleted, matching
+	// ErrAccessDenied
+	OpAccess Op = "access"
+	// OpArchive is moving a file to the archive, matching ErrArchiveFile
+	OpArchive Op = "archive"
+	// OpCompress is compressing a file, matching ErrCompressFile
+	OpCompress Op = "compress"
+	// OpSize is computing the size of a file or directory, matching
+	// ErrComputeSize
+	OpSize Op = "size"
+)
+
+// sentinel returns the error an operation's PathError matches, and
+// ErrFileOperation for an operation other than those above
+func (op Op) sentinel() error {
+	switch op {
+	case OpDelete:
+		return ErrDeleteFile
+	case OpAccess:
+		return ErrAccessDenied
+	case OpArchive:
+		return ErrArchiveFile
+	case OpCompress:
+		return ErrCompressFile
+	case OpSize:
+		return ErrComputeSize
+	default:
+		return ErrFileOperation
+	}
+}
+
+// PathError records an operation on a backup file that failed, the path of
+// the file and the cause. It matches both the error for its operation, such
+// as ErrDeleteFile for OpDelete, and the cause with errors.Is. An Op other
+// than the ones declared here matches ErrFileOperation.
+type PathError struct {
+	Op   Op
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	sentinel := e.Op.sentinel()
+	if errors.Is(sentinel, ErrFileOperation) {
+		return fmt.Sprintf("%s: %s %s: %v", sentinel, e.Op, e.Path, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s: %v", sentinel, e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() []error {
+	return []error{e.Op.sentinel(), e.Err}
+}
+
+// Problems reported by PatternTokenError
+const (
+	// TokenRepeated means the token appears more than once in the pattern
+	TokenRepeated = "appears more than once"
+	// TokenMissing means the pattern lacks a token it needs
+	TokenMissing = "is required"
+)
+
+// PatternTokenError reports a token that makes a file pattern unusable,
+// such as one that appears twice or a required one that is missing. It
+// matches ErrInvalidPattern with errors.Is.
+type PatternTokenError struct {
+	Pattern string
+	Token   string
+	Problem string
+}
+
+func (e *PatternTokenError) Error() string {
+	return fmt.Sprintf("%s %q: {%s} %s", ErrInvalidPattern, e.Pattern, e.Token, e.Problem)
+}
+
+func (e *PatternTokenError) Unwrap() error {
+	return ErrInvalidPattern
+}
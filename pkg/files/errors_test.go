@@ -0,0 +1,62 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package files
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathError(t *testing.T) {
+	var err error = &PathError{Op: OpDelete, Path: "/backups/a.tar", Err: os.ErrPermission}
+
+	require.EqualError(t, err, "failed to delete file /backups/a.tar: permission denied")
+	require.ErrorIs(t, err, ErrDeleteFile)
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.NotErrorIs(t, err, ErrArchiveFile)
+
+	var pathErr *PathError
+	require.ErrorAs(t, errors.Join(errors.New("prune"), err), &pathErr)
+	require.Equal(t, OpDelete, pathErr.Op)
+	require.Equal(t, "/backups/a.tar", pathErr.Path)
+
+	// An undeclared operation matches one fixed error
+	err = &PathError{Op: "chmod", Path: "/backups/a.tar", Err: os.ErrPermission}
+	require.EqualError(t, err, "file operation failed: chmod /backups/a.tar: permission denied")
+	require.ErrorIs(t, err, ErrFileOperation)
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.NotErrorIs(t, err, ErrDeleteFile)
+}
+
+func TestPatternTokenError(t *testing.T) {
+	err := &PatternTokenError{Pattern: "{year}-{year}", Token: "year", Problem: TokenRepeated}
+
+	require.EqualError(t, err,
+		`invalid file pattern "{year}-{year}": {year} appears more than once`)
+	require.ErrorIs(t, err, ErrInvalidPattern)
+}
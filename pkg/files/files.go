@@ -28,14 +28,31 @@
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 )
 
 // ErrNotImplemented is returned when a platform-specific operation is not implemented
 var ErrNotImplemented = errors.New("operation not implemented on this platform")
 
-// FileSystemStats contains filesystem statistics
+// ErrNoWriteAccess is returned when the current user may not delete a file.
+// It wraps os.ErrPermission so callers can test for either.
+var ErrNoWriteAccess = fmt.Errorf("no write access: %w", os.ErrPermission)
+
+// ErrFileChanged is returned by RemoveFile when the name no longer refers to
+// the regular file it was listed as
+var ErrFileChanged = errors.New("file changed since it was listed")
+
+// ErrNotMountPoint is returned by MountType when a directory is not the root
+// of a mounted filesystem
+var ErrNotMountPoint = errors.New("not a mountpoint")
+
+// FileSystemStats contains filesystem statistics. AvailableBytes is the
+// free space available to the current user.
 type FileSystemStats struct {
-	Type int64
+	Type           int64
+	AvailableBytes uint64
 }
 
 // Platform provides platform-specific file operations
@@ -56,6 +73,54 @@ type Platform interface {
 	SetReadOnly(ctx context.Context, path string) error
 	// RemoveReadOnly removes read-only attribute from a file
 	RemoveReadOnly(ctx context.Context, path string) error
+	// CheckWriteAccess checks that the current user may modify and delete a
+	// file, returning an error wrapping ErrNoWriteAccess if not
+	CheckWriteAccess(path string) error
+	// CheckWriteAccessAt is CheckWriteAccess for the file name in the open
+	// directory dir, checked relative to dir rather than by path. Platforms
+	// that cannot do so return ErrNotImplemented.
+	CheckWriteAccessAt(dir *os.File, name string) error
+	// RemoveFile deletes the regular file name in the open directory dir. It
+	// refuses symlinks and anything that is not a regular file and, when
+	// listed is not nil, any file other than the one listed describes,
+	// returning an error wrapping ErrFileChanged. Platforms that cannot check
+	// the file relative to dir return ErrNotImplemented.
+	RemoveFile(dir *os.File, name string, listed os.FileInfo) error
+	// StatAt returns the file info of name in the open directory dir,
+	// without following a symlink, so a parent directory renamed or
+	// replaced since dir was opened cannot redirect it. Platforms that
+	// cannot stat relative to an open directory return ErrNotImplemented.
+	StatAt(dir *os.File, name string) (os.FileInfo, error)
+	// LinkCount returns the number of hard links to the file described by
+	// info, as returned by os.Lstat. Platforms whose file info carries no
+	// link count return ErrNotImplemented.
+	LinkCount(info os.FileInfo) (uint64, error)
+	// Owner returns the numeric user and group IDs owning the file described
+	// by info, as returned by os.Lstat. Platforms without numeric owners
+	// return ErrNotImplemented.
+	Owner(info os.FileInfo) (uid, gid string, err error)
+	// Getxattr returns the value of the extended attribute name of the file
+	// at path, without following symlinks. Platforms without extended
+	// attributes return ErrNotImplemented.
+	Getxattr(path, name string) ([]byte, error)
+	// MountType returns the type of the filesystem mounted at the directory
+	// path, such as nfs4 or ext4, or an error wrapping ErrNotMountPoint if
+	// path is not the root of a mounted filesystem
+	MountType(path string) (string, error)
+	// NormalizePath cleans a directory path so it can be walked reliably,
+	// e.g. making Windows paths absolute so long paths and UNC shares work
+	NormalizePath(path string) string
 }
 
 // Platform-specific implementations are in separate files with build tags
+
+// resolveDirectory returns the absolute path of a directory with symlinks
+// resolved, as the system lists the mountpoints it is compared against
+func resolveDirectory(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
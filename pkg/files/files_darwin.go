@@ -28,12 +28,20 @@
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"syscall"
 )
 
+// Mode bits for access(2), which the syscall package does not export
+const (
+	accessWrite   = 0x2
+	accessExecute = 0x1
+)
+
 // DarwinPlatform implements Platform for OSX systems
 type DarwinPlatform struct{}
 
@@ -52,6 +60,7 @@ func (p *DarwinPlatform) Statfs(path string, stat *FileSystemStats) error {
 	}
 
 	stat.Type = int64(unixStat.Type)
+	stat.AvailableBytes = unixStat.Bavail * uint64(unixStat.Bsize)
 
 	return nil
 }
@@ -97,3 +106,105 @@ func (p *DarwinPlatform) SetReadOnly(ctx context.Context, path string) error {
 func (p *DarwinPlatform) RemoveReadOnly(ctx context.Context, path string) error {
 	return os.Chmod(filepath.Clean(path), 0o600)
 }
+
+// CheckWriteAccess implements Platform.CheckWriteAccess for OSX systems.
+// The file must be writable and its directory writable and searchable.
+func (p *DarwinPlatform) CheckWriteAccess(path string) error {
+	path = filepath.Clean(path)
+
+	if err := syscall.Access(path, accessWrite); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, path, err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := syscall.Access(dir, accessWrite|accessExecute); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, dir, err)
+	}
+
+	return nil
+}
+
+// CheckWriteAccessAt implements Platform.CheckWriteAccessAt for OSX
+// systems. It is not implemented.
+func (p *DarwinPlatform) CheckWriteAccessAt(dir *os.File, name string) error {
+	return ErrNotImplemented
+}
+
+// RemoveFile implements Platform.RemoveFile for OSX systems. It is not
+// implemented, so callers fall back to removing the file by name.
+func (p *DarwinPlatform) RemoveFile(dir *os.File, name string, listed os.FileInfo) error {
+	return ErrNotImplemented
+}
+
+// StatAt implements Platform.StatAt for OSX systems. It is not
+// implemented.
+func (p *DarwinPlatform) StatAt(dir *os.File, name string) (os.FileInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+// LinkCount implements Platform.LinkCount for OSX systems
+func (p *DarwinPlatform) LinkCount(info os.FileInfo) (uint64, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, ErrNotImplemented
+	}
+
+	return uint64(stat.Nlink), nil
+}
+
+// Owner implements Platform.Owner for OSX systems
+func (p *DarwinPlatform) Owner(info os.FileInfo) (string, string, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", ErrNotImplemented
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10), nil
+}
+
+// Getxattr implements Platform.Getxattr for OSX systems. Extended attributes
+// are not read on macOS yet.
+func (p *DarwinPlatform) Getxattr(path, name string) ([]byte, error) {
+	return nil, ErrNotImplemented
+}
+
+// MountType implements Platform.MountType for OSX systems. statfs reports
+// where the filesystem holding the directory is mounted, which must be the
+// directory itself.
+func (p *DarwinPlatform) MountType(path string) (string, error) {
+	resolved, err := resolveDirectory(path)
+	if err != nil {
+		return "", err
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(resolved, &stat); err != nil {
+		return "", &os.PathError{Op: "statfs", Path: resolved, Err: err}
+	}
+
+	if cString(stat.Mntonname[:]) != resolved {
+		return "", fmt.Errorf("%s: %w", path, ErrNotMountPoint)
+	}
+
+	return cString(stat.Fstypename[:]), nil
+}
+
+// cString converts a NUL-terminated string from a system structure
+func cString(chars []int8) string {
+	b := make([]byte, 0, len(chars))
+
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+
+		b = append(b, byte(c))
+	}
+
+	return string(b)
+}
+
+// NormalizePath implements Platform.NormalizePath for OSX systems
+func (p *DarwinPlatform) NormalizePath(path string) string {
+	return filepath.Clean(path)
+}
@@ -27,15 +27,29 @@
 package files
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"golang.org/x/sys/unix"
 )
 
+// xattrSize is the buffer size extended attributes are first read with,
+// enough for a timestamp or a checksum
+const xattrSize = 256
+
+// mountInfoPath lists the filesystems mounted in the mount namespace of the
+// process
+const mountInfoPath = "/proc/self/mountinfo"
+
 // LinuxPlatform implements Platform for Linux systems
 type LinuxPlatform struct{}
 
@@ -54,6 +68,7 @@ func (p *LinuxPlatform) Statfs(path string, stat *FileSystemStats) error {
 	}
 
 	stat.Type = unixStat.Type
+	stat.AvailableBytes = unixStat.Bavail * uint64(unixStat.Bsize)
 
 	return nil
 }
@@ -103,3 +118,214 @@ func (p *LinuxPlatform) SetReadOnly(ctx context.Context, path string) error {
 func (p *LinuxPlatform) RemoveReadOnly(ctx context.Context, path string) error {
 	return os.Chmod(filepath.Clean(path), 0o600)
 }
+
+// CheckWriteAccess implements Platform.CheckWriteAccess for Linux systems.
+// The file must be writable and its directory writable and searchable.
+func (p *LinuxPlatform) CheckWriteAccess(path string) error {
+	path = filepath.Clean(path)
+
+	if err := unix.Access(path, unix.W_OK); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, path, err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := unix.Access(dir, unix.W_OK|unix.X_OK); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, dir, err)
+	}
+
+	return nil
+}
+
+// CheckWriteAccessAt implements Platform.CheckWriteAccessAt for Linux
+// systems with faccessat relative to dir
+func (p *LinuxPlatform) CheckWriteAccessAt(dir *os.File, name string) error {
+	dirfd := int(dir.Fd())
+
+	if err := unix.Faccessat(dirfd, name, unix.W_OK, 0); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, name, err)
+	}
+
+	if err := unix.Faccessat(dirfd, ".", unix.W_OK|unix.X_OK, 0); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, dir.Name(), err)
+	}
+
+	return nil
+}
+
+// RemoveFile implements Platform.RemoveFile for Linux systems. The file is
+// opened with O_PATH|O_NOFOLLOW relative to dir and checked with fstat, then
+// unlinked relative to dir by name. That narrows the window for a symlink or
+// a different file to be substituted to the time between fstat and unlinkat,
+// but does not close it: unlinkat cannot be bound to the descriptor checked.
+func (p *LinuxPlatform) RemoveFile(dir *os.File, name string, listed os.FileInfo) error {
+	dirfd := int(dir.Fd())
+
+	fd, err := unix.Openat(dirfd, name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+	defer unix.Close(fd)
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(fd, &stat); err != nil {
+		return &os.PathError{Op: "fstat", Path: name, Err: err}
+	}
+
+	if stat.Mode&unix.S_IFMT != unix.S_IFREG {
+		return fmt.Errorf("%w: %s is not a regular file", ErrFileChanged, name)
+	}
+
+	if listed != nil {
+		sys, ok := listed.Sys().(*syscall.Stat_t)
+		if ok && (uint64(sys.Dev) != uint64(stat.Dev) || sys.Ino != stat.Ino) {
+			return fmt.Errorf("%w: %s was replaced", ErrFileChanged, name)
+		}
+	}
+
+	if err := unix.Unlinkat(dirfd, name, 0); err != nil {
+		return &os.PathError{Op: "unlinkat", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// StatAt implements Platform.StatAt for Linux systems. The file is opened
+// with O_PATH|O_NOFOLLOW relative to dir and checked with fstat, as
+// RemoveFile does.
+func (p *LinuxPlatform) StatAt(dir *os.File, name string) (os.FileInfo, error) {
+	fd, err := unix.Openat(int(dir.Fd()), name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+
+	f := os.NewFile(uintptr(fd), name)
+	defer f.Close()
+
+	return f.Stat()
+}
+
+// LinkCount implements Platform.LinkCount for Linux systems
+func (p *LinuxPlatform) LinkCount(info os.FileInfo) (uint64, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, ErrNotImplemented
+	}
+
+	return uint64(stat.Nlink), nil
+}
+
+// Owner implements Platform.Owner for Linux systems
+func (p *LinuxPlatform) Owner(info os.FileInfo) (string, string, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", ErrNotImplemented
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10), nil
+}
+
+// Getxattr implements Platform.Getxattr for Linux systems
+func (p *LinuxPlatform) Getxattr(path, name string) ([]byte, error) {
+	buf := make([]byte, xattrSize)
+
+	for {
+		n, err := unix.Lgetxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The value does not fit, ask for its size and read it again
+			if n, err = unix.Lgetxattr(path, name, nil); err == nil {
+				buf = make([]byte, n)
+				continue
+			}
+		}
+
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+
+		return buf[:n], nil
+	}
+}
+
+// MountType implements Platform.MountType for Linux systems. The mount table
+// of the process is consulted rather than comparing device numbers, so bind
+// mounts are recognized as well.
+func (p *LinuxPlatform) MountType(path string) (string, error) {
+	resolved, err := resolveDirectory(path)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fstype, err := mountType(f, resolved)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	return fstype, nil
+}
+
+// mountType returns the type of the filesystem mounted at path in a table in
+// the format of /proc/self/mountinfo, whose lines look like
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+//
+// The last mount at path wins, as it hides those mounted there before it.
+func mountType(r io.Reader, path string) (string, error) {
+	var fstype string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// The optional fields before the separator vary in number
+		sep := slices.Index(fields, "-")
+		if sep < 5 || sep+1 >= len(fields) {
+			continue
+		}
+
+		if unescapeMount(fields[4]) == path {
+			fstype = fields[sep+1]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if fstype == "" {
+		return "", ErrNotMountPoint
+	}
+
+	return fstype, nil
+}
+
+// unescapeMount decodes the octal escapes the kernel writes for spaces, tabs,
+// newlines and backslashes in the paths of the mount table
+func unescapeMount(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+
+				continue
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// NormalizePath implements Platform.NormalizePath for Linux systems
+func (p *LinuxPlatform) NormalizePath(path string) string {
+	return filepath.Clean(path)
+}
@@ -0,0 +1,232 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxPlatform_RemoveFile(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := os.Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+
+	platform := NewPlatform()
+
+	write := func(t *testing.T, name string) (string, os.FileInfo) {
+		t.Helper()
+
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o600))
+
+		info, err := os.Lstat(path)
+		require.NoError(t, err)
+
+		return path, info
+	}
+
+	t.Run("regular file", func(t *testing.T) {
+		path, info := write(t, "backup.zip")
+
+		require.NoError(t, platform.RemoveFile(d, "backup.zip", info))
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("without listed info", func(t *testing.T) {
+		path, _ := write(t, "unlisted.zip")
+
+		require.NoError(t, platform.RemoveFile(d, "unlisted.zip", nil))
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("replaced file", func(t *testing.T) {
+		path, info := write(t, "replaced.zip")
+		replacement, _ := write(t, "replacement")
+		require.NoError(t, os.Rename(replacement, path))
+
+		err := platform.RemoveFile(d, "replaced.zip", info)
+		require.ErrorIs(t, err, ErrFileChanged)
+		require.FileExists(t, path)
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		target, info := write(t, "target.zip")
+		link := filepath.Join(dir, "link.zip")
+		require.NoError(t, os.Symlink(target, link))
+
+		err := platform.RemoveFile(d, "link.zip", info)
+		require.ErrorIs(t, err, ErrFileChanged)
+		require.FileExists(t, target)
+
+		_, err = os.Lstat(link)
+		require.NoError(t, err)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		path := filepath.Join(dir, "subdir")
+		require.NoError(t, os.Mkdir(path, 0o750))
+
+		err := platform.RemoveFile(d, "subdir", nil)
+		require.ErrorIs(t, err, ErrFileChanged)
+		require.DirExists(t, path)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		err := platform.RemoveFile(d, "missing.zip", nil)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestLinuxPlatform_StatAt(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "backup.zip"), []byte("backup"), 0o600))
+	require.NoError(t, os.Symlink("backup.zip", filepath.Join(dir, "link.zip")))
+
+	d, err := os.Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+
+	platform := NewPlatform()
+
+	info, err := platform.StatAt(d, "backup.zip")
+	require.NoError(t, err)
+	require.True(t, info.Mode().IsRegular())
+	require.EqualValues(t, 6, info.Size())
+
+	// Renaming the directory does not affect lookups through the open handle
+	moved := dir + ".moved"
+	require.NoError(t, os.Rename(dir, moved))
+	t.Cleanup(func() { _ = os.Rename(moved, dir) })
+
+	info, err = platform.StatAt(d, "link.zip")
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&os.ModeSymlink)
+
+	_, err = platform.StatAt(d, "missing.zip")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLinuxPlatform_CheckWriteAccessAt(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "backup.zip"), nil, 0o600))
+
+	d, err := os.Open(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+
+	platform := NewPlatform()
+
+	require.NoError(t, platform.CheckWriteAccessAt(d, "backup.zip"))
+
+	err = platform.CheckWriteAccessAt(d, "missing.zip")
+	require.ErrorIs(t, err, ErrNoWriteAccess)
+}
+
+func TestLinuxPlatform_Owner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.zip")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+
+	uid, gid, err := NewPlatform().Owner(info)
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(os.Getuid()), uid)
+	require.Equal(t, strconv.Itoa(os.Getegid()), gid)
+}
+
+func TestLinuxPlatform_Getxattr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.zip")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	value := strings.Repeat("x", xattrSize+1)
+	if err := unix.Setxattr(path, "user.backup.timestamp", []byte(value), 0); err != nil {
+		t.Skip("user extended attributes not supported:", err)
+	}
+
+	platform := NewPlatform()
+
+	got, err := platform.Getxattr(path, "user.backup.timestamp")
+	require.NoError(t, err)
+	require.Equal(t, value, string(got))
+
+	_, err = platform.Getxattr(path, "user.missing")
+	require.ErrorIs(t, err, unix.ENODATA)
+}
+
+func TestMountType(t *testing.T) {
+	table := strings.Join([]string{
+		"22 1 0:21 / / rw,relatime shared:1 - ext4 /dev/sda1 rw",
+		"36 22 0:32 / /mnt/backups rw,relatime shared:2 - nfs4 server:/export rw",
+		"37 22 0:33 / /mnt/my\\040backups rw,relatime - cifs //server/share rw",
+		"38 36 0:34 / /mnt/backups rw,relatime shared:3 master:1 - fuse.sshfs host: rw",
+		"malformed line",
+	}, "\n")
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/", expected: "ext4"},
+		{path: "/mnt/my backups", expected: "cifs"},
+		{path: "/mnt/backups", expected: "fuse.sshfs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			fstype, err := mountType(strings.NewReader(table), tc.path)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, fstype)
+		})
+	}
+
+	_, err := mountType(strings.NewReader(table), "/mnt/backups/daily")
+	require.ErrorIs(t, err, ErrNotMountPoint)
+}
+
+func TestLinuxPlatform_MountType(t *testing.T) {
+	platform := NewPlatform()
+
+	fstype, err := platform.MountType("/")
+	require.NoError(t, err)
+	require.NotEmpty(t, fstype)
+
+	dir := filepath.Join(t.TempDir(), "unmounted")
+	require.NoError(t, os.Mkdir(dir, 0o750))
+
+	_, err = platform.MountType(dir)
+	require.ErrorIs(t, err, ErrNotMountPoint)
+
+	_, err = platform.MountType(filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
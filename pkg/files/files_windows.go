@@ -28,8 +28,20 @@
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// Prefixes used by Windows extended-length paths
+const (
+	extendedPrefix    = `\\?\`
+	extendedUNCPrefix = `\\?\UNC\`
+	uncPrefix         = `\\`
 )
 
 // WindowsPlatform implements Platform for Windows systems
@@ -40,9 +52,24 @@ func NewPlatform() Platform {
 	return &WindowsPlatform{}
 }
 
-// Statfs implements Platform.Statfs for Windows
+// Statfs implements Platform.Statfs for Windows. Only the available space
+// is reported, Windows has no filesystem type magic numbers.
 func (p *WindowsPlatform) Statfs(path string, stat *FileSystemStats) error {
-	return ErrNotImplemented
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	var available, total, free uint64
+
+	err = windows.GetDiskFreeSpaceEx(dir, &available, &total, &free)
+	if err != nil {
+		return err
+	}
+
+	stat.AvailableBytes = available
+
+	return nil
 }
 
 // Mkfifo implements Platform.Mkfifo for Windows
@@ -83,3 +110,150 @@ func (p *WindowsPlatform) RemoveReadOnly(ctx context.Context, path string) error
 	cmd := exec.CommandContext(ctx, "attrib", "-R", filepath.Clean(path))
 	return cmd.Run()
 }
+
+// CheckWriteAccess implements Platform.CheckWriteAccess for Windows. Files
+// with the read-only attribute are rejected, and the file is then opened
+// requesting DELETE access so the security descriptors of both the file and
+// its directory are evaluated by the system.
+func (p *WindowsPlatform) CheckWriteAccess(path string) error {
+	path = filepath.Clean(path)
+
+	name, err := windows.UTF16PtrFromString(ExtendedLengthPath(path))
+	if err != nil {
+		return err
+	}
+
+	attrs, err := windows.GetFileAttributes(name)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, path, err)
+	}
+
+	if attrs&windows.FILE_ATTRIBUTE_READONLY != 0 {
+		return fmt.Errorf("%w: %s: file is read-only", ErrNoWriteAccess, path)
+	}
+
+	handle, err := windows.CreateFile(
+		name,
+		windows.DELETE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OPEN_REPARSE_POINT|windows.FILE_FLAG_BACKUP_SEMANTICS,
+		0,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrNoWriteAccess, path, err)
+	}
+
+	return windows.CloseHandle(handle)
+}
+
+// CheckWriteAccessAt implements Platform.CheckWriteAccessAt for Windows. It
+// is not implemented.
+func (p *WindowsPlatform) CheckWriteAccessAt(dir *os.File, name string) error {
+	return ErrNotImplemented
+}
+
+// RemoveFile implements Platform.RemoveFile for Windows. It is not
+// implemented, so callers fall back to removing the file by name.
+func (p *WindowsPlatform) RemoveFile(dir *os.File, name string, listed os.FileInfo) error {
+	return ErrNotImplemented
+}
+
+// StatAt implements Platform.StatAt for Windows. It is not implemented.
+func (p *WindowsPlatform) StatAt(dir *os.File, name string) (os.FileInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+// LinkCount implements Platform.LinkCount for Windows. The file info
+// returned by os.Lstat carries no link count, so it is not implemented.
+func (p *WindowsPlatform) LinkCount(info os.FileInfo) (uint64, error) {
+	return 0, ErrNotImplemented
+}
+
+// Owner implements Platform.Owner for Windows. Files are owned by security
+// identifiers rather than numeric IDs, so it is not implemented.
+func (p *WindowsPlatform) Owner(info os.FileInfo) (string, string, error) {
+	return "", "", ErrNotImplemented
+}
+
+// Getxattr implements Platform.Getxattr for Windows, which has no extended
+// attributes in the POSIX sense
+func (p *WindowsPlatform) Getxattr(path, name string) ([]byte, error) {
+	return nil, ErrNotImplemented
+}
+
+// MountType implements Platform.MountType for Windows. A directory is a
+// mountpoint when it is the root of its volume, such as a drive, a share or
+// a volume mounted in a folder, and the type is the file system name of the
+// volume, such as NTFS.
+func (p *WindowsPlatform) MountType(path string) (string, error) {
+	resolved, err := resolveDirectory(path)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := windows.UTF16PtrFromString(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	volume := make([]uint16, windows.MAX_PATH+1)
+
+	err = windows.GetVolumePathName(name, &volume[0], uint32(len(volume)))
+	if err != nil {
+		return "", &os.PathError{Op: "GetVolumePathName", Path: resolved, Err: err}
+	}
+
+	root := windows.UTF16ToString(volume)
+	if !strings.EqualFold(strings.TrimSuffix(root, `\`), strings.TrimSuffix(resolved, `\`)) {
+		return "", fmt.Errorf("%s: %w", path, ErrNotMountPoint)
+	}
+
+	fsName := make([]uint16, windows.MAX_PATH+1)
+
+	err = windows.GetVolumeInformation(
+		&volume[0], nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName)),
+	)
+	if err != nil {
+		return "", &os.PathError{Op: "GetVolumeInformation", Path: root, Err: err}
+	}
+
+	return windows.UTF16ToString(fsName), nil
+}
+
+// NormalizePath implements Platform.NormalizePath for Windows. Paths are made
+// absolute, which lets the os package transparently apply the extended-length
+// prefix to deep trees. Paths that already carry the \\?\ prefix are kept.
+func (p *WindowsPlatform) NormalizePath(path string) string {
+	if strings.HasPrefix(path, extendedPrefix) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return abs
+}
+
+// ExtendedLengthPath converts a path to its \\?\ extended-length form so it
+// can exceed MAX_PATH when passed directly to Win32 APIs. UNC paths such as
+// \\server\share\dir become \\?\UNC\server\share\dir.
+func ExtendedLengthPath(path string) string {
+	if strings.HasPrefix(path, extendedPrefix) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	if rest, ok := strings.CutPrefix(abs, uncPrefix); ok {
+		return extendedUNCPrefix + rest
+	}
+
+	return extendedPrefix + abs
+}
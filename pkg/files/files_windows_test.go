@@ -0,0 +1,73 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package files
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendedLengthPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "drive path",
+			path:     `C:\backups\daily`,
+			expected: `\\?\C:\backups\daily`,
+		},
+		{
+			name:     "unc share",
+			path:     `\\server\share\backups`,
+			expected: `\\?\UNC\server\share\backups`,
+		},
+		{
+			name:     "already extended",
+			path:     `\\?\D:\backups`,
+			expected: `\\?\D:\backups`,
+		},
+		{
+			name:     "forward slashes",
+			path:     `C:/backups/daily`,
+			expected: `\\?\C:\backups\daily`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ExtendedLengthPath(tc.path))
+		})
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	plat := NewPlatform()
+
+	require.Equal(t, `\\server\share\backups`, plat.NormalizePath(`//server/share/backups/`))
+	require.Equal(t, `\\?\D:\backups`, plat.NormalizePath(`\\?\D:\backups`))
+}
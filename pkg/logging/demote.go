@@ -0,0 +1,75 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// demotedCore writes info entries at debug level, so they only appear when
+// debug logging is enabled. Warnings and errors are written unchanged.
+type demotedCore struct {
+	zapcore.Core
+}
+
+// demote returns the level an entry is written at
+func demote(level zapcore.Level) zapcore.Level {
+	if level == zapcore.InfoLevel {
+		return zapcore.DebugLevel
+	}
+
+	return level
+}
+
+// Enabled implements zapcore.LevelEnabler
+func (c demotedCore) Enabled(level zapcore.Level) bool {
+	return c.Core.Enabled(demote(level))
+}
+
+// With implements zapcore.Core
+func (c demotedCore) With(fields []zapcore.Field) zapcore.Core {
+	return demotedCore{c.Core.With(fields)}
+}
+
+// Check implements zapcore.Core
+func (c demotedCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	entry.Level = demote(entry.Level)
+	return c.Core.Check(entry, ce)
+}
+
+// Write implements zapcore.Core
+func (c demotedCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Level = demote(entry.Level)
+	return c.Core.Write(entry, fields)
+}
+
+// Demoted returns a logger that writes the info entries of l at debug level,
+// for detail such as one line per file that is only wanted when debugging
+func (l *Logger) Demoted() *Logger {
+	return &Logger{l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return demotedCore{core}
+	}))}
+}
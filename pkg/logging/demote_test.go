@@ -0,0 +1,58 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger_Demoted(t *testing.T) {
+	t.Run("hidden above debug", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		log := (&Logger{zap.New(core)}).Demoted()
+
+		log.Info("deleted file")
+		log.Warn("retrying failed deletion")
+
+		require.Equal(t, 1, logs.Len())
+		require.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+	})
+
+	t.Run("written at debug", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := (&Logger{zap.New(core)}).Demoted().With(zap.String("directory", "/backups"))
+
+		log.Info("deleted file")
+
+		require.Equal(t, 1, logs.Len())
+		require.Equal(t, zapcore.DebugLevel, logs.All()[0].Level)
+		require.Equal(t, "/backups", logs.All()[0].ContextMap()["directory"])
+	})
+}
@@ -0,0 +1,131 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// EventLogSummary is the name of the logger whose info entries are sent to
+// the Windows Event Log along with every warning and error
+const EventLogSummary = "summary"
+
+// eventLogID is the event ID of every entry, in the range the EventCreate
+// message file registered for the source accepts
+const eventLogID = 1
+
+var (
+	// ErrEventLog is returned when the event log source cannot be opened
+	ErrEventLog = errors.New("failed to log to the Windows Event Log")
+	// ErrEventLogUnsupported is returned by New for WithEventLog on systems
+	// other than Windows
+	ErrEventLogUnsupported = errors.New("the Windows Event Log is only supported on Windows")
+)
+
+// eventWriter reports events to an event log source
+type eventWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+}
+
+// eventLogCore is a zapcore.Core that reports warnings, errors and the
+// entries of the EventLogSummary logger as events, with their fields as
+// "key: value" lines below the message
+type eventLogCore struct {
+	zapcore.LevelEnabler
+
+	writer eventWriter
+	fields []zapcore.Field
+}
+
+// With returns a core that adds fields to every entry
+func (c *eventLogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &eventLogCore{
+		LevelEnabler: c.LevelEnabler,
+		writer:       c.writer,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+// Check adds the core to the checked entry for warnings, errors and the
+// summary logger's entries at an enabled level
+func (c *eventLogCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) &&
+		(entry.Level >= zapcore.WarnLevel || entry.LoggerName == EventLogSummary) {
+		return ce.AddCore(entry, c)
+	}
+
+	return ce
+}
+
+// Write reports an entry as an event of the type matching its level
+func (c *eventLogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+
+	for _, field := range append(c.fields[:len(c.fields):len(c.fields)], fields...) {
+		field.AddTo(enc)
+	}
+
+	var msg strings.Builder
+
+	msg.WriteString(entry.Message)
+
+	if len(enc.Fields) > 0 {
+		msg.WriteString("\n")
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(enc.Fields)) {
+		fmt.Fprintf(&msg, "\n%s: %v", key, enc.Fields[key])
+	}
+
+	var err error
+
+	switch {
+	case entry.Level >= zapcore.ErrorLevel:
+		err = c.writer.Error(eventLogID, msg.String())
+	case entry.Level == zapcore.WarnLevel:
+		err = c.writer.Warning(eventLogID, msg.String())
+	default:
+		err = c.writer.Info(eventLogID, msg.String())
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEventLog, err)
+	}
+
+	return nil
+}
+
+// Sync is a no-op, every entry is reported as soon as it is written
+func (c *eventLogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recordedEvent is an event reported to a fakeEventWriter
+type recordedEvent struct {
+	kind string
+	msg  string
+}
+
+// fakeEventWriter records the events reported to it
+type fakeEventWriter struct {
+	events []recordedEvent
+}
+
+func (w *fakeEventWriter) Info(_ uint32, msg string) error {
+	w.events = append(w.events, recordedEvent{"info", msg})
+	return nil
+}
+
+func (w *fakeEventWriter) Warning(_ uint32, msg string) error {
+	w.events = append(w.events, recordedEvent{"warning", msg})
+	return nil
+}
+
+func (w *fakeEventWriter) Error(_ uint32, msg string) error {
+	w.events = append(w.events, recordedEvent{"error", msg})
+	return nil
+}
+
+func TestEventLog(t *testing.T) {
+	writer := &fakeEventWriter{}
+	logger := zap.New(&eventLogCore{LevelEnabler: zapcore.InfoLevel, writer: writer}).
+		With(zap.String("directory", "/backups"))
+
+	logger.Info("deleting file")
+	logger.Debug("filtered by level")
+	logger.Warn("no backup files found")
+	logger.Error("failed to delete file", zap.String("file", "a.tar.gz"))
+	logger.Named(EventLogSummary).Info("run summary", zap.Int("deleted", 3))
+	logger.Named(EventLogSummary).Debug("filtered by level")
+
+	require.Equal(t, []recordedEvent{
+		{"warning", "no backup files found\n\ndirectory: /backups"},
+		{"error", "failed to delete file\n\ndirectory: /backups\nfile: a.tar.gz"},
+		{"info", "run summary\n\ndeleted: 3\ndirectory: /backups"},
+	}, writer.events)
+}
+
+func TestEventLogUnsupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the Windows Event Log is supported on Windows")
+	}
+
+	_, err := New("info", WithEventLog("apply-retention-policy"))
+	require.ErrorIs(t, err, ErrEventLogUnsupported)
+}
@@ -0,0 +1,184 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// JournalSocket is the socket journald receives native protocol
+	// messages on
+	JournalSocket = "/run/systemd/journal/socket"
+	// journalIdentifier is the SYSLOG_IDENTIFIER of every journal entry
+	journalIdentifier = "apply-retention-policy"
+)
+
+// ErrJournal is returned when the journal socket cannot be used
+var ErrJournal = errors.New("failed to log to journal")
+
+// journalCore is a zapcore.Core that sends every entry to journald using its
+// native protocol, keeping structured fields as separate journal fields and
+// mapping log levels onto syslog priorities
+type journalCore struct {
+	zapcore.LevelEnabler
+
+	conn   net.Conn
+	fields []zapcore.Field
+}
+
+// newJournalCore connects to the journal socket at path
+func newJournalCore(path string, level zapcore.LevelEnabler) (*journalCore, error) {
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrJournal, err)
+	}
+
+	return &journalCore{LevelEnabler: level, conn: conn}, nil
+}
+
+// With returns a core that adds fields to every entry
+func (c *journalCore) With(fields []zapcore.Field) zapcore.Core {
+	return &journalCore{
+		LevelEnabler: c.LevelEnabler,
+		conn:         c.conn,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+// Check adds the core to the checked entry if its level is enabled
+func (c *journalCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+
+	return ce
+}
+
+// Write sends an entry to the journal as a single datagram
+func (c *journalCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+
+	for _, field := range append(c.fields[:len(c.fields):len(c.fields)], fields...) {
+		field.AddTo(enc)
+	}
+
+	var msg bytes.Buffer
+
+	writeJournalField(&msg, "MESSAGE", entry.Message)
+	writeJournalField(&msg, "PRIORITY", strconv.Itoa(journalPriority(entry.Level)))
+	writeJournalField(&msg, "SYSLOG_IDENTIFIER", journalIdentifier)
+
+	if entry.Caller.Defined {
+		writeJournalField(&msg, "CODE_FILE", filepath.Base(entry.Caller.File))
+		writeJournalField(&msg, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+	}
+
+	if entry.LoggerName != "" {
+		writeJournalField(&msg, "LOGGER", entry.LoggerName)
+	}
+
+	for key, value := range enc.Fields {
+		writeJournalField(&msg, journalFieldName(key), fmt.Sprint(value))
+	}
+
+	if _, err := c.conn.Write(msg.Bytes()); err != nil {
+		return fmt.Errorf("%w: %w", ErrJournal, err)
+	}
+
+	return nil
+}
+
+// Sync is a no-op, every entry is sent as soon as it is written
+func (c *journalCore) Sync() error {
+	return nil
+}
+
+// journalPriority maps a log level onto a syslog priority
+func journalPriority(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// journalFieldName converts a zap field key into a valid journal field
+// name: upper case letters, digits and underscores, not starting with an
+// underscore, which is reserved for trusted fields
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	name = strings.TrimLeft(name, "_0123456789")
+	if name == "" {
+		return "FIELD"
+	}
+
+	return name
+}
+
+// writeJournalField appends a field to a native protocol message. Values
+// containing a newline are length-prefixed as the protocol requires.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+
+		return
+	}
+
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,101 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJournal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on Windows")
+	}
+
+	// Socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "journal")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "socket")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	logger, err := New("info", WithJournalSocket(socket))
+	require.NoError(t, err)
+
+	receive := func() string {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+
+		return string(buf[:n])
+	}
+
+	logger.With(zap.String("directory", "/backups")).
+		Warn("deleting file", zap.Int("file.count", 3))
+
+	msg := receive()
+	require.Contains(t, msg, "MESSAGE=deleting file\n")
+	require.Contains(t, msg, "PRIORITY=4\n")
+	require.Contains(t, msg, "SYSLOG_IDENTIFIER=apply-retention-policy\n")
+	require.Contains(t, msg, "DIRECTORY=/backups\n")
+	require.Contains(t, msg, "FILE_COUNT=3\n")
+	require.Contains(t, msg, "CODE_FILE=journal_test.go\n")
+
+	logger.Debug("filtered by level")
+	logger.Error("two\nlines")
+
+	var want bytes.Buffer
+
+	want.WriteString("MESSAGE\n")
+	require.NoError(t, binary.Write(&want, binary.LittleEndian, uint64(len("two\nlines"))))
+	want.WriteString("two\nlines\n")
+
+	msg = receive()
+	require.True(t, strings.HasPrefix(msg, want.String()), "got %q", msg)
+	require.Contains(t, msg, "PRIORITY=3\n")
+}
+
+func TestJournalFieldName(t *testing.T) {
+	require.Equal(t, "RECLAIMED_BYTES", journalFieldName("reclaimed_bytes"))
+	require.Equal(t, "DRY_RUN", journalFieldName("dry-run"))
+	require.Equal(t, "PRIVATE", journalFieldName("_private"))
+	require.Equal(t, "FIELD", journalFieldName("42"))
+}
@@ -27,7 +27,10 @@
 package logging
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -38,23 +41,178 @@ type Logger struct {
 	*zap.Logger
 }
 
-// New creates a new logger with the specified log level
-func New(level string) (*Logger, error) {
+// Log formats accepted by WithFormat
+const (
+	// FormatJSON writes one JSON object per entry
+	FormatJSON = "json"
+	// FormatConsole writes human-readable, tab-separated entries
+	FormatConsole = "console"
+)
+
+// ErrUnknownFormat is returned for a log format that is not supported
+var ErrUnknownFormat = errors.New("unknown log format")
+
+// Option configures a logger created by New
+type Option func(*options)
+
+// options holds the settings applied by Options
+type options struct {
+	journal       string
+	format        string
+	file          string
+	rotation      Rotation
+	syslogNetwork string
+	syslogAddress string
+	eventSource   string
+}
+
+// WithJournal sends log entries to the systemd journal's native protocol,
+// with their level as the syslog priority, instead of JSON on stderr
+func WithJournal() Option {
+	return WithJournalSocket(JournalSocket)
+}
+
+// WithJournalSocket is WithJournal with the path of the journal socket
+func WithJournalSocket(path string) Option {
+	return func(o *options) {
+		o.journal = path
+	}
+}
+
+// WithFormat selects FormatJSON, the default, or FormatConsole
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithFile writes log entries to a file instead of stderr, rotating it as
+// configured
+func WithFile(path string, rotation Rotation) Option {
+	return func(o *options) {
+		o.file = path
+		o.rotation = rotation
+	}
+}
+
+// WithSyslog sends log entries to a syslog server as RFC 5424 messages
+// instead of to stderr. network is one of those returned by ParseSyslogURL.
+func WithSyslog(network, address string) Option {
+	return func(o *options) {
+		o.syslogNetwork = network
+		o.syslogAddress = address
+	}
+}
+
+// WithEventLog also reports warnings, errors and the entries of the
+// EventLogSummary logger to the Windows Event Log under source
+func WithEventLog(source string) Option {
+	return func(o *options) {
+		o.eventSource = source
+	}
+}
+
+// New creates a new logger with the specified log level. By default entries
+// are written to stderr as JSON, like zap's production logger.
+func New(level string, opts ...Option) (*Logger, error) {
 	var zapLevel zapcore.Level
 	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
 		zapLevel = zapcore.InfoLevel
 	}
 
-	config := zap.NewProductionConfig()
-	config.Level = zap.NewAtomicLevelAt(zapLevel)
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.journal != "" {
+		journal, err := newJournalCore(o.journal, zap.NewAtomicLevelAt(zapLevel))
+		if err != nil {
+			return nil, err
+		}
+
+		core, err := teeEventLog(journal, &o, zapLevel)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Logger{zap.New(core, zap.AddCaller())}, nil
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+
+	switch o.format {
+	case FormatJSON, "":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case FormatConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, o.format)
+	}
+
+	inner, err := newCore(&o, encoder, zap.NewAtomicLevelAt(zapLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	inner, err = teeEventLog(inner, &o, zapLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sample like zap's production logger: after the first 100 entries with
+	// the same message in a second, only every 100th is logged
+	core := zapcore.NewSamplerWithOptions(inner, time.Second, 100, 100)
+
+	return &Logger{zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)}, nil
+}
+
+// newCore writes encoded entries to the syslog server, the log file or
+// stderr, in that order of preference
+func newCore(
+	o *options, encoder zapcore.Encoder, level zapcore.LevelEnabler,
+) (zapcore.Core, error) {
+	if o.syslogNetwork != "" {
+		return newSyslogCore(o.syslogNetwork, o.syslogAddress, encoder, level)
+	}
+
+	var sink zapcore.WriteSyncer = zapcore.Lock(os.Stderr)
+
+	if o.file != "" {
+		file, err := openRotatingFile(o.file, o.rotation)
+		if err != nil {
+			return nil, err
+		}
+
+		sink = file
+	}
+
+	return zapcore.NewCore(encoder, sink, level), nil
+}
+
+// teeEventLog adds the Windows Event Log to core when WithEventLog is set
+func teeEventLog(core zapcore.Core, o *options, level zapcore.Level) (zapcore.Core, error) {
+	if o.eventSource == "" {
+		return core, nil
+	}
 
-	logger, err := config.Build()
+	writer, err := openEventLog(o.eventSource)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Logger{logger}, nil
+	return zapcore.NewTee(core, &eventLogCore{
+		LevelEnabler: zap.NewAtomicLevelAt(level),
+		writer:       writer,
+	}), nil
 }
 
 // NewDefault creates a new logger with default settings (INFO level)
@@ -0,0 +1,203 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp added to the name of a rotated log file
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// ErrLogFile is returned when the log file cannot be opened or rotated
+var ErrLogFile = errors.New("failed to write log file")
+
+// Rotation configures when a log file is rotated and how many rotated files
+// are kept. Zero values disable the corresponding limit.
+type Rotation struct {
+	// MaxSize is the size in bytes at which the file is rotated
+	MaxSize int64
+	// MaxAge is how long rotated files are kept
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept
+	MaxBackups int
+}
+
+// rotatingFile is a log file that is renamed to <name>-<timestamp><ext> and
+// replaced by a new file once it would grow beyond its maximum size
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+	now      func() time.Time
+}
+
+// openRotatingFile opens path for appending, creating it and its directory
+// if needed
+func openRotatingFile(path string, rotation Rotation) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, rotation: rotation, now: time.Now}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if p would take it beyond
+// the maximum size. A single write is never split across files.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.rotation.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.rotation.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Sync flushes the file to disk
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Sync()
+}
+
+// open opens the log file and records its current size
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o750); err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	f.file, f.size = file, info.Size()
+
+	return nil
+}
+
+// rotate renames the current file with a timestamp, opens a new one and
+// removes rotated files beyond the configured limits
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + f.now().UTC().Format(backupTimeFormat) + ext
+
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	return f.prune()
+}
+
+// prune removes rotated files that exceed MaxBackups or are older than
+// MaxAge. Rotated files are recognised by the timestamp in their name.
+func (f *rotatingFile) prune() error {
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	dir := filepath.Dir(f.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	type backup struct {
+		path    string
+		rotated time.Time
+	}
+
+	var backups []backup
+
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+
+		rotated, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backup{filepath.Join(dir, entry.Name()), rotated})
+	}
+
+	// Newest first, so everything past MaxBackups is the oldest
+	slices.SortFunc(backups, func(a, b backup) int {
+		return b.rotated.Compare(a.rotated)
+	})
+
+	var errs []error
+
+	for i, b := range backups {
+		tooMany := f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups
+		tooOld := f.rotation.MaxAge > 0 && f.now().Sub(b.rotated) > f.rotation.MaxAge
+
+		if tooMany || tooOld {
+			if err := os.Remove(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	return nil
+}
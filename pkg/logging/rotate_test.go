@@ -0,0 +1,108 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "arp.log")
+
+	f, err := openRotatingFile(path, Rotation{MaxSize: 10, MaxBackups: 2})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		now = now.Add(time.Minute)
+
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, f.file.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "fourth\n", string(data))
+
+	// The file holding "first" was rotated away beyond MaxBackups
+	backups, err := filepath.Glob(filepath.Join(dir, "arp-*.log"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "arp-2024-03-15T12-03-00.000.log"),
+		filepath.Join(dir, "arp-2024-03-15T12-04-00.000.log"),
+	}, backups)
+
+	data, err = os.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, "second\n", string(data))
+
+	t.Run("max age", func(t *testing.T) {
+		f, err := openRotatingFile(path, Rotation{MaxSize: 1, MaxAge: 90 * time.Second})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = f.file.Close() })
+
+		now = now.Add(time.Minute)
+		f.now = func() time.Time { return now }
+
+		_, err = f.Write([]byte("fifth\n"))
+		require.NoError(t, err)
+
+		backups, err := filepath.Glob(filepath.Join(dir, "arp-*.log"))
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join(dir, "arp-2024-03-15T12-04-00.000.log"),
+			filepath.Join(dir, "arp-2024-03-15T12-05-00.000.log"),
+		}, backups)
+	})
+}
+
+func TestNew_FormatAndFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "arp.log")
+
+	logger, err := New("info", WithFormat(FormatConsole), WithFile(path, Rotation{}))
+	require.NoError(t, err)
+
+	logger.Info("pruned directory")
+	logger.SyncQuietly()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(data), "\tINFO\t"), "got %q", data)
+	require.Contains(t, string(data), "pruned directory")
+
+	_, err = New("info", WithFormat("xml"))
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,235 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// SyslogPort is the port used for syslog URLs without one
+	SyslogPort = "514"
+	// syslogFacility is the daemon facility every message is sent with
+	syslogFacility = 3
+	// syslogTimeFormat is the RFC 5424 TIMESTAMP with microseconds
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var (
+	// ErrSyslog is returned when the syslog server cannot be reached
+	ErrSyslog = errors.New("failed to log to syslog")
+	// ErrSyslogURL is returned for a log output that is not a syslog URL
+	ErrSyslogURL = errors.New("invalid syslog URL")
+)
+
+// ParseSyslogURL returns the network and address of a syslog URL:
+// syslog://host:port or syslog+udp://host:port for UDP, syslog+tcp://host:port
+// for TCP and syslog+unix:///path for a unix socket. The port defaults to
+// SyslogPort.
+func ParseSyslogURL(raw string) (network, address string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrSyslogURL, err)
+	}
+
+	switch u.Scheme {
+	case "syslog", "syslog+udp":
+		network = "udp"
+	case "syslog+tcp":
+		network = "tcp"
+	case "syslog+unix":
+		if u.Host != "" || u.Path == "" {
+			return "", "", fmt.Errorf("%w: %q needs a socket path", ErrSyslogURL, raw)
+		}
+
+		return "unix", u.Path, nil
+	default:
+		return "", "", fmt.Errorf("%w: unknown scheme in %q", ErrSyslogURL, raw)
+	}
+
+	if u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return "", "", fmt.Errorf("%w: %q needs a host and no path", ErrSyslogURL, raw)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = SyslogPort
+	}
+
+	return network, net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// syslogConn is a connection to a syslog server shared by every core
+// derived from the same logger. Stream connections are redialled once when
+// a write fails, so a restarted server does not lose the rest of a run.
+type syslogConn struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	conn     net.Conn
+	stream   bool
+	hostname string
+}
+
+// dialSyslog connects to a syslog server. A unix socket is tried as a
+// datagram socket first, like /dev/log, and as a stream socket otherwise.
+func dialSyslog(network, address string) (*syslogConn, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	c := &syslogConn{network: network, address: address, hostname: hostname}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// dial (re)connects to the server
+func (c *syslogConn) dial() error {
+	networks := []string{c.network}
+	if c.network == "unix" {
+		networks = []string{"unixgram", "unix"}
+	}
+
+	var err error
+
+	for _, network := range networks {
+		var conn net.Conn
+
+		conn, err = net.DialTimeout(network, c.address, 5*time.Second)
+		if err == nil {
+			c.conn = conn
+			c.stream = network == "tcp" || network == "unix"
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %w", ErrSyslog, err)
+}
+
+// send writes one message, framed with its octet count on stream
+// connections as RFC 6587 describes
+func (c *syslogConn) send(msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	_, err := c.conn.Write(msg)
+	if err != nil && c.stream {
+		_ = c.conn.Close()
+
+		if err = c.dial(); err == nil {
+			_, err = c.conn.Write(msg)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSyslog, err)
+	}
+
+	return nil
+}
+
+// syslogCore is a zapcore.Core that sends every entry to a syslog server
+// as an RFC 5424 message, with the encoded entry as the MSG and its level
+// mapped onto a syslog severity
+type syslogCore struct {
+	zapcore.LevelEnabler
+
+	enc  zapcore.Encoder
+	conn *syslogConn
+}
+
+// newSyslogCore connects to the syslog server at address
+func newSyslogCore(
+	network, address string, enc zapcore.Encoder, level zapcore.LevelEnabler,
+) (*syslogCore, error) {
+	conn, err := dialSyslog(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogCore{LevelEnabler: level, enc: enc, conn: conn}, nil
+}
+
+// With returns a core that adds fields to every entry
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	return &syslogCore{LevelEnabler: c.LevelEnabler, enc: enc, conn: c.conn}
+}
+
+// Check adds the core to the checked entry if its level is enabled
+func (c *syslogCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+
+	return ce
+}
+
+// Write sends an entry to the server as a single message
+func (c *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoded, err := c.enc.EncodeEntry(entry, fields)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSyslog, err)
+	}
+	defer encoded.Free()
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "<%d>1 %s %s %s %d - - ",
+		syslogFacility*8+journalPriority(entry.Level),
+		entry.Time.Format(syslogTimeFormat),
+		c.conn.hostname, journalIdentifier, os.Getpid())
+	msg.Write(bytes.TrimRight(encoded.Bytes(), "\n"))
+
+	return c.conn.send(msg.Bytes())
+}
+
+// Sync is a no-op, every entry is sent as soon as it is written
+func (c *syslogCore) Sync() error {
+	return nil
+}
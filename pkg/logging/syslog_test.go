@@ -0,0 +1,167 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logging
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// syslogPriority returns the PRI of an RFC 5424 message, failing the test
+// if its header is malformed
+func syslogPriority(t *testing.T, msg string) string {
+	t.Helper()
+
+	header := regexp.MustCompile(`^<(\d+)>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ ` +
+		`\S+ apply-retention-policy \d+ - - `)
+
+	match := header.FindStringSubmatch(msg)
+	require.NotNil(t, match, "got %q", msg)
+
+	return match[1]
+}
+
+func TestParseSyslogURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		network string
+		address string
+	}{
+		{"syslog://logs.example.com", "udp", "logs.example.com:514"},
+		{"syslog+udp://logs.example.com:1514", "udp", "logs.example.com:1514"},
+		{"syslog+tcp://[::1]:601", "tcp", "[::1]:601"},
+		{"syslog+unix:///dev/log", "unix", "/dev/log"},
+	}
+
+	for _, tt := range tests {
+		network, address, err := ParseSyslogURL(tt.raw)
+		require.NoError(t, err, tt.raw)
+		require.Equal(t, tt.network, network, tt.raw)
+		require.Equal(t, tt.address, address, tt.raw)
+	}
+
+	for _, raw := range []string{
+		"logs.example.com:514",
+		"http://logs.example.com",
+		"syslog://",
+		"syslog://logs.example.com/path",
+		"syslog+unix://",
+	} {
+		_, _, err := ParseSyslogURL(raw)
+		require.ErrorIs(t, err, ErrSyslogURL, raw)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	logger, err := New("info", WithSyslog("udp", conn.LocalAddr().String()))
+	require.NoError(t, err)
+
+	receive := func() string {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+		buf := make([]byte, 4096)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+
+		return string(buf[:n])
+	}
+
+	logger.With(zap.String("directory", "/backups")).
+		Warn("deleting file", zap.Int("file.count", 3))
+
+	msg := receive()
+	require.Equal(t, "28", syslogPriority(t, msg))
+	require.Contains(t, msg, `"msg":"deleting file"`)
+	require.Contains(t, msg, `"directory":"/backups"`)
+	require.Contains(t, msg, `"file.count":3`)
+	require.False(t, strings.HasSuffix(msg, "\n"))
+
+	logger.Debug("filtered by level")
+	logger.Error("failed")
+
+	msg = receive()
+	require.Equal(t, "27", syslogPriority(t, msg))
+	require.Contains(t, msg, `"msg":"failed"`)
+}
+
+func TestSyslogTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	logger, err := New("info",
+		WithSyslog("tcp", listener.Addr().String()), WithFormat(FormatConsole))
+	require.NoError(t, err)
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	logger.Info("first")
+	logger.Info("second")
+
+	reader := bufio.NewReader(conn)
+
+	for _, want := range []string{"first", "second"} {
+		length, err := reader.ReadString(' ')
+		require.NoError(t, err)
+
+		n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		require.NoError(t, err)
+
+		buf := make([]byte, n)
+		_, err = io.ReadFull(reader, buf)
+		require.NoError(t, err)
+
+		require.Equal(t, "30", syslogPriority(t, string(buf)))
+		require.Contains(t, string(buf), "INFO\t")
+		require.True(t, strings.HasSuffix(string(buf), want), "got %q", buf)
+	}
+}
+
+func TestSyslogUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	_, err = New("info", WithSyslog("tcp", address))
+	require.ErrorIs(t, err, ErrSyslog)
+}
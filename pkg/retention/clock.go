@@ -0,0 +1,55 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention
+
+import "time"
+
+// Clock tells the current time. A Policy reads it for Age, so tests can
+// substitute a fixed clock such as the one in the retentiontest package.
+// Deciding which backups to keep never depends on the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock of a Policy without one
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time from the policy's clock
+func (p Policy) now() time.Time {
+	if p.Clock == nil {
+		return systemClock{}.Now()
+	}
+
+	return p.Clock.Now()
+}
+
+// Age returns how long ago f was taken, by the policy's clock
+func (p Policy) Age(f FileInfo) time.Duration {
+	return p.now().Sub(f.Timestamp)
+}
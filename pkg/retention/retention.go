@@ -0,0 +1,335 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package retention exposes the retention engine as a stable library API so
+// other Go programs can decide which backups to keep without importing the
+// internal packages of this module.
+//
+// A Policy is applied to a list of FileInfo values and produces a Result
+// with a Decision for every file. Very large sets of backups can be streamed
+// through ApplyStream instead. Nothing is ever deleted by this package;
+// acting on the result is left to the caller. Age reads the current time
+// from the policy's Clock.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/config"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/file"
+	"github.com/TotallyNotRobots/apply-retention-policy/internal/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/logging"
+)
+
+// FileInfo describes a single backup. Tag groups backups that are evaluated
+// independently, Pinned backups are never deleted. DependsOn is the Path of
+// another backup this one needs to be restored, such as the base of an
+// incremental backup; a kept backup always keeps its dependencies.
+type FileInfo struct {
+	Path      string
+	Timestamp time.Time
+	Size      int64
+	Tag       string
+	Pinned    bool
+	DependsOn string
+}
+
+// Retention is the number of backups to keep for each time period
+type Retention struct {
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+}
+
+// config converts the retention into the counts the engine expects
+func (r Retention) config() config.RetentionPolicy {
+	return config.RetentionPolicy{
+		Hourly:  r.Hourly,
+		Daily:   r.Daily,
+		Weekly:  r.Weekly,
+		Monthly: r.Monthly,
+		Yearly:  r.Yearly,
+	}
+}
+
+// Policy configures the retention engine. TagRetention overrides Retention
+// for backups with a matching tag, compared case-insensitively. KeepCount,
+// when set, replaces the tiers and keeps only the newest KeepCount backups
+// of each tag. DayBoundaryOffset shifts timestamps before they are grouped
+// into days and coarser periods. Dedupe deletes backups byte-identical to a
+// newer backup of the same tag and day, reading them from their Path. At
+// least RequireMinimum backups always survive. Logger is an optional
+// log/slog logger, and nothing is logged without one. Clock is where Age
+// reads the current time from, and defaults to the system clock.
+type Policy struct {
+	Retention         Retention
+	TagRetention      map[string]Retention
+	KeepCount         int
+	DayBoundaryOffset time.Duration
+	Dedupe            bool
+	RequireMinimum    int
+	Logger            *slog.Logger
+	Clock             Clock
+}
+
+// Reason explains why the policy kept or deleted a file
+type Reason string
+
+// Reasons a file is kept or deleted
+const (
+	// ReasonHourly means the file fills an hourly slot
+	ReasonHourly Reason = Reason(retention.ReasonHourly)
+	// ReasonDaily means the file fills a daily slot
+	ReasonDaily Reason = Reason(retention.ReasonDaily)
+	// ReasonWeekly means the file fills a weekly slot
+	ReasonWeekly Reason = Reason(retention.ReasonWeekly)
+	// ReasonMonthly means the file fills a monthly slot
+	ReasonMonthly Reason = Reason(retention.ReasonMonthly)
+	// ReasonYearly means the file fills a yearly slot
+	ReasonYearly Reason = Reason(retention.ReasonYearly)
+	// ReasonKeepCount means the file is one of the newest KeepCount files
+	ReasonKeepCount Reason = Reason(retention.ReasonKeepCount)
+	// ReasonDuplicate means the file is identical to a newer file of the
+	// same tag and day
+	ReasonDuplicate Reason = Reason(retention.ReasonDuplicate)
+	// ReasonPinned means the file is pinned and is never deleted
+	ReasonPinned Reason = Reason(retention.ReasonPinned)
+	// ReasonRequireMinimum means the file is kept to satisfy RequireMinimum
+	ReasonRequireMinimum Reason = Reason(retention.ReasonRequireMinimum)
+	// ReasonSuperseded means a newer file was kept for the same period
+	ReasonSuperseded Reason = Reason(retention.ReasonSuperseded)
+	// ReasonExpired means the file is older than every retention slot
+	ReasonExpired Reason = Reason(retention.ReasonExpired)
+	// ReasonDependency means a kept backup depends on the file
+	ReasonDependency Reason = Reason(retention.ReasonDependency)
+	// ReasonNew means the file was missing from the first listing of
+	// ApplyStream and is left for the next run
+	ReasonNew Reason = Reason(retention.ReasonNew)
+)
+
+// Decision records whether the policy deletes a file and why. Slot is the
+// 1-based position of the file within its tier, counting from the newest
+// period, and is zero for decisions not made by a tier. Tier is the tier
+// whose period the file fell into, even if a newer file superseded it or a
+// later rule overrode the tier's decision, and ReasonExpired for files
+// outside every tier.
+type Decision struct {
+	File   FileInfo
+	Delete bool
+	Reason Reason
+	Slot   int
+	Tier   Reason
+}
+
+// Result is the outcome of applying a policy, with a decision for every
+// input file, oldest first
+type Result struct {
+	Decisions []Decision
+}
+
+// Deleted returns the files the policy deletes, oldest first
+func (r *Result) Deleted() []FileInfo {
+	return r.files(true)
+}
+
+// Kept returns the files the policy keeps, oldest first
+func (r *Result) Kept() []FileInfo {
+	return r.files(false)
+}
+
+// files returns the files whose decision matches deleted
+func (r *Result) files(deleted bool) []FileInfo {
+	var files []FileInfo
+
+	for _, d := range r.Decisions {
+		if d.Delete == deleted {
+			files = append(files, d.File)
+		}
+	}
+
+	return files
+}
+
+// Validate checks that every count of the policy is non-negative, that
+// KeepCount is not combined with retention tiers and that DayBoundaryOffset
+// is shorter than a day
+func (p Policy) Validate() error {
+	if err := p.Retention.config().Validate(); err != nil {
+		return err
+	}
+
+	for _, tag := range slices.Sorted(maps.Keys(p.TagRetention)) {
+		if err := p.TagRetention[tag].config().Validate(); err != nil {
+			return fmt.Errorf("tag %q: %w", tag, err)
+		}
+	}
+
+	if p.KeepCount < 0 {
+		return errors.New("keep count must be non-negative")
+	}
+
+	if p.KeepCount > 0 && (p.Retention != Retention{} || len(p.TagRetention) > 0) {
+		return errors.New("keep count cannot be combined with retention tiers")
+	}
+
+	if p.DayBoundaryOffset <= -24*time.Hour || p.DayBoundaryOffset >= 24*time.Hour {
+		return errors.New("day boundary offset must be within a day")
+	}
+
+	if p.RequireMinimum < 0 {
+		return errors.New("require minimum must be non-negative")
+	}
+
+	return nil
+}
+
+// Apply decides for every file whether the policy keeps or deletes it
+func (p Policy) Apply(files []FileInfo) (*Result, error) {
+	engine, err := p.engine()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]file.Info, len(files))
+	for i, f := range files {
+		infos[i] = info(f)
+	}
+
+	internal, err := engine.Apply(infos)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Decisions: make([]Decision, len(internal.Decisions))}
+	for i, d := range internal.Decisions {
+		result.Decisions[i] = decision(d)
+	}
+
+	return result, nil
+}
+
+// ApplyStream reaches the same decisions as Apply for the files listed by
+// walk, without holding them all in memory, and calls emit with each
+// decision. walk is called twice and must list the same files both times, in
+// any order; a file missing from the first listing is kept with ReasonNew.
+func (p Policy) ApplyStream(
+	ctx context.Context,
+	walk func(ctx context.Context, fn func(FileInfo) error) error,
+	emit func(Decision) error,
+) error {
+	engine, err := p.engine()
+	if err != nil {
+		return err
+	}
+
+	return engine.ApplyStream(ctx, func(ctx context.Context, fn func(file.Info) error) error {
+		return walk(ctx, func(f FileInfo) error {
+			return fn(info(f))
+		})
+	}, func(d retention.Decision) error {
+		return emit(decision(d))
+	})
+}
+
+// engine validates the policy and creates the internal engine for it
+func (p Policy) engine() (*retention.Policy, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	logger := &logging.Logger{Logger: zap.NewNop()}
+	if p.Logger != nil {
+		logger = logging.FromSlog(p.Logger)
+	}
+
+	cfg := p.config()
+
+	return retention.NewPolicy(logger, &cfg), nil
+}
+
+// info converts a file to the file info of the internal engine
+func info(f FileInfo) file.Info {
+	return file.Info{
+		Path:      f.Path,
+		Timestamp: f.Timestamp,
+		Size:      f.Size,
+		Tag:       f.Tag,
+		Pinned:    f.Pinned,
+		DependsOn: f.DependsOn,
+	}
+}
+
+// fileInfo converts a file info of the internal engine
+func fileInfo(f file.Info) FileInfo {
+	return FileInfo{
+		Path:      f.Path,
+		Timestamp: f.Timestamp,
+		Size:      f.Size,
+		Tag:       f.Tag,
+		Pinned:    f.Pinned,
+		DependsOn: f.DependsOn,
+	}
+}
+
+// decision converts a decision of the internal engine
+func decision(d retention.Decision) Decision {
+	return Decision{
+		File:   fileInfo(d.File),
+		Delete: d.Delete,
+		Reason: Reason(d.Reason),
+		Slot:   d.Slot,
+		Tier:   Reason(d.Tier),
+	}
+}
+
+// config converts the policy into the configuration the engine expects
+func (p Policy) config() config.Config {
+	cfg := config.Config{
+		Retention:         p.Retention.config(),
+		KeepCount:         p.KeepCount,
+		DayBoundaryOffset: p.DayBoundaryOffset,
+		Dedupe:            p.Dedupe,
+		RequireMinimum:    p.RequireMinimum,
+	}
+
+	if len(p.TagRetention) > 0 {
+		cfg.TagRetention = make(config.TagPolicies, len(p.TagRetention))
+		for tag, r := range p.TagRetention {
+			cfg.TagRetention[strings.ToLower(tag)] = r.config()
+		}
+	}
+
+	return cfg
+}
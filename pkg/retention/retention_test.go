@@ -0,0 +1,160 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retention_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/retention"
+)
+
+func TestPolicy_Apply(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []retention.FileInfo{
+		{Path: "nightly-3", Timestamp: now, Tag: "nightly"},
+		{Path: "nightly-2", Timestamp: now.AddDate(0, 0, -1), Tag: "nightly"},
+		{Path: "nightly-1", Timestamp: now.AddDate(0, 0, -2), Tag: "nightly", Pinned: true},
+		{Path: "release-2", Timestamp: now.AddDate(0, -1, 0), Tag: "release"},
+		{Path: "release-1", Timestamp: now.AddDate(0, -2, 0), Tag: "release"},
+	}
+
+	policy := retention.Policy{
+		Retention: retention.Retention{Daily: 1},
+		TagRetention: map[string]retention.Retention{
+			"Release": {Monthly: 12},
+		},
+	}
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+	require.Len(t, result.Decisions, len(files))
+
+	require.Equal(t, []retention.FileInfo{files[1]}, result.Deleted())
+	require.Equal(t, []retention.FileInfo{files[4], files[3], files[2], files[0]}, result.Kept())
+
+	reasons := map[string]retention.Reason{}
+	for _, d := range result.Decisions {
+		reasons[d.File.Path] = d.Reason
+	}
+
+	require.Equal(t, map[string]retention.Reason{
+		"nightly-3": retention.ReasonDaily,
+		"nightly-2": retention.ReasonExpired,
+		"nightly-1": retention.ReasonPinned,
+		"release-2": retention.ReasonMonthly,
+		"release-1": retention.ReasonMonthly,
+	}, reasons)
+}
+
+func TestPolicy_Logger(t *testing.T) {
+	var out bytes.Buffer
+
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	policy := retention.Policy{
+		Retention: retention.Retention{Daily: 1},
+		Logger: slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})),
+	}
+
+	_, err := policy.Apply([]retention.FileInfo{
+		{Path: "backup-2", Timestamp: now},
+		{Path: "backup-1", Timestamp: now.AddDate(0, 0, -1)},
+	})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), `msg="retention policy summary"`)
+	require.Contains(t, out.String(), "files_to_delete=1")
+}
+
+func TestPolicy_ApplyStream(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	files := []retention.FileInfo{
+		{Path: "backup-3", Timestamp: now},
+		{Path: "backup-1", Timestamp: now.AddDate(0, 0, -2)},
+		{Path: "backup-2", Timestamp: now.AddDate(0, 0, -1)},
+	}
+
+	policy := retention.Policy{Retention: retention.Retention{Daily: 2}}
+
+	walk := func(_ context.Context, fn func(retention.FileInfo) error) error {
+		for _, f := range files {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	deleted := map[string]bool{}
+
+	err := policy.ApplyStream(t.Context(), walk, func(d retention.Decision) error {
+		deleted[d.File.Path] = d.Delete
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{
+		"backup-1": true,
+		"backup-2": false,
+		"backup-3": false,
+	}, deleted)
+
+	err = retention.Policy{RequireMinimum: -1}.ApplyStream(t.Context(), walk,
+		func(retention.Decision) error { return nil })
+	require.Error(t, err)
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	require.NoError(t, retention.Policy{}.Validate())
+
+	err := retention.Policy{Retention: retention.Retention{Weekly: -1}}.Validate()
+	require.ErrorContains(t, err, "weekly retention must be non-negative")
+
+	_, err = retention.Policy{
+		TagRetention: map[string]retention.Retention{"nightly": {Daily: -1}},
+	}.Apply(nil)
+	require.ErrorContains(t, err, `tag "nightly"`)
+
+	err = retention.Policy{RequireMinimum: -1}.Validate()
+	require.Error(t, err)
+
+	err = retention.Policy{KeepCount: -1}.Validate()
+	require.ErrorContains(t, err, "keep count must be non-negative")
+
+	err = retention.Policy{
+		KeepCount: 2,
+		Retention: retention.Retention{Daily: 1},
+	}.Validate()
+	require.ErrorContains(t, err, "cannot be combined")
+
+	err = retention.Policy{DayBoundaryOffset: 25 * time.Hour}.Validate()
+	require.ErrorContains(t, err, "day boundary offset must be within a day")
+}
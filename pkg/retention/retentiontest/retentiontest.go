@@ -0,0 +1,131 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package retentiontest provides helpers for testing code built on the
+// retention package: fabricated sets of backups and a clock that only moves
+// when told to.
+package retentiontest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/retention"
+)
+
+// Clock is a retention.Clock that stands still until it is set or advanced.
+// It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock reading now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Set moves the clock to now
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Series returns n backups taken every interval, the first at start, oldest
+// first. Each is named after its timestamp, such as
+// backup-2024-03-15T12:00:00Z, and is one byte long.
+func Series(start time.Time, every time.Duration, n int) []retention.FileInfo {
+	files := make([]retention.FileInfo, n)
+
+	for i := range files {
+		files[i] = backup(start.Add(time.Duration(i) * every))
+	}
+
+	return files
+}
+
+// Daily returns a backup for each of the days days up to and including
+// end, at the time of day of end, oldest first
+func Daily(end time.Time, days int) []retention.FileInfo {
+	files := make([]retention.FileInfo, days)
+
+	for i := range files {
+		files[i] = backup(end.AddDate(0, 0, i-days+1))
+	}
+
+	return files
+}
+
+// backup returns a backup taken at ts, named after it
+func backup(ts time.Time) retention.FileInfo {
+	return retention.FileInfo{
+		Path:      "backup-" + ts.Format(time.RFC3339),
+		Timestamp: ts,
+		Size:      1,
+	}
+}
+
+// Tagged returns copies of files with Tag set to tag and the tag prefixed to
+// their paths, so that the same series can be used for several tags
+func Tagged(tag string, files []retention.FileInfo) []retention.FileInfo {
+	tagged := make([]retention.FileInfo, len(files))
+
+	for i, f := range files {
+		f.Tag = tag
+		f.Path = tag + "/" + f.Path
+		tagged[i] = f
+	}
+
+	return tagged
+}
+
+// Paths returns the paths of files, in order, for comparing the result of
+// a policy with the backups a test expects
+func Paths(files []retention.FileInfo) []string {
+	paths := make([]string, len(files))
+
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+
+	return paths
+}
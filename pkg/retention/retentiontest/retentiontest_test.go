@@ -0,0 +1,107 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package retentiontest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/retention"
+	"github.com/TotallyNotRobots/apply-retention-policy/pkg/retention/retentiontest"
+)
+
+func TestClock(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	clock := retentiontest.NewClock(now)
+	require.Equal(t, now, clock.Now())
+
+	clock.Advance(time.Hour)
+	require.Equal(t, now.Add(time.Hour), clock.Now())
+
+	clock.Set(now)
+	require.Equal(t, now, clock.Now())
+}
+
+func TestSeries(t *testing.T) {
+	start := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	files := retentiontest.Series(start, 6*time.Hour, 3)
+
+	require.Equal(t, []string{
+		"backup-2024-03-15T00:00:00Z",
+		"backup-2024-03-15T06:00:00Z",
+		"backup-2024-03-15T12:00:00Z",
+	}, retentiontest.Paths(files))
+	require.Equal(t, start.Add(12*time.Hour), files[2].Timestamp)
+}
+
+func TestDaily(t *testing.T) {
+	end := time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC)
+
+	require.Equal(t, []string{
+		"backup-2024-02-28T03:00:00Z",
+		"backup-2024-02-29T03:00:00Z",
+		"backup-2024-03-01T03:00:00Z",
+		"backup-2024-03-02T03:00:00Z",
+	}, retentiontest.Paths(retentiontest.Daily(end, 4)))
+}
+
+func TestTagged(t *testing.T) {
+	files := retentiontest.Daily(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), 2)
+	tagged := retentiontest.Tagged("db", files)
+
+	require.Equal(t, []string{
+		"db/backup-2024-03-14T00:00:00Z",
+		"db/backup-2024-03-15T00:00:00Z",
+	}, retentiontest.Paths(tagged))
+	require.Equal(t, "db", tagged[0].Tag)
+	require.Empty(t, files[0].Tag, "the original files are unchanged")
+}
+
+func TestPolicyWithFakeClock(t *testing.T) {
+	end := time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC)
+	files := append(
+		retentiontest.Tagged("web", retentiontest.Daily(end, 3)),
+		retentiontest.Tagged("db", retentiontest.Daily(end.AddDate(0, 0, -2), 3))...,
+	)
+
+	clock := retentiontest.NewClock(end.Add(time.Hour))
+	policy := retention.Policy{Retention: retention.Retention{Daily: 2}, Clock: clock}
+
+	require.Equal(t, time.Hour, policy.Age(files[2]))
+
+	clock.Advance(24 * time.Hour)
+	require.Equal(t, 25*time.Hour, policy.Age(files[2]))
+
+	result, err := policy.Apply(files)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"db/backup-2024-03-12T02:00:00Z",
+		"db/backup-2024-03-13T02:00:00Z",
+		"web/backup-2024-03-14T02:00:00Z",
+		"web/backup-2024-03-15T02:00:00Z",
+	}, retentiontest.Paths(result.Kept()))
+}
@@ -0,0 +1,99 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package units provides helpers for rendering quantities in human units.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteUnit is the multiplier between successive binary size units
+const byteUnit = 1024
+
+// ErrInvalidSize is returned for a size that ParseBytes cannot parse
+var ErrInvalidSize = errors.New("invalid size")
+
+// byteSuffixes maps the unit suffixes accepted by ParseBytes to their
+// multiplier. IEC suffixes and bare letters are binary, SI suffixes decimal.
+var byteSuffixes = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KIB": 1 << 10,
+	"KB":  1e3,
+	"M":   1 << 20,
+	"MIB": 1 << 20,
+	"MB":  1e6,
+	"G":   1 << 30,
+	"GIB": 1 << 30,
+	"GB":  1e9,
+	"T":   1 << 40,
+	"TIB": 1 << 40,
+	"TB":  1e12,
+}
+
+// FormatBytes renders a byte count using binary (IEC) units, e.g. "1.5 MiB"
+func FormatBytes(n int64) string {
+	if n < byteUnit && n > -byteUnit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+	var suffix string
+	for _, suffix = range suffixes {
+		value /= byteUnit
+		if value < byteUnit && value > -byteUnit {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
+
+// ParseBytes parses a human readable size such as "512", "10GiB", "1.5 TB"
+// or "500M" into a byte count. Suffixes are case-insensitive.
+func ParseBytes(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	number := strings.TrimRightFunc(trimmed, func(r rune) bool {
+		return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z'
+	})
+
+	multiplier, ok := byteSuffixes[strings.ToUpper(trimmed[len(number):])]
+	if !ok {
+		return 0, fmt.Errorf("%w %q: unknown unit", ErrInvalidSize, s)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidSize, s)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
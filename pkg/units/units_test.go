@@ -0,0 +1,59 @@
+/*
+The MIT License (MIT)
+
+Copyright © 2025 linuxdaemon <linuxdaemon.irc@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package units
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "512 B", FormatBytes(512))
+	require.Equal(t, "1.5 KiB", FormatBytes(1536))
+	require.Equal(t, "2.0 GiB", FormatBytes(2<<30))
+}
+
+func TestParseBytes(t *testing.T) {
+	for input, want := range map[string]int64{
+		"512":     512,
+		"512B":    512,
+		"10GiB":   10 << 30,
+		"10 gib":  10 << 30,
+		"500M":    500 << 20,
+		"1.5 TB":  1_500_000_000_000,
+		"2KB":     2000,
+		" 4 KiB ": 4096,
+	} {
+		got, err := ParseBytes(input)
+		require.NoError(t, err, input)
+		require.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "GiB", "10 PB", "-1G", "ten"} {
+		_, err := ParseBytes(input)
+		require.ErrorIs(t, err, ErrInvalidSize, input)
+	}
+}